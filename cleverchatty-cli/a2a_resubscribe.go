@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	cleverchatty "github.com/gelembjuk/cleverchatty/core"
+	"github.com/google/uuid"
+	a2aclient "trpc.group/trpc-go/trpc-a2a-go/client"
+	a2aprotocol "trpc.group/trpc-go/trpc-a2a-go/protocol"
+)
+
+// maxA2AStreamResumes is the number of times a dropped stream of a prompt is resumed
+const maxA2AStreamResumes = 3
+
+// a2aStreamResumeDelay is the pause before resubscribing to a task after the stream dropped
+const a2aStreamResumeDelay = 1 * time.Second
+
+// errA2AStreamDropped is returned when the stream of a task ends before its final event
+var errA2AStreamDropped = errors.New("the task stream is closed before the task is finished")
+
+// streamA2APrompt sends the message in the streaming mode and processes the events of the task.
+// When the stream drops before the task is finished, the client resubscribes to the same task
+// and continues receiving its events, so the server does not process the prompt twice.
+// If the server does not know the task anymore, the message is sent again.
+func streamA2APrompt(ctx context.Context,
+	a2aClient *a2aclient.A2AClient,
+	serverURL string,
+	params a2aprotocol.SendMessageParams,
+	callbacks cleverchatty.UICallbacks) (string, error) {
+
+	streamChan, err := a2aClient.StreamMessage(ctx, params)
+	if err != nil {
+		return "", fmt.Errorf("error starting task stream: %v", err)
+	}
+
+	for resumes := 0; ; resumes++ {
+		response, err := processA2AStreamEvents(ctx, streamChan, callbacks, runningA2ATask)
+		if !errors.Is(err, errA2AStreamDropped) || resumes >= maxA2AStreamResumes {
+			return response, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", nil
+		case <-time.After(a2aStreamResumeDelay):
+		}
+
+		streamChan = nil
+		if taskID := runningA2ATask.get(); taskID != "" {
+			log.Printf("The stream of task %s is dropped, resubscribing", taskID)
+			streamChan, err = resubscribeA2ATask(ctx, serverURL, taskID)
+			if err != nil {
+				log.Printf("Failed to resubscribe to task %s: %v. Sending the message again", taskID, err)
+			}
+		}
+		if streamChan == nil {
+			runningA2ATask.set("")
+			streamChan, err = a2aClient.StreamMessage(ctx, params)
+			if err != nil {
+				return "", fmt.Errorf("error starting task stream: %v", err)
+			}
+		}
+	}
+}
+
+// resubscribeA2ATask reattaches to the events of a streaming task with tasks/resubscribe.
+// The A2A client library does not support this method, so the request is sent directly
+func resubscribeA2ATask(ctx context.Context, serverURL string, taskID string) (<-chan a2aprotocol.StreamingMessageEvent, error) {
+	requestID := uuid.New().String()
+	params, err := json.Marshal(a2aprotocol.TaskIDParams{ID: taskID})
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      requestID,
+		"method":  a2aprotocol.MethodTasksResubscribe,
+		"params":  json.RawMessage(params),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, serverURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Accept", "text/event-stream")
+
+	// No timeout, the stream lasts until the task is finished
+	resp, err := (&http.Client{}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK || !strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream") {
+		// The server responds with a JSON-RPC error when the task is unknown
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("the server did not open the stream: %s", strings.TrimSpace(string(data)))
+	}
+
+	eventsChan := make(chan a2aprotocol.StreamingMessageEvent, 10)
+	go readA2AEventStream(ctx, resp.Body, eventsChan)
+	return eventsChan, nil
+}
+
+// readA2AEventStream reads the server-sent events with JSON-RPC responses carrying task
+// events. The channel is closed when the stream ends
+func readA2AEventStream(ctx context.Context, body io.ReadCloser, eventsChan chan<- a2aprotocol.StreamingMessageEvent) {
+	defer body.Close()
+	defer close(eventsChan)
+
+	scanner := bufio.NewScanner(body)
+	// Events with files can be large
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	data := []string{}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "data:") {
+			data = append(data, strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+			continue
+		}
+		if line != "" || len(data) == 0 {
+			continue
+		}
+		// An empty line ends the event
+		var response struct {
+			Result json.RawMessage `json:"result"`
+			Error  *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		payload := strings.Join(data, "\n")
+		data = data[:0]
+		if err := json.Unmarshal([]byte(payload), &response); err != nil || response.Error != nil || len(response.Result) == 0 {
+			continue
+		}
+		var event a2aprotocol.StreamingMessageEvent
+		if err := json.Unmarshal(response.Result, &event); err != nil {
+			log.Printf("Failed to parse a task event: %v", err)
+			continue
+		}
+		select {
+		case eventsChan <- event:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
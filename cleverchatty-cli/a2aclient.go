@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 
 	cleverchatty "github.com/gelembjuk/cleverchatty/core"
@@ -92,9 +93,78 @@ func sendHelloMessage(ctx context.Context, serverURL string, agentid string, Con
 
 	return err
 }
+
+// parseA2AStatus reads the callback status of a working task update. The status is taken
+// from the message metadata, older servers sent it as three text parts: code, message and extra
+func parseA2AStatus(message *a2aprotocol.Message) (cleverchatty.A2AStatus, bool) {
+	if message == nil {
+		return cleverchatty.A2AStatus{}, false
+	}
+	if status, ok := cleverchatty.A2AStatusFromMetadata(message.Metadata); ok {
+		return status, true
+	}
+	if len(message.Parts) != 3 {
+		return cleverchatty.A2AStatus{}, false
+	}
+	texts := make([]string, 0, 3)
+	for _, part := range message.Parts {
+		textPart, ok := part.(*a2aprotocol.TextPart)
+		if !ok {
+			return cleverchatty.A2AStatus{}, false
+		}
+		texts = append(texts, textPart.Text)
+	}
+	return cleverchatty.A2AStatus{
+		Code:    texts[0],
+		Message: texts[1],
+		Extra:   texts[2],
+	}, true
+}
+
+// errA2ATaskCanceled is returned when the server reports the task of the prompt as cancelled
+var errA2ATaskCanceled = errors.New("the task is cancelled")
+
+// a2aRunningTask is the streaming task of the prompt being processed by the server.
+// The client cancels it on the server when the user interrupts the client
+type a2aRunningTask struct {
+	mu     sync.Mutex
+	taskID string
+}
+
+var runningA2ATask = &a2aRunningTask{}
+
+func (t *a2aRunningTask) set(taskID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.taskID = taskID
+}
+
+func (t *a2aRunningTask) get() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.taskID
+}
+
+// cancel asks the server to stop the running task, so it does not keep working for a gone client
+func (t *a2aRunningTask) cancel(a2aClient *a2aclient.A2AClient) error {
+	t.mu.Lock()
+	taskID := t.taskID
+	t.taskID = ""
+	t.mu.Unlock()
+
+	if taskID == "" || a2aClient == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := a2aClient.CancelTasks(ctx, a2aprotocol.TaskIDParams{ID: taskID})
+	return err
+}
+
 func processA2AStreamEvents(ctx context.Context,
 	streamChan <-chan a2aprotocol.StreamingMessageEvent,
-	callbacks cleverchatty.UICallbacks) (string, error) {
+	callbacks cleverchatty.UICallbacks,
+	task *a2aRunningTask) (string, error) {
 
 	for {
 		select {
@@ -107,17 +177,21 @@ func processA2AStreamEvents(ctx context.Context,
 				if ctx.Err() != nil {
 					return "", ctx.Err() // Return context error if any
 				}
-				return "", nil
+				// The final event is not received, the connection is lost
+				return "", errA2AStreamDropped
 			}
 
 			// Process the received event
 			switch e := event.Result.(type) {
 			case *a2aprotocol.TaskStatusUpdateEvent:
+				if !e.Final {
+					task.set(e.TaskID)
+				}
 				if e.Status.State == a2aprotocol.TaskStateWorking {
-					if e.Status.Message != nil && len(e.Status.Message.Parts) == 3 {
-						statusCode := e.Status.Message.Parts[0].(*a2aprotocol.TextPart).Text
-						statusMessage := e.Status.Message.Parts[1].(*a2aprotocol.TextPart).Text
-						statusMessageExtra := e.Status.Message.Parts[2].(*a2aprotocol.TextPart).Text
+					if status, ok := parseA2AStatus(e.Status.Message); ok {
+						statusCode := status.Code
+						statusMessage := status.Message
+						statusMessageExtra := status.Extra
 
 						switch statusCode {
 						case cleverchatty.CallbackCodePromptProcessing:
@@ -127,19 +201,38 @@ func processA2AStreamEvents(ctx context.Context,
 						case cleverchatty.CallbackCodeResponseReceived:
 							callbacks.CallResponseReceived(statusMessage)
 						case cleverchatty.CallbackCodeToolCalling:
-							callbacks.CallToolCalling(statusMessage)
+							callbacks.CallToolCalling(statusMessageExtra)
+						case cleverchatty.CallbackCodeToolArguments:
+							callbacks.CallToolArguments(statusMessageExtra, statusMessage)
+						case cleverchatty.CallbackCodeToolResult:
+							callbacks.CallToolResultReceived(statusMessageExtra, statusMessage)
 						case cleverchatty.CallbackCodeToolCallFailed:
 							callbacks.CallToolCallFailed(statusMessageExtra, errors.New(statusMessage))
+						case cleverchatty.CallbackCodeToolCallFinished:
+							elapsed, _ := time.ParseDuration(statusMessage)
+							callbacks.CallToolCallFinished(statusMessageExtra, elapsed)
+						case cleverchatty.CallbackCodeToolsChanged:
+							callbacks.CallToolsChanged(statusMessageExtra)
 						case cleverchatty.CallbackCodeMemoryRetrieval:
 							callbacks.CallMemoryRetrievalStarted()
 						case cleverchatty.CallbackCodeRAGRetrieval:
 							callbacks.CallRAGRetrievalStarted()
+						case cleverchatty.CallbackCodeRAGPreprocessing:
+							callbacks.CallRAGPreprocessing()
+						case cleverchatty.CallbackCodeReasoning:
+							callbacks.CallReasoningReceived(statusMessage)
+						case cleverchatty.CallbackCodeNotification:
+							var notification cleverchatty.Notification
+							if err := json.Unmarshal([]byte(statusMessageExtra), &notification); err == nil {
+								callbacks.CallNotificationReceived(notification)
+							}
 						default:
 							//
 						}
 					}
 				}
 				if e.Final {
+					task.set("")
 					switch e.Status.State {
 					case a2aprotocol.TaskStateCompleted:
 						if e.Status.Message != nil {
@@ -152,7 +245,7 @@ func processA2AStreamEvents(ctx context.Context,
 							return "", fmt.Errorf("task failed: %s", errorMessage)
 						}
 					case a2aprotocol.TaskStateCanceled:
-						// in this architecture we do not expect to receive canceled state
+						return "", errA2ATaskCanceled
 					}
 					return "", nil
 				}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	cleverchatty "github.com/gelembjuk/cleverchatty/core"
+)
+
+// attachedFile is a local file loaded into the file cache with /attach
+type attachedFile struct {
+	name      string
+	reference string
+}
+
+// attachments keeps files attached with /attach until the next prompt is sent
+type attachments struct {
+	files []attachedFile
+	mu    sync.Mutex
+}
+
+var pendingAttachments = &attachments{}
+
+func (a *attachments) Add(name string, reference string) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.files = append(a.files, attachedFile{name: name, reference: reference})
+	return len(a.files)
+}
+
+// Apply adds references of the attached files to the prompt and clears the list
+func (a *attachments) Apply(prompt string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if len(a.files) == 0 {
+		return prompt
+	}
+	var builder strings.Builder
+	builder.WriteString(prompt)
+	builder.WriteString("\n\nAttached files. Pass the reference as a tool argument to use the file content:\n")
+	for _, file := range a.files {
+		builder.WriteString(fmt.Sprintf("- %s: %s\n", file.name, file.reference))
+	}
+	a.files = nil
+	return builder.String()
+}
+
+func isAttachCommand(prompt string) bool {
+	fields := strings.Fields(strings.ToLower(prompt))
+	return len(fields) > 0 && fields[0] == "/attach"
+}
+
+func handleAttachCommand(prompt string, cleverChattyObject *cleverchatty.CleverChatty) {
+	path := strings.TrimSpace(prompt[len("/attach"):])
+	if path == "" {
+		tuiPrint(errorStyle.Render("Missing file path") + "\nUsage: /attach <path>\n\n")
+		return
+	}
+
+	reference, err := cleverChattyObject.AttachFile(path)
+	if err != nil {
+		tuiPrint("\n" + errorStyle.Render(fmt.Sprintf("Error attaching file: %v", err)) + "\n\n")
+		return
+	}
+
+	count := pendingAttachments.Add(filepath.Base(path), reference)
+	tuiPrint(fmt.Sprintf("\nAttached %s. %d file(s) will be sent with your next message.\n\n", filepath.Base(path), count))
+}
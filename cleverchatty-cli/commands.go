@@ -3,24 +3,56 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/huh/spinner"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/list"
 	cleverchatty "github.com/gelembjuk/cleverchatty/core"
-	"github.com/mark3labs/mcphost/pkg/history"
+	"github.com/gelembjuk/cleverchatty/core/history"
 	a2aclient "trpc.group/trpc-go/trpc-a2a-go/client"
 	a2aprotocol "trpc.group/trpc-go/trpc-a2a-go/protocol"
 )
 
-func handleSlashCommand(prompt string, cleverChattyObject cleverchatty.CleverChatty) (bool, error) {
+func handleSlashCommand(prompt string, cleverChattyObject *cleverchatty.CleverChatty) (bool, error) {
 	if !strings.HasPrefix(prompt, "/") {
 		return false, nil
 	}
 
+	if isNotificationsCommand(prompt) {
+		handleNotificationsCommand(prompt)
+		return true, nil
+	}
+
+	if isAttachCommand(prompt) {
+		handleAttachCommand(prompt, cleverChattyObject)
+		return true, nil
+	}
+
+	if isSystemCommand(prompt) {
+		handleSystemCommand(prompt, cleverChattyObject)
+		return true, nil
+	}
+
+	if isForgetCommand(prompt) {
+		handleForgetCommand(prompt, cleverChattyObject)
+		return true, nil
+	}
+
+	if isCallCommand(prompt) {
+		handleCallCommand(prompt, cleverChattyObject)
+		return true, nil
+	}
+
+	if isToolStatsCommand(prompt) {
+		handleToolStatsCommand(prompt, cleverChattyObject)
+		return true, nil
+	}
+
 	switch strings.ToLower(strings.TrimSpace(prompt)) {
 	case "/tools":
 		handleToolsCommand(cleverChattyObject)
@@ -32,7 +64,10 @@ func handleSlashCommand(prompt string, cleverChattyObject cleverchatty.CleverCha
 		handleVersionCommand()
 		return true, nil
 	case "/history":
-		handleHistoryCommand(cleverChattyObject)
+		handleHistoryCommand(cleverChattyObject, fullHistoryFlag)
+		return true, nil
+	case "/history --full-history":
+		handleHistoryCommand(cleverChattyObject, true)
 		return true, nil
 	case "/servers":
 		handleServersCommand(cleverChattyObject)
@@ -57,6 +92,33 @@ func handleSlashCommandAsClient(prompt string, a2aClient a2aclient.A2AClient, ct
 
 	cleanPrompt := strings.ToLower(strings.TrimSpace(prompt))
 
+	if isNotificationsCommand(prompt) {
+		handleNotificationsCommand(prompt)
+		return true, nil
+	}
+
+	if isAttachCommand(prompt) {
+		// Files are cached where tools run, the server does not have access to local files
+		tuiPrint(errorStyle.Render("/attach is available only in the standalone mode") + "\n\n")
+		return true, nil
+	}
+
+	if isSystemCommand(prompt) {
+		// The system instruction is managed by the server
+		tuiPrint(errorStyle.Render("/system is available only in the standalone mode") + "\n\n")
+		return true, nil
+	}
+
+	if isForgetCommand(prompt) {
+		tuiPrint(errorStyle.Render("/forget is available only in the standalone mode") + "\n\n")
+		return true, nil
+	}
+
+	if isCallCommand(prompt) {
+		tuiPrint(errorStyle.Render("/call is available only in the standalone mode") + "\n\n")
+		return true, nil
+	}
+
 	if cleanPrompt == "/servers" || cleanPrompt == "/tools" || cleanPrompt == "/history" {
 		// These commands should be processed on the server side
 		return false, nil
@@ -105,6 +167,20 @@ func handleHelpCommand() {
 		)
 		return
 	}
+
+	rendered, err := renderer.Render(helpMarkdown())
+	if err != nil {
+		tuiPrint(
+			"\n" + errorStyle.Render(fmt.Sprintf("Error rendering help: %v", err)) + "\n",
+		)
+		return
+	}
+
+	tuiPrint(rendered)
+}
+
+// helpMarkdown returns the /help text, with the lines added in the TUI settings
+func helpMarkdown() string {
 	var markdown strings.Builder
 
 	markdown.WriteString("# Available Commands\n\n")
@@ -112,22 +188,263 @@ func handleHelpCommand() {
 	markdown.WriteString("- **/help**: Show this help message\n")
 	markdown.WriteString("- **/tools**: List all available tools\n")
 	markdown.WriteString("- **/servers**: List configured MCP servers\n")
-	markdown.WriteString("- **/history**: Display conversation history\n")
+	markdown.WriteString("- **/history**: Display conversation history. Long tool results are truncated, use `/history --full-history` to show everything\n")
+	markdown.WriteString("- **/notifications**: Show the notifications filter\n")
+	markdown.WriteString("- **/notifications filter <patterns>**: Show only matching notification methods, prefix with ! to hide (e.g. `!*/progress`). Use `off` to show all\n")
+	markdown.WriteString("- **/attach <path>**: Attach a local file to the next message, tools receive its content\n")
+	markdown.WriteString("- **/system**: Show the current system instruction\n")
+	markdown.WriteString("- **/system set <text>**: Replace the system instruction, it is applied from the next message\n")
+	markdown.WriteString("- **/forget <query>**: Delete the matching memories from the memory server\n")
+	markdown.WriteString("- **/call <server>__<tool> {json-args}**: Call a tool directly, without the model, and show the raw result\n")
+	markdown.WriteString("- **/toolstats**: Show the number of calls, failures and the average latency of each tool. `/toolstats reset` clears them\n")
 	markdown.WriteString("- **/quit**, **/bye**, **/exit**: Exit the application\n")
+	markdown.WriteString("\n## Prompt prefixes\n\n")
+	markdown.WriteString("- **!nomemory <question>**: Ask without the stored memories\n")
+	markdown.WriteString("- **!norag <question>**: Ask without the RAG context\n")
+	markdown.WriteString("- **!ephemeral <question>**: Ask without remembering the question and the answer\n")
 	markdown.WriteString("\n## Navigation\n\n")
 	markdown.WriteString("- **PgUp/PgDn**: Scroll through chat history\n")
 	markdown.WriteString("- **Ctrl+Home/End**: Jump to top/bottom\n")
+	markdown.WriteString("- **Ctrl+N**: Show/hide the notifications pane\n")
 	markdown.WriteString("- **Ctrl+C**: Quit at any time\n")
+	if len(tuiSettings.HelpExtra) > 0 {
+		markdown.WriteString("\n## More\n\n")
+		for _, line := range tuiSettings.HelpExtra {
+			markdown.WriteString("- " + line + "\n")
+		}
+	}
 	markdown.WriteString("\nCleverChatty CLI version: " + cleverchatty.ThisAppVersion + "\n")
 
-	rendered, err := renderer.Render(markdown.String())
+	return markdown.String()
+}
+
+// Prompt prefixes changing how a single prompt is processed, like "!nomemory your question"
+const (
+	promptPrefixNoMemory  = "!nomemory"
+	promptPrefixNoRAG     = "!norag"
+	promptPrefixEphemeral = "!ephemeral"
+)
+
+// parsePromptOptions removes the leading option prefixes from the prompt and returns the options they set
+func parsePromptOptions(prompt string) (string, cleverchatty.PromptOptions) {
+	options := cleverchatty.PromptOptions{}
+	for {
+		word, rest, _ := strings.Cut(strings.TrimLeft(prompt, " \t"), " ")
+		switch strings.ToLower(word) {
+		case promptPrefixNoMemory:
+			options.SkipMemory = true
+		case promptPrefixNoRAG:
+			options.SkipRAG = true
+		case promptPrefixEphemeral:
+			options.Ephemeral = true
+		default:
+			return prompt, options
+		}
+		prompt = rest
+	}
+}
+
+// clientMessageMetadata returns the metadata of a prompt sent to the server
+func clientMessageMetadata(agentID string, options cleverchatty.PromptOptions) map[string]any {
+	metadata := options.Metadata()
+	metadata["agent_id"] = agentID
+	if len(toolContextFlag) > 0 {
+		toolContext := map[string]any{}
+		for key, value := range toolContextFlag {
+			toolContext[key] = value
+		}
+		metadata["tool_context"] = toolContext
+	}
+	if verboseToolsFlag {
+		metadata[cleverchatty.MetadataVerboseTools] = true
+	}
+	// The server does not forward notifications the client would hide
+	if filter := notificationsFilter.String(); filter != "" {
+		metadata[cleverchatty.MetadataNotificationsFilter] = filter
+	}
+	return metadata
+}
+
+func isNotificationsCommand(prompt string) bool {
+	fields := strings.Fields(strings.ToLower(prompt))
+	return len(fields) > 0 && fields[0] == "/notifications"
+}
+
+func handleNotificationsCommand(prompt string) {
+	fields := strings.Fields(prompt)
+
+	if len(fields) >= 2 && strings.ToLower(fields[1]) == "filter" {
+		value := strings.TrimSpace(strings.Join(fields[2:], " "))
+		if value == "" || strings.ToLower(value) == "off" {
+			notificationsFilter.Set(nil)
+			tuiPrint("\nNotifications filter removed, all notifications are shown.\n\n")
+			return
+		}
+		notificationsFilter.Set(cleverchatty.ParseNotificationFilter(value))
+		tuiPrint(fmt.Sprintf("\nNotifications filter set: %s\n\n", notificationsFilter.String()))
+		return
+	}
+	if len(fields) > 1 {
+		tuiPrint(errorStyle.Render("Unknown command: "+prompt) + "\nUsage: /notifications filter <patterns>|off\n\n")
+		return
+	}
+
+	filter := notificationsFilter.String()
+	if filter == "" {
+		filter = "none"
+	}
+	tuiPrint(fmt.Sprintf("\nNotifications filter: %s. Hidden notifications: %d\n\n", filter, notificationsFilter.Hidden()))
+}
+
+func isSystemCommand(prompt string) bool {
+	fields := strings.Fields(strings.ToLower(prompt))
+	return len(fields) > 0 && fields[0] == "/system"
+}
+
+func handleSystemCommand(prompt string, cleverChattyObject *cleverchatty.CleverChatty) {
+	args := strings.TrimSpace(strings.TrimSpace(prompt)[len("/system"):])
+
+	if args == "" {
+		instruction := cleverChattyObject.GetSystemInstruction()
+		if instruction == "" {
+			tuiPrint("\nNo system instruction is set.\n\n")
+			return
+		}
+		tuiPrint(fmt.Sprintf("\nSystem instruction:\n\n%s\n\n", instruction))
+		return
+	}
+
+	fields := strings.Fields(args)
+	if strings.ToLower(fields[0]) != "set" {
+		tuiPrint(errorStyle.Render("Unknown command: "+prompt) + "\nUsage: /system [set <text>]\n\n")
+		return
+	}
+	instruction := strings.TrimSpace(args[len("set"):])
+	if instruction == "" {
+		tuiPrint(errorStyle.Render("Missing system instruction") + "\nUsage: /system set <text>\n\n")
+		return
+	}
+
+	cleverChattyObject.SetSystemInstruction(instruction)
+	tuiPrint("\nSystem instruction updated. It is applied from the next message.\n\n")
+}
+
+func isForgetCommand(prompt string) bool {
+	fields := strings.Fields(strings.ToLower(prompt))
+	return len(fields) > 0 && fields[0] == "/forget"
+}
+
+func handleForgetCommand(prompt string, cleverChattyObject *cleverchatty.CleverChatty) {
+	query := strings.TrimSpace(strings.TrimSpace(prompt)[len("/forget"):])
+	if query == "" {
+		tuiPrint(errorStyle.Render("Missing query") + "\nUsage: /forget <query>\n\n")
+		return
+	}
+
+	result, err := cleverChattyObject.Forget(context.Background(), query)
 	if err != nil {
+		tuiPrint("\n" + errorStyle.Render(fmt.Sprintf("Error forgetting: %v", err)) + "\n\n")
+		return
+	}
+	if result == "" {
+		result = "Done"
+	}
+	tuiPrint(fmt.Sprintf("\nMemory server: %s\n\n", result))
+}
+
+func isCallCommand(prompt string) bool {
+	fields := strings.Fields(strings.ToLower(prompt))
+	return len(fields) > 0 && fields[0] == "/call"
+}
+
+func handleCallCommand(prompt string, cleverChattyObject *cleverchatty.CleverChatty) {
+	usage := "\nUsage: /call <server>__<tool> {json-args}\n\n"
+	args := strings.TrimSpace(strings.TrimSpace(prompt)[len("/call"):])
+	toolName, argsJSON, _ := strings.Cut(args, " ")
+	if toolName == "" {
+		tuiPrint(errorStyle.Render("Missing tool name") + usage)
+		return
+	}
+
+	toolArgs := map[string]interface{}{}
+	if argsJSON = strings.TrimSpace(argsJSON); argsJSON != "" {
+		if err := json.Unmarshal([]byte(argsJSON), &toolArgs); err != nil {
+			tuiPrint(errorStyle.Render(fmt.Sprintf("Invalid JSON arguments, an object is expected: %v", err)) + usage)
+			return
+		}
+	}
+
+	result, err := cleverChattyObject.CallTool(context.Background(), toolName, toolArgs)
+	if errors.Is(err, cleverchatty.ErrToolNotFound) {
+		tuiPrint(errorStyle.Render(err.Error()) + "\nUse /tools to list the available tools\n\n")
+		return
+	}
+	if err != nil {
+		tuiPrint("\n" + errorStyle.Render(fmt.Sprintf("Error calling tool %s: %v", toolName, err)) + "\n\n")
+		return
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("\nResult of %s:\n\n", toolName))
+	for _, content := range result.Content {
+		text, ok := content.(history.TextContent)
+		if !ok {
+			output.WriteString(fmt.Sprintf("[%T content]\n", content))
+			continue
+		}
+		if file, ok := cleverChattyObject.DescribeFileRef(text.Text); ok {
+			output.WriteString(fmt.Sprintf("📎 file: %s (%s), %s\n", file.Name, file.MimeType, formatFileSize(file.Size)))
+			continue
+		}
+		output.WriteString(text.Text + "\n")
+	}
+	tuiPrint(output.String() + "\n")
+}
+
+func isToolStatsCommand(prompt string) bool {
+	fields := strings.Fields(strings.ToLower(prompt))
+	return len(fields) > 0 && fields[0] == "/toolstats"
+}
+
+func handleToolStatsCommand(prompt string, cleverChattyObject *cleverchatty.CleverChatty) {
+	fields := strings.Fields(strings.ToLower(prompt))
+	if len(fields) == 2 && fields[1] == "reset" {
+		cleverChattyObject.ResetToolStats()
+		tuiPrint("\nTool statistics cleared\n\n")
+		return
+	}
+	if len(fields) > 1 {
+		tuiPrint(errorStyle.Render("Unknown arguments") + "\nUsage: /toolstats [reset]\n\n")
+		return
+	}
+
+	stats := cleverChattyObject.GetToolStats()
+	if len(stats) == 0 {
+		tuiPrint("\nNo tools were called yet\n\n")
+		return
+	}
+	if err := updateRenderer(); err != nil {
 		tuiPrint(
-			"\n" + errorStyle.Render(fmt.Sprintf("Error rendering help: %v", err)) + "\n",
+			"\n" + errorStyle.Render(fmt.Sprintf("Error updating renderer: %v", err)) + "\n",
 		)
 		return
 	}
 
+	var markdown strings.Builder
+	markdown.WriteString("| Tool | Calls | Successes | Failures | Avg latency |\n")
+	markdown.WriteString("|---|---:|---:|---:|---:|\n")
+	for _, tool := range stats {
+		markdown.WriteString(fmt.Sprintf("| %s | %d | %d | %d | %s |\n",
+			tool.Tool, tool.Calls, tool.Successes, tool.Failures, tool.AverageLatency().Round(time.Millisecond)))
+	}
+
+	rendered, err := renderer.Render(markdown.String())
+	if err != nil {
+		tuiPrint(
+			"\n" + errorStyle.Render(fmt.Sprintf("Error rendering tool statistics: %v", err)) + "\n",
+		)
+		return
+	}
 	tuiPrint(rendered)
 }
 
@@ -153,7 +470,37 @@ func handleVersionCommand() {
 	tuiPrint(rendered)
 }
 
-func handleServersCommand(cleverChattyObject cleverchatty.CleverChatty) {
+func handleListModelsCommand(ctx context.Context, config *cleverchatty.CleverChattyConfig) {
+	for _, result := range cleverchatty.ListModels(ctx, *config) {
+		if result.Err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("%s: failed to list models: %v", result.Provider, result.Err)))
+			continue
+		}
+		fmt.Printf("%s: %d models\n", result.Provider, len(result.Models))
+		for _, model := range result.Models {
+			fmt.Printf("  %s\n", model)
+		}
+	}
+}
+
+// handleDoctorCommand prints the report of cleverchatty.Diagnose, returns an error if any check failed
+func handleDoctorCommand(ctx context.Context, config *cleverchatty.CleverChattyConfig) error {
+	failed := 0
+	for _, check := range cleverchatty.Diagnose(ctx, *config) {
+		if check.Passed() {
+			fmt.Printf("%s %s %s: %s\n", responseStyle.Render("[PASS]"), check.Component, check.Name, check.Details)
+			continue
+		}
+		failed++
+		fmt.Println(errorStyle.Render(fmt.Sprintf("[FAIL] %s %s: %v", check.Component, check.Name, check.Err)))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d checks failed", failed)
+	}
+	return nil
+}
+
+func handleServersCommand(cleverChattyObject *cleverchatty.CleverChatty) {
 	if err := updateRenderer(); err != nil {
 		tuiPrint(
 			"\n" + errorStyle.Render(fmt.Sprintf("Error updating renderer: %v", err)) + "\n",
@@ -163,6 +510,9 @@ func handleServersCommand(cleverChattyObject cleverchatty.CleverChatty) {
 
 	var markdown strings.Builder
 	action := func() {
+		if !cleverChattyObject.ToolsSupported() {
+			markdown.WriteString("*Tools disabled: model does not support function calling.*\n\n")
+		}
 		servers := cleverChattyObject.GetServersInfo()
 		if len(servers) == 0 {
 			markdown.WriteString("No servers configured.\n")
@@ -240,13 +590,22 @@ func handleServersCommand(cleverChattyObject cleverchatty.CleverChatty) {
 	tuiPrint("\n" + containerStyle.Render(rendered) + "\n")
 }
 
-func handleToolsCommand(cleverChattyObject cleverchatty.CleverChatty) {
+func handleToolsCommand(cleverChattyObject *cleverchatty.CleverChatty) {
 	// Get terminal width for proper wrapping
 	width := getTerminalWidth()
 
 	// Adjust width to account for margins and list indentation
 	contentWidth := width - 12 // Account for margins and list markers
 
+	if !cleverChattyObject.ToolsSupported() {
+		tuiPrint(
+			"\n" + contentStyle.Render(
+				"Tools disabled: model does not support function calling.\n",
+			) + "\n\n",
+		)
+		return
+	}
+
 	results := cleverChattyObject.GetToolsInfo()
 	// If tools are disabled (empty client map), show a message
 	if len(results) == 0 {
@@ -312,7 +671,7 @@ func handleToolsCommand(cleverChattyObject cleverchatty.CleverChatty) {
 	// Wrap the entire content in the container
 	tuiPrint("\n" + containerStyle.Render(l.String()) + "\n")
 }
-func handleHistoryCommand(cleverChattyObject cleverchatty.CleverChatty) {
+func handleHistoryCommand(cleverChattyObject *cleverchatty.CleverChatty, fullHistory bool) {
 	if err := updateRenderer(); err != nil {
 		tuiPrint(
 			"\n" + errorStyle.Render(fmt.Sprintf("Error updating renderer: %v", err)) + "\n",
@@ -339,6 +698,9 @@ func handleHistoryCommand(cleverChattyObject cleverchatty.CleverChatty) {
 				markdown.WriteString("### Text\n")
 				markdown.WriteString(block.Text + "\n\n")
 
+			case "image":
+				markdown.WriteString(fmt.Sprintf("📎 image: %s (%s)\n\n", block.Name, block.MimeType))
+
 			case "tool_use":
 				markdown.WriteString("### Tool Use\n")
 				markdown.WriteString(
@@ -366,19 +728,8 @@ func handleHistoryCommand(cleverChattyObject cleverchatty.CleverChatty) {
 				markdown.WriteString(
 					fmt.Sprintf("**Tool ID:** %s\n\n", block.ToolUseID),
 				)
-				switch v := block.Content.(type) {
-				case string:
-					markdown.WriteString("```\n")
-					markdown.WriteString(v)
-					markdown.WriteString("\n```\n\n")
-				case []history.ContentBlock:
-					for _, contentBlock := range v {
-						if contentBlock.Type == "text" {
-							markdown.WriteString("```\n")
-							markdown.WriteString(contentBlock.Text)
-							markdown.WriteString("\n```\n\n")
-						}
-					}
+				for _, text := range toolResultTexts(block) {
+					markdown.WriteString(renderToolResultText(text, cleverChattyObject, fullHistory))
 				}
 			}
 		}
@@ -397,3 +748,58 @@ func handleHistoryCommand(cleverChattyObject cleverchatty.CleverChatty) {
 	// Print directly without box
 	tuiPrint("\n" + rendered + "\n")
 }
+
+// maxHistoryResultLength is the number of characters of a tool result shown by /history
+const maxHistoryResultLength = 1000
+
+// toolResultTexts returns the text parts of a tool result block
+func toolResultTexts(block history.ContentBlock) []string {
+	texts := []string{}
+	switch v := block.Content.(type) {
+	case string:
+		texts = append(texts, v)
+	case []history.Content:
+		for _, content := range v {
+			if text, ok := content.(history.TextContent); ok {
+				texts = append(texts, text.Text)
+			}
+		}
+	}
+	if len(texts) == 0 && block.Text != "" {
+		texts = append(texts, block.Text)
+	}
+	return texts
+}
+
+// renderToolResultText renders a part of a tool result for the history. References to
+// cached files are shown as file descriptions, long texts are truncated unless
+// the full history is requested
+func renderToolResultText(text string, cleverChattyObject *cleverchatty.CleverChatty, fullHistory bool) string {
+	if file, ok := cleverChattyObject.DescribeFileRef(text); ok {
+		size := "removed"
+		if file.Size >= 0 {
+			size = formatFileSize(file.Size)
+		}
+		return fmt.Sprintf("📎 file: %s (%s), %s\n\n", file.Name, file.MimeType, size)
+	}
+
+	runes := []rune(text)
+	if fullHistory || len(runes) <= maxHistoryResultLength {
+		return "```\n" + text + "\n```\n\n"
+	}
+	return fmt.Sprintf(
+		"```\n%s\n```\n\n*… %d more characters. Use `/history --full-history` to show everything*\n\n",
+		string(runes[:maxHistoryResultLength]),
+		len(runes)-maxHistoryResultLength,
+	)
+}
+
+func formatFileSize(size int64) string {
+	switch {
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+	case size >= 1024:
+		return fmt.Sprintf("%.1f KB", float64(size)/1024)
+	}
+	return fmt.Sprintf("%d bytes", size)
+}
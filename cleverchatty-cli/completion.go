@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// modelProviderPrefixes are suggested by the shell completion of the --model flag
+var modelProviderPrefixes = []string{"anthropic:", "openai:", "ollama:", "google:"}
+
+var completionCmd = &cobra.Command{
+	Use:   "completion [bash|zsh|fish|powershell]",
+	Short: "Generate the shell completion script",
+	Long: `Generate the completion script of cleverchatty-cli for the given shell.
+
+Bash:
+  # current session
+  source <(cleverchatty-cli completion bash)
+  # all sessions, Linux
+  cleverchatty-cli completion bash > /etc/bash_completion.d/cleverchatty-cli
+  # all sessions, macOS (bash-completion package)
+  cleverchatty-cli completion bash > $(brew --prefix)/etc/bash_completion.d/cleverchatty-cli
+
+Zsh:
+  # enable completion once if it is not enabled yet
+  echo "autoload -U compinit; compinit" >> ~/.zshrc
+  cleverchatty-cli completion zsh > "${fpath[1]}/_cleverchatty-cli"
+
+Fish:
+  cleverchatty-cli completion fish > ~/.config/fish/completions/cleverchatty-cli.fish
+
+PowerShell:
+  cleverchatty-cli completion powershell | Out-String | Invoke-Expression
+  # add the line above to the PowerShell profile to load it in all sessions
+
+Start a new shell after the installation.`,
+	ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+	Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	DisableFlagsInUseLine: true,
+	SilenceUsage:          true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch args[0] {
+		case "bash":
+			return rootCmd.GenBashCompletionV2(os.Stdout, true)
+		case "zsh":
+			return rootCmd.GenZshCompletion(os.Stdout)
+		case "fish":
+			return rootCmd.GenFishCompletion(os.Stdout, true)
+		case "powershell":
+			return rootCmd.GenPowerShellCompletionWithDesc(os.Stdout)
+		}
+		return fmt.Errorf("unsupported shell: %s", args[0])
+	},
+}
+
+// completeModelFlag suggests the provider prefixes of the provider:model format
+func completeModelFlag(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	suggestions := []string{}
+	for _, prefix := range modelProviderPrefixes {
+		if strings.HasPrefix(prefix, toComplete) {
+			suggestions = append(suggestions, prefix)
+		}
+	}
+	return suggestions, cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveNoFileComp
+}
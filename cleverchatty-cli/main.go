@@ -9,13 +9,16 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
 	"time"
 
 	markdown "github.com/MichaelMure/go-term-markdown"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/huh"
 	cleverchatty "github.com/gelembjuk/cleverchatty/core"
+	"github.com/gelembjuk/cleverchatty/core/history"
 	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 	a2aclient "trpc.group/trpc-go/trpc-a2a-go/client"
@@ -38,6 +41,12 @@ var (
 	openaiAPIKey     string
 	anthropicAPIKey  string
 	googleAPIKey     string
+	notifFilterFlag  string // Patterns of notification methods to show or hide
+	fullHistoryFlag  bool   // Show tool results untruncated in /history
+	seedFlag         int    // Seed of the provider requests, negative means not set
+	verboseToolsFlag bool   // Show the arguments and the results of tool calls in the chat
+
+	toolContextFlag map[string]string // Values added to the arguments of every tool call
 )
 
 var (
@@ -49,6 +58,7 @@ var (
 var (
 	tuiContext      context.Context
 	tuiConfig       *cleverchatty.CleverChattyConfig
+	tuiSettings     cleverchatty.TUIConfig // Welcome message and help additions, set in both modes
 	tuiCleverChatty *cleverchatty.CleverChatty
 	useTUIMode      bool
 	// For client mode
@@ -88,6 +98,9 @@ func initCleverChattyFunc() tea.Msg {
 		tuiCleverChatty.WithLogger(customLogger)
 
 		tuiCleverChatty.Callbacks = composeCallbacks(true)
+		tuiCleverChatty.SetNotificationFeedbackCallback(func(feedback cleverchatty.NotificationFeedback) {
+			tuiSendNotificationFeedback(feedback)
+		})
 		tuiCleverChatty.SetNotificationCallback(func(notification cleverchatty.Notification) {
 			tuiSendNotification(notification)
 		})
@@ -115,6 +128,11 @@ func initCleverChattyFunc() tea.Msg {
 	// Set callbacks to use TUI
 	cleverChattyObject.Callbacks = composeCallbacks(true)
 
+	// Set notification feedback callback to show attributed feedback in the notifications pane
+	cleverChattyObject.SetNotificationFeedbackCallback(func(feedback cleverchatty.NotificationFeedback) {
+		tuiSendNotificationFeedback(feedback)
+	})
+
 	// Set notification callback to send notifications to TUI
 	cleverChattyObject.SetNotificationCallback(func(notification cleverchatty.Notification) {
 		tuiSendNotification(notification)
@@ -164,8 +182,40 @@ var versionCmd = &cobra.Command{
 	},
 }
 
+var listModelsCmd = &cobra.Command{
+	Use:          "list-models",
+	Short:        "List models available from the configured providers",
+	Long:         `Query the models endpoints of the providers that have credentials (config file, flags or environment variables) and the local Ollama server. The models are printed in the provider:model format accepted by the --model flag.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		handleListModelsCommand(context.Background(), config)
+		return nil
+	},
+}
+
+var doctorCmd = &cobra.Command{
+	Use:          "doctor",
+	Short:        "Check the config, the provider and the tools servers",
+	Long:         `Load the config (config file, flags and environment variables), check the credentials of the provider and connect to every tools server. Prints a pass/fail report per component and exits with a non-zero code if any check fails.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		return handleDoctorCommand(context.Background(), config)
+	},
+}
+
 func init() {
 	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(listModelsCmd)
+	rootCmd.AddCommand(completionCmd)
+	rootCmd.AddCommand(doctorCmd)
 	rootCmd.PersistentFlags().
 		StringVar(&configFile, "config", "", "config file. Use it to run CleverChatty as a standalone tool. Will be ignored if --server and --agentid are set.")
 	rootCmd.PersistentFlags().
@@ -175,6 +225,7 @@ func init() {
 	rootCmd.PersistentFlags().
 		StringVarP(&modelFlag, "model", "m", "",
 			"model to use (format: provider:model, e.g. anthropic:claude-3-5-sonnet-latest or ollama:qwen2.5:3b). If not provided then "+defaultModelFlag+" will be used")
+	rootCmd.RegisterFlagCompletionFunc("model", completeModelFlag)
 	rootCmd.PersistentFlags().
 		StringVarP(&promptFlag, "prompt", "p", "",
 			"execute a single prompt and exit without starting the interactive UI")
@@ -199,6 +250,30 @@ func init() {
 	flags.StringVar(&openaiAPIKey, "openai-api-key", "", "OpenAI API key")
 	flags.StringVar(&anthropicAPIKey, "anthropic-api-key", "", "Anthropic API key")
 	flags.StringVar(&googleAPIKey, "google-api-key", "", "Google (Gemini) API key")
+	flags.StringVar(&notifFilterFlag, "notifications-filter", "",
+		"comma separated notification methods to show in the notifications pane. Prefix a pattern with ! to hide it (e.g. '!*/progress')")
+	flags.BoolVar(&fullHistoryFlag, "full-history", false, "show tool results untruncated in the /history output")
+	flags.BoolVar(&verboseToolsFlag, "verbose-tools", false, "show the arguments and the results of tool calls in the chat")
+	flags.IntVar(&seedFlag, "seed", -1, "seed of the LLM requests for repeatable responses. Honored by OpenAI and Ollama")
+	flags.StringToStringVar(&toolContextFlag, "tool-context", nil,
+		"key=value pairs added to the arguments of every tool call (e.g. tenant_id=acme,locale=de). In the client mode they are sent to the server")
+}
+
+// loadTUIConfig reads the TUI settings for the client mode. Unlike loadConfig it never
+// creates the config file, a missing file means the default settings
+func loadTUIConfig() (cleverchatty.TUIConfig, error) {
+	path := configFile
+	if path == "" {
+		path = "config.json"
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return cleverchatty.TUIConfig{}, nil
+	}
+	config, err := cleverchatty.LoadConfig(path)
+	if err != nil {
+		return cleverchatty.TUIConfig{}, fmt.Errorf("error loading config file: %v", err)
+	}
+	return config.TUIConfig, nil
 }
 
 func loadConfig() (*cleverchatty.CleverChattyConfig, error) {
@@ -236,6 +311,25 @@ func loadConfig() (*cleverchatty.CleverChattyConfig, error) {
 	if config.Model == "" {
 		config.Model = defaultModelFlag
 	}
+	if len(toolContextFlag) > 0 {
+		// The flags replace the values of the config file
+		toolContext := map[string]interface{}{}
+		for key, value := range config.ToolContext {
+			toolContext[key] = value
+		}
+		for key, value := range toolContextFlag {
+			toolContext[key] = value
+		}
+		config.ToolContext = toolContext
+	}
+	if seedFlag >= 0 {
+		seed := seedFlag
+		config.OpenAI.Seed = &seed
+		config.Ollama.Seed = &seed
+		if provider, _, _ := strings.Cut(config.Model, ":"); provider == "anthropic" || provider == "google" {
+			log.Printf("The %s provider does not support the seed, it is ignored", provider)
+		}
+	}
 	if openaiBaseURL != "" {
 		config.OpenAI.BaseURL = openaiBaseURL
 	}
@@ -280,6 +374,7 @@ func loadConfig() (*cleverchatty.CleverChattyConfig, error) {
 // ============================================
 func run(ctx context.Context) error {
 	var err error
+	notificationsFilter.Set(cleverchatty.ParseNotificationFilter(notifFilterFlag))
 	if server != "" {
 		err = runAsClient(ctx)
 	} else {
@@ -328,14 +423,66 @@ func composeCallbacks(useTUI bool) cleverchatty.UICallbacks {
 		}
 		return nil
 	})
+	callbacks.SetRAGPreprocessing(func() error {
+		if useTUI {
+			tuiSendSpinner("🔎  Refining query…")
+		} else {
+			showSpinner("🔎  Refining query…")
+		}
+		return nil
+	})
+	callbacks.SetNotificationReceived(func(notification cleverchatty.Notification) error {
+		// Notifications arriving while a prompt is processed (e.g. tool progress) are shown in the status line
+		if useTUI && notificationsFilter.Shows(notification.Method) {
+			tuiSendSpinner("📢 " + notification.FormatForDisplay())
+		}
+		return nil
+	})
+	callbacks.SetReasoningReceived(func(text string) error {
+		if useTUI {
+			tuiSendChat("\n" + reasoningStyle.Render(text) + "\n")
+		} else {
+			releaseActionSpinner()
+			fmt.Printf("\n%s\n", reasoningStyle.Render(text))
+		}
+		return nil
+	})
 	callbacks.SetToolCalling(func(toolName string) error {
 		if useTUI {
-			tuiSendSpinner("🔧 Using tool: " + toolName)
+			tuiStartToolTimer(toolName)
 		} else {
 			showSpinner("🔧 Using tool: " + toolName)
 		}
 		return nil
 	})
+	callbacks.SetToolCallFinished(func(toolName string, elapsed time.Duration) error {
+		if useTUI {
+			tuiStopToolTimer()
+		}
+		return nil
+	})
+	if verboseToolsFlag {
+		callbacks.SetToolArguments(func(toolName string, arguments string) error {
+			details := toolNameStyle.Render("🔧 "+toolName+" arguments:") + "\n" + formatToolArguments(arguments)
+			if useTUI {
+				tuiSendChat("\n" + details + "\n")
+			} else {
+				releaseActionSpinner()
+				fmt.Printf("\n%s\n", details)
+			}
+			return nil
+		})
+		callbacks.SetToolResultReceived(func(toolName string, result string) error {
+			details := toolNameStyle.Render("🔧 "+toolName+" result:") + "\n" + truncateToolResult(cleverchatty.RedactSecretsJSON(result))
+			if useTUI {
+				tuiSendChat("\n" + details + "\n")
+			} else {
+				releaseActionSpinner()
+				fmt.Printf("\n%s\n", details)
+			}
+			return nil
+		})
+	}
 	callbacks.SetToolCallFailed(func(toolName string, err error) error {
 		if useTUI {
 			tuiClearSpinner()
@@ -392,10 +539,18 @@ func composeSinglePromptCallbacks() cleverchatty.UICallbacks {
 		fmt.Fprintln(os.Stderr, "Searching knowledge database...")
 		return nil
 	})
+	callbacks.SetRAGPreprocessing(func() error {
+		fmt.Fprintln(os.Stderr, "Refining query...")
+		return nil
+	})
 	callbacks.SetToolCalling(func(toolName string) error {
 		fmt.Fprintf(os.Stderr, "Using tool: %s\n", toolName)
 		return nil
 	})
+	callbacks.SetToolCallFinished(func(toolName string, elapsed time.Duration) error {
+		fmt.Fprintf(os.Stderr, "Tool %s finished in %s\n", toolName, elapsed.Round(time.Millisecond))
+		return nil
+	})
 	callbacks.SetToolCallFailed(func(toolName string, err error) error {
 		fmt.Fprintf(os.Stderr, "Tool call failed: %s: %v\n", toolName, err)
 		return nil
@@ -434,7 +589,8 @@ func runSinglePromptStandalone(ctx context.Context, config *cleverchatty.CleverC
 
 	cc.Callbacks = composeSinglePromptCallbacks()
 
-	response, err := cc.Prompt(promptFlag)
+	prompt, options := parsePromptOptions(promptFlag)
+	response, err := cc.PromptWithOptions(ctx, history.NewUserPromptMessage(prompt), options)
 	if err != nil {
 		return fmt.Errorf("error processing prompt: %v", err)
 	}
@@ -452,34 +608,48 @@ func runSinglePromptClient(ctx context.Context, a2aClient *a2aclient.A2AClient,
 		// Suppress all logs unless debug mode is enabled
 		log.SetOutput(io.Discard)
 	}
+	prompt, options := parsePromptOptions(promptFlag)
 	message := a2aprotocol.Message{
 		Role: a2aprotocol.MessageRoleUser,
 		Parts: []a2aprotocol.Part{
-			a2aprotocol.NewTextPart(promptFlag),
+			a2aprotocol.NewTextPart(prompt),
 		},
 		ContextID: &contextID,
-		Metadata: map[string]any{
-			"agent_id": agentID,
-		},
+		Metadata:  clientMessageMetadata(agentID, options),
 	}
 
 	taskParams := a2aprotocol.SendMessageParams{
 		Message: message,
 	}
 
-	streamChan, err := a2aClient.StreamMessage(ctx, taskParams)
-	if err != nil {
-		return fmt.Errorf("error starting task stream: %v", err)
-	}
+	// Ctrl+C stops the task on the server too
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
 
-	response, err := processA2AStreamEvents(ctx, streamChan, composeSinglePromptCallbacks())
+	response, err := streamA2APrompt(ctx, a2aClient, server, taskParams, composeSinglePromptCallbacks())
+	if ctx.Err() != nil {
+		if cancelErr := runningA2ATask.cancel(a2aClient); cancelErr != nil {
+			log.Printf("Failed to cancel the task on the server: %v", cancelErr)
+		}
+		err = fmt.Errorf("interrupted")
+	}
 	if err != nil {
+		sendByeMessage(a2aClient, contextID, agentID)
 		return fmt.Errorf("error processing response: %v", err)
 	}
 
 	fmt.Println(response)
 
 	// Send /bye to release server resources
+	sendByeMessage(a2aClient, contextID, agentID)
+
+	return nil
+}
+
+// sendByeMessage finishes the session on the server, so it releases the resources
+// immediately instead of waiting for the session timeout
+func sendByeMessage(a2aClient *a2aclient.A2AClient, contextID string, agentID string) {
+
 	byeMessage := a2aprotocol.Message{
 		Role: a2aprotocol.MessageRoleUser,
 		Parts: []a2aprotocol.Part{
@@ -493,8 +663,6 @@ func runSinglePromptClient(ctx context.Context, a2aClient *a2aclient.A2AClient,
 	byeCtx, byeCancel := context.WithTimeout(context.Background(), 5*time.Second)
 	a2aClient.SendMessage(byeCtx, a2aprotocol.SendMessageParams{Message: byeMessage})
 	byeCancel()
-
-	return nil
 }
 
 func runWithTUI(ctx context.Context, config *cleverchatty.CleverChattyConfig) error {
@@ -505,6 +673,7 @@ func runWithTUI(ctx context.Context, config *cleverchatty.CleverChattyConfig) er
 	// Store config and context for initialization
 	tuiContext = ctx
 	tuiConfig = config
+	tuiSettings = config.TUIConfig
 	useTUIMode = true
 
 	// Pre-init assistant before starting TUI to catch errors early
@@ -552,7 +721,7 @@ func runWithTUI(ctx context.Context, config *cleverchatty.CleverChattyConfig) er
 			return fmt.Errorf("CleverChatty not initialized")
 		}
 		// Handle slash commands
-		handled, err := handleSlashCommand(prompt, *cleverChattyObject)
+		handled, err := handleSlashCommand(prompt, cleverChattyObject)
 		if err != nil {
 			tuiSendError(err)
 			return err
@@ -561,7 +730,8 @@ func runWithTUI(ctx context.Context, config *cleverchatty.CleverChattyConfig) er
 			return nil
 		}
 
-		_, err = cleverChattyObject.Prompt(prompt)
+		prompt, options := parsePromptOptions(prompt)
+		_, err = cleverChattyObject.PromptWithOptions(context.Background(), history.NewUserPromptMessage(pendingAttachments.Apply(prompt)), options)
 		if err != nil {
 			tuiSendError(err)
 			return err
@@ -638,7 +808,7 @@ func runWithSimpleInput(ctx context.Context, cleverChattyObject *cleverchatty.Cl
 		}
 
 		// Handle slash commands
-		handled, err := handleSlashCommand(prompt, *cleverChattyObject)
+		handled, err := handleSlashCommand(prompt, cleverChattyObject)
 		if err != nil {
 			return err
 		}
@@ -646,7 +816,8 @@ func runWithSimpleInput(ctx context.Context, cleverChattyObject *cleverchatty.Cl
 			continue
 		}
 
-		_, err = cleverChattyObject.Prompt(prompt)
+		prompt, options := parsePromptOptions(prompt)
+		_, err = cleverChattyObject.PromptWithOptions(context.Background(), history.NewUserPromptMessage(pendingAttachments.Apply(prompt)), options)
 
 		if err != nil {
 			return err
@@ -763,6 +934,27 @@ func handleNotificationEvent(event a2aprotocol.StreamingMessageEvent) {
 					return
 				}
 
+				// Handle feedback produced while processing a notification
+				if textPart.Text == "notification_feedback" && len(e.Status.Message.Parts) >= 4 {
+					feedback := cleverchatty.NotificationFeedback{}
+					if part, ok := e.Status.Message.Parts[1].(*a2aprotocol.TextPart); ok {
+						feedback.ServerName = part.Text
+					}
+					if part, ok := e.Status.Message.Parts[2].(*a2aprotocol.TextPart); ok {
+						feedback.Method = part.Text
+					}
+					if part, ok := e.Status.Message.Parts[3].(*a2aprotocol.TextPart); ok {
+						feedback.Message = part.Text
+					}
+
+					if useTUIMode && program != nil {
+						tuiSendNotificationFeedback(feedback)
+					} else {
+						log.Printf("💬 Feedback on %s notification from %s: %s", feedback.Method, feedback.ServerName, feedback.Message)
+					}
+					return
+				}
+
 				if textPart.Text == "mcp_notification" && len(e.Status.Message.Parts) >= 3 {
 					// Extract notification details from the A2A stream
 					serverName := ""
@@ -907,29 +1099,22 @@ func runAsClientWithTUI(ctx context.Context, a2aClient *a2aclient.A2AClient, con
 		}
 
 		// Send message via A2A streaming
+		prompt, options := parsePromptOptions(prompt)
 		message := a2aprotocol.Message{
 			Role: a2aprotocol.MessageRoleUser,
 			Parts: []a2aprotocol.Part{
 				a2aprotocol.NewTextPart(prompt),
 			},
 			ContextID: &tuiContextID,
-			Metadata: map[string]any{
-				"agent_id": tuiAgentID,
-			},
+			Metadata:  clientMessageMetadata(tuiAgentID, options),
 		}
 
 		taskParams := a2aprotocol.SendMessageParams{
 			Message: message,
 		}
 
-		streamChan, err := tuiA2AClient.StreamMessage(ctx, taskParams)
-		if err != nil {
-			tuiSendError(err)
-			return fmt.Errorf("error starting task stream: %v", err)
-		}
-
 		// Process stream events with TUI callbacks
-		_, err = processA2AStreamEvents(ctx, streamChan, composeCallbacks(true))
+		_, err = streamA2APrompt(ctx, tuiA2AClient, server, taskParams, composeCallbacks(true))
 		if err != nil {
 			tuiSendError(err)
 			return fmt.Errorf("error processing task stream events: %v", err)
@@ -946,26 +1131,13 @@ func runAsClientWithTUI(ctx context.Context, a2aClient *a2aclient.A2AClient, con
 	var runErr error
 	_, runErr = program.Run()
 
-	// Send /bye to server to terminate the session before cleanup
-	// This ensures the server releases resources immediately instead of waiting for timeout
 	if a2aClient != nil {
-		byeMessage := a2aprotocol.Message{
-			Role: a2aprotocol.MessageRoleUser,
-			Parts: []a2aprotocol.Part{
-				a2aprotocol.NewTextPart("/bye"),
-			},
-			ContextID: &contextID,
-			Metadata: map[string]any{
-				"agent_id": agentID,
-			},
-		}
-		byeParams := a2aprotocol.SendMessageParams{
-			Message: byeMessage,
+		// A prompt interrupted by Ctrl+C is still processed by the server, stop it
+		if err := runningA2ATask.cancel(a2aClient); err != nil {
+			log.Printf("Failed to cancel the task on the server: %v", err)
 		}
-		// Use a short timeout context for the bye message
-		byeCtx, byeCancel := context.WithTimeout(context.Background(), 5*time.Second)
-		a2aClient.SendMessage(byeCtx, byeParams)
-		byeCancel()
+		// Send /bye to server to terminate the session before cleanup
+		sendByeMessage(a2aClient, contextID, agentID)
 	}
 
 	// Cleanup
@@ -1027,6 +1199,12 @@ func runAsClient(ctx context.Context) error {
 		return fmt.Errorf("error initializing renderer: %v", err)
 	}
 
+	// Only the TUI settings of the config are used in the client mode
+	tuiSettings, err = loadTUIConfig()
+	if err != nil {
+		return err
+	}
+
 	// Always use TUI in client mode
 	return runAsClientWithTUI(ctx, a2aClient, contextID, agentid)
 }
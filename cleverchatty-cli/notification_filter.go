@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"sync"
+
+	cleverchatty "github.com/gelembjuk/cleverchatty/core"
+)
+
+// notificationFilter decides which notifications are shown in the notifications pane,
+// see cleverchatty.NotificationFilterShows for the patterns
+type notificationFilter struct {
+	patterns []string
+	hidden   int
+	mu       sync.Mutex
+}
+
+var notificationsFilter = &notificationFilter{}
+
+// Set replaces the filter patterns and resets the hidden counter
+func (f *notificationFilter) Set(patterns []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.patterns = patterns
+	f.hidden = 0
+}
+
+// Allow reports whether the notification should be displayed and counts the hidden ones
+func (f *notificationFilter) Allow(method string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	allowed := cleverchatty.NotificationFilterShows(f.patterns, method)
+	if !allowed {
+		f.hidden++
+	}
+	return allowed
+}
+
+// Shows reports whether the notification passes the filter without counting it as hidden
+func (f *notificationFilter) Shows(method string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return cleverchatty.NotificationFilterShows(f.patterns, method)
+}
+
+// Hidden returns the number of notifications hidden since the filter was set
+func (f *notificationFilter) Hidden() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.hidden
+}
+
+// String returns the patterns in the same form they are set
+func (f *notificationFilter) String() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return strings.Join(f.patterns, ",")
+}
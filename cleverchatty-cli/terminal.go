@@ -48,6 +48,10 @@ var (
 	separatorStyle = lipgloss.NewStyle().
 			Foreground(tokyoGray)
 
+	reasoningStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("240")).
+			Italic(true)
+
 	//descriptionStyle = lipgloss.NewStyle().
 	//			Foreground(tokyoFg).
 	//			PaddingLeft(2).
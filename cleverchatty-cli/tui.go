@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/viewport"
@@ -21,8 +22,16 @@ type notificationMsg struct {
 type agentMessageMsg struct {
 	message string
 }
+type notificationFeedbackMsg struct {
+	feedback cleverchatty.NotificationFeedback
+}
 type spinnerMsg string
 type clearSpinnerMsg struct{}
+type toolStartedMsg string
+type toolFinishedMsg struct{}
+type toolTickMsg struct {
+	run int
+}
 type errorMsg error
 type quitMsg struct{}
 type initCompleteMsg struct {
@@ -39,6 +48,9 @@ type tuiModel struct {
 	ready                 bool
 	initialized           bool
 	currentSpinner        string
+	runningTool           string
+	toolStarted           time.Time
+	toolRun               int
 	width                 int
 	height                int
 	chatContent           *strings.Builder
@@ -65,8 +77,12 @@ func newTUIModel(showNotifications bool, promptCallback func(string) error) tuiM
 	welcomeStyle := lipgloss.NewStyle().Foreground(tokyoCyan).Bold(true)
 	infoStyle := lipgloss.NewStyle().Foreground(tokyoFg)
 
-	chatContent.WriteString(welcomeStyle.Render("Welcome to CleverChatty CLI!"))
-	chatContent.WriteString("\n")
+	if welcome := renderWelcomeMessage(tuiSettings.WelcomeMessage); welcome != "" {
+		chatContent.WriteString(welcome)
+	} else {
+		chatContent.WriteString(welcomeStyle.Render("Welcome to CleverChatty CLI!"))
+		chatContent.WriteString("\n")
+	}
 	chatContent.WriteString(infoStyle.Render("Type /help for available commands."))
 	chatContent.WriteString("\n")
 
@@ -79,6 +95,22 @@ func newTUIModel(showNotifications bool, promptCallback func(string) error) tuiM
 	}
 }
 
+// renderWelcomeMessage renders the configured markdown welcome message.
+// Returns an empty string when the message is not set
+func renderWelcomeMessage(message string) string {
+	if strings.TrimSpace(message) == "" {
+		return ""
+	}
+	if renderer == nil {
+		return message + "\n"
+	}
+	rendered, err := renderer.Render(message)
+	if err != nil {
+		return message + "\n"
+	}
+	return rendered
+}
+
 func (m tuiModel) Init() tea.Cmd {
 	return tea.Batch(
 		textarea.Blink,
@@ -145,6 +177,13 @@ func (m tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Ctrl+Down - scroll down one line
 			m.chatViewport.LineDown(1)
 			return m, nil
+		case tea.KeyCtrlN:
+			// Ctrl+N - show/hide the notifications pane
+			m.showNotifications = !m.showNotifications
+			if m.ready {
+				m.resizeViewports()
+			}
+			return m, nil
 		}
 
 		// Handle Alt+Enter for newline
@@ -156,51 +195,7 @@ func (m tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
-
-		// Calculate heights: leave space for input (5 lines) + spinner (1 line) + borders
-		viewportHeight := msg.Height - 8
-
-		// Calculate widths based on layout
-		var chatWidth, notificationsWidth int
-		if m.showNotifications {
-			// Split view - 75% for chat (left), 25% for notifications (right)
-			// Account for borders (4 chars each side) and gap
-			chatWidth = ((msg.Width * 3) / 4) - 8
-			notificationsWidth = (msg.Width / 4) - 8
-		} else {
-			chatWidth = msg.Width - 8
-		}
-
-		if !m.ready {
-			m.chatViewport = viewport.New(chatWidth, viewportHeight)
-			m.notificationsViewport = viewport.New(notificationsWidth, viewportHeight)
-			m.chatViewport.YPosition = 0
-			m.notificationsViewport.YPosition = 0
-			// Enable word wrapping
-			m.chatViewport.Style = lipgloss.NewStyle().Width(chatWidth)
-			m.notificationsViewport.Style = lipgloss.NewStyle().Width(notificationsWidth)
-			// Set initial content
-			m.chatViewport.SetContent(m.chatContent.String())
-			m.notificationsViewport.SetContent(m.notificationsContent.String())
-			// Scroll to bottom
-			m.chatViewport.GotoBottom()
-			m.notificationsViewport.GotoBottom()
-			m.ready = true
-		} else {
-			m.chatViewport.Width = chatWidth
-			m.notificationsViewport.Width = notificationsWidth
-			m.chatViewport.Height = viewportHeight
-			m.notificationsViewport.Height = viewportHeight
-		}
-
-		// Update input width to match chat viewport
-		m.input.SetWidth(chatWidth)
-
-		// Keep scrolled to bottom after resize
-		if m.ready {
-			m.chatViewport.GotoBottom()
-			m.notificationsViewport.GotoBottom()
-		}
+		m.resizeViewports()
 
 	case chatMsg:
 		// Wrap text to viewport width if ready
@@ -247,7 +242,8 @@ func (m tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.chatViewport.GotoBottom()
 
 	case notificationMsg:
-		if m.showNotifications {
+		// Notifications are collected while the pane is hidden too, so toggling it back shows them
+		if notificationsFilter.Allow(msg.notification.Method) {
 			// Format notification message using the unified Notification structure
 			notifStyle := lipgloss.NewStyle().Foreground(tokyoYellow)
 			serverStyle := lipgloss.NewStyle().Foreground(tokyoCyan).Bold(true)
@@ -288,12 +284,54 @@ func (m tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.notificationsViewport.GotoBottom()
 		}
 
+	case notificationFeedbackMsg:
+		serverStyle := lipgloss.NewStyle().Foreground(tokyoCyan).Bold(true)
+		methodStyle := lipgloss.NewStyle().Foreground(tokyoYellow)
+		feedbackStyle := lipgloss.NewStyle().Foreground(tokyoGreen)
+
+		text := fmt.Sprintf("%s\n", serverStyle.Render("["+msg.feedback.ServerName+"]"))
+		if msg.feedback.Method != "" {
+			text += fmt.Sprintf("💬 %s\n", methodStyle.Render(msg.feedback.Method))
+		}
+		text += fmt.Sprintf("   %s\n\n", feedbackStyle.Render(msg.feedback.Message))
+
+		if m.ready && m.notificationsViewport.Width > 0 {
+			text = wordwrap.String(text, m.notificationsViewport.Width)
+		}
+		m.notificationsContent.WriteString(text)
+		m.notificationsViewport.SetContent(m.notificationsContent.String())
+		m.notificationsViewport.GotoBottom()
+
 	case spinnerMsg:
+		// Other statuses (e.g. tool progress notifications) replace the elapsed time of a tool
+		m.runningTool = ""
 		m.currentSpinner = string(msg)
 
 	case clearSpinnerMsg:
+		m.runningTool = ""
 		m.currentSpinner = ""
 
+	case toolStartedMsg:
+		// Each tool run has own ticks, so ticks of a previous tool are ignored
+		m.toolRun++
+		m.runningTool = string(msg)
+		m.toolStarted = time.Now()
+		m.currentSpinner = m.toolSpinnerText()
+		return m, toolTick(m.toolRun)
+
+	case toolTickMsg:
+		if m.runningTool == "" || msg.run != m.toolRun {
+			return m, nil
+		}
+		m.currentSpinner = m.toolSpinnerText()
+		return m, toolTick(m.toolRun)
+
+	case toolFinishedMsg:
+		if m.runningTool != "" {
+			m.runningTool = ""
+			m.currentSpinner = ""
+		}
+
 	case errorMsg:
 		errText := errorStyle.Render(fmt.Sprintf("Error: %v\n", msg))
 		m.chatContent.WriteString(errText)
@@ -324,6 +362,61 @@ func (m tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(tiCmd, vpCmd, lpCmd)
 }
 
+// resizeViewports recalculates the sizes of the panes from the window size
+// and the notifications pane visibility
+func (m *tuiModel) resizeViewports() {
+	// Calculate heights: leave space for input (5 lines) + spinner (1 line) + borders
+	viewportHeight := m.height - 8
+
+	// Split view - 75% for chat (left), 25% for notifications (right)
+	// Account for borders (4 chars each side) and gap.
+	// The notifications width is kept when the pane is hidden to wrap notifications received meanwhile
+	notificationsWidth := (m.width / 4) - 8
+	chatWidth := m.width - 8
+	if m.showNotifications {
+		chatWidth = ((m.width * 3) / 4) - 8
+	}
+
+	if !m.ready {
+		m.chatViewport = viewport.New(chatWidth, viewportHeight)
+		m.notificationsViewport = viewport.New(notificationsWidth, viewportHeight)
+		m.chatViewport.YPosition = 0
+		m.notificationsViewport.YPosition = 0
+		// Set initial content
+		m.chatViewport.SetContent(m.chatContent.String())
+		m.notificationsViewport.SetContent(m.notificationsContent.String())
+		m.ready = true
+	} else {
+		m.chatViewport.Width = chatWidth
+		m.notificationsViewport.Width = notificationsWidth
+		m.chatViewport.Height = viewportHeight
+		m.notificationsViewport.Height = viewportHeight
+	}
+	// Enable word wrapping
+	m.chatViewport.Style = lipgloss.NewStyle().Width(chatWidth)
+	m.notificationsViewport.Style = lipgloss.NewStyle().Width(notificationsWidth)
+
+	// Update input width to match chat viewport
+	m.input.SetWidth(chatWidth)
+
+	// Keep scrolled to bottom after resize
+	m.chatViewport.GotoBottom()
+	m.notificationsViewport.GotoBottom()
+}
+
+// toolSpinnerText is the status line of a running tool with the elapsed time
+func (m tuiModel) toolSpinnerText() string {
+	elapsed := time.Since(m.toolStarted).Truncate(time.Second)
+	return fmt.Sprintf("🔧 Using tool: %s (%s)", m.runningTool, elapsed)
+}
+
+// toolTick schedules the update of the elapsed time of the tool run
+func toolTick(run int) tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return toolTickMsg{run: run}
+	})
+}
+
 func (m tuiModel) View() string {
 	if !m.ready {
 		return "Initializing UI..."
@@ -366,6 +459,9 @@ func (m tuiModel) View() string {
 		// Split view with titles
 		chatTitle := titleStyle.Render("Chat & Logs")
 		notificationsTitle := titleStyle.Render("Notifications")
+		if hidden := notificationsFilter.Hidden(); hidden > 0 {
+			notificationsTitle += scrollIndicatorStyle.Render(fmt.Sprintf(" (%d hidden)", hidden))
+		}
 
 		chatContent := chatTitle + "\n" + scrollIndicator + m.chatViewport.View()
 		notificationsContent := notificationsTitle + "\n" + m.notificationsViewport.View()
@@ -458,6 +554,18 @@ func tuiClearSpinner() {
 	}
 }
 
+func tuiStartToolTimer(toolName string) {
+	if program != nil {
+		program.Send(toolStartedMsg(toolName))
+	}
+}
+
+func tuiStopToolTimer() {
+	if program != nil {
+		program.Send(toolFinishedMsg{})
+	}
+}
+
 func tuiSendError(err error) {
 	if program != nil {
 		program.Send(errorMsg(err))
@@ -476,6 +584,12 @@ func tuiSendNotification(notification cleverchatty.Notification) {
 	}
 }
 
+func tuiSendNotificationFeedback(feedback cleverchatty.NotificationFeedback) {
+	if program != nil {
+		program.Send(notificationFeedbackMsg{feedback: feedback})
+	}
+}
+
 func tuiSendAgentMessage(message string) {
 	if program != nil {
 		program.Send(agentMessageMsg{message: message})
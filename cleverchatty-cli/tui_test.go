@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cleverchatty "github.com/gelembjuk/cleverchatty/core"
+)
+
+func withTUISettings(t *testing.T, settings cleverchatty.TUIConfig) {
+	previous := tuiSettings
+	tuiSettings = settings
+	t.Cleanup(func() { tuiSettings = previous })
+}
+
+func withConfigFile(t *testing.T, path string) {
+	previous := configFile
+	configFile = path
+	t.Cleanup(func() { configFile = previous })
+}
+
+func TestWelcomeMessage(t *testing.T) {
+	withTUISettings(t, cleverchatty.TUIConfig{})
+	if content := newTUIModel(false, nil).chatContent.String(); !strings.Contains(content, "Welcome to CleverChatty CLI!") {
+		t.Errorf("Expected the default welcome message, got %q", content)
+	}
+
+	withTUISettings(t, cleverchatty.TUIConfig{WelcomeMessage: "Hello from the support bot"})
+	content := newTUIModel(false, nil).chatContent.String()
+	if !strings.Contains(content, "Hello from the support bot") {
+		t.Errorf("Expected the configured welcome message, got %q", content)
+	}
+	if strings.Contains(content, "Welcome to CleverChatty CLI!") {
+		t.Errorf("Expected the default welcome message to be replaced, got %q", content)
+	}
+	if !strings.Contains(content, "Type /help for available commands.") {
+		t.Errorf("Expected the help hint after the welcome message, got %q", content)
+	}
+
+	if welcome := renderWelcomeMessage("  \n"); welcome != "" {
+		t.Errorf("Expected a blank welcome message to be ignored, got %q", welcome)
+	}
+}
+
+func TestHelpExtraLines(t *testing.T) {
+	withTUISettings(t, cleverchatty.TUIConfig{})
+	if help := helpMarkdown(); strings.Contains(help, "## More") {
+		t.Errorf("Expected no extra section without help lines, got %q", help)
+	}
+
+	withTUISettings(t, cleverchatty.TUIConfig{HelpExtra: []string{"Ask about **orders**", "Call +1 555 0100"}})
+	help := helpMarkdown()
+	if !strings.Contains(help, "## More\n\n- Ask about **orders**\n- Call +1 555 0100\n") {
+		t.Errorf("Expected the extra help lines, got %q", help)
+	}
+	if !strings.Contains(help, "- **/help**: Show this help message") {
+		t.Errorf("Expected the standard commands to stay in the help, got %q", help)
+	}
+}
+
+func TestLoadTUIConfigDoesNotCreateConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.json")
+	withConfigFile(t, path)
+
+	settings, err := loadTUIConfig()
+	if err != nil {
+		t.Fatalf("Expected no error for a missing config file, got %v", err)
+	}
+	if settings.WelcomeMessage != "" || len(settings.HelpExtra) != 0 {
+		t.Errorf("Expected the default settings, got %+v", settings)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the config file not to be created, got %v", err)
+	}
+}
+
+func TestLoadTUIConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"tui_settings": {"welcome_message": "Hi", "help_extra": ["More help"]}}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write the config file: %v", err)
+	}
+	withConfigFile(t, path)
+
+	settings, err := loadTUIConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if settings.WelcomeMessage != "Hi" || len(settings.HelpExtra) != 1 || settings.HelpExtra[0] != "More help" {
+		t.Errorf("Expected the TUI settings of the config file, got %+v", settings)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	cleverchatty "github.com/gelembjuk/cleverchatty/core"
+)
+
+// maxVerboseToolResultLength is the number of characters of a tool result shown with --verbose-tools
+const maxVerboseToolResultLength = 500
+
+// formatToolArguments pretty prints the JSON arguments of a tool call, values of
+// arguments that look like secrets are redacted
+func formatToolArguments(arguments string) string {
+	var args interface{}
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return arguments
+	}
+	pretty, err := json.MarshalIndent(cleverchatty.RedactSecretArguments(args), "", "  ")
+	if err != nil {
+		return arguments
+	}
+	return string(pretty)
+}
+
+// truncateToolResult shortens a tool result for the verbose tools output
+func truncateToolResult(result string) string {
+	runes := []rune(result)
+	if len(runes) <= maxVerboseToolResultLength {
+		return result
+	}
+	return fmt.Sprintf("%s\n… %d more characters", string(runes[:maxVerboseToolResultLength]), len(runes)-maxVerboseToolResultLength)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	cleverchatty "github.com/gelembjuk/cleverchatty/core"
+)
+
+// metadataRule is the expected type and the size limit of a message metadata value
+type metadataRule struct {
+	kind      string // "string", "bool" or "object"
+	maxLength int    // For strings and objects (as JSON), in bytes
+}
+
+// messageMetadataRules lists the metadata keys read by the server. Other keys are ignored
+var messageMetadataRules = map[string]metadataRule{
+	"agent_id":                      {kind: "string", maxLength: 256},
+	"system_instruction":            {kind: "string", maxLength: 16 * 1024},
+	"tool_context":                  {kind: "object", maxLength: 4 * 1024},
+	cleverchatty.MetadataSkipMemory: {kind: "bool"},
+	cleverchatty.MetadataSkipRAG:    {kind: "bool"},
+	cleverchatty.MetadataEphemeral:  {kind: "bool"},
+
+	cleverchatty.MetadataVerboseTools:        {kind: "bool"},
+	cleverchatty.MetadataNotificationsFilter: {kind: "string", maxLength: 1024},
+}
+
+// maxLoggedMetadataKeyLength limits the length of an unknown key written to the log
+const maxLoggedMetadataKeyLength = 64
+
+// sanitizeMetadata returns the message metadata with the known keys only. A known key with
+// a value of a wrong type or above the size limit is an error, the message is rejected.
+// Unknown keys are dropped with a warning in the log
+func (a *A2AServer) sanitizeMetadata(metadata map[string]any) (map[string]any, error) {
+	sanitized := map[string]any{}
+	for key, value := range metadata {
+		rule, ok := messageMetadataRules[key]
+		if !ok {
+			if len(key) > maxLoggedMetadataKeyLength {
+				key = key[:maxLoggedMetadataKeyLength] + "…"
+			}
+			a.Logger.Printf("Warning: unknown metadata key %q is ignored", key)
+			continue
+		}
+		switch rule.kind {
+		case "string":
+			str, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("metadata %s must be a string", key)
+			}
+			if len(str) > rule.maxLength {
+				a.Logger.Printf("Warning: metadata %s of %d bytes is rejected, the limit is %d bytes", key, len(str), rule.maxLength)
+				return nil, fmt.Errorf("metadata %s is too large: %d bytes, the limit is %d bytes", key, len(str), rule.maxLength)
+			}
+		case "bool":
+			if _, ok := value.(bool); !ok {
+				return nil, fmt.Errorf("metadata %s must be a boolean", key)
+			}
+		case "object":
+			if _, ok := value.(map[string]any); !ok {
+				return nil, fmt.Errorf("metadata %s must be an object", key)
+			}
+			data, _ := json.Marshal(value)
+			if len(data) > rule.maxLength {
+				a.Logger.Printf("Warning: metadata %s of %d bytes is rejected, the limit is %d bytes", key, len(data), rule.maxLength)
+				return nil, fmt.Errorf("metadata %s is too large: %d bytes, the limit is %d bytes", key, len(data), rule.maxLength)
+			}
+		}
+		sanitized[key] = value
+	}
+	return sanitized, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	cleverchatty "github.com/gelembjuk/cleverchatty/core"
+)
+
+// notificationForwarder decides which notifications of tools servers are forwarded to the
+// client of a streaming task. Notifications hidden by the display filter of the client are
+// not sent, repeated notifications of the same method are throttled to avoid flooding the stream
+type notificationForwarder struct {
+	filter   []string
+	lastSent map[string]time.Time // By server and method
+	mux      sync.Mutex
+}
+
+func newNotificationForwarder(filter []string) *notificationForwarder {
+	return &notificationForwarder{
+		filter:   filter,
+		lastSent: map[string]time.Time{},
+	}
+}
+
+// allow returns true if the notification is sent to the client. The final progress
+// notification is always sent, so the client does not miss the end of the work
+func (f *notificationForwarder) allow(notification cleverchatty.Notification) bool {
+	if !cleverchatty.NotificationFilterShows(f.filter, notification.Method) {
+		return false
+	}
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	key := notification.ServerName + "|" + notification.Method
+	if last, ok := f.lastSent[key]; ok && time.Since(last) < notificationForwardInterval && !notification.IsFinalProgress() {
+		return false
+	}
+	f.lastSent[key] = time.Now()
+	return true
+}
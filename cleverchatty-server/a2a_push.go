@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	a2aprotocol "trpc.group/trpc-go/trpc-a2a-go/protocol"
+)
+
+// defaultPushNotificationTimeout is the number of seconds to wait for the webhook of a client
+const defaultPushNotificationTimeout = 10
+
+// defaultPushNotificationRetries is the number of retries of a failed push notification
+const defaultPushNotificationRetries = 3
+
+// pushNotificationRetryDelay is the delay before the first retry, it doubles with every retry
+const pushNotificationRetryDelay = 1 * time.Second
+
+// maxQueuedPushNotifications is the number of events of a task waiting to be delivered
+const maxQueuedPushNotifications = 100
+
+// errForbiddenPushTarget is returned when a webhook resolves to an internal address
+var errForbiddenPushTarget = errors.New("push notification webhook must not point to a loopback, private or link-local address")
+
+// internalPushNetworks are the networks not covered by the checks of net.IP, the shared
+// address space of carrier-grade NAT and the "this network" block
+var internalPushNetworks = []*net.IPNet{
+	{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)},
+	{IP: net.IPv4(0, 0, 0, 0), Mask: net.CIDRMask(8, 32)},
+}
+
+// pushNotifier delivers the events of tasks to the webhooks of clients that set a push
+// notification config, so a client does not have to keep a stream open for a long task.
+// Events of a task are delivered in order by a separate goroutine of the task
+type pushNotifier struct {
+	client       *http.Client
+	allowedHosts []string // Webhook hosts allowed by the config, any public host if empty
+	retries      int
+	retryDelay   time.Duration
+	logger       *log.Logger
+	targets      map[string]*pushTarget // By task ID
+	mux          sync.Mutex
+}
+
+// pushTarget is the webhook of one task with the queue of its events
+type pushTarget struct {
+	config    a2aprotocol.PushNotificationConfig
+	configMux sync.Mutex
+	events    chan any
+}
+
+func (t *pushTarget) getConfig() a2aprotocol.PushNotificationConfig {
+	t.configMux.Lock()
+	defer t.configMux.Unlock()
+	return t.config
+}
+
+// newPushNotifier creates the notifier. Webhooks of clients are limited to the allowed hosts.
+// Without the allowed hosts any host is accepted, but connections to internal addresses are
+// refused, so clients can not make the server call services of its own network
+func newPushNotifier(timeout time.Duration, retries int, allowedHosts []string, logger *log.Logger) *pushNotifier {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if len(allowedHosts) == 0 {
+		// The address is checked at dial time, so a host resolving to an internal address is refused too
+		transport.DialContext = (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   refuseInternalAddress,
+		}).DialContext
+	}
+	return &pushNotifier{
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			// A redirect could lead to a host that is not allowed
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		allowedHosts: allowedHosts,
+		retries:      retries,
+		retryDelay:   pushNotificationRetryDelay,
+		logger:       logger,
+		targets:      map[string]*pushTarget{},
+	}
+}
+
+// refuseInternalAddress is the dialer control refusing loopback, private, link-local, unspecified
+// and shared (carrier-grade NAT) addresses
+func refuseInternalAddress(network string, address string, conn syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return errForbiddenPushTarget
+	}
+	for _, block := range internalPushNetworks {
+		if block.Contains(ip) {
+			return errForbiddenPushTarget
+		}
+	}
+	return nil
+}
+
+// validatePushNotificationConfig checks the webhook of a client before it is stored
+func (p *pushNotifier) validatePushNotificationConfig(config a2aprotocol.PushNotificationConfig) error {
+	webhook, err := url.Parse(config.URL)
+	if err != nil || webhook.Host == "" || (webhook.Scheme != "http" && webhook.Scheme != "https") {
+		return fmt.Errorf("push notification URL must be an absolute http or https URL, got %q", config.URL)
+	}
+	if len(p.allowedHosts) == 0 {
+		return nil
+	}
+	for _, host := range p.allowedHosts {
+		if strings.EqualFold(host, webhook.Hostname()) {
+			return nil
+		}
+	}
+	return fmt.Errorf("push notification webhook host %q is not allowed", webhook.Hostname())
+}
+
+// setConfig starts delivering the events of the task to the webhook. A new config
+// of the same task replaces the webhook for the next events
+func (p *pushNotifier) setConfig(taskID string, config a2aprotocol.PushNotificationConfig) error {
+	if err := p.validatePushNotificationConfig(config); err != nil {
+		return err
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if target, ok := p.targets[taskID]; ok {
+		target.configMux.Lock()
+		target.config = config
+		target.configMux.Unlock()
+		return nil
+	}
+	target := &pushTarget{
+		config: config,
+		events: make(chan any, maxQueuedPushNotifications),
+	}
+	p.targets[taskID] = target
+	go p.deliver(taskID, target)
+	p.logger.Printf("Push notifications of task %s are sent to %s", taskID, config.URL)
+	return nil
+}
+
+// notify queues the event of the task if its client set a push notification config.
+// Events are dropped when too many are waiting, except the final one: it takes the place
+// of the oldest waiting event, so the client always learns the task ended.
+// The final event ends the notifications of the task
+func (p *pushNotifier) notify(taskID string, event a2aprotocol.StreamingMessageEvent) {
+	if p == nil {
+		return
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	target, ok := p.targets[taskID]
+	if !ok {
+		return
+	}
+	final := false
+	if status, ok := event.Result.(*a2aprotocol.TaskStatusUpdateEvent); ok {
+		final = status.Final
+	}
+	for queued := false; !queued; {
+		select {
+		case target.events <- event.Result:
+			queued = true
+		default:
+			if !final {
+				p.logger.Printf("Push notification of task %s is dropped, too many events are waiting for delivery", taskID)
+				return
+			}
+			// Only notify sends to the queue, so the slot freed here stays free
+			select {
+			case <-target.events:
+				p.logger.Printf("Push notification of task %s is dropped for the final event, too many events are waiting for delivery", taskID)
+			default:
+			}
+		}
+	}
+	if final {
+		close(target.events)
+		delete(p.targets, taskID)
+	}
+}
+
+// remove stops the notifications of a task that ended without the final event
+func (p *pushNotifier) remove(taskID string) {
+	if p == nil {
+		return
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if target, ok := p.targets[taskID]; ok {
+		close(target.events)
+		delete(p.targets, taskID)
+	}
+}
+
+// deliver sends the queued events of the task one by one
+func (p *pushNotifier) deliver(taskID string, target *pushTarget) {
+	for event := range target.events {
+		config := target.getConfig()
+		if err := p.send(config, event); err != nil {
+			p.logger.Printf("Failed to deliver a push notification of task %s to %s: %v", taskID, config.URL, err)
+		}
+	}
+}
+
+// send posts the event to the webhook. Network errors and server errors are retried
+func (p *pushNotifier) send(config a2aprotocol.PushNotificationConfig, event any) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	delay := p.retryDelay
+	for attempt := 0; ; attempt++ {
+		retry, err := p.post(config, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= p.retries {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// post makes one delivery attempt. Returns true if a failed attempt can be retried
+func (p *pushNotifier) post(config a2aprotocol.PushNotificationConfig, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, config.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if config.Token != "" {
+		// The client checks the token to make sure the notification is for its task
+		req.Header.Set("X-A2A-Notification-Token", config.Token)
+	}
+	if authorization := pushAuthorization(config.Authentication); authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return !errors.Is(err, errForbiddenPushTarget), err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+}
+
+// pushAuthorization builds the Authorization header from the credentials of the client
+// for the bearer or basic scheme
+func pushAuthorization(authentication *a2aprotocol.AuthenticationInfo) string {
+	if authentication == nil || authentication.Credentials == nil || *authentication.Credentials == "" {
+		return ""
+	}
+	for _, scheme := range authentication.Schemes {
+		switch strings.ToLower(scheme) {
+		case "bearer":
+			return "Bearer " + *authentication.Credentials
+		case "basic":
+			return "Basic " + *authentication.Credentials
+		}
+	}
+	return ""
+}
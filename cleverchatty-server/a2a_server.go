@@ -2,6 +2,9 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -9,6 +12,7 @@ import (
 	"time"
 
 	cleverchatty "github.com/gelembjuk/cleverchatty/core"
+	"github.com/gelembjuk/cleverchatty/core/history"
 	"github.com/google/uuid"
 	a2aprotocol "trpc.group/trpc-go/trpc-a2a-go/protocol"
 	a2aserver "trpc.group/trpc-go/trpc-a2a-go/server"
@@ -17,14 +21,35 @@ import (
 
 const A2AServerVersion = "0.1.0"
 
+// notificationForwardInterval is the minimum interval between forwarded notifications
+// of the same server and method within a streaming task
+const notificationForwardInterval = 1 * time.Second
+
+// maxToolResultStatusLength is the number of characters of a tool result sent in a status update
+const maxToolResultStatusLength = 2000
+
+// defaultStreamResumeTimeout is the number of seconds a streaming task waits for its
+// disconnected client to resubscribe
+const defaultStreamResumeTimeout = 60
+
+// maxPendingStreamEvents is the number of the last events kept for a disconnected client
+const maxPendingStreamEvents = 100
+
+// resumedSubscriberBufferSize is the number of live events buffered for a resubscribed client
+const resumedSubscriberBufferSize = 10
+
 type A2AServer struct {
 	A2AServerConfig     *cleverchatty.A2AServerConfig
+	MaxPromptBytes      int // Messages larger than this are rejected before processing. 0 means unlimited
 	SessionsManager     *cleverchatty.SessionManager
 	WorkDirectory       string
 	Logger              *log.Logger
 	server              *a2aserver.A2AServer
 	notificationSubs    map[string]a2ataskmanager.TaskSubscriber
 	notificationSubsMux sync.RWMutex
+	streams             map[string]*taskStream // Streaming tasks in progress by task ID, so they can be cancelled
+	streamsMux          sync.Mutex
+	pushes              *pushNotifier // nil if push notifications are disabled
 }
 
 // Helper function to create string pointers
@@ -49,6 +74,7 @@ func getA2AServer(
 		WorkDirectory:    WorkDirectory,
 		Logger:           logger,
 		notificationSubs: make(map[string]a2ataskmanager.TaskSubscriber),
+		streams:          make(map[string]*taskStream),
 	}
 
 	return a2aServer, nil
@@ -72,7 +98,8 @@ func (a *A2AServer) agentCard() a2aserver.AgentCard {
 			Organization: a.A2AServerConfig.Organization,
 		},
 		Capabilities: a2aserver.AgentCapabilities{
-			Streaming: boolPtr(true),
+			Streaming:         boolPtr(true),
+			PushNotifications: boolPtr(a.A2AServerConfig.PushNotifications),
 		},
 		DefaultInputModes:  []string{a2aprotocol.KindText},
 		DefaultOutputModes: []string{a2aprotocol.KindText},
@@ -97,6 +124,77 @@ func (a *A2AServer) extractTextFromMessage(message a2aprotocol.Message) string {
 	return ""
 }
 
+// messageSize returns the size of the text and the files of a message in bytes
+func messageSize(message a2aprotocol.Message) int64 {
+	size := int64(0)
+	for _, part := range message.Parts {
+		switch p := part.(type) {
+		case *a2aprotocol.TextPart:
+			size += int64(len(p.Text))
+		case *a2aprotocol.FilePart:
+			if file, ok := p.File.(*a2aprotocol.FileWithBytes); ok {
+				size += int64(base64.StdEncoding.DecodedLen(len(file.Bytes)))
+			}
+		}
+	}
+	return size
+}
+
+// promptContext limits the processing of a client's message by the configured timeout
+func (a *A2AServer) promptContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if a.A2AServerConfig.PromptTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, time.Duration(a.A2AServerConfig.PromptTimeout)*time.Second)
+}
+
+// promptError explains the error of a prompt that reached the configured timeout
+func (a *A2AServer) promptError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("the message was not processed within %d seconds: %w", a.A2AServerConfig.PromptTimeout, err)
+	}
+	return err
+}
+
+// promptMessage converts the parts of an A2A message to a user's message. Files sent
+// with bytes are stored in the session file cache and referenced in the message.
+// A file that can not be attached, for example of a type that is not allowed, fails the
+// message, so the model does not answer without the file the client expects it to see
+func (a *A2AServer) promptMessage(session *cleverchatty.Session, message a2aprotocol.Message) (history.HistoryMessage, error) {
+	msg := history.HistoryMessage{Role: "user"}
+
+	for _, part := range message.Parts {
+		switch p := part.(type) {
+		case *a2aprotocol.TextPart:
+			msg.Content = append(msg.Content, history.ContentBlock{Type: "text", Text: p.Text})
+		case *a2aprotocol.FilePart:
+			switch file := p.File.(type) {
+			case *a2aprotocol.FileWithBytes:
+				name := "file"
+				if file.Name != nil && *file.Name != "" {
+					name = *file.Name
+				}
+				mimeType := ""
+				if file.MimeType != nil {
+					mimeType = *file.MimeType
+				}
+				data, err := base64.StdEncoding.DecodeString(file.Bytes)
+				if err != nil {
+					return msg, fmt.Errorf("failed to decode file %s: %w", name, err)
+				}
+				reference, err := session.AI.AttachContent(name, mimeType, data)
+				if err != nil {
+					return msg, fmt.Errorf("failed to attach file %s: %w", name, err)
+				}
+				msg.Content = append(msg.Content, history.NewFileReferenceBlock(name, reference))
+			case *a2aprotocol.FileWithURI:
+				msg.Content = append(msg.Content, history.ContentBlock{Type: "text", Text: "File: " + file.URI})
+			}
+		}
+	}
+	return msg, nil
+}
+
 func (a *A2AServer) ProcessMessage(
 	ctx context.Context,
 	message a2aprotocol.Message,
@@ -110,6 +208,17 @@ func (a *A2AServer) ProcessMessage(
 		return nil, fmt.Errorf("no text part found in the message")
 	}
 
+	if err := cleverchatty.CheckPromptSize(messageSize(message), a.MaxPromptBytes); err != nil {
+		return nil, err
+	}
+
+	// Only the known metadata keys are processed, their values are validated
+	metadata, err := a.sanitizeMetadata(message.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	message.Metadata = metadata
+
 	// Check if this is a notification subscription request
 	if prompt == "__subscribe_notifications__" {
 		return a.handleNotificationSubscription(ctx, message, options, handle)
@@ -149,7 +258,24 @@ func (a *A2AServer) ProcessMessage(
 		}
 	}
 
-	session, err := a.SessionsManager.GetOrCreateSession(*message.ContextID, agentid) // Ensure session exists
+	// A client can set the role and the tool context of a new session. They are ignored for existing sessions
+	sessionOptions := cleverchatty.SessionOptions{}
+	if a.A2AServerConfig.AllowSystemInstruction {
+		if val, ok := message.Metadata["system_instruction"]; ok {
+			if str, ok := val.(string); ok {
+				sessionOptions.SystemInstruction = str
+			}
+		}
+	}
+	if a.A2AServerConfig.AllowToolContext {
+		if val, ok := message.Metadata["tool_context"]; ok {
+			if toolContext, ok := val.(map[string]any); ok {
+				sessionOptions.ToolContext = toolContext
+			}
+		}
+	}
+
+	session, err := a.SessionsManager.GetOrCreateSessionWithOptions(*message.ContextID, agentid, sessionOptions) // Ensure session exists
 
 	if prompt == "/hello" {
 		// in fact this is a command to test the server and agentid (and auth in the future)
@@ -160,19 +286,53 @@ func (a *A2AServer) ProcessMessage(
 		return nil, fmt.Errorf("failed to get or create session: %w", err)
 	}
 
-	if !options.Streaming {
+	userMessage, err := a.promptMessage(session, message)
+	if err != nil {
+		a.Logger.Printf("Message of agent %s is rejected: %v", agentid, err)
+		return nil, err
+	}
+
+	access := accessLogEntry{
+		AgentID:      agentid,
+		ContextID:    *message.ContextID,
+		Streaming:    options.Streaming,
+		PromptLength: len(prompt),
+		started:      time.Now(),
+	}
+
+	// A client with a push notification config gets the task at once, the events of
+	// the task are posted to its webhook
+	pushMode := !options.Streaming && options.PushNotificationConfig != nil && a.pushes != nil
+
+	if !options.Streaming && !pushMode {
 		// Process the text This is not streaming response
-		response, err := session.AI.Prompt(prompt)
+		promptCtx, cancel := a.promptContext(ctx)
+		// There is no task in this mode, the logs of the request are marked with the message ID
+		promptCtx = cleverchatty.WithRequestID(promptCtx, message.MessageID)
+		response, err := session.AI.PromptWithOptions(promptCtx, userMessage, cleverchatty.PromptOptionsFromMetadata(message.Metadata))
+		cancel()
+
+		a.logAccess(access.finish(session.AI.LastPromptStats(), response, err))
 
 		if err != nil {
-			return nil, fmt.Errorf("failed to process prompt: %w", err)
+			return nil, fmt.Errorf("failed to process prompt: %w", a.promptError(err))
 		}
 		a.Logger.Printf("Response from AI: %s. ", response)
-		// Return a simple response message
-		return a.buildTextMessageResponse(response), nil
+		// Files returned by tools are sent as file parts next to the text
+		responseMessage := a2aprotocol.NewMessage(
+			a2aprotocol.MessageRoleAgent,
+			append([]a2aprotocol.Part{a2aprotocol.NewTextPart(response)}, a.fileParts(session.AI.TakeProducedFiles())...),
+		)
+		return &a2ataskmanager.MessageProcessingResult{
+			Result: &responseMessage,
+		}, nil
 	}
 
-	a.Logger.Println("Using streaming mode")
+	if pushMode {
+		a.Logger.Println("Using push notifications mode")
+	} else {
+		a.Logger.Println("Using streaming mode")
+	}
 
 	// Create a task for streaming
 	taskID, err := handle.BuildTask(nil, nil)
@@ -180,63 +340,171 @@ func (a *A2AServer) ProcessMessage(
 		return nil, fmt.Errorf("failed to build task: %w", err)
 	}
 
-	// Subscribe to the task for streaming events
-	subscriber, err := handle.SubScribeTask(&taskID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to subscribe to task: %w", err)
+	if options.PushNotificationConfig != nil && a.pushes != nil {
+		if err := a.pushes.setConfig(taskID, *options.PushNotificationConfig); err != nil {
+			handle.CleanTask(&taskID)
+			return nil, err
+		}
+	}
+
+	var subscriber a2ataskmanager.TaskSubscriber
+	if !pushMode {
+		// Subscribe to the task for streaming events
+		subscriber, err = handle.SubScribeTask(&taskID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to subscribe to task: %w", err)
+		}
+	}
+
+	stream := newTaskStream(taskID, handle.GetContextID(), subscriber)
+	if pushMode {
+		stream.handle = handle
+	}
+	a.addStream(stream)
+	if subscriber != nil {
+		// The client can resubscribe to the task when its connection drops
+		context.AfterFunc(ctx, func() {
+			a.detachStream(stream, subscriber)
+		})
 	}
 
 	// Start streaming processing in a goroutine
 	go func() {
-		defer func() {
-			if subscriber != nil {
-				subscriber.Close()
-			}
-		}()
-
-		contextID := handle.GetContextID()
+		defer a.finishStream(stream)
 
 		session.AI.Callbacks.SetStartedPromptProcessing(func(prompt string) error {
-			a.statusUpdate(cleverchatty.CallbackCodePromptProcessing, prompt, "", taskID, contextID, subscriber)
+			a.statusUpdate(cleverchatty.CallbackCodePromptProcessing, prompt, "", stream)
 			return nil
 		})
 		session.AI.Callbacks.SetStartedThinking(func() error {
-			a.statusUpdate(cleverchatty.CallbackCodeStartedThinking, "Thinking...", "", taskID, contextID, subscriber)
+			a.statusUpdate(cleverchatty.CallbackCodeStartedThinking, "Thinking...", "", stream)
 			return nil
 		})
 		session.AI.Callbacks.SetMemoryRetrievalStarted(func() error {
-			a.statusUpdate(cleverchatty.CallbackCodeMemoryRetrieval, "Recalling...", "", taskID, contextID, subscriber)
+			a.statusUpdate(cleverchatty.CallbackCodeMemoryRetrieval, "Recalling...", "", stream)
 			return nil
 		})
 		session.AI.Callbacks.SetRAGRetrievalStarted(func() error {
-			a.statusUpdate(cleverchatty.CallbackCodeRAGRetrieval, "Searching knowledge database ...", "", taskID, contextID, subscriber)
+			a.statusUpdate(cleverchatty.CallbackCodeRAGRetrieval, "Searching knowledge database ...", "", stream)
+			return nil
+		})
+		session.AI.Callbacks.SetRAGPreprocessing(func() error {
+			a.statusUpdate(cleverchatty.CallbackCodeRAGPreprocessing, "Refining query...", "", stream)
 			return nil
 		})
 		session.AI.Callbacks.SetToolCalling(func(toolName string) error {
-			a.statusUpdate(cleverchatty.CallbackCodeToolCalling, "Using tool: "+toolName, toolName, taskID, contextID, subscriber)
+			a.statusUpdate(cleverchatty.CallbackCodeToolCalling, "Using tool: "+toolName, toolName, stream)
 			return nil
 		})
+		// Arguments and results of tools can carry private data, they are sent only to clients asking for them
+		if verboseTools, _ := message.Metadata[cleverchatty.MetadataVerboseTools].(bool); verboseTools {
+			session.AI.Callbacks.SetToolArguments(func(toolName string, arguments string) error {
+				a.statusUpdate(cleverchatty.CallbackCodeToolArguments, cleverchatty.RedactSecretsJSON(arguments), toolName, stream)
+				return nil
+			})
+			session.AI.Callbacks.SetToolResultReceived(func(toolName string, result string) error {
+				// Results can be large, clients show them for debugging only
+				result = cleverchatty.RedactSecretsJSON(result)
+				if runes := []rune(result); len(runes) > maxToolResultStatusLength {
+					result = string(runes[:maxToolResultStatusLength]) + "…"
+				}
+				a.statusUpdate(cleverchatty.CallbackCodeToolResult, result, toolName, stream)
+				return nil
+			})
+		} else {
+			session.AI.Callbacks.SetToolArguments(nil)
+			session.AI.Callbacks.SetToolResultReceived(nil)
+		}
 		session.AI.Callbacks.SetToolCallFailed(func(toolName string, err error) error {
-			a.statusUpdate(cleverchatty.CallbackCodeToolCallFailed, err.Error(), toolName, taskID, contextID, subscriber)
+			a.statusUpdate(cleverchatty.CallbackCodeToolCallFailed, err.Error(), toolName, stream)
+			return nil
+		})
+		session.AI.Callbacks.SetToolCallFinished(func(toolName string, elapsed time.Duration) error {
+			// The message is the elapsed time in the Go duration format, e.g. "1.25s"
+			a.statusUpdate(cleverchatty.CallbackCodeToolCallFinished, elapsed.Round(time.Millisecond).String(), toolName, stream)
+			return nil
+		})
+		session.AI.Callbacks.SetToolsChanged(func(serverName string) error {
+			a.statusUpdate(cleverchatty.CallbackCodeToolsChanged, "Tools of server "+serverName+" changed", serverName, stream)
 			return nil
 		})
 		session.AI.Callbacks.SetResponseReceived(func(response string) error {
-			a.statusUpdate(cleverchatty.CallbackCodeResponseReceived, response, "", taskID, contextID, subscriber)
+			a.statusUpdate(cleverchatty.CallbackCodeResponseReceived, response, "", stream)
+			return nil
+		})
+		session.AI.Callbacks.SetReasoningReceived(func(text string) error {
+			a.statusUpdate(cleverchatty.CallbackCodeReasoning, text, "", stream)
 			return nil
 		})
 
-		response, err := session.AI.Prompt(prompt)
+		// Forward notifications of this session's tools servers (e.g. tool progress) to the client
+		filter, _ := message.Metadata[cleverchatty.MetadataNotificationsFilter].(string)
+		forwarder := newNotificationForwarder(cleverchatty.ParseNotificationFilter(filter))
+		session.AI.Callbacks.SetNotificationReceived(func(notification cleverchatty.Notification) error {
+			if !forwarder.allow(notification) {
+				return nil
+			}
+			notificationJSON, err := json.Marshal(notification)
+			if err != nil {
+				return err
+			}
+			a.statusUpdate(cleverchatty.CallbackCodeNotification, notification.Description, string(notificationJSON), stream)
+			return nil
+		})
+
+		// A new session greets the client before the first response
+		if greeting := a.SessionsManager.TakeGreeting(session.ID); greeting != "" {
+			a.statusUpdate(cleverchatty.CallbackCodeResponseReceived, greeting, "", stream)
+		}
+
+		// The processing is cancelled when the client is gone or the prompt timeout is reached
+		promptCtx, cancel := a.promptContext(stream.ctx)
+		promptCtx = cleverchatty.WithRequestID(promptCtx, taskID)
+		response, err := session.AI.PromptWithOptions(promptCtx, userMessage, cleverchatty.PromptOptionsFromMetadata(message.Metadata))
+		cancel()
+
+		a.logAccess(access.finish(session.AI.LastPromptStats(), response, err))
+
+		// The stream is closed after this prompt, stop forwarding notifications to it
+		session.AI.Callbacks.SetNotificationReceived(nil)
+
+		if stream.ctx.Err() != nil {
+			a.Logger.Printf("Task %s is abandoned, the client is gone or the task is cancelled", taskID)
+			return
+		}
 
 		if err != nil {
-			a.statusFailed(err, taskID, contextID, subscriber)
+			a.statusFailed(a.promptError(err), stream)
 			return
 		}
 
+		// Files returned by tools are delivered as artifacts of the task. They are sent
+		// with the task stream, so a client that resubscribed gets them too
+		for _, file := range session.AI.TakeProducedFiles() {
+			artifactEvent := a2aprotocol.StreamingMessageEvent{
+				Result: &a2aprotocol.TaskArtifactUpdateEvent{
+					TaskID:    taskID,
+					ContextID: stream.contextID,
+					Kind:      "artifact-update",
+					Artifact: a2aprotocol.Artifact{
+						ArtifactID: uuid.New().String(),
+						Name:       stringPtr(file.Name),
+						Parts:      []a2aprotocol.Part{a.filePart(file)},
+					},
+					LastChunk: boolPtr(true),
+					Append:    boolPtr(false),
+				},
+			}
+			if !a.sendTaskEvent(stream, artifactEvent) {
+				a.Logger.Printf("Failed to send artifact %s of task %s", file.Name, taskID)
+			}
+		}
+
 		// Final completion status update
 		completeEvent := a2aprotocol.StreamingMessageEvent{
 			Result: &a2aprotocol.TaskStatusUpdateEvent{
 				TaskID:    taskID,
-				ContextID: contextID,
+				ContextID: stream.contextID,
 				Kind:      "status-update",
 				Status: a2aprotocol.TaskStatus{
 					State: a2aprotocol.TaskStateCompleted,
@@ -250,19 +518,85 @@ func (a *A2AServer) ProcessMessage(
 				Final: true,
 			},
 		}
-		err = subscriber.Send(completeEvent)
-		if err != nil {
-			a.Logger.Fatalf("Failed to send complete event: %v", err)
+		if !a.sendTaskEvent(stream, completeEvent) {
+			return
 		}
 
 		a.Logger.Printf("Task %s streaming completed successfully.", taskID)
 	}()
 
+	if pushMode {
+		task, err := handle.GetTask(&taskID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get task: %w", err)
+		}
+		return &a2ataskmanager.MessageProcessingResult{
+			Result: task.Task(),
+		}, nil
+	}
+
 	return &a2ataskmanager.MessageProcessingResult{
 		StreamingEvents: subscriber,
 	}, nil
 
 }
+
+// accessLogEntry is the summary of one processed message, written as a single log line
+type accessLogEntry struct {
+	AgentID        string
+	ContextID      string
+	Streaming      bool
+	PromptLength   int
+	ResponseLength int
+	Latency        time.Duration
+	Stats          cleverchatty.PromptStats
+	Err            error
+	started        time.Time
+}
+
+// finish completes the entry with the result of the prompt
+func (e accessLogEntry) finish(stats cleverchatty.PromptStats, response string, err error) accessLogEntry {
+	e.ResponseLength = len(response)
+	e.Latency = time.Since(e.started)
+	e.Stats = stats
+	e.Err = err
+	return e
+}
+
+func (e accessLogEntry) String() string {
+	status := "ok"
+	if e.Err != nil {
+		status = "error"
+	}
+	return fmt.Sprintf(
+		"access agent_id=%q context_id=%s streaming=%t status=%s prompt_length=%d response_length=%d latency=%s tool_calls=%d model=%s input_tokens=%d output_tokens=%d",
+		e.AgentID, e.ContextID, e.Streaming, status, e.PromptLength, e.ResponseLength,
+		e.Latency.Round(time.Millisecond), e.Stats.ToolCalls, e.Stats.Model, e.Stats.InputTokens, e.Stats.OutputTokens,
+	)
+}
+
+// logAccess writes the access log line of a processed message
+func (a *A2AServer) logAccess(entry accessLogEntry) {
+	a.Logger.Print(entry.String())
+}
+
+// fileParts converts files returned by tools to A2A file parts
+func (a *A2AServer) fileParts(files []cleverchatty.ProducedFile) []a2aprotocol.Part {
+	parts := []a2aprotocol.Part{}
+	for _, file := range files {
+		parts = append(parts, a.filePart(file))
+	}
+	return parts
+}
+
+func (a *A2AServer) filePart(file cleverchatty.ProducedFile) a2aprotocol.FilePart {
+	return a2aprotocol.NewFilePartWithBytes(
+		file.Name,
+		file.MimeType,
+		base64.StdEncoding.EncodeToString(file.Data),
+	)
+}
+
 func (a *A2AServer) buildTextMessageResponse(text string) *a2ataskmanager.MessageProcessingResult {
 	responseMessage := a2aprotocol.NewMessage(
 		a2aprotocol.MessageRoleAgent,
@@ -272,11 +606,96 @@ func (a *A2AServer) buildTextMessageResponse(text string) *a2ataskmanager.Messag
 		Result: &responseMessage,
 	}
 }
-func (a *A2AServer) statusUpdate(statusCode string, statusMessage string, statusMessageExtra string, taskID string, contextID string, subscriber a2ataskmanager.TaskSubscriber) {
+
+// taskStream is the stream of events of a task processed in the streaming mode.
+// When an event can not be sent (the client is gone), the context is cancelled
+// and no more events are sent.
+// When the connection of the client drops, the events are kept until the client
+// resubscribes to the task with tasks/resubscribe.
+type taskStream struct {
+	taskID     string
+	contextID  string
+	subscriber a2ataskmanager.TaskSubscriber       // nil while the client is disconnected
+	pending    []a2aprotocol.StreamingMessageEvent // Events for the disconnected client
+	finished   bool                                // The processing is over, the stream waits for the client only
+	handle     a2ataskmanager.TaskHandler          // Set in the push mode to store the final state in the task
+	mux        sync.Mutex
+	ctx        context.Context
+	cancel     context.CancelFunc
+}
+
+func newTaskStream(taskID string, contextID string, subscriber a2ataskmanager.TaskSubscriber) *taskStream {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &taskStream{
+		taskID:     taskID,
+		contextID:  contextID,
+		subscriber: subscriber,
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+func (s *taskStream) close() {
+	s.cancel()
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.subscriber != nil {
+		s.subscriber.Close()
+	}
+}
+
+// sendTaskEvent sends an event to the task stream. A failure to send means the client is gone
+// or its buffer is full, the client is detached as if it disconnected. Returns false if the task
+// is cancelled. Events of a disconnected client are kept to be sent when it resubscribes.
+// The events are also posted to the webhook of the client if it set one
+func (a *A2AServer) sendTaskEvent(stream *taskStream, event a2aprotocol.StreamingMessageEvent) bool {
+	stream.mux.Lock()
+	defer stream.mux.Unlock()
+
+	if stream.ctx.Err() != nil {
+		return false
+	}
+	a.pushes.notify(stream.taskID, event)
+	if status, ok := event.Result.(*a2aprotocol.TaskStatusUpdateEvent); ok && status.Final && stream.handle != nil {
+		// No stream of the task manager gets the events in the push mode, the task keeps
+		// the final state for the client asking for it with tasks/get
+		if err := stream.handle.UpdateTaskState(&stream.taskID, status.Status.State, status.Status.Message); err != nil {
+			a.Logger.Printf("Failed to update the state of task %s: %v", stream.taskID, err)
+		}
+	}
+	if stream.subscriber == nil {
+		stream.pending = append(stream.pending, event)
+		if len(stream.pending) > maxPendingStreamEvents {
+			stream.pending = stream.pending[len(stream.pending)-maxPendingStreamEvents:]
+		}
+		return true
+	}
+	if err := stream.subscriber.Send(event); err != nil {
+		// The client is gone or does not read fast enough. The task goes on, the client
+		// can resubscribe and get the event with the ones sent meanwhile
+		a.Logger.Printf("Failed to send event of task %s, detaching the client: %v", stream.taskID, err)
+		subscriber := stream.subscriber
+		stream.subscriber = nil
+		stream.pending = append(stream.pending, event)
+		subscriber.Close()
+		a.cancelUnlessResubscribed(stream)
+	}
+	return true
+}
+
+// statusUpdate reports a callback of the working task. The status is carried in the message
+// metadata, the text part contains only the human readable message
+func (a *A2AServer) statusUpdate(statusCode string, statusMessage string, statusMessageExtra string, stream *taskStream) {
+	status := cleverchatty.A2AStatus{
+		Code:      statusCode,
+		Message:   statusMessage,
+		Extra:     statusMessageExtra,
+		RequestID: stream.taskID,
+	}
 	workingEvent := a2aprotocol.StreamingMessageEvent{
 		Result: &a2aprotocol.TaskStatusUpdateEvent{
-			TaskID:    taskID,
-			ContextID: contextID,
+			TaskID:    stream.taskID,
+			ContextID: stream.contextID,
 			Kind:      "status-update",
 			Status: a2aprotocol.TaskStatus{
 				State: a2aprotocol.TaskStateWorking,
@@ -284,26 +703,20 @@ func (a *A2AServer) statusUpdate(statusCode string, statusMessage string, status
 					MessageID: uuid.New().String(),
 					Kind:      "message",
 					Role:      a2aprotocol.MessageRoleAgent,
-					Parts: []a2aprotocol.Part{
-						a2aprotocol.NewTextPart(statusCode),
-						a2aprotocol.NewTextPart(statusMessage),
-						a2aprotocol.NewTextPart(statusMessageExtra),
-					},
+					Parts:     []a2aprotocol.Part{a2aprotocol.NewTextPart(statusMessage)},
+					Metadata:  status.Metadata(),
 				},
 			},
 		},
 	}
-	err := subscriber.Send(workingEvent)
-	if err != nil {
-		a.Logger.Fatalf("Failed to send status update event: %v", err)
-	}
+	a.sendTaskEvent(stream, workingEvent)
 }
 
-func (a *A2AServer) statusFailed(err error, taskID string, contextID string, subscriber a2ataskmanager.TaskSubscriber) {
+func (a *A2AServer) statusFailed(err error, stream *taskStream) {
 	cancelEvent := a2aprotocol.StreamingMessageEvent{
 		Result: &a2aprotocol.TaskStatusUpdateEvent{
-			TaskID:    taskID,
-			ContextID: contextID,
+			TaskID:    stream.taskID,
+			ContextID: stream.contextID,
 			Kind:      "status-update",
 			Status: a2aprotocol.TaskStatus{
 				State: a2aprotocol.TaskStateFailed,
@@ -317,10 +730,213 @@ func (a *A2AServer) statusFailed(err error, taskID string, contextID string, sub
 			Final: true,
 		},
 	}
-	err = subscriber.Send(cancelEvent)
+	a.sendTaskEvent(stream, cancelEvent)
+}
+
+func (a *A2AServer) statusCanceled(stream *taskStream) {
+	canceledEvent := a2aprotocol.StreamingMessageEvent{
+		Result: &a2aprotocol.TaskStatusUpdateEvent{
+			TaskID:    stream.taskID,
+			ContextID: stream.contextID,
+			Kind:      "status-update",
+			Status: a2aprotocol.TaskStatus{
+				State: a2aprotocol.TaskStateCanceled,
+			},
+			Final: true,
+		},
+	}
+	a.sendTaskEvent(stream, canceledEvent)
+}
+
+func (a *A2AServer) addStream(stream *taskStream) {
+	a.streamsMux.Lock()
+	defer a.streamsMux.Unlock()
+	a.streams[stream.taskID] = stream
+}
+
+func (a *A2AServer) removeStream(taskID string) {
+	a.streamsMux.Lock()
+	defer a.streamsMux.Unlock()
+	delete(a.streams, taskID)
+}
+
+// isStreamRunning returns true if the task is still processed
+func (a *A2AServer) isStreamRunning(taskID string) bool {
+	a.streamsMux.Lock()
+	stream, ok := a.streams[taskID]
+	a.streamsMux.Unlock()
+	if !ok {
+		return false
+	}
+	stream.mux.Lock()
+	defer stream.mux.Unlock()
+	return !stream.finished
+}
+
+func (a *A2AServer) streamResumeTimeout() time.Duration {
+	if a.A2AServerConfig == nil || a.A2AServerConfig.StreamResumeTimeout == 0 {
+		return defaultStreamResumeTimeout * time.Second
+	}
+	return time.Duration(a.A2AServerConfig.StreamResumeTimeout) * time.Second
+}
+
+func (a *A2AServer) pushNotificationTimeout() time.Duration {
+	if a.A2AServerConfig.PushNotificationTimeout <= 0 {
+		return defaultPushNotificationTimeout * time.Second
+	}
+	return time.Duration(a.A2AServerConfig.PushNotificationTimeout) * time.Second
+}
+
+func (a *A2AServer) pushNotificationRetries() int {
+	if a.A2AServerConfig.PushNotificationRetries <= 0 {
+		return defaultPushNotificationRetries
+	}
+	return a.A2AServerConfig.PushNotificationRetries
+}
+
+// finishStream closes the stream after the processing of the task. If the client is
+// disconnected, the stream is kept with the result until the client resubscribes or the timeout
+func (a *A2AServer) finishStream(stream *taskStream) {
+	stream.mux.Lock()
+	stream.finished = true
+	waiting := stream.subscriber == nil && len(stream.pending) > 0
+	stream.mux.Unlock()
+
+	stream.close()
+	a.pushes.remove(stream.taskID)
+	if !waiting {
+		a.removeStream(stream.taskID)
+		return
+	}
+	time.AfterFunc(a.streamResumeTimeout(), func() {
+		a.removeStream(stream.taskID)
+	})
+}
+
+// detachStream is called when the connection of the subscriber is closed. The processing
+// continues, it is cancelled if the client does not resubscribe within the resume timeout
+func (a *A2AServer) detachStream(stream *taskStream, subscriber a2ataskmanager.TaskSubscriber) {
+	stream.mux.Lock()
+	if stream.finished || stream.subscriber != subscriber {
+		stream.mux.Unlock()
+		return
+	}
+	stream.subscriber = nil
+	stream.mux.Unlock()
+	subscriber.Close()
+
+	a.cancelUnlessResubscribed(stream)
+}
+
+// cancelUnlessResubscribed cancels the task of the disconnected client if the client does not
+// resubscribe within the resume timeout
+func (a *A2AServer) cancelUnlessResubscribed(stream *taskStream) {
+	timeout := a.streamResumeTimeout()
+	if timeout < 0 {
+		timeout = 0
+	}
+	a.Logger.Printf("The client of task %s is disconnected, waiting %s for it to resubscribe", stream.taskID, timeout)
+	time.AfterFunc(timeout, func() {
+		stream.mux.Lock()
+		gone := stream.subscriber == nil && !stream.finished
+		stream.mux.Unlock()
+		if gone {
+			a.Logger.Printf("The client of task %s did not resubscribe, cancelling the task", stream.taskID)
+			stream.cancel()
+		}
+	})
+}
+
+// resumeStream attaches a new subscriber of a client that resubscribed to a streaming task.
+// The events sent while the client was disconnected are delivered first
+func (a *A2AServer) resumeStream(ctx context.Context, taskID string) (a2ataskmanager.TaskSubscriber, error) {
+	a.streamsMux.Lock()
+	stream, ok := a.streams[taskID]
+	a.streamsMux.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("task not found: %s", taskID)
+	}
+
+	stream.mux.Lock()
+	subscriber := a2ataskmanager.NewMemoryTaskSubscriber(taskID, len(stream.pending)+resumedSubscriberBufferSize)
+	for _, event := range stream.pending {
+		subscriber.Send(event)
+	}
+	stream.pending = nil
+	previous := stream.subscriber
+	finished := stream.finished
+	if !finished {
+		stream.subscriber = subscriber
+	}
+	stream.mux.Unlock()
+
+	if previous != nil {
+		// The client opened a new connection before the old one was noticed as closed
+		previous.Close()
+	}
+	a.Logger.Printf("The client resubscribed to task %s", taskID)
+
+	if finished {
+		// Only the final events are left, the stream ends after them
+		subscriber.Close()
+		a.removeStream(taskID)
+		return subscriber, nil
+	}
+	context.AfterFunc(ctx, func() {
+		a.detachStream(stream, subscriber)
+	})
+	return subscriber, nil
+}
+
+// cancelStream stops the processing of a streaming task. The client gets the final
+// canceled status before the stream is closed. Returns false if the task is not in progress
+func (a *A2AServer) cancelStream(taskID string) bool {
+	a.streamsMux.Lock()
+	stream, ok := a.streams[taskID]
+	a.streamsMux.Unlock()
+	if !ok {
+		return false
+	}
+	a.statusCanceled(stream)
+	stream.cancel()
+	return true
+}
+
+// streamingTaskManager stops the prompt of a task cancelled with tasks/cancel and lets a
+// client resubscribe to a streaming task. The memory task manager only removes a cancelled
+// task and does not know about the events of a streaming task.
+type streamingTaskManager struct {
+	a2ataskmanager.TaskManager
+	server *A2AServer
+}
+
+func (m *streamingTaskManager) OnResubscribe(ctx context.Context, params a2aprotocol.TaskIDParams) (<-chan a2aprotocol.StreamingMessageEvent, error) {
+	subscriber, err := m.server.resumeStream(ctx, params.ID)
 	if err != nil {
-		a.Logger.Fatalf("Failed to report failed event: %v", err)
+		return nil, err
 	}
+	return subscriber.Channel(), nil
+}
+
+// OnPushNotificationSet starts posting the events of a task in progress to the webhook of the client
+func (m *streamingTaskManager) OnPushNotificationSet(ctx context.Context, params a2aprotocol.TaskPushNotificationConfig) (*a2aprotocol.TaskPushNotificationConfig, error) {
+	if m.server.pushes == nil {
+		return nil, fmt.Errorf("push notifications are not enabled")
+	}
+	if !m.server.isStreamRunning(params.TaskID) {
+		return nil, fmt.Errorf("task %s is not in progress", params.TaskID)
+	}
+	if err := m.server.pushes.setConfig(params.TaskID, params.PushNotificationConfig); err != nil {
+		return nil, err
+	}
+	return m.TaskManager.OnPushNotificationSet(ctx, params)
+}
+
+func (m *streamingTaskManager) OnCancelTask(ctx context.Context, params a2aprotocol.TaskIDParams) (*a2aprotocol.Task, error) {
+	if m.server.cancelStream(params.ID) {
+		m.server.Logger.Printf("Task %s is cancelled by the client", params.ID)
+	}
+	return m.TaskManager.OnCancelTask(ctx, params)
 }
 
 // handleNotificationSubscription handles persistent notification subscription requests
@@ -493,6 +1109,48 @@ func (a *A2AServer) BroadcastAgentMessage(message string) {
 	}
 }
 
+// BroadcastNotificationFeedback broadcasts a feedback message produced while processing
+// a notification to all subscribed A2A clients, together with the notification origin
+func (a *A2AServer) BroadcastNotificationFeedback(feedback cleverchatty.NotificationFeedback) {
+	a.notificationSubsMux.RLock()
+	defer a.notificationSubsMux.RUnlock()
+
+	if len(a.notificationSubs) == 0 {
+		return
+	}
+
+	a.Logger.Printf("Broadcasting notification feedback from %s: %s to %d subscribers", feedback.ServerName, feedback.Method, len(a.notificationSubs))
+
+	for contextID, subscriber := range a.notificationSubs {
+		feedbackEvent := a2aprotocol.StreamingMessageEvent{
+			Result: &a2aprotocol.TaskStatusUpdateEvent{
+				TaskID:    "notification_feedback_" + uuid.New().String(),
+				ContextID: contextID,
+				Kind:      "status-update",
+				Status: a2aprotocol.TaskStatus{
+					State: a2aprotocol.TaskStateWorking,
+					Message: &a2aprotocol.Message{
+						MessageID: uuid.New().String(),
+						Kind:      "message",
+						Role:      a2aprotocol.MessageRoleAgent,
+						Parts: []a2aprotocol.Part{
+							a2aprotocol.NewTextPart("notification_feedback"),
+							a2aprotocol.NewTextPart(feedback.ServerName),
+							a2aprotocol.NewTextPart(feedback.Method),
+							a2aprotocol.NewTextPart(feedback.Message),
+						},
+					},
+				},
+			},
+		}
+
+		err := subscriber.Send(feedbackEvent)
+		if err != nil {
+			a.Logger.Printf("Failed to send notification feedback to context %s: %v", contextID, err)
+		}
+	}
+}
+
 // BroadcastNotification broadcasts a notification to all subscribed A2A clients
 func (a *A2AServer) BroadcastNotification(notification cleverchatty.Notification) {
 	a.notificationSubsMux.RLock()
@@ -539,6 +1197,10 @@ func (a *A2AServer) BroadcastNotification(notification cleverchatty.Notification
 }
 
 func (a *A2AServer) Start() error {
+	if a.A2AServerConfig.PushNotifications {
+		a.pushes = newPushNotifier(a.pushNotificationTimeout(), a.pushNotificationRetries(), a.A2AServerConfig.PushNotificationAllowedHosts, a.Logger)
+	}
+
 	// Create task manager, inject processor
 	taskManager, err := a2ataskmanager.NewMemoryTaskManager(a)
 	if err != nil {
@@ -548,7 +1210,7 @@ func (a *A2AServer) Start() error {
 	// Create the server with no timeouts for long-lived notification streams
 	a.server, err = a2aserver.NewA2AServer(
 		a.agentCard(),
-		taskManager,
+		&streamingTaskManager{TaskManager: taskManager, server: a},
 		a2aserver.WithReadTimeout(0),  // No read timeout for persistent connections
 		a2aserver.WithWriteTimeout(0), // No write timeout for streaming responses
 		a2aserver.WithIdleTimeout(0),  // No idle timeout for long-lived connections
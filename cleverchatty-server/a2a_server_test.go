@@ -0,0 +1,529 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cleverchatty "github.com/gelembjuk/cleverchatty/core"
+	a2aprotocol "trpc.group/trpc-go/trpc-a2a-go/protocol"
+	a2ataskmanager "trpc.group/trpc-go/trpc-a2a-go/taskmanager"
+)
+
+// closedSubscriber is a task subscriber of a client that is gone
+type closedSubscriber struct {
+	sent int
+}
+
+func (s *closedSubscriber) Send(event a2aprotocol.StreamingMessageEvent) error {
+	s.sent++
+	return errors.New("subscriber is closed")
+}
+
+func (s *closedSubscriber) Channel() <-chan a2aprotocol.StreamingMessageEvent {
+	return nil
+}
+
+func (s *closedSubscriber) Closed() bool {
+	return true
+}
+
+func (s *closedSubscriber) Close() {}
+
+// recordingSubscriber is a task subscriber of a connected client
+type recordingSubscriber struct {
+	events []a2aprotocol.StreamingMessageEvent
+}
+
+func (s *recordingSubscriber) Send(event a2aprotocol.StreamingMessageEvent) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *recordingSubscriber) Channel() <-chan a2aprotocol.StreamingMessageEvent {
+	return nil
+}
+
+func (s *recordingSubscriber) Closed() bool {
+	return false
+}
+
+func (s *recordingSubscriber) Close() {}
+
+func TestStatusUpdateToClosedSubscriber(t *testing.T) {
+	server := &A2AServer{
+		Logger:          log.New(io.Discard, "", 0),
+		A2AServerConfig: &cleverchatty.A2AServerConfig{StreamResumeTimeout: -1},
+	}
+	subscriber := &closedSubscriber{}
+	stream := newTaskStream("task", "context", subscriber)
+	defer stream.close()
+
+	// Failing to send must not stop the server, the client is detached instead
+	server.statusUpdate("thinking", "Thinking...", "", stream)
+
+	// Nothing else is sent to the gone client
+	server.statusUpdate("tool_calling", "Using tool", "tool", stream)
+	server.statusFailed(errors.New("failed"), stream)
+
+	if subscriber.sent != 1 {
+		t.Errorf("Expected a single send attempt, got %d", subscriber.sent)
+	}
+
+	// The client does not resubscribe
+	deadline := time.Now().Add(time.Second)
+	for stream.ctx.Err() == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if stream.ctx.Err() == nil {
+		t.Errorf("Expected the stream to be cancelled without the resubscription")
+	}
+}
+
+func TestSlowResumedSubscriberDetached(t *testing.T) {
+	server := &A2AServer{
+		Logger:          log.New(io.Discard, "", 0),
+		A2AServerConfig: &cleverchatty.A2AServerConfig{StreamResumeTimeout: 60},
+		streams:         map[string]*taskStream{},
+	}
+	subscriber := &recordingSubscriber{}
+	stream := newTaskStream("task", "context", subscriber)
+	server.addStream(stream)
+	defer stream.close()
+	server.detachStream(stream, subscriber)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	resumed, err := server.resumeStream(ctx, "task")
+	if err != nil {
+		t.Fatalf("Failed to resume the stream: %v", err)
+	}
+
+	// The client does not read, the buffer of the resubscribed client overflows
+	total := resumedSubscriberBufferSize + 5
+	for i := 0; i < total; i++ {
+		server.statusUpdate("thinking", "Thinking...", "", stream)
+	}
+	if stream.ctx.Err() != nil {
+		t.Fatalf("Expected the task to continue after the overflow")
+	}
+	if !resumed.Closed() {
+		t.Fatalf("Expected the slow client to be detached")
+	}
+
+	// The events that did not fit are kept for the next resubscription
+	again, err := server.resumeStream(ctx, "task")
+	if err != nil {
+		t.Fatalf("Failed to resume the stream again: %v", err)
+	}
+	if kept := len(again.Channel()); kept != total-resumedSubscriberBufferSize {
+		t.Errorf("Expected %d kept events, got %d", total-resumedSubscriberBufferSize, kept)
+	}
+}
+
+func TestAccessLogEntry(t *testing.T) {
+	entry := accessLogEntry{
+		AgentID:      "agent 1",
+		ContextID:    "ctx",
+		Streaming:    true,
+		PromptLength: 5,
+		started:      time.Now().Add(-1500 * time.Millisecond),
+	}
+	entry = entry.finish(cleverchatty.PromptStats{Model: "mock:mock", ToolCalls: 2, InputTokens: 30, OutputTokens: 4}, "hello!", nil)
+
+	line := entry.String()
+	for _, expected := range []string{
+		`agent_id="agent 1"`, "context_id=ctx", "streaming=true", "status=ok", "prompt_length=5",
+		"response_length=6", "latency=1.5", "tool_calls=2", "model=mock:mock", "input_tokens=30", "output_tokens=4",
+	} {
+		if !strings.Contains(line, expected) {
+			t.Errorf("Expected '%s' in the access log line, got %s", expected, line)
+		}
+	}
+
+	entry = entry.finish(cleverchatty.PromptStats{}, "", errors.New("failed"))
+	if !strings.Contains(entry.String(), "status=error") {
+		t.Errorf("Expected the failed status, got %s", entry.String())
+	}
+}
+
+func TestCancelStream(t *testing.T) {
+	server := &A2AServer{Logger: log.New(io.Discard, "", 0), streams: map[string]*taskStream{}}
+	subscriber := &recordingSubscriber{}
+	stream := newTaskStream("task", "context", subscriber)
+	defer stream.close()
+
+	if server.cancelStream("task") {
+		t.Fatalf("Expected an unknown task not to be cancelled")
+	}
+
+	server.addStream(stream)
+	if !server.cancelStream("task") {
+		t.Fatalf("Expected the task to be cancelled")
+	}
+	if stream.ctx.Err() == nil {
+		t.Errorf("Expected the prompt context of the task to be cancelled")
+	}
+	if len(subscriber.events) != 1 {
+		t.Fatalf("Expected the canceled status to be sent, got %d events", len(subscriber.events))
+	}
+	update, ok := subscriber.events[0].Result.(*a2aprotocol.TaskStatusUpdateEvent)
+	if !ok || update.Status.State != a2aprotocol.TaskStateCanceled || !update.Final {
+		t.Errorf("Expected the final canceled status, got %+v", subscriber.events[0].Result)
+	}
+
+	// Nothing is sent after the cancellation
+	server.statusUpdate("thinking", "Thinking...", "", stream)
+	if len(subscriber.events) != 1 {
+		t.Errorf("Expected no events after the cancellation, got %d", len(subscriber.events))
+	}
+}
+
+func TestResumeStream(t *testing.T) {
+	server := &A2AServer{
+		Logger:          log.New(io.Discard, "", 0),
+		A2AServerConfig: &cleverchatty.A2AServerConfig{StreamResumeTimeout: 60},
+		streams:         map[string]*taskStream{},
+	}
+	subscriber := &recordingSubscriber{}
+	stream := newTaskStream("task", "context", subscriber)
+	server.addStream(stream)
+
+	if _, err := server.resumeStream(context.Background(), "unknown"); err == nil {
+		t.Fatalf("Expected an error for an unknown task")
+	}
+
+	// The connection drops, the processing continues and the events are kept
+	server.detachStream(stream, subscriber)
+	server.statusUpdate("thinking", "Thinking...", "", stream)
+	if stream.ctx.Err() != nil {
+		t.Fatalf("Expected the task to wait for the client")
+	}
+	if len(subscriber.events) != 0 {
+		t.Fatalf("Expected no events sent to the disconnected client, got %d", len(subscriber.events))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	resumed, err := server.resumeStream(ctx, "task")
+	if err != nil {
+		t.Fatalf("Failed to resume the stream: %v", err)
+	}
+	server.statusUpdate("tool_calling", "Using tool", "tool", stream)
+	if len(resumed.Channel()) != 2 {
+		t.Fatalf("Expected the kept and the new event, got %d", len(resumed.Channel()))
+	}
+
+	// The task finishes while the client is disconnected again
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for !resumed.Closed() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	server.statusUpdate("response", "Done", "", stream)
+	server.finishStream(stream)
+
+	final, err := server.resumeStream(context.Background(), "task")
+	if err != nil {
+		t.Fatalf("Expected the finished task to wait for the client: %v", err)
+	}
+	if len(final.Channel()) != 1 || !final.Closed() {
+		t.Errorf("Expected the last event and the closed stream, got %d events", len(final.Channel()))
+	}
+	if _, err := server.resumeStream(context.Background(), "task"); err == nil {
+		t.Errorf("Expected the task to be removed after the client got the result")
+	}
+}
+
+func TestOversizedMetadataRejected(t *testing.T) {
+	server := &A2AServer{
+		Logger:          log.New(io.Discard, "", 0),
+		A2AServerConfig: &cleverchatty.A2AServerConfig{},
+	}
+	text := a2aprotocol.NewTextPart("Hello")
+	message := a2aprotocol.NewMessage(a2aprotocol.MessageRoleUser, []a2aprotocol.Part{&text})
+	message.Metadata = map[string]any{"agent_id": strings.Repeat("a", 1024*1024)}
+
+	_, err := server.ProcessMessage(context.Background(), message, a2ataskmanager.ProcessOptions{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "metadata agent_id is too large") {
+		t.Fatalf("Expected the oversized metadata to be rejected, got %v", err)
+	}
+
+	message.Metadata = map[string]any{"skip_rag": "yes"}
+	if _, err := server.ProcessMessage(context.Background(), message, a2ataskmanager.ProcessOptions{}, nil); err == nil {
+		t.Errorf("Expected the metadata of a wrong type to be rejected")
+	}
+
+	if _, err := server.sanitizeMetadata(map[string]any{"tool_context": "tenant_id=acme"}); err == nil {
+		t.Errorf("Expected the tool context that is not an object to be rejected")
+	}
+	if _, err := server.sanitizeMetadata(map[string]any{"tool_context": map[string]any{"data": strings.Repeat("x", 8*1024)}}); err == nil {
+		t.Errorf("Expected the oversized tool context to be rejected")
+	}
+
+	metadata, err := server.sanitizeMetadata(map[string]any{"agent_id": "user1", "skip_rag": true, "unknown": strings.Repeat("x", 1024)})
+	if err != nil {
+		t.Fatalf("Failed to sanitize the metadata: %v", err)
+	}
+	if len(metadata) != 2 || metadata["agent_id"] != "user1" || metadata["skip_rag"] != true {
+		t.Errorf("Expected only the known keys, got %v", metadata)
+	}
+}
+
+func TestFilePartNotAllowed(t *testing.T) {
+	config := &cleverchatty.CleverChattyConfig{
+		Model:             "mock:mock",
+		WorkDir:           t.TempDir(),
+		ToolsServers:      map[string]cleverchatty.ServerConfigWrapper{},
+		AttachmentsConfig: cleverchatty.AttachmentsConfig{AllowedMimeTypes: []string{"text/*"}},
+	}
+	logger := log.New(io.Discard, "", 0)
+	server := &A2AServer{
+		Logger:          logger,
+		SessionsManager: cleverchatty.NewSessionManager(config, context.Background(), logger),
+	}
+	session, err := server.SessionsManager.GetOrCreateSession("context", "client")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	defer server.SessionsManager.FinishSession("context")
+
+	filePart := func(name string, mimeType string) a2aprotocol.Part {
+		// Parts of received messages are pointers
+		part := a2aprotocol.NewFilePartWithBytes(name, mimeType, base64.StdEncoding.EncodeToString([]byte("content")))
+		return &part
+	}
+	textPart := a2aprotocol.NewTextPart("Read the notes")
+	message := a2aprotocol.NewMessage(a2aprotocol.MessageRoleUser, []a2aprotocol.Part{
+		&textPart,
+		filePart("notes.txt", "text/plain"),
+	})
+	if msg, err := server.promptMessage(session, message); err != nil || len(msg.Content) != 2 {
+		t.Errorf("Expected the allowed file to be attached, got %+v, %v", msg.Content, err)
+	}
+
+	message.Parts = append(message.Parts, filePart("run.exe", "application/octet-stream"))
+	if _, err := server.promptMessage(session, message); err == nil || !strings.Contains(err.Error(), "run.exe") {
+		t.Errorf("Expected the message with the file that is not allowed to be rejected, got %v", err)
+	}
+}
+
+func TestPushNotifications(t *testing.T) {
+	type delivery struct {
+		kind          string
+		state         string
+		token         string
+		authorization string
+	}
+	deliveries := make(chan delivery, 10)
+	var requests atomic.Int32
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			// The first delivery fails and is retried
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var event struct {
+			Kind   string `json:"kind"`
+			Status struct {
+				State string `json:"state"`
+			} `json:"status"`
+		}
+		json.NewDecoder(r.Body).Decode(&event)
+		deliveries <- delivery{event.Kind, event.Status.State, r.Header.Get("X-A2A-Notification-Token"), r.Header.Get("Authorization")}
+	}))
+	defer webhook.Close()
+
+	server := &A2AServer{
+		Logger:  log.New(io.Discard, "", 0),
+		streams: map[string]*taskStream{},
+		pushes:  newPushNotifier(time.Second, 2, []string{"127.0.0.1"}, log.New(io.Discard, "", 0)),
+	}
+	server.pushes.retryDelay = time.Millisecond
+
+	if err := server.pushes.setConfig("task", a2aprotocol.PushNotificationConfig{URL: "file:///tmp/hook"}); err == nil {
+		t.Errorf("Expected the webhook URL of a wrong scheme to be rejected")
+	}
+	credentials := "secret"
+	err := server.pushes.setConfig("task", a2aprotocol.PushNotificationConfig{
+		URL:            webhook.URL,
+		Token:          "task-token",
+		Authentication: &a2aprotocol.AuthenticationInfo{Schemes: []string{"Bearer"}, Credentials: &credentials},
+	})
+	if err != nil {
+		t.Fatalf("Failed to set the push notification config: %v", err)
+	}
+
+	// A task of a client that does not keep a stream open
+	stream := newTaskStream("task", "context", nil)
+	handle := &stateRecordingHandler{}
+	stream.handle = handle
+	server.addStream(stream)
+	server.statusUpdate(cleverchatty.CallbackCodeStartedThinking, "Thinking...", "", stream)
+	server.statusFailed(errors.New("failed"), stream)
+	server.finishStream(stream)
+
+	expected := []string{string(a2aprotocol.TaskStateWorking), string(a2aprotocol.TaskStateFailed)}
+	for _, state := range expected {
+		select {
+		case d := <-deliveries:
+			if d.kind != "status-update" || d.state != state {
+				t.Errorf("Expected the %s status update, got %+v", state, d)
+			}
+			if d.token != "task-token" || d.authorization != "Bearer secret" {
+				t.Errorf("Expected the token and the credentials of the client, got %+v", d)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("The %s status update is not delivered", state)
+		}
+	}
+	if requests.Load() != 3 {
+		t.Errorf("Expected the failed delivery to be retried once, got %d requests", requests.Load())
+	}
+
+	server.pushes.mux.Lock()
+	left := len(server.pushes.targets)
+	server.pushes.mux.Unlock()
+	if left != 0 {
+		t.Errorf("Expected the notifications of the finished task to stop, got %d webhooks", left)
+	}
+	if handle.state != a2aprotocol.TaskStateFailed {
+		t.Errorf("Expected the final state to be stored in the task, got '%s'", handle.state)
+	}
+}
+
+// stateRecordingHandler is a task handler remembering the last state of the task
+type stateRecordingHandler struct {
+	a2ataskmanager.TaskHandler
+	state a2aprotocol.TaskState
+}
+
+func (h *stateRecordingHandler) UpdateTaskState(taskID *string, state a2aprotocol.TaskState, message *a2aprotocol.Message) error {
+	h.state = state
+	return nil
+}
+
+func TestPushNotificationTargets(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+
+	// Only the allowed hosts are accepted
+	pushes := newPushNotifier(time.Second, 2, []string{"hooks.example.com"}, logger)
+	if err := pushes.validatePushNotificationConfig(a2aprotocol.PushNotificationConfig{URL: "https://HOOKS.example.com/task"}); err != nil {
+		t.Errorf("Expected the allowed host to be accepted, got %v", err)
+	}
+	if err := pushes.validatePushNotificationConfig(a2aprotocol.PushNotificationConfig{URL: "http://169.254.169.254/latest"}); err == nil {
+		t.Errorf("Expected the host that is not allowed to be rejected")
+	}
+
+	// Without the allowed hosts, internal addresses are refused when connecting
+	var requests atomic.Int32
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer webhook.Close()
+
+	pushes = newPushNotifier(time.Second, 2, nil, logger)
+	config := a2aprotocol.PushNotificationConfig{URL: webhook.URL}
+	if err := pushes.validatePushNotificationConfig(config); err != nil {
+		t.Fatalf("Expected any host to be accepted, got %v", err)
+	}
+	retry, err := pushes.post(config, []byte("{}"))
+	if !errors.Is(err, errForbiddenPushTarget) {
+		t.Errorf("Expected the loopback webhook to be refused, got %v", err)
+	}
+	if retry {
+		t.Errorf("Expected the refused webhook not to be retried")
+	}
+	if requests.Load() != 0 {
+		t.Errorf("Expected no requests to the loopback webhook, got %d", requests.Load())
+	}
+}
+
+func TestPushNotificationInternalAddresses(t *testing.T) {
+	tests := []struct {
+		address string
+		refused bool
+	}{
+		{"127.0.0.1:80", true},
+		{"10.1.2.3:80", true},
+		{"169.254.169.254:80", true},
+		{"100.64.0.1:80", true},
+		{"100.127.255.254:80", true},
+		{"0.1.2.3:80", true},
+		{"[::1]:80", true},
+		{"100.128.0.1:80", false},
+		{"93.184.216.34:443", false},
+	}
+	for _, test := range tests {
+		err := refuseInternalAddress("tcp", test.address, nil)
+		if refused := errors.Is(err, errForbiddenPushTarget); refused != test.refused {
+			t.Errorf("Expected %s refused %v, got %v", test.address, test.refused, err)
+		}
+	}
+}
+
+func TestPushNotificationFinalEventDelivered(t *testing.T) {
+	pushes := newPushNotifier(time.Second, 2, nil, log.New(io.Discard, "", 0))
+	// Nothing is delivered, so the queue fills up
+	target := &pushTarget{events: make(chan any, 2)}
+	pushes.targets["task"] = target
+
+	statusEvent := func(state a2aprotocol.TaskState, final bool) a2aprotocol.StreamingMessageEvent {
+		return a2aprotocol.StreamingMessageEvent{Result: &a2aprotocol.TaskStatusUpdateEvent{
+			TaskID: "task",
+			Status: a2aprotocol.TaskStatus{State: state},
+			Final:  final,
+		}}
+	}
+	for i := 0; i < 3; i++ {
+		pushes.notify("task", statusEvent(a2aprotocol.TaskStateWorking, false))
+	}
+	pushes.notify("task", statusEvent(a2aprotocol.TaskStateCompleted, true))
+
+	events := []*a2aprotocol.TaskStatusUpdateEvent{}
+	for event := range target.events {
+		events = append(events, event.(*a2aprotocol.TaskStatusUpdateEvent))
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 queued events, got %d", len(events))
+	}
+	if last := events[1]; !last.Final || last.Status.State != a2aprotocol.TaskStateCompleted {
+		t.Errorf("Expected the final event to be queued last, got %+v", last)
+	}
+	if _, ok := pushes.targets["task"]; ok {
+		t.Errorf("Expected the notifications of the task to stop after the final event")
+	}
+}
+
+func TestNotificationForwarder(t *testing.T) {
+	forwarder := newNotificationForwarder(cleverchatty.ParseNotificationFilter("!task/*"))
+	progress := func(value float64) cleverchatty.Notification {
+		return cleverchatty.Notification{
+			ServerName: "worker",
+			Method:     "notifications/progress",
+			Params:     map[string]interface{}{"progress": value, "total": float64(10)},
+		}
+	}
+
+	if forwarder.allow(cleverchatty.Notification{ServerName: "worker", Method: "task/started"}) {
+		t.Errorf("Expected the notification hidden by the filter not to be forwarded")
+	}
+	if !forwarder.allow(progress(1)) {
+		t.Errorf("Expected the first progress notification to be forwarded")
+	}
+	if forwarder.allow(progress(5)) {
+		t.Errorf("Expected the repeated progress notification to be throttled")
+	}
+	if !forwarder.allow(progress(10)) {
+		t.Errorf("Expected the final progress notification to be forwarded")
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	cleverchatty "github.com/gelembjuk/cleverchatty/core"
+)
+
+// maxBatchPrompts is the number of prompts accepted in one batch request
+const maxBatchPrompts = 100
+
+// maxBatchRequestBytes limits the size of the body of a batch request
+const maxBatchRequestBytes = 10 * 1024 * 1024
+
+// AdminServer serves HTTP endpoints for operators to inspect the running daemon
+type AdminServer struct {
+	Config          *cleverchatty.AdminServerConfig
+	Logger          *log.Logger
+	SessionsManager *cleverchatty.SessionManager
+	httpServer      *http.Server
+}
+
+func NewAdminServer(
+	config *cleverchatty.AdminServerConfig,
+	sessionsManager *cleverchatty.SessionManager,
+	logger *log.Logger,
+) *AdminServer {
+	return &AdminServer{
+		Config:          config,
+		Logger:          logger,
+		SessionsManager: sessionsManager,
+	}
+}
+
+func (s *AdminServer) Start() error {
+	if s.Config.AuthToken == "" {
+		return fmt.Errorf("auth_token is required for the admin server")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tools", s.requireAuth(s.handleTools))
+	mux.HandleFunc("/memory", s.requireAuth(s.handleMemory))
+	mux.HandleFunc("/toolstats", s.requireAuth(s.handleToolStats))
+	mux.HandleFunc("/health/llm", s.requireAuth(s.handleProviderHealth))
+	mux.HandleFunc("/v1/batch", s.requireAuth(s.handleBatch))
+
+	s.httpServer = &http.Server{
+		Handler:      mux,
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 60 * time.Second,
+	}
+
+	listener, err := net.Listen("tcp", s.Config.ListenHost)
+	if err != nil {
+		return fmt.Errorf("failed to start listener: %w", err)
+	}
+	s.Logger.Printf("Admin server starting on %s", s.Config.ListenHost)
+
+	go func() {
+		if err := s.httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			s.Logger.Printf("Admin server error: %v", err)
+		}
+	}()
+
+	if s.Config.CheckProvider {
+		// The daemon starts without waiting, the result is logged and cached for /health/llm
+		go s.logProviderHealth()
+	}
+	return nil
+}
+
+func (s *AdminServer) logProviderHealth() {
+	health := s.SessionsManager.GetProviderHealth(context.Background())
+	if !health.Reachable {
+		s.Logger.Printf("WARNING: LLM provider of model %s is not reachable: %s", health.Model, health.Error)
+		return
+	}
+	s.Logger.Printf("LLM provider of model %s is reachable, responded in %d ms", health.Model, health.LatencyMs)
+}
+
+func (s *AdminServer) Stop() error {
+	if s.httpServer == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.httpServer.Shutdown(ctx)
+}
+
+// requireAuth checks the bearer token before calling the handler
+func (s *AdminServer) requireAuth(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.Config.AuthToken)) != 1 {
+			s.Logger.Printf("Admin request %s %s from %s rejected: unauthorized", r.Method, r.URL.Path, r.RemoteAddr)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// handleTools returns the merged list of tools with the servers connection status
+func (s *AdminServer) handleTools(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	report, err := s.SessionsManager.GetToolsReport()
+	if err != nil {
+		s.Logger.Printf("Failed to get tools report: %v", err)
+		s.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	s.writeJSON(w, http.StatusOK, report)
+}
+
+// handleMemory returns the number of messages waiting to be sent to the memory server
+func (s *AdminServer) handleMemory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, s.SessionsManager.GetMemoryQueueStats())
+}
+
+// handleToolStats returns the tool calls statistics of all sessions, DELETE clears them
+func (s *AdminServer) handleToolStats(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.writeJSON(w, http.StatusOK, s.SessionsManager.GetToolStats())
+	case http.MethodDelete:
+		s.SessionsManager.ResetToolStats()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleProviderHealth reports whether the LLM provider is reachable. Responds with 503
+// when it is not, so orchestrators do not route traffic to the daemon
+func (s *AdminServer) handleProviderHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// The result is cached, it must not be the error of a client that disconnected
+	health := s.SessionsManager.GetProviderHealth(context.WithoutCancel(r.Context()))
+	status := http.StatusOK
+	if !health.Reachable {
+		status = http.StatusServiceUnavailable
+	}
+	s.writeJSON(w, status, health)
+}
+
+// handleBatch processes an array of independent prompts, each in a fresh session, and returns
+// the array of results in the same order. Failed prompts have the error in their result
+func (s *AdminServer) handleBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var prompts []cleverchatty.BatchPrompt
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchRequestBytes)).Decode(&prompts); err != nil {
+		s.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "expected a JSON array of prompts: " + err.Error()})
+		return
+	}
+	if len(prompts) == 0 || len(prompts) > maxBatchPrompts {
+		s.writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("a batch must have from 1 to %d prompts", maxBatchPrompts)})
+		return
+	}
+
+	// A batch can take longer than the write timeout of the admin requests
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		s.Logger.Printf("Failed to remove the write deadline of the batch request: %v", err)
+	}
+	s.Logger.Printf("Batch of %d prompts started", len(prompts))
+	results := s.SessionsManager.PromptBatch(r.Context(), prompts, s.Config.BatchConcurrency)
+
+	failed := 0
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+		}
+	}
+	s.Logger.Printf("Batch of %d prompts finished, %d failed", len(prompts), failed)
+	s.writeJSON(w, http.StatusOK, results)
+}
+
+func (s *AdminServer) writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		s.Logger.Printf("Failed to write admin response: %v", err)
+	}
+}
@@ -87,12 +87,45 @@ var versionCmd = &cobra.Command{
 	},
 }
 
+var migrateConfigCmd = &cobra.Command{
+	Use:          "migrate-config",
+	Short:        "Migrate the config file to the current schema",
+	Long:         `Rewrite a config file that uses the deprecated "mcpServers" key into the current "tools_servers" schema. The original file is kept with a ".bak" suffix.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return migrateConfig()
+	},
+}
+
+var listModelsCmd = &cobra.Command{
+	Use:          "list-models",
+	Short:        "List models available from the configured providers",
+	Long:         `Query the models endpoints of the providers that have credentials in the config file (and the local Ollama server) and print the models in the provider:model format used by the "model" option.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return listModels()
+	},
+}
+
+var doctorCmd = &cobra.Command{
+	Use:          "doctor",
+	Short:        "Check the config, the provider and the tools servers",
+	Long:         `Load the config file, check the credentials of the provider and connect to every tools server. Prints a pass/fail report per component and exits with a non-zero code if any check fails.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return doctor()
+	},
+}
+
 func init() {
 	rootCmd.AddCommand(runCmd)
 	rootCmd.AddCommand(startCmd)
 	rootCmd.AddCommand(stopCmd)
 	rootCmd.AddCommand(reloadCmd)
 	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(migrateConfigCmd)
+	rootCmd.AddCommand(listModelsCmd)
+	rootCmd.AddCommand(doctorCmd)
 
 	rootCmd.PersistentFlags().
 		StringVarP(&directoryPath, "directory", "d", "", "Path to the directory with config files and data")
@@ -235,6 +268,7 @@ func runServer() error {
 			commonContextCancel()
 			return fmt.Errorf("failed to initialize A2A server: %v", err)
 		}
+		a2aServer.MaxPromptBytes = config.MaxPromptBytes
 		err = a2aServer.Start()
 		if err != nil {
 			commonContextCancel()
@@ -254,6 +288,11 @@ func runServer() error {
 			a2aServer.BroadcastAgentMessage(message)
 		})
 		logger.Println("Agent message broadcasting to A2A clients enabled.")
+
+		// Set notification feedback callback to push attributed feedback to A2A clients
+		sessions_manager.SetNotificationFeedbackCallback(func(feedback cleverchatty.NotificationFeedback) {
+			a2aServer.BroadcastNotificationFeedback(feedback)
+		})
 	}
 
 	// Initialize Reverse MCP connector if enabled
@@ -282,7 +321,40 @@ func runServer() error {
 		logger.Println("Reverse MCP connector started successfully.")
 	}
 
+	var adminServer *AdminServer
+	adminServer = nil
+
+	if config.AdminServerConfig.Enabled {
+		adminServer = NewAdminServer(
+			&config.AdminServerConfig,
+			sessions_manager,
+			logger,
+		)
+		err = adminServer.Start()
+		if err != nil {
+			if reverseMCPConnector != nil {
+				reverseMCPConnector.Stop()
+			}
+			if a2aServer != nil {
+				a2aServer.Stop()
+			}
+			commonContextCancel()
+			return fmt.Errorf("failed to start admin server: %v", err)
+		}
+		logger.Println("Admin server started successfully.")
+	}
+
 	shutDown := func() {
+		if adminServer != nil {
+			logger.Println("Stopping admin server...")
+			err := adminServer.Stop()
+			if err != nil {
+				logger.Printf("Error stopping admin server: %v", err)
+			} else {
+				logger.Println("Admin server stopped successfully.")
+			}
+			adminServer = nil
+		}
 		if reverseMCPConnector != nil {
 			logger.Println("Stopping Reverse MCP connector...")
 			err := reverseMCPConnector.Stop()
@@ -316,12 +388,105 @@ func runServer() error {
 			return nil
 		case syscall.SIGHUP:
 			fmt.Println("Reloading config...")
-			// Reload logic here
+			reloadToolsServers(sessions_manager, reverseMCPConnector, logger)
 		}
 	}
 	return nil
 }
 
+// reloadToolsServers reads the config file again and applies its tools servers to the running
+// sessions without a restart. Other settings are applied on the next start
+func reloadToolsServers(sessionsManager *cleverchatty.SessionManager, reverseMCPConnector *ReverseMCPConnector, logger *log.Logger) {
+	// The working directory is the config directory, see loadConfigAndLogger
+	config, err := cleverchatty.LoadConfig(configFileName)
+	if err != nil {
+		logger.Printf("Failed to reload config, the current one is kept: %v", err)
+		return
+	}
+	if err := cleverchatty.ValidateToolsServersConfig(config.ToolsServers); err != nil {
+		logger.Printf("Reloaded tools servers config is invalid, the current one is kept: %v", err)
+		return
+	}
+	if reverseMCPConnector != nil {
+		reverseMCPConnector.SetToolsServers(config.ToolsServers)
+	}
+	if err := sessionsManager.ApplyToolsServersConfig(config.ToolsServers); err != nil {
+		logger.Printf("Tools servers config reloaded with errors: %v", err)
+		return
+	}
+	logger.Println("Tools servers config reloaded. Other settings are applied on restart")
+}
+
+func migrateConfig() error {
+	configFile := directoryPath + "/" + configFileName
+	if _, err := os.Stat(configFile); os.IsNotExist(err) {
+		return fmt.Errorf("config file not found: %s", configFile)
+	}
+	migrated, err := cleverchatty.MigrateConfigFile(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to migrate config: %v", err)
+	}
+	if !migrated {
+		fmt.Println("Config file already uses the current schema, nothing to migrate.")
+		return nil
+	}
+	fmt.Printf("Config file %s migrated. The original is saved as %s.bak\n", configFile, configFile)
+	return nil
+}
+
+func listModels() error {
+	configFile := directoryPath + "/" + configFileName
+	if _, err := os.Stat(configFile); os.IsNotExist(err) {
+		return fmt.Errorf("config file not found: %s", configFile)
+	}
+	config, err := cleverchatty.LoadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
+	for _, result := range cleverchatty.ListModels(context.Background(), *config) {
+		if result.Err != nil {
+			fmt.Printf("%s: failed to list models: %v\n", result.Provider, result.Err)
+			continue
+		}
+		fmt.Printf("%s: %d models\n", result.Provider, len(result.Models))
+		for _, model := range result.Models {
+			fmt.Printf("  %s\n", model)
+		}
+	}
+	return nil
+}
+
+func doctor() error {
+	configFile := directoryPath + "/" + configFileName
+	if _, err := os.Stat(configFile); os.IsNotExist(err) {
+		return fmt.Errorf("config file not found: %s", configFile)
+	}
+	config, err := cleverchatty.LoadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
+	fmt.Printf("[PASS] config: %s loaded\n", configFile)
+
+	// relative paths in the config are relative to the directory
+	if err = os.Chdir(directoryPath); err != nil {
+		return fmt.Errorf("error changing working directory to %s: %v", directoryPath, err)
+	}
+
+	failed := 0
+	for _, check := range cleverchatty.Diagnose(context.Background(), *config) {
+		if check.Passed() {
+			fmt.Printf("[PASS] %s %s: %s\n", check.Component, check.Name, check.Details)
+			continue
+		}
+		failed++
+		fmt.Printf("[FAIL] %s %s: %v\n", check.Component, check.Name, check.Err)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d checks failed", failed)
+	}
+	return nil
+}
+
 func loadConfigAndLogger() (config *cleverchatty.CleverChattyConfig, logger *log.Logger, err error) {
 
 	configFile := directoryPath + "/" + configFileName
@@ -26,6 +26,10 @@ const (
 	HeaderMCPServerName = "X-MCP-Server-Name"
 )
 
+// defaultReverseMCPIdleTimeout is the time a connection can stay without a message or pong.
+// Connections are pinged twice within this time
+const defaultReverseMCPIdleTimeout = 60 * time.Second
+
 // ReverseMCPConnection represents a single reverse MCP connection over WebSocket
 type ReverseMCPConnection struct {
 	ServerName  string
@@ -38,16 +42,18 @@ type ReverseMCPConnection struct {
 
 // ReverseMCPConnector handles incoming MCP connections from remote MCP servers via WebSocket
 type ReverseMCPConnector struct {
-	Config         *cleverchatty.ReverseMCPListenerConfig
-	ToolsServers   map[string]cleverchatty.ServerConfigWrapper
-	Logger         *log.Logger
-	httpServer     *http.Server
-	listener       net.Listener
-	connections    map[string]*ReverseMCPConnection
-	connectionsMux sync.RWMutex
-	ctx            context.Context
-	cancel         context.CancelFunc
-	upgrader       websocket.Upgrader
+	Config          *cleverchatty.ReverseMCPListenerConfig
+	ToolsServers    map[string]cleverchatty.ServerConfigWrapper // Replaced with SetToolsServers when the config is reloaded
+	toolsServersMux sync.RWMutex
+	Logger          *log.Logger
+	httpServer      *http.Server
+	listener        net.Listener
+	connections     map[string]*ReverseMCPConnection
+	reservedSlots   int // Slots of connections being upgraded, they count toward the limit
+	connectionsMux  sync.RWMutex
+	ctx             context.Context
+	cancel          context.CancelFunc
+	upgrader        websocket.Upgrader
 }
 
 // NewReverseMCPConnector creates a new reverse MCP connector
@@ -172,12 +178,24 @@ func (s *ReverseMCPConnector) handleWebSocket(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	// Only authenticated servers count toward the limit of connections
+	reserved, ok := s.reserveConnectionSlot(serverName)
+	if !ok {
+		s.Logger.Printf("Connection of server %s from %s is rejected, the limit of %d connections is reached",
+			serverName, r.RemoteAddr, s.Config.MaxConnections)
+		http.Error(w, "Too many connections", http.StatusServiceUnavailable)
+		return
+	}
+
 	// Upgrade to WebSocket
 	wsConn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		s.Logger.Printf("WebSocket upgrade failed for %s: %v", r.RemoteAddr, err)
+		s.releaseConnectionSlot(reserved)
 		return
 	}
+	// The deadline is extended by every message and pong, see WebSocketAdapter.Read and monitorConnection
+	wsConn.SetReadDeadline(time.Now().Add(s.idleTimeout()))
 
 	s.Logger.Printf("WebSocket connection established with %s", serverName)
 
@@ -185,9 +203,9 @@ func (s *ReverseMCPConnector) handleWebSocket(w http.ResponseWriter, r *http.Req
 	connCtx, connCancel := context.WithCancel(s.ctx)
 
 	// Create the WebSocket adapter for MCP transport
-	wsAdapter := NewWebSocketAdapter(wsConn, func() {
+	wsAdapter := NewWebSocketAdapter(wsConn, s.idleTimeout(), func() {
 		s.Logger.Printf("Connection closed/error for %s", serverName)
-		s.removeConnection(serverName)
+		s.removeConnection(serverName, wsConn)
 	})
 
 	// Create MCP client using the IO transport over WebSocket
@@ -203,8 +221,11 @@ func (s *ReverseMCPConnector) handleWebSocket(w http.ResponseWriter, r *http.Req
 		wsConn:      wsConn,
 	}
 
-	// Store connection
+	// Store connection, it takes the place of the reserved slot
 	s.connectionsMux.Lock()
+	if reserved {
+		s.reservedSlots--
+	}
 	// Close existing connection if any
 	if existing, exists := s.connections[serverName]; exists {
 		if existing.cancel != nil {
@@ -224,20 +245,81 @@ func (s *ReverseMCPConnector) handleWebSocket(w http.ResponseWriter, r *http.Req
 	go s.monitorConnection(serverName, connCtx, wsConn)
 }
 
+// reserveConnectionSlot checks the limit of connections and reserves a slot for the connection
+// being upgraded, so concurrent connections can not exceed the limit. A server reconnecting
+// replaces its old connection, so it always has a slot and nothing is reserved.
+// Returns true as the first value if the slot is reserved and must be released or taken
+func (s *ReverseMCPConnector) reserveConnectionSlot(serverName string) (bool, bool) {
+	if s.Config.MaxConnections <= 0 {
+		return false, true
+	}
+	s.connectionsMux.Lock()
+	defer s.connectionsMux.Unlock()
+
+	if _, exists := s.connections[serverName]; exists {
+		return false, true
+	}
+	if len(s.connections)+s.reservedSlots >= s.Config.MaxConnections {
+		return false, false
+	}
+	s.reservedSlots++
+	return true, true
+}
+
+// releaseConnectionSlot frees the slot reserved for a connection that failed to upgrade
+func (s *ReverseMCPConnector) releaseConnectionSlot(reserved bool) {
+	if !reserved {
+		return
+	}
+	s.connectionsMux.Lock()
+	defer s.connectionsMux.Unlock()
+	s.reservedSlots--
+}
+
+// idleTimeout returns the time after which a connection without messages and pongs is closed
+func (s *ReverseMCPConnector) idleTimeout() time.Duration {
+	if s.Config.IdleTimeout > 0 {
+		return time.Duration(s.Config.IdleTimeout) * time.Second
+	}
+	return defaultReverseMCPIdleTimeout
+}
+
+// SetToolsServers replaces the tools servers config used to authenticate new connections.
+// Connected servers no longer matching a reverse MCP server of the config are disconnected,
+// so their tools are not offered anymore
+func (s *ReverseMCPConnector) SetToolsServers(toolsServers map[string]cleverchatty.ServerConfigWrapper) {
+	s.toolsServersMux.Lock()
+	s.ToolsServers = toolsServers
+	s.toolsServersMux.Unlock()
+
+	removed := map[string]*websocket.Conn{}
+	s.connectionsMux.RLock()
+	for serverName, conn := range s.connections {
+		if _, _, exists := cleverchatty.FindReverseMCPServerConfig(toolsServers, serverName); !exists {
+			removed[serverName] = conn.wsConn
+		}
+	}
+	s.connectionsMux.RUnlock()
+
+	for serverName, wsConn := range removed {
+		s.Logger.Printf("Server %s is removed from the config, closing its connection", serverName)
+		s.removeConnection(serverName, wsConn)
+	}
+}
+
 // validateAuth validates the authentication token from the request against the server's config
 func (s *ReverseMCPConnector) validateAuth(r *http.Request, serverName string) bool {
-	// Look up the server in the tools servers config
-	serverConfig, exists := s.ToolsServers[serverName]
+	// Look up the server in the tools servers config, by the exact name or a pattern
+	s.toolsServersMux.RLock()
+	configKey, serverConfig, exists := cleverchatty.FindReverseMCPServerConfig(s.ToolsServers, serverName)
+	s.toolsServersMux.RUnlock()
 	if !exists {
-		s.Logger.Printf("Server %s not found in tools servers config", serverName)
-		return false
-	}
-
-	// Check if it's a reverse MCP server
-	if !serverConfig.IsReverseMCPServer() {
 		s.Logger.Printf("Server %s is not configured as a reverse MCP server", serverName)
 		return false
 	}
+	if configKey != serverName {
+		s.Logger.Printf("Server %s matches the reverse MCP server pattern %s", serverName, configKey)
+	}
 
 	// Get the expected auth token for this server
 	expectedToken := serverConfig.GetReverseMCPAuthToken()
@@ -278,7 +360,7 @@ func (s *ReverseMCPConnector) initializeConnection(serverName string, ctx contex
 	// Start the MCP client
 	if err := conn.client.Start(ctx); err != nil {
 		s.Logger.Printf("Failed to start MCP client for %s: %v", serverName, err)
-		s.removeConnection(serverName)
+		s.removeConnection(serverName, conn.wsConn)
 		return
 	}
 
@@ -294,7 +376,7 @@ func (s *ReverseMCPConnector) initializeConnection(serverName string, ctx contex
 	_, err := conn.client.Initialize(ctx, initReq)
 	if err != nil {
 		s.Logger.Printf("Initialize failed for %s: %v", serverName, err)
-		s.removeConnection(serverName)
+		s.removeConnection(serverName, conn.wsConn)
 		return
 	}
 
@@ -324,35 +406,37 @@ func (s *ReverseMCPConnector) initializeConnection(serverName string, ctx contex
 // monitorConnection monitors the WebSocket connection and handles disconnection
 func (s *ReverseMCPConnector) monitorConnection(serverName string, ctx context.Context, wsConn *websocket.Conn) {
 	// Set up ping/pong for connection health
+	idleTimeout := s.idleTimeout()
 	wsConn.SetPongHandler(func(string) error {
-		wsConn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		wsConn.SetReadDeadline(time.Now().Add(idleTimeout))
 		return nil
 	})
 
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(idleTimeout / 2)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			s.Logger.Printf("Connection context cancelled for %s", serverName)
-			s.removeConnection(serverName)
+			s.removeConnection(serverName, wsConn)
 			return
 		case <-ticker.C:
 			if err := wsConn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(10*time.Second)); err != nil {
 				s.Logger.Printf("Ping failed for %s: %v", serverName, err)
-				s.removeConnection(serverName)
+				s.removeConnection(serverName, wsConn)
 				return
 			}
 		}
 	}
 }
 
-// removeConnection removes a connection from the map
-func (s *ReverseMCPConnector) removeConnection(serverName string) {
+// removeConnection removes the connection over the WebSocket from the map. A connection
+// replaced by a reconnect of the same server is already closed and is not in the map
+func (s *ReverseMCPConnector) removeConnection(serverName string, wsConn *websocket.Conn) {
 	s.connectionsMux.Lock()
 	var toolCount int
-	if conn, exists := s.connections[serverName]; exists {
+	if conn, exists := s.connections[serverName]; exists && conn.wsConn == wsConn {
 		toolCount = len(conn.Tools)
 		if conn.cancel != nil {
 			conn.cancel()
@@ -438,7 +522,7 @@ func (s *ReverseMCPConnector) CallTool(serverName, toolName string, args map[str
 				break
 			}
 		}
-		result.Error = fmt.Errorf("tool error: %s", errMsg)
+		result.Error = fmt.Errorf("%w: %s", cleverchatty.ErrToolReported, errMsg)
 	}
 
 	return result, nil
@@ -447,18 +531,21 @@ func (s *ReverseMCPConnector) CallTool(serverName, toolName string, args map[str
 // WebSocketAdapter adapts a websocket.Conn to io.Reader and io.Writer interfaces
 // needed by transport.NewIO
 type WebSocketAdapter struct {
-	conn     *websocket.Conn
-	readBuf  []byte
-	readMux  sync.Mutex
-	writeMux sync.Mutex
-	onClose  func()
+	conn        *websocket.Conn
+	readBuf     []byte
+	readMux     sync.Mutex
+	writeMux    sync.Mutex
+	idleTimeout time.Duration
+	onClose     func()
 }
 
-// NewWebSocketAdapter creates a new WebSocket adapter
-func NewWebSocketAdapter(conn *websocket.Conn, onClose func()) *WebSocketAdapter {
+// NewWebSocketAdapter creates a new WebSocket adapter. The connection is closed when
+// nothing is read from it during idleTimeout
+func NewWebSocketAdapter(conn *websocket.Conn, idleTimeout time.Duration, onClose func()) *WebSocketAdapter {
 	return &WebSocketAdapter{
-		conn:    conn,
-		onClose: onClose,
+		conn:        conn,
+		idleTimeout: idleTimeout,
+		onClose:     onClose,
 	}
 }
 
@@ -482,6 +569,9 @@ func (w *WebSocketAdapter) Read(p []byte) (n int, err error) {
 		}
 		return 0, err
 	}
+	if w.idleTimeout > 0 {
+		w.conn.SetReadDeadline(time.Now().Add(w.idleTimeout))
+	}
 
 	// Ensure message ends with newline (JSON-RPC messages should be newline-delimited)
 	if len(message) > 0 && message[len(message)-1] != '\n' {
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	cleverchatty "github.com/gelembjuk/cleverchatty/core"
+	"github.com/gorilla/websocket"
+)
+
+// newTestReverseMCPConnector starts the connector with reverse MCP servers without tokens
+func newTestReverseMCPConnector(t *testing.T, config cleverchatty.ReverseMCPListenerConfig, serverNames ...string) (*ReverseMCPConnector, string) {
+	toolsServers := map[string]cleverchatty.ServerConfigWrapper{}
+	for _, name := range serverNames {
+		var wrapper cleverchatty.ServerConfigWrapper
+		if err := json.Unmarshal([]byte(`{"transport": "reverse_mcp"}`), &wrapper); err != nil {
+			t.Fatalf("Failed to parse the server config: %v", err)
+		}
+		toolsServers[name] = wrapper
+	}
+	connector := NewReverseMCPConnector(&config, toolsServers, log.New(io.Discard, "", 0))
+	server := httptest.NewServer(http.HandlerFunc(connector.handleWebSocket))
+	t.Cleanup(func() {
+		connector.Stop()
+		server.Close()
+	})
+	return connector, "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func dialReverseMCP(url string, serverName string) (*websocket.Conn, int, error) {
+	header := http.Header{}
+	header.Set(HeaderMCPServerName, serverName)
+	conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	return conn, status, err
+}
+
+// waitReverseMCPConnection waits until the connection of the server is stored or removed
+func waitReverseMCPConnection(connector *ReverseMCPConnector, serverName string, connected bool) bool {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		connector.connectionsMux.RLock()
+		_, exists := connector.connections[serverName]
+		connector.connectionsMux.RUnlock()
+		if exists == connected {
+			return true
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return false
+}
+
+func TestReverseMCPMaxConnections(t *testing.T) {
+	connector, url := newTestReverseMCPConnector(t,
+		cleverchatty.ReverseMCPListenerConfig{MaxConnections: 1}, "first", "second")
+
+	if _, status, _ := dialReverseMCP(url, "unknown"); status != http.StatusUnauthorized {
+		t.Fatalf("Expected an unknown server to be rejected with 401, got %d", status)
+	}
+
+	conn, _, err := dialReverseMCP(url, "first")
+	if err != nil {
+		t.Fatalf("Expected the first server to connect, got %v", err)
+	}
+	defer conn.Close()
+	if !waitReverseMCPConnection(connector, "first", true) {
+		t.Fatalf("Expected the connection of the first server to be stored")
+	}
+
+	if _, status, err := dialReverseMCP(url, "second"); err == nil || status != http.StatusServiceUnavailable {
+		t.Fatalf("Expected the second server to be rejected with 503, got %d, %v", status, err)
+	}
+
+	// The same server reconnecting replaces its connection
+	again, _, err := dialReverseMCP(url, "first")
+	if err != nil {
+		t.Fatalf("Expected the first server to reconnect, got %v", err)
+	}
+	defer again.Close()
+
+	connector.connectionsMux.RLock()
+	count := len(connector.connections)
+	connector.connectionsMux.RUnlock()
+	if count != 1 {
+		t.Errorf("Expected 1 connection, got %d", count)
+	}
+}
+
+func TestReverseMCPConnectionSlotReservation(t *testing.T) {
+	connector, _ := newTestReverseMCPConnector(t,
+		cleverchatty.ReverseMCPListenerConfig{MaxConnections: 1}, "first", "second")
+
+	// The slot is taken while the first connection is upgraded
+	reserved, ok := connector.reserveConnectionSlot("first")
+	if !reserved || !ok {
+		t.Fatalf("Expected the slot to be reserved for the first server")
+	}
+	if _, ok := connector.reserveConnectionSlot("second"); ok {
+		t.Errorf("Expected no slot for the second server while the first one is upgraded")
+	}
+
+	// The upgrade failed, the slot is free again
+	connector.releaseConnectionSlot(reserved)
+	if reserved, ok := connector.reserveConnectionSlot("second"); !reserved || !ok {
+		t.Errorf("Expected the released slot to be reserved for the second server")
+	}
+}
+
+func TestReverseMCPIdleTimeout(t *testing.T) {
+	connector, url := newTestReverseMCPConnector(t,
+		cleverchatty.ReverseMCPListenerConfig{IdleTimeout: 1}, "idle")
+
+	// The client never reads, so it does not answer pings and the MCP handshake
+	conn, _, err := dialReverseMCP(url, "idle")
+	if err != nil {
+		t.Fatalf("Expected the server to connect, got %v", err)
+	}
+	defer conn.Close()
+	if !waitReverseMCPConnection(connector, "idle", true) {
+		t.Fatalf("Expected the connection to be stored")
+	}
+	if !waitReverseMCPConnection(connector, "idle", false) {
+		t.Fatalf("Expected the idle connection to be closed")
+	}
+}
+
+func TestReverseMCPServerRemovedFromConfig(t *testing.T) {
+	connector, url := newTestReverseMCPConnector(t, cleverchatty.ReverseMCPListenerConfig{}, "kept", "removed")
+
+	for _, serverName := range []string{"kept", "removed"} {
+		conn, _, err := dialReverseMCP(url, serverName)
+		if err != nil {
+			t.Fatalf("Expected the server %s to connect, got %v", serverName, err)
+		}
+		defer conn.Close()
+		if !waitReverseMCPConnection(connector, serverName, true) {
+			t.Fatalf("Expected the connection of the server %s to be stored", serverName)
+		}
+	}
+
+	connector.toolsServersMux.RLock()
+	toolsServers := map[string]cleverchatty.ServerConfigWrapper{"kept": connector.ToolsServers["kept"]}
+	connector.toolsServersMux.RUnlock()
+	connector.SetToolsServers(toolsServers)
+
+	connector.connectionsMux.RLock()
+	_, removed := connector.connections["removed"]
+	_, kept := connector.connections["kept"]
+	connector.connectionsMux.RUnlock()
+	if removed {
+		t.Errorf("Expected the connection of the removed server to be closed")
+	}
+	if !kept {
+		t.Errorf("Expected the connection of the kept server to stay")
+	}
+}
+
+func TestReverseMCPServerNamePattern(t *testing.T) {
+	toolsServers := map[string]cleverchatty.ServerConfigWrapper{}
+	for name, config := range map[string]string{
+		"worker-*":       `{"transport": "reverse_mcp", "auth_token": "shared"}`,
+		"worker-special": `{"transport": "reverse_mcp", "auth_token": "special"}`,
+		"worker-search":  `{"url": "http://localhost:8080/sse", "transport": "sse"}`,
+	} {
+		var wrapper cleverchatty.ServerConfigWrapper
+		if err := json.Unmarshal([]byte(config), &wrapper); err != nil {
+			t.Fatalf("Failed to parse the server config: %v", err)
+		}
+		toolsServers[name] = wrapper
+	}
+	connector := NewReverseMCPConnector(&cleverchatty.ReverseMCPListenerConfig{}, toolsServers, log.New(io.Discard, "", 0))
+
+	tests := []struct {
+		serverName string
+		token      string
+		allowed    bool
+	}{
+		{"worker-host1", "shared", true},
+		{"worker-host2", "wrong", false},
+		{"worker-special", "special", true},
+		{"worker-special", "shared", false}, // The exact name takes precedence over the pattern
+		{"worker-search", "shared", false},  // The name of another server can not be taken
+		{"worker-a__b", "shared", false},    // The name would break the names of the tools
+		{"other-host", "shared", false},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+		req.Header.Set(HeaderAuthToken, "Bearer "+test.token)
+		if allowed := connector.validateAuth(req, test.serverName); allowed != test.allowed {
+			t.Errorf("Expected %s with token %s allowed %v, got %v", test.serverName, test.token, test.allowed, allowed)
+		}
+	}
+}
+
+func TestReverseMCPReservedServerNames(t *testing.T) {
+	toolsServers := map[string]cleverchatty.ServerConfigWrapper{}
+	for name, config := range map[string]string{
+		"*":     `{"transport": "reverse_mcp"}`,
+		"files": `{"command": "files-server"}`,
+	} {
+		var wrapper cleverchatty.ServerConfigWrapper
+		if err := json.Unmarshal([]byte(config), &wrapper); err != nil {
+			t.Fatalf("Failed to parse the server config: %v", err)
+		}
+		toolsServers[name] = wrapper
+	}
+
+	if _, _, ok := cleverchatty.FindReverseMCPServerConfig(toolsServers, "worker"); !ok {
+		t.Errorf("Expected any free name to match the pattern")
+	}
+	for _, name := range []string{"custom", "files"} {
+		if _, _, ok := cleverchatty.FindReverseMCPServerConfig(toolsServers, name); ok {
+			t.Errorf("Expected the reserved name %s not to match the pattern", name)
+		}
+	}
+}
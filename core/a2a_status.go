@@ -0,0 +1,61 @@
+package core
+
+// A2AStatusMetadataKey is the key of the message metadata that carries a callback
+// of a streaming A2A task. The value is an object with the fields of A2AStatus:
+//
+//	"metadata": {
+//	    "cleverchatty_status": {"code": "tool_calling", "message": "Using tool: x", "extra": "x"}
+//	}
+const A2AStatusMetadataKey = "cleverchatty_status"
+
+// MetadataVerboseTools is the boolean key of the A2A message metadata that asks the server
+// to send the arguments and the results of tool calls as status updates of the task.
+// Values of secret looking arguments are redacted
+const MetadataVerboseTools = "verbose_tools"
+
+// MetadataNotificationsFilter is the string key of the A2A message metadata with the display
+// filter of the client, see NotificationFilterShows. Notifications hidden by the filter are
+// not forwarded to the client
+const MetadataNotificationsFilter = "notifications_filter"
+
+// A2AStatus is a callback reported by the A2A server while a streaming task is working
+type A2AStatus struct {
+	Code    string `json:"code"`            // One of the CallbackCode* values
+	Message string `json:"message"`         // Human readable message, also sent as the text part
+	Extra   string `json:"extra,omitempty"` // Additional data, depends on the code
+	// ID of the request the status belongs to, the same as in the server logs
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Metadata returns the message metadata carrying the status
+func (s A2AStatus) Metadata() map[string]interface{} {
+	value := map[string]interface{}{
+		"code":    s.Code,
+		"message": s.Message,
+		"extra":   s.Extra,
+	}
+	if s.RequestID != "" {
+		value["request_id"] = s.RequestID
+	}
+	return map[string]interface{}{
+		A2AStatusMetadataKey: value,
+	}
+}
+
+// A2AStatusFromMetadata extracts the status from the message metadata.
+// Returns false if the metadata has no status.
+func A2AStatusFromMetadata(metadata map[string]interface{}) (A2AStatus, bool) {
+	value, ok := metadata[A2AStatusMetadataKey].(map[string]interface{})
+	if !ok {
+		return A2AStatus{}, false
+	}
+	status := A2AStatus{}
+	status.Code, _ = value["code"].(string)
+	status.Message, _ = value["message"].(string)
+	status.Extra, _ = value["extra"].(string)
+	status.RequestID, _ = value["request_id"].(string)
+	if status.Code == "" {
+		return A2AStatus{}, false
+	}
+	return status, true
+}
@@ -2,7 +2,10 @@ package core
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -17,6 +20,7 @@ func (assistant *CleverChatty) pruneMessages() {
 	}
 
 	// Keep only the most recent messages based on window size
+	assistant.archivePrunedMessages(assistant.messages[:len(assistant.messages)-assistant.config.MessageWindow])
 	assistant.messages = assistant.messages[len(assistant.messages)-assistant.config.MessageWindow:]
 
 	// Handle messages
@@ -70,26 +74,74 @@ func (assistant *CleverChatty) pruneMessages() {
 	assistant.messages = prunedMessages
 }
 
-func (assistant *CleverChatty) addToMemory(role string, content string) {
-	// TODO. Add timeouts to context
+// addToMemory sends the message to the memory server with the metadata describing where it comes from.
+// Tools are the names of the tools called in the response, the memory server can use them as tags
+func (assistant *CleverChatty) addToMemory(ctx context.Context, role string, content string, tools []string) {
+	if assistant.ephemeralPrompt {
+		return
+	}
+	metadata := map[string]interface{}{
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	}
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		metadata["request_id"] = requestID
+	}
+	if assistant.config.AgentID != "" {
+		metadata["agent_id"] = assistant.config.AgentID
+	}
+	if assistant.ClientAgentID != "" {
+		metadata["client_agent_id"] = assistant.ClientAgentID
+	}
+	if len(tools) > 0 {
+		metadata["tools"] = tools
+	}
 	assistant.toolsHost.Remember(role, history.ContentBlock{
 		Type: "text",
 		Text: content,
-	}, context.Background())
+	}, ctx, metadata)
 }
 
-func (assistant *CleverChatty) injectMemories(prompt string) {
+// injectMemories adds the memories related to the prompt to the history according to the
+// memory_injection_mode. In the "prompt" mode nothing is added, the returned text must
+// be prepended to the user's prompt
+func (assistant *CleverChatty) injectMemories(ctx context.Context, prompt string) string {
 	// get memories if there are any
-	// TODO. Add timeouts to context
 	assistant.Callbacks.CallMemoryRetrievalStarted()
 
-	memories, _ := assistant.toolsHost.Recall(context.Background(), prompt)
+	memories, _ := assistant.toolsHost.Recall(ctx, prompt)
 
 	if memories == "" {
-		return // no memories to inject
+		return "" // no memories to inject
 	}
+	memories = assistant.formatMemories(memories)
+
+	if assistant.config.MemoryInjectionMode == MemoryInjectionModePrompt {
+		assistant.logger.Printf("%sInjecting memories into the prompt: %s\n", logPrefix(ctx), memories)
+		return memories + "\n\n"
+	}
+
 	// if this kind of message is already in the history, remove it to add fresh one
-	// from the array assistant.messages remove any records where msg.IsMemoryNote()
+	assistant.removeMemoryNotes()
+
+	assistant.logger.Printf("%sInjecting memories into the history: %s\n", logPrefix(ctx), memories)
+
+	if assistant.config.MemoryInjectionMode == MemoryInjectionModeSystem {
+		// Right after the system instruction, before the conversation
+		position := 0
+		if len(assistant.messages) > 0 && assistant.messages[0].IsSystemInstruction() {
+			position = 1
+		}
+		assistant.messages = append(assistant.messages[:position],
+			append([]history.HistoryMessage{history.NewMemoryNoteMessage(memories)}, assistant.messages[position:]...)...)
+		return ""
+	}
+
+	assistant.messages = append(assistant.messages, history.NewMemoryNoteMessage(memories))
+	return ""
+}
+
+// removeMemoryNotes removes the memories injected for the previous prompts from the history
+func (assistant *CleverChatty) removeMemoryNotes() {
 	var filteredMessages []history.HistoryMessage
 	for _, msg := range assistant.messages {
 		if !msg.IsMemoryNote() {
@@ -97,13 +149,21 @@ func (assistant *CleverChatty) injectMemories(prompt string) {
 		}
 	}
 	assistant.messages = filteredMessages
+}
 
-	assistant.logger.Printf("Injecting memories into the history: %s\n", memories)
-
-	assistant.messages = append(assistant.messages, history.NewMemoryNoteMessage(memories))
+// formatMemories puts the memories in the memory_template
+func (assistant *CleverChatty) formatMemories(memories string) string {
+	template := assistant.config.MemoryTemplate
+	if template == "" {
+		return memories
+	}
+	if !strings.Contains(template, memoryTemplatePlaceholder) {
+		return template + "\n" + memories
+	}
+	return strings.ReplaceAll(template, memoryTemplatePlaceholder, memories)
 }
 
-func (assistant *CleverChatty) injectRAGContext(prompt string) {
+func (assistant *CleverChatty) injectRAGContext(ctx context.Context, prompt string) {
 	// get RAG context if there are any
 	if !assistant.toolsHost.HasRagServer() {
 		// no RAG context configured, nothing to inject
@@ -115,28 +175,18 @@ func (assistant *CleverChatty) injectRAGContext(prompt string) {
 	if assistant.config.RAGConfig.RequirePreprocessing &&
 		assistant.config.RAGConfig.PreprocessingPrompt != "" {
 		// if preprocessing is required, we need to preprocess the prompt first
-		// Send a request to connected LLM provider to preprocess the prompt
-		instructionMessage := history.NewSystemInstructionMessage(assistant.config.RAGConfig.PreprocessingPrompt)
-
-		msg, err := assistant.provider.CreateMessage(
-			assistant.context,
-			prompt,
-			[]llm.Message{&instructionMessage},
-			assistant.toolsHost.GetAllToolsForLLM(),
-		)
-		if err == nil {
-			// if we got a response, use it as the prompt for RAG context
-			if msg.GetContent() != "" {
-				// modify the prompt to use the response from the LLM
-				prompt = msg.GetContent()
-			}
+		// If it fails, the original prompt is used for the RAG request
+		refined, err := assistant.preprocessRAGQuery(ctx, prompt)
+		if err != nil {
+			assistant.logger.Printf("%sError preprocessing RAG query, using the original prompt: %v\n", logPrefix(ctx), err)
+		} else if refined != "" {
+			prompt = refined
 		}
 	}
-	// TODO. Add timeouts to context
-	ragDocuments, err := assistant.toolsHost.GetRAGContext(context.Background(), prompt)
+	ragDocuments, err := assistant.toolsHost.GetRAGContext(ctx, prompt)
 
 	if err != nil {
-		assistant.logger.Printf("Error getting RAG context: %v\n", err)
+		assistant.logger.Printf("%sError getting RAG context: %v\n", logPrefix(ctx), err)
 		return
 	}
 
@@ -154,12 +204,205 @@ func (assistant *CleverChatty) injectRAGContext(prompt string) {
 	}
 }
 
-// Method implementations for simpleMessage
+// preprocessRAGQuery sends a request to connected LLM provider to refine the prompt for the RAG request
+func (assistant *CleverChatty) preprocessRAGQuery(ctx context.Context, prompt string) (string, error) {
+	assistant.Callbacks.CallRAGPreprocessing()
+
+	timeout := time.Duration(assistant.config.RAGConfig.PreprocessingTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultRAGPreprocessingTimeout * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	instructionMessage := history.NewSystemInstructionMessage(assistant.config.RAGConfig.PreprocessingPrompt)
+
+	msg, err := assistant.provider.CreateMessage(
+		ctx,
+		prompt,
+		[]llm.Message{&instructionMessage},
+		assistant.toolsForLLM(),
+	)
+	if err != nil {
+		return "", err
+	}
+	return msg.GetContent(), nil
+}
+
+// addSystemInstruction starts an empty history with the system instruction.
+// The instruction changed with SetSystemInstruction replaces the one in the history
+func (assistant *CleverChatty) addSystemInstruction() {
+	if len(assistant.messages) > 0 {
+		if assistant.systemInstructionSet {
+			assistant.replaceSystemInstruction()
+		}
+		return
+	}
+	assistant.systemInstructionSet = false
+
+	if instructions := assistant.systemInstruction(); instructions != "" {
+		assistant.messages = append(assistant.messages, history.NewSystemInstructionMessage(instructions))
+	}
+}
+
+// replaceSystemInstruction puts the current system instruction in place of the system message
+// of the history. It is added as the first message if the history has no system message
+func (assistant *CleverChatty) replaceSystemInstruction() {
+	assistant.systemInstructionSet = false
+
+	messages := assistant.messages
+	if len(messages) > 0 && messages[0].Role == "system" {
+		messages = messages[1:]
+	}
+	if instructions := assistant.systemInstruction(); instructions != "" {
+		messages = append([]history.HistoryMessage{history.NewSystemInstructionMessage(instructions)}, messages...)
+	}
+	assistant.messages = messages
+}
+
+// systemInstruction returns the system instruction with the placeholders replaced
+func (assistant *CleverChatty) systemInstruction() string {
+	instructions := ""
+
+	if assistant.config.SystemInstruction != "" {
+		instructions = assistant.replaceInstructionPlaceholders(assistant.config.SystemInstruction)
+	} else if assistant.ClientAgentID != "" {
+		instructions = fmt.Sprintf(
+			"You communicate with the agent ID %s. Use this ID for future references.",
+			assistant.ClientAgentID,
+		)
+	}
+	if identity := assistant.identityInstruction(); identity != "" {
+		if instructions == "" {
+			return identity
+		}
+		instructions = identity + "\n\n" + instructions
+	}
+	return instructions
+}
+
+// replaceInstructionPlaceholders puts the IDs and the identity of the agent in place of the placeholders
+func (assistant *CleverChatty) replaceInstructionPlaceholders(text string) string {
+	text = strings.ReplaceAll(text, "{AGENT_ID}", assistant.config.AgentID)
+	text = strings.ReplaceAll(text, "{CLIENT_AGENT_ID}", assistant.ClientAgentID)
+	text = strings.ReplaceAll(text, "{AGENT_NAME}", assistant.config.A2AServerConfig.Title)
+	text = strings.ReplaceAll(text, "{AGENT_ORG}", assistant.config.A2AServerConfig.Organization)
+	return text
+}
+
+// Default identity instructions, with and without the organization
+const (
+	defaultIdentityTemplate      = "You are {AGENT_NAME}, an AI agent of {AGENT_ORG}. Use this name when you are asked who you are."
+	defaultIdentityTemplateNoOrg = "You are {AGENT_NAME}, an AI agent. Use this name when you are asked who you are."
+)
+
+// identityInstruction tells the model its name when identity_instruction is enabled
+func (assistant *CleverChatty) identityInstruction() string {
+	if !assistant.config.IdentityInstruction || assistant.config.A2AServerConfig.Title == "" {
+		return ""
+	}
+	template := assistant.config.IdentityTemplate
+	if template == "" {
+		template = defaultIdentityTemplate
+		if assistant.config.A2AServerConfig.Organization == "" {
+			template = defaultIdentityTemplateNoOrg
+		}
+	}
+	return assistant.replaceInstructionPlaceholders(template)
+}
+
+// Greet runs the greeting prompt and adds it with the produced message to the history, so the
+// conversation starts with a user turn as providers expect. Tools are not used for the greeting.
+func (assistant *CleverChatty) Greet(greetingPrompt string) (string, error) {
+	if greetingPrompt == "" {
+		return "", nil
+	}
+
+	assistant.addSystemInstruction()
+
+	greetingMessage := history.NewUserPromptMessage(greetingPrompt)
+	llmMessages := make([]llm.Message, len(assistant.messages), len(assistant.messages)+1)
+	for i := range assistant.messages {
+		llmMessages[i] = &(assistant.messages)[i]
+	}
+	llmMessages = append(llmMessages, &greetingMessage)
+
+	message, err := assistant.provider.CreateMessage(
+		assistant.context,
+		greetingPrompt,
+		llmMessages,
+		[]llm.Tool{},
+	)
+	if err != nil {
+		return "", fmt.Errorf("error creating greeting: %w", err)
+	}
+
+	greeting := message.GetContent()
+	if greeting == "" {
+		return "", nil
+	}
+
+	assistant.messages = append(assistant.messages, greetingMessage, history.HistoryMessage{
+		Role: message.GetRole(),
+		Content: []history.ContentBlock{
+			{
+				Type: "text",
+				Text: greeting,
+			},
+		},
+	})
+	return greeting, nil
+}
+
+// Prompt processes a text prompt of the user and returns the final response
 func (assistant *CleverChatty) Prompt(prompt string) (string, error) {
+	return assistant.PromptMessage(history.NewUserPromptMessage(prompt))
+}
+
+// PromptCtx is like Prompt, but the processing is stopped when the context is cancelled
+// or its deadline is reached. The LLM requests and tool calls receive the context.
+func (assistant *CleverChatty) PromptCtx(ctx context.Context, prompt string) (string, error) {
+	return assistant.PromptMessageCtx(ctx, history.NewUserPromptMessage(prompt))
+}
+
+// PromptMessage processes a user's message that can have multiple content blocks. Text blocks
+// form the prompt, file reference blocks (see history.NewFileReferenceBlock) are listed
+// after the text so the LLM can pass the references to tools.
+func (assistant *CleverChatty) PromptMessage(msg history.HistoryMessage) (string, error) {
+	return assistant.PromptMessageCtx(context.Background(), msg)
+}
+
+// PromptMessageCtx is like PromptMessage, but the processing is stopped when the context
+// is cancelled or its deadline is reached
+func (assistant *CleverChatty) PromptMessageCtx(ctx context.Context, msg history.HistoryMessage) (string, error) {
+	return assistant.PromptWithOptions(ctx, msg, PromptOptions{})
+}
+
+// PromptWithOptions is like PromptMessageCtx, the options change how this prompt is processed,
+// for example to ask a question without the stored memories
+func (assistant *CleverChatty) PromptWithOptions(ctx context.Context, msg history.HistoryMessage, options PromptOptions) (string, error) {
+	prompt := promptFromMessage(msg)
 	if prompt == "" {
 		return "", nil
 	}
 
+	if err := CheckPromptSize(assistant.promptSize(msg, prompt), assistant.config.MaxPromptBytes); err != nil {
+		return "", err
+	}
+
+	// The prompt is also stopped when the assistant is finished
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(assistant.context, cancel)
+	defer stop()
+
+	// The ID correlates the log lines of this prompt
+	if RequestIDFromContext(ctx) == "" {
+		ctx = WithRequestID(ctx, newRequestID())
+	}
+	assistant.requestID = RequestIDFromContext(ctx)
+	assistant.promptStats = PromptStats{Model: assistant.config.Model}
+
 	// Check for slash commands first
 	handled, response, err := assistant.handleSlashCommand(prompt)
 	if handled {
@@ -171,42 +414,52 @@ func (assistant *CleverChatty) Prompt(prompt string) (string, error) {
 		return response, nil
 	}
 
-	if len(assistant.messages) == 0 {
-		// append system instruction to the history
-
-		instructions := ""
-
-		if assistant.config.SystemInstruction != "" {
-			instructions = assistant.config.SystemInstruction
-			instructions = strings.ReplaceAll(instructions, "{AGENT_ID}", assistant.config.AgentID)
-			instructions = strings.ReplaceAll(instructions, "{CLIENT_AGENT_ID}", assistant.ClientAgentID)
-		} else if assistant.ClientAgentID != "" {
-			instructions = fmt.Sprintf(
-				"You communicate with the agent ID %s. Use this ID for future references.",
-				assistant.ClientAgentID,
-			)
-		}
+	// Attached images are shown to the model next to the prompt
+	images, err := assistant.imageBlocks(msg)
+	if err != nil {
+		return "", err
+	}
 
-		if instructions != "" {
-			assistant.messages = append(assistant.messages, history.NewSystemInstructionMessage(instructions))
-		}
+	prompt, err = assistant.preprocessPrompt(ctx, prompt)
+	if err != nil {
+		return "", err
 	}
 
+	assistant.addSystemInstruction()
+
 	assistant.pruneMessages()
 
+	// Files returned by tools are reported per prompt
+	assistant.TakeProducedFiles()
+
+	assistant.lastToolCall = ""
+	assistant.toolCallRepeats = 0
+	assistant.continuations = 0
+	assistant.ephemeralPrompt = options.Ephemeral
+
 	assistant.Callbacks.CallStartedPromptProcessing(prompt)
 
-	// if there are memories, inject them into the history
-	assistant.injectMemories(prompt)
-	// if there is RAG server configured, do request to it and inject in messages
-	assistant.injectRAGContext(prompt)
+	memoriesPrefix := ""
+	if options.SkipMemory {
+		// Memories injected for earlier prompts must not affect this one either
+		assistant.removeMemoryNotes()
+	} else {
+		// if there are memories, inject them into the history
+		memoriesPrefix = assistant.injectMemories(ctx, prompt)
+	}
+	if !options.SkipRAG {
+		// if there is RAG server configured, do request to it and inject in messages
+		assistant.injectRAGContext(ctx, prompt)
+	}
 
-	assistant.messages = append(assistant.messages, history.NewUserPromptMessage(prompt))
+	userMessage := history.NewUserPromptMessage(memoriesPrefix + prompt)
+	userMessage.Content = append(userMessage.Content, images...)
+	assistant.messages = append(assistant.messages, userMessage)
 
 	// time to refresh the memory
-	assistant.addToMemory("user", prompt)
+	assistant.addToMemory(ctx, "user", prompt, nil)
 
-	response, err = assistant.processPrompt(prompt)
+	response, err = assistant.processPrompt(ctx, memoriesPrefix+prompt, "")
 	if err != nil {
 		return "", err
 	}
@@ -214,12 +467,88 @@ func (assistant *CleverChatty) Prompt(prompt string) (string, error) {
 	return response, nil
 }
 
-func (assistant *CleverChatty) processPrompt(prompt string) (string, error) {
+// providerTimeout returns the time limit of a single LLM request. The setting of
+// the provider of the configured model overrides the common one
+func (assistant *CleverChatty) providerTimeout() time.Duration {
+	timeout := assistant.config.ProviderTimeout
+
+	provider, _, _ := strings.Cut(assistant.config.Model, ":")
+	switch provider {
+	case "anthropic":
+		if assistant.config.Anthropic.Timeout > 0 {
+			timeout = assistant.config.Anthropic.Timeout
+		}
+	case "openai":
+		if assistant.config.OpenAI.Timeout > 0 {
+			timeout = assistant.config.OpenAI.Timeout
+		}
+	case "google":
+		if assistant.config.Google.Timeout > 0 {
+			timeout = assistant.config.Google.Timeout
+		}
+	case "ollama":
+		if assistant.config.Ollama.Timeout > 0 {
+			timeout = assistant.config.Ollama.Timeout
+		}
+	}
+	if timeout <= 0 {
+		timeout = defaultProviderTimeout
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+// promptSize returns the size of the prompt text and the files referenced in the message
+func (assistant *CleverChatty) promptSize(msg history.HistoryMessage, prompt string) int64 {
+	size := int64(len(prompt))
+	for _, block := range msg.Content {
+		if block.Type != "file" {
+			continue
+		}
+		if file, ok := assistant.DescribeFileRef(block.Text); ok && file.Size > 0 {
+			size += file.Size
+		}
+	}
+	return size
+}
+
+// promptFromMessage composes the prompt text from the blocks of a user's message
+func promptFromMessage(msg history.HistoryMessage) string {
+	texts := []string{}
+	files := []history.ContentBlock{}
+	for _, block := range msg.Content {
+		switch block.Type {
+		case "text":
+			if block.Text != "" {
+				texts = append(texts, block.Text)
+			}
+		case "file":
+			files = append(files, block)
+		}
+	}
+	prompt := strings.Join(texts, "\n\n")
+	if len(files) == 0 {
+		return prompt
+	}
+
+	var builder strings.Builder
+	builder.WriteString(prompt)
+	builder.WriteString("\n\nAttached files. Pass the reference as a tool argument to use the file content:\n")
+	for _, file := range files {
+		builder.WriteString(fmt.Sprintf("- %s: %s\n", file.Name, file.Text))
+	}
+	return builder.String()
+}
+
+// processPrompt sends the history to the model and handles the response. The instruction,
+// if not empty, is sent to the model as a user message with this request only, it is not
+// kept in the history
+func (assistant *CleverChatty) processPrompt(ctx context.Context, prompt string, instruction string) (string, error) {
 
 	var message llm.Message
 	var err error
 	backoff := initialBackoff
 	retries := 0
+	timeoutRetries := 0
 
 	// Convert MessageParam to llm.Message for provider
 	// Messages already implement llm.Message interface
@@ -228,6 +557,10 @@ func (assistant *CleverChatty) processPrompt(prompt string) (string, error) {
 	for i := range assistant.messages {
 		llmMessages[i] = &(assistant.messages)[i]
 	}
+	if instruction != "" {
+		instructionMessage := history.NewUserPromptMessage(instruction)
+		llmMessages = append(llmMessages, &instructionMessage)
+	}
 
 	for {
 		assistant.Callbacks.CallStartedThinking()
@@ -239,12 +572,16 @@ func (assistant *CleverChatty) processPrompt(prompt string) (string, error) {
 
 		resultCh := make(chan result, 1)
 
+		// A hung provider connection must not block the prompt until the outer context ends
+		timeout := assistant.providerTimeout()
+		requestCtx, cancel := context.WithTimeout(ctx, timeout)
+
 		go func() {
 			msg, err := assistant.provider.CreateMessage(
-				assistant.context,
+				requestCtx,
 				prompt,
 				llmMessages,
-				assistant.toolsHost.GetAllToolsForLLM(),
+				assistant.toolsForLLM(),
 			)
 			resultCh <- result{message: msg, err: err}
 		}()
@@ -254,24 +591,38 @@ func (assistant *CleverChatty) processPrompt(prompt string) (string, error) {
 			// done!
 			message = res.message
 			err = res.err
-		case <-assistant.context.Done():
+		case <-requestCtx.Done():
 			// context cancelled or timed out
-			err = assistant.context.Err()
+			err = requestCtx.Err()
+		}
+		if err != nil && requestCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			err = fmt.Errorf("%w after %s", ErrProviderTimeout, timeout)
 		}
+		cancel()
 
 		if err != nil {
+			// A timed out request is retried once, a new connection often succeeds
+			if errors.Is(err, ErrProviderTimeout) && timeoutRetries < maxProviderTimeoutRetries {
+				assistant.logger.Printf("%sLLM provider request timed out after %s, retrying...\n", logPrefix(ctx), timeout)
+				timeoutRetries++
+				continue
+			}
 			// Check if it's an overloaded error
-			if strings.Contains(err.Error(), "overloaded_error") {
-				// it is specific to Anthropic
+			if errors.Is(err, ErrProviderOverloaded) {
 				if retries >= maxRetries {
 					return "", fmt.Errorf(
-						"claude is currently overloaded. please wait a few minutes and try again",
+						"%w: please wait a few minutes and try again",
+						ErrProviderOverloaded,
 					)
 				}
 
-				assistant.logger.Printf("Claude is overloaded, retrying... (attempt %d, %s)\n", retries+1, backoff.String())
+				assistant.logger.Printf("%sLLM provider is overloaded, retrying... (attempt %d, %s)\n", logPrefix(ctx), retries+1, backoff.String())
 
-				time.Sleep(backoff)
+				select {
+				case <-time.After(backoff):
+				case <-ctx.Done():
+					return "", ctx.Err()
+				}
 				backoff *= 2
 				if backoff > maxBackoff {
 					backoff = maxBackoff
@@ -286,8 +637,38 @@ func (assistant *CleverChatty) processPrompt(prompt string) (string, error) {
 		break
 	}
 
+	inputTokens, outputTokens := message.GetUsage()
+	assistant.promptStats.InputTokens += inputTokens
+	assistant.promptStats.OutputTokens += outputTokens
+
+	if message.GetFinishReason() == llm.FinishReasonContentFilter {
+		assistant.logger.Printf("%sThe response is blocked by the content filter of the provider: %+v\n", logPrefix(ctx), message)
+		return "", ErrContentFiltered
+	}
+
+	if message.GetContent() == "" && len(message.GetToolCalls()) == 0 {
+		if assistant.turnHasText() {
+			// The model already answered before calling the tools and has nothing to add
+			return "", nil
+		}
+		// Providers do this, for example, when the response is blocked by a content filter
+		assistant.logger.Printf("%sThe model returned an empty response: %+v\n", logPrefix(ctx), message)
+		if instruction == emptyResponseNudge {
+			return "", ErrEmptyResponse
+		}
+		// The empty response is not kept in the history, the model is asked once more
+		return assistant.processPrompt(ctx, "", emptyResponseNudge)
+	}
+
 	toolResults := []history.ContentBlock{}
+	failedToolCalls := 0
 	messageContent := []history.ContentBlock{}
+	loopDetected := false
+
+	// The reasoning is kept before the tool calls, the provider needs it with the tool results
+	if thinkingMsg, ok := message.(llm.ThinkingMessage); ok {
+		messageContent = append(messageContent, assistant.thinkingContent(ctx, thinkingMsg.GetThinkingBlocks())...)
+	}
 
 	// Add text content
 	if message.GetContent() != "" {
@@ -298,7 +679,14 @@ func (assistant *CleverChatty) processPrompt(prompt string) (string, error) {
 			Text: message.GetContent(),
 		})
 
-		assistant.addToMemory("assistant", message.GetContent())
+		// The text next to tool calls is usually an intermediate step, not the answer
+		if len(message.GetToolCalls()) == 0 || assistant.rememberToolTurns() {
+			tools := []string{}
+			for _, toolCall := range message.GetToolCalls() {
+				tools = append(tools, toolCall.GetName())
+			}
+			assistant.addToMemory(ctx, "assistant", message.GetContent(), tools)
+		}
 	}
 
 	// Handle tool calls
@@ -313,46 +701,81 @@ func (assistant *CleverChatty) processPrompt(prompt string) (string, error) {
 		})
 
 		// Log usage statistics if available
-		inputTokens, outputTokens := message.GetUsage()
 		if inputTokens > 0 || outputTokens > 0 {
-			assistant.logger.Printf("Usage statistics: input_tokens=%d, output_tokens=%d, total_tokens=%d\n",
-				inputTokens, outputTokens, inputTokens+outputTokens)
+			assistant.logger.Printf("%sUsage statistics: input_tokens=%d, output_tokens=%d, total_tokens=%d\n",
+				logPrefix(ctx), inputTokens, outputTokens, inputTokens+outputTokens)
 		}
 
+		assistant.promptStats.ToolCalls++
 		assistant.Callbacks.CallToolCalling(toolCall.GetName())
+		assistant.Callbacks.CallToolArguments(toolCall.GetName(), string(input))
+		toolStarted := time.Now()
 
 		parts := strings.Split(toolCall.GetName(), "__")
 		if len(parts) != 2 {
+			assistant.Callbacks.CallToolCallFinished(toolCall.GetName(), time.Since(toolStarted))
 			continue // Invalid tool name format
 		}
 
 		serverName, toolName := parts[0], parts[1]
 
+		if repeats := assistant.countToolCallRepeats(toolCall.GetName(), input); repeats > 0 && repeats >= assistant.maxRepeatedToolCalls() {
+			assistant.logger.Printf("%sTool %s is called %d times in a row with the same arguments\n", logPrefix(ctx), toolCall.GetName(), repeats)
+
+			toolResults = append(toolResults, history.ContentBlock{
+				Type:      "tool_result",
+				Text:      repeatedToolCallWarning,
+				ToolUseID: toolCall.GetID(),
+				Content:   history.NewTextContent(repeatedToolCallWarning),
+			})
+			assistant.Callbacks.CallToolCallFinished(toolCall.GetName(), time.Since(toolStarted))
+			if repeats > assistant.maxRepeatedToolCalls() {
+				// The model ignored the warning, stop the turn keeping the history consistent
+				loopDetected = true
+			}
+			continue
+		}
+
 		toolResult := assistant.toolsHost.callTool(
 			serverName,
 			toolName,
-			toolCall.GetArguments(),
-			assistant.context,
+			assistant.toolArguments(ctx, toolCall.GetName(), toolCall.GetArguments()),
+			ctx,
 		)
+		assistant.Callbacks.CallToolCallFinished(toolCall.GetName(), time.Since(toolStarted))
 
 		if toolResult.Error != nil {
+			if ctx.Err() != nil {
+				// The prompt was cancelled, there is no reason to continue the turn
+				return "", fmt.Errorf("tool call %s aborted: %w", toolCall.GetName(), ctx.Err())
+			}
 			errMsg := fmt.Sprintf(
 				"Error calling tool %s: %v",
 				toolCall.GetName(),
 				toolResult.Error,
 			)
+			var timeoutErr *ToolTimeoutError
+			if errors.As(toolResult.Error, &timeoutErr) {
+				errMsg = timeoutErr.Error()
+			}
 			assistant.Callbacks.CallToolCallFailed(toolCall.GetName(), toolResult.Error)
 
-			// Add error message as tool result
+			// Add error message with its classification as tool result
+			errText := toolResult.errorResultText(errMsg)
 			toolResults = append(toolResults, history.ContentBlock{
 				Type:      "tool_result",
-				Text:      errMsg,
+				Text:      errText,
 				ToolUseID: toolCall.GetID(),
-				Content:   history.NewTextContent(errMsg),
+				Content:   history.NewTextContent(errText),
 			})
+			failedToolCalls++
 			continue
 		}
 
+		if assistant.config.FormatJSONToolResults {
+			toolResult.formatJSONContent()
+		}
+
 		// Create the tool result block
 		resultBlock := history.ContentBlock{
 			Type:      "tool_result",
@@ -361,8 +784,11 @@ func (assistant *CleverChatty) processPrompt(prompt string) (string, error) {
 			Content:   toolResult.Content,
 		}
 
+		assistant.Callbacks.CallToolResultReceived(toolCall.GetName(), resultBlock.Text)
+
 		if assistant.config.DebugMode {
-			assistant.logger.Printf("created tool result block. %s, %s\n",
+			assistant.logger.Printf("%screated tool result block. %s, %s\n",
+				logPrefix(ctx),
 				resultBlock,
 				toolCall.GetID())
 		}
@@ -380,9 +806,151 @@ func (assistant *CleverChatty) processPrompt(prompt string) (string, error) {
 			Content: toolResults,
 		})
 
+		if loopDetected {
+			return "", fmt.Errorf("%w: the model keeps calling the same tool with the same arguments", ErrToolCallLoop)
+		}
+
+		guidance := ""
+		if failedToolCalls == len(toolResults) && assistant.config.AllToolsFailedGuidance {
+			// The guidance is sent with the next request only, it is not kept in the history
+			guidance = assistant.allToolsFailedMessage()
+			assistant.logger.Printf("%sAll %d tool calls failed, the model gets the guidance\n", logPrefix(ctx), failedToolCalls)
+		}
+
 		// Make another call to get LLM's response to the tool results
-		return assistant.processPrompt("")
+		return assistant.processPrompt(ctx, "", guidance)
+	}
+
+	if message.GetFinishReason() == llm.FinishReasonLength {
+		if assistant.config.ContinueTruncatedReplies && assistant.continuations < assistant.maxReplyContinuations() {
+			assistant.continuations++
+			assistant.logger.Printf("%sThe response reached the output token limit, asking the model to continue\n", logPrefix(ctx))
+			continued, err := assistant.processPrompt(ctx, "", continueResponsePrompt)
+			if err != nil {
+				return "", err
+			}
+			return message.GetContent() + continued, nil
+		}
+		assistant.logger.Printf("%sThe response is truncated, it reached the output token limit\n", logPrefix(ctx))
+		return message.GetContent() + truncatedResponseNote, nil
 	}
 
 	return message.GetContent(), nil
 }
+
+// defaultMaxReplyContinuations is the number of times a truncated response is continued in one prompt
+const defaultMaxReplyContinuations = 3
+
+// rememberToolTurns returns true if the text of responses with tool calls is remembered
+func (assistant *CleverChatty) rememberToolTurns() bool {
+	return assistant.config.RememberToolTurns == nil || *assistant.config.RememberToolTurns
+}
+
+// maxReplyContinuations returns the cap of continuations of a truncated response in one prompt
+func (assistant *CleverChatty) maxReplyContinuations() int {
+	if assistant.config.MaxReplyContinuations <= 0 {
+		return defaultMaxReplyContinuations
+	}
+	return assistant.config.MaxReplyContinuations
+}
+
+// continueResponsePrompt asks the model to continue a response cut by the output token limit
+const continueResponsePrompt = "Your response was cut off by the length limit. Continue exactly where it stopped, without repeating anything."
+
+// truncatedResponseNote is added to a truncated response that is not continued
+const truncatedResponseNote = "\n\n[The response is truncated, it reached the output token limit]"
+
+// emptyResponseNudge is sent to the model once when it returned neither text nor tool calls
+const emptyResponseNudge = "Your previous response was empty. Please answer the user's last message."
+
+// turnHasText returns true if the model already answered with text in the current turn,
+// before the tool results that follow it
+func (assistant *CleverChatty) turnHasText() bool {
+	for i := len(assistant.messages) - 1; i >= 0; i-- {
+		message := assistant.messages[i]
+		if message.Role == "assistant" {
+			if message.GetContent() != "" {
+				return true
+			}
+			continue
+		}
+		if !message.IsToolResponse() {
+			// The prompt of the user starts the turn
+			return false
+		}
+	}
+	return false
+}
+
+const repeatedToolCallWarning = "You already called this tool with the same arguments; the result is unchanged. Use the previous result or try something different."
+
+// defaultAllToolsFailedMessage is sent to the model when every tool call of a turn failed
+const defaultAllToolsFailedMessage = "All tool calls failed; answer from your own knowledge or ask the user for help."
+
+func (assistant *CleverChatty) allToolsFailedMessage() string {
+	if assistant.config.AllToolsFailedMessage == "" {
+		return defaultAllToolsFailedMessage
+	}
+	return assistant.config.AllToolsFailedMessage
+}
+
+// thinkingContent converts the reasoning of the model to history blocks and reports
+// the readable part of it to the callback
+func (assistant *CleverChatty) thinkingContent(ctx context.Context, blocks []llm.ThinkingBlock) []history.ContentBlock {
+	content := []history.ContentBlock{}
+	reasoning := []string{}
+	for _, block := range blocks {
+		if block.Redacted != "" {
+			content = append(content, history.ContentBlock{
+				Type: "redacted_thinking",
+				Text: block.Redacted,
+			})
+			continue
+		}
+		content = append(content, history.ContentBlock{
+			Type:      "thinking",
+			Text:      block.Text,
+			Signature: block.Signature,
+		})
+		if text := strings.TrimSpace(block.Text); text != "" {
+			reasoning = append(reasoning, text)
+		}
+	}
+	if len(reasoning) > 0 {
+		text := strings.Join(reasoning, "\n\n")
+		// Providers count the reasoning in output_tokens, this is a rough estimate of its share
+		assistant.logger.Printf("%sThinking statistics: thinking_tokens~%d\n", logPrefix(ctx), len(text)/4)
+		assistant.Callbacks.CallReasoningReceived(text)
+	}
+	return content
+}
+
+// maxRepeatedToolCalls returns how many identical tool calls in a row are allowed
+// before the call is short-circuited. Zero means the check is disabled
+func (assistant *CleverChatty) maxRepeatedToolCalls() int {
+	if assistant.config.MaxRepeatedToolCalls < 0 {
+		return 0
+	}
+	if assistant.config.MaxRepeatedToolCalls == 0 {
+		return defaultMaxRepeatedToolCalls
+	}
+	return assistant.config.MaxRepeatedToolCalls
+}
+
+// countToolCallRepeats registers a tool call and returns how many times in a row
+// the same tool was requested with the same arguments. Returns 0 when the check is disabled
+func (assistant *CleverChatty) countToolCallRepeats(name string, input []byte) int {
+	if assistant.maxRepeatedToolCalls() == 0 {
+		return 0
+	}
+	hash := sha256.Sum256(input)
+	signature := name + ":" + hex.EncodeToString(hash[:])
+
+	if signature != assistant.lastToolCall {
+		assistant.lastToolCall = signature
+		assistant.toolCallRepeats = 0
+	}
+	assistant.toolCallRepeats++
+
+	return assistant.toolCallRepeats
+}
@@ -2,23 +2,18 @@ package core
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/gelembjuk/cleverchatty/core/history"
+	"github.com/gelembjuk/cleverchatty/core/llm"
 )
 
 func TestBasicChat(t *testing.T) {
-	cleverChattyObj, err := GetCleverChatty(CleverChattyConfig{
-		Model:        "mock:mock",
-		ToolsServers: map[string]ServerConfigWrapper{},
-	}, context.Background())
-
-	if err != nil {
-		t.Fatalf("Failed to create CleverChatty object: %v", err)
-	}
-
-	err = cleverChattyObj.Init()
-	if err != nil {
-		t.Fatalf("Failed to init CleverChatty object: %v", err)
-	}
+	cleverChattyObj := newTestAssistant(t, CleverChattyConfig{})
 
 	cleverChattyObj.Callbacks.SetResponseReceived(func(response string) error {
 		if response != "FAKE_RESPONSE:Hello, how are you?" {
@@ -27,7 +22,7 @@ func TestBasicChat(t *testing.T) {
 		return nil
 	})
 
-	_, err = cleverChattyObj.Prompt("Hello, how are you?")
+	_, err := cleverChattyObj.Prompt("Hello, how are you?")
 
 	if err != nil {
 		t.Fatalf("Failed to prompt: %v", err)
@@ -43,8 +38,7 @@ func TestChatWithTool(t *testing.T) {
 	// The internal server config with Kind="mock" is not yet supported
 	t.Skip("Skipping: mock MCP server infrastructure not yet implemented")
 
-	cleverChattyObj, err := GetCleverChatty(CleverChattyConfig{
-		Model: "mock:mock",
+	cleverChattyObj := newTestAssistant(t, CleverChattyConfig{
 		ToolsServers: map[string]ServerConfigWrapper{
 			"test": {
 				Config: InternalServerConfig{
@@ -52,16 +46,7 @@ func TestChatWithTool(t *testing.T) {
 				},
 			},
 		},
-	}, context.Background())
-
-	if err != nil {
-		t.Fatalf("Failed to create CleverChatty object: %v", err)
-	}
-
-	err = cleverChattyObj.Init()
-	if err != nil {
-		t.Fatalf("Failed to init CleverChatty object: %v", err)
-	}
+	})
 
 	responseRecevied := false
 
@@ -79,7 +64,7 @@ func TestChatWithTool(t *testing.T) {
 		return nil
 	})
 
-	_, err = cleverChattyObj.Prompt("tool:1:Hello, how are you?")
+	_, err := cleverChattyObj.Prompt("tool:1:Hello, how are you?")
 
 	if err != nil {
 		t.Fatalf("Failed to prompt: %v", err)
@@ -89,3 +74,403 @@ func TestChatWithTool(t *testing.T) {
 		t.Fatalf("Response not received")
 	}
 }
+
+func TestGreetingAddedBeforeFirstPrompt(t *testing.T) {
+	cleverChattyObj := newTestAssistant(t, CleverChattyConfig{})
+
+	greeting, err := cleverChattyObj.Greet("Introduce yourself")
+	if err != nil {
+		t.Fatalf("Failed to greet: %v", err)
+	}
+	if greeting != "FAKE_RESPONSE:Introduce yourself" {
+		t.Fatalf("Unexpected greeting '%s'", greeting)
+	}
+
+	_, err = cleverChattyObj.Prompt("Hello, how are you?")
+	if err != nil {
+		t.Fatalf("Failed to prompt: %v", err)
+	}
+
+	// The history starts with the greeting prompt and the greeting, then the user prompt and the response
+	if len(cleverChattyObj.messages) != 4 {
+		t.Fatalf("Expected 4 messages, got %d", len(cleverChattyObj.messages))
+	}
+	if cleverChattyObj.messages[0].Role != "user" || cleverChattyObj.messages[0].GetContent() != "Introduce yourself" {
+		t.Errorf("Expected the greeting prompt to be the first message, got '%s'", cleverChattyObj.messages[0].GetContent())
+	}
+	if cleverChattyObj.messages[1].GetContent() != greeting {
+		t.Errorf("Expected the greeting to be the second message, got '%s'", cleverChattyObj.messages[1].GetContent())
+	}
+}
+
+func TestRepeatedToolCallsDetection(t *testing.T) {
+	assistant := &CleverChatty{config: CleverChattyConfig{MaxRepeatedToolCalls: 2}}
+
+	if repeats := assistant.countToolCallRepeats("server__tool", []byte(`{"a":1}`)); repeats != 1 {
+		t.Fatalf("Expected first call to be counted once, got %d", repeats)
+	}
+	if repeats := assistant.countToolCallRepeats("server__tool", []byte(`{"a":1}`)); repeats != 2 {
+		t.Fatalf("Expected identical call to be counted twice, got %d", repeats)
+	}
+	// Different arguments start a new sequence
+	if repeats := assistant.countToolCallRepeats("server__tool", []byte(`{"a":2}`)); repeats != 1 {
+		t.Fatalf("Expected call with other arguments to reset the counter, got %d", repeats)
+	}
+
+	assistant.config.MaxRepeatedToolCalls = -1
+	if repeats := assistant.countToolCallRepeats("server__tool", []byte(`{"a":2}`)); repeats != 0 {
+		t.Fatalf("Expected the check to be disabled, got %d", repeats)
+	}
+}
+
+func TestPromptPreprocessors(t *testing.T) {
+	cleverChattyObj := newTestAssistant(t, CleverChattyConfig{})
+
+	cleverChattyObj.WithPromptPreprocessor(
+		DateTimePreprocessor,
+		func(ctx context.Context, prompt string) (string, error) {
+			return strings.ToUpper(prompt), nil
+		},
+	)
+
+	response, err := cleverChattyObj.Prompt("today is {{date}}")
+	if err != nil {
+		t.Fatalf("Failed to prompt: %v", err)
+	}
+	expected := "FAKE_RESPONSE:TODAY IS " + time.Now().Format("2006-01-02")
+	if response != expected {
+		t.Errorf("Expected response '%s', got '%s'", expected, response)
+	}
+
+	cleverChattyObj.WithPromptPreprocessor(func(ctx context.Context, prompt string) (string, error) {
+		return "", errors.New("unknown macro")
+	})
+	if _, err := cleverChattyObj.Prompt("Hello"); err == nil || !strings.Contains(err.Error(), "unknown macro") {
+		t.Errorf("Expected preprocessor error, got %v", err)
+	}
+}
+
+func TestConversationSearchFindsPrunedMessages(t *testing.T) {
+	cleverChattyObj := newTestAssistant(t, CleverChattyConfig{
+		MessageWindow:      2,
+		ConversationSearch: true,
+	})
+
+	for _, prompt := range []string{"My cat is called Tom", "Hello", "How are you?"} {
+		if _, err := cleverChattyObj.Prompt(prompt); err != nil {
+			t.Fatalf("Failed to prompt: %v", err)
+		}
+	}
+
+	result := cleverChattyObj.toolsHost.callTool("custom", conversationSearchToolName, map[string]interface{}{"query": "CAT"}, context.Background())
+	if result.Error != nil {
+		t.Fatalf("Failed to call search tool: %v", result.Error)
+	}
+	if text := result.getTextContent(); !strings.Contains(text, "[user] My cat is called Tom") {
+		t.Errorf("Expected the pruned message to be found, got '%s'", text)
+	}
+}
+
+func TestConversationSearchFindsToolResults(t *testing.T) {
+	cleverChattyObj := newTestAssistant(t, CleverChattyConfig{
+		ConversationSearch: true,
+	})
+
+	// Tool results with the text and with only the content
+	cleverChattyObj.archivePrunedMessages([]history.HistoryMessage{
+		{Role: "user", Content: []history.ContentBlock{
+			{Type: "tool_result", ToolUseID: "call_1", Text: "Order 1234 is shipped"},
+		}},
+		{Role: "user", Content: []history.ContentBlock{
+			{Type: "tool_result", ToolUseID: "call_2", Content: []history.ContentBlock{{Type: "text", Text: "Order 5678 is delayed"}}},
+		}},
+	})
+
+	for _, query := range []string{"1234", "5678"} {
+		snippets := cleverChattyObj.searchConversation(query)
+		if len(snippets) != 1 || !strings.Contains(snippets[0], "Order "+query) {
+			t.Errorf("Expected the tool result with %s to be found, got %v", query, snippets)
+		}
+	}
+}
+
+func TestPromptMessageWithFile(t *testing.T) {
+	cleverChattyObj := newTestAssistant(t, CleverChattyConfig{
+		WorkDir: t.TempDir(),
+	})
+	defer cleverChattyObj.Finish()
+
+	reference, err := cleverChattyObj.AttachContent("notes.txt", "text/plain", []byte("some notes"))
+	if err != nil {
+		t.Fatalf("Failed to attach content: %v", err)
+	}
+
+	response, err := cleverChattyObj.PromptMessage(history.HistoryMessage{
+		Role: "user",
+		Content: []history.ContentBlock{
+			{Type: "text", Text: "Summarize the file"},
+			history.NewFileReferenceBlock("notes.txt", reference),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to prompt: %v", err)
+	}
+	if !strings.HasPrefix(response, "FAKE_RESPONSE:Summarize the file") || !strings.Contains(response, "- notes.txt: "+reference) {
+		t.Errorf("Expected the prompt to include the text and the file reference, got '%s'", response)
+	}
+}
+
+func TestPromptCtxDeadline(t *testing.T) {
+	// A slow LLM answering only when the request is cancelled
+	cleverChattyObj := newTestAssistant(t, CleverChattyConfig{}, func(next llm.CreateMessageFunc) llm.CreateMessageFunc {
+		return func(ctx context.Context, prompt string, messages []llm.Message, tools []llm.Tool) (llm.Message, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := cleverChattyObj.PromptCtx(ctx, "Hello"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the prompt to stop at the deadline, got %v", err)
+	}
+}
+
+func TestProviderTimeout(t *testing.T) {
+	var calls atomic.Int32
+	// A hung LLM connection answering only when the request is cancelled
+	cleverChattyObj := newTestAssistant(t, CleverChattyConfig{ProviderTimeout: 1}, func(next llm.CreateMessageFunc) llm.CreateMessageFunc {
+		return func(ctx context.Context, prompt string, messages []llm.Message, tools []llm.Tool) (llm.Message, error) {
+			calls.Add(1)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+	})
+
+	if _, err := cleverChattyObj.Prompt("Hello"); !errors.Is(err, ErrProviderTimeout) {
+		t.Errorf("Expected ErrProviderTimeout, got %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Expected the timed out request to be retried once, got %d calls", calls.Load())
+	}
+}
+
+func TestProviderTimeoutOverride(t *testing.T) {
+	config := CleverChattyConfig{
+		ProviderTimeout: 30,
+		OpenAI:          OpenAIConfig{Timeout: 10},
+		Ollama:          OllamaConfig{Timeout: 600},
+	}
+	tests := map[string]time.Duration{
+		"openai:gpt-4o":           10 * time.Second,
+		"ollama:qwen2.5:3b":       600 * time.Second,
+		"anthropic:claude-sonnet": 30 * time.Second,
+	}
+	for model, expected := range tests {
+		config.Model = model
+		assistant := &CleverChatty{config: config}
+		if timeout := assistant.providerTimeout(); timeout != expected {
+			t.Errorf("Expected timeout %v for %s, got %v", expected, model, timeout)
+		}
+	}
+}
+
+func TestMaxPromptBytes(t *testing.T) {
+	cleverChattyObj := newTestAssistant(t, CleverChattyConfig{
+		MaxPromptBytes: 10,
+	})
+
+	if _, err := cleverChattyObj.Prompt("Hello"); err != nil {
+		t.Fatalf("Expected a short prompt to be processed, got %v", err)
+	}
+	if _, err := cleverChattyObj.Prompt("Hello, how are you?"); !errors.Is(err, ErrPromptTooLarge) {
+		t.Errorf("Expected ErrPromptTooLarge, got %v", err)
+	}
+}
+
+func TestSetSystemInstruction(t *testing.T) {
+	cleverChattyObj := newTestAssistant(t, CleverChattyConfig{
+		AgentID:           "agent1",
+		SystemInstruction: "You are the agent {AGENT_ID}.",
+	})
+
+	if _, err := cleverChattyObj.Prompt("Hello"); err != nil {
+		t.Fatalf("Failed to process prompt: %v", err)
+	}
+	cleverChattyObj.SetSystemInstruction("You are the translator {AGENT_ID}.")
+	if instruction := cleverChattyObj.GetSystemInstruction(); instruction != "You are the translator agent1." {
+		t.Errorf("Unexpected system instruction %q", instruction)
+	}
+	if _, err := cleverChattyObj.Prompt("Hello again"); err != nil {
+		t.Fatalf("Failed to process prompt: %v", err)
+	}
+
+	systemMessages := 0
+	for _, msg := range cleverChattyObj.GetMessages() {
+		if msg.Role == "system" {
+			systemMessages++
+		}
+	}
+	messages := cleverChattyObj.GetMessages()
+	if systemMessages != 1 || messages[0].Content[0].Text != "You are the translator agent1." {
+		t.Errorf("Expected the system message to be replaced, got %+v", messages)
+	}
+}
+
+func TestIdentityInstruction(t *testing.T) {
+	config := CleverChattyConfig{
+		Model:               "mock:mock",
+		SystemInstruction:   "Help the customers of {AGENT_ORG}.",
+		IdentityInstruction: true,
+		ToolsServers:        map[string]ServerConfigWrapper{},
+	}
+	config.A2AServerConfig.Title = "Secretary"
+	config.A2AServerConfig.Organization = "Acme"
+
+	cleverChattyObj, err := GetCleverChatty(config, context.Background())
+	if err != nil {
+		t.Fatalf("Failed to create CleverChatty object: %v", err)
+	}
+	expected := "You are Secretary, an AI agent of Acme. Use this name when you are asked who you are.\n\n" +
+		"Help the customers of Acme."
+	if instruction := cleverChattyObj.GetSystemInstruction(); instruction != expected {
+		t.Errorf("Expected the identity before the system instruction, got %q", instruction)
+	}
+
+	config.IdentityTemplate = "Your name is {AGENT_NAME}."
+	cleverChattyObj, _ = GetCleverChatty(config, context.Background())
+	if instruction := cleverChattyObj.GetSystemInstruction(); instruction != "Your name is Secretary.\n\nHelp the customers of Acme." {
+		t.Errorf("Expected the custom identity template, got %q", instruction)
+	}
+
+	config.IdentityInstruction = false
+	cleverChattyObj, _ = GetCleverChatty(config, context.Background())
+	if instruction := cleverChattyObj.GetSystemInstruction(); instruction != "Help the customers of Acme." {
+		t.Errorf("Expected no identity when it is disabled, got %q", instruction)
+	}
+}
+
+func TestForgetWithoutMemoryServer(t *testing.T) {
+	cleverChattyObj := newTestAssistant(t, CleverChattyConfig{})
+
+	if _, err := cleverChattyObj.Forget(context.Background(), "my address"); !errors.Is(err, ErrForgetNotSupported) {
+		t.Errorf("Expected ErrForgetNotSupported, got %v", err)
+	}
+}
+
+func TestForgetToolFollowsMemoryServer(t *testing.T) {
+	cleverChattyObj := newTestAssistant(t, CleverChattyConfig{})
+	defer cleverChattyObj.Finish()
+	if cleverChattyObj.toolsHost.hasCustomTool(forgetMemoryToolName) {
+		t.Fatalf("Expected no forget tool without a memory server")
+	}
+
+	memory := ServerConfigWrapper{
+		Config:    HTTPStreamingMCPServerConfig{Url: newTestMCPServer(t, memoryToolForgetName).URL + "/mcp"},
+		Interface: toolsServerInterfaceMemory,
+	}
+	if err := cleverChattyObj.ApplyToolsServersConfig(map[string]ServerConfigWrapper{"memory": memory}); err != nil {
+		t.Fatalf("Failed to apply the config: %v", err)
+	}
+	if !cleverChattyObj.toolsHost.hasCustomTool(forgetMemoryToolName) {
+		t.Errorf("Expected the forget tool after the memory server is added")
+	}
+
+	if err := cleverChattyObj.ApplyToolsServersConfig(map[string]ServerConfigWrapper{}); err != nil {
+		t.Fatalf("Failed to apply the config: %v", err)
+	}
+	if cleverChattyObj.toolsHost.hasCustomTool(forgetMemoryToolName) {
+		t.Errorf("Expected no forget tool after the memory server is removed")
+	}
+	if _, err := cleverChattyObj.Forget(context.Background(), "my address"); !errors.Is(err, ErrForgetNotSupported) {
+		t.Errorf("Expected ErrForgetNotSupported after the memory server is removed, got %v", err)
+	}
+}
+
+func TestMemoryInjectionModes(t *testing.T) {
+	for _, mode := range []string{MemoryInjectionModeNote, MemoryInjectionModeSystem, MemoryInjectionModePrompt} {
+		cleverChattyObj := newTestAssistant(t, CleverChattyConfig{
+			SystemInstruction:   "You are the helpful assistant.",
+			MemoryInjectionMode: mode,
+			MemoryTemplate:      "Known facts: {MEMORIES}",
+		})
+		newTestMemoryServer(t, cleverChattyObj, "User likes tea")
+
+		response, err := cleverChattyObj.Prompt("Hello")
+		if err != nil {
+			t.Fatalf("Failed to process prompt: %v", err)
+		}
+		messages := cleverChattyObj.GetMessages()
+
+		switch mode {
+		case MemoryInjectionModeNote:
+			if !messages[1].IsMemoryNote() || messages[1].Content[0].Text != "Known facts: User likes tea" {
+				t.Errorf("Expected the memory note before the prompt, got %+v", messages)
+			}
+		case MemoryInjectionModeSystem:
+			if !messages[1].IsMemoryNote() {
+				t.Errorf("Expected the memory note after the system instruction, got %+v", messages)
+			}
+		case MemoryInjectionModePrompt:
+			if response != "FAKE_RESPONSE:Known facts: User likes tea\n\nHello" {
+				t.Errorf("Expected memories in the prompt, got %q", response)
+			}
+			for _, msg := range messages {
+				if msg.IsMemoryNote() {
+					t.Errorf("Expected no memory note in the prompt mode")
+				}
+			}
+		}
+	}
+}
+
+func TestPromptWithOptionsSkipMemory(t *testing.T) {
+	cleverChattyObj := newTestAssistant(t, CleverChattyConfig{
+		MemoryInjectionMode: MemoryInjectionModePrompt,
+	})
+	newTestMemoryServer(t, cleverChattyObj, "User likes tea")
+
+	options := PromptOptionsFromMetadata(PromptOptions{SkipMemory: true}.Metadata())
+	if !options.SkipMemory || options.SkipRAG {
+		t.Fatalf("Unexpected options from metadata %+v", options)
+	}
+
+	response, err := cleverChattyObj.PromptWithOptions(context.Background(), history.NewUserPromptMessage("Hello"), options)
+	if err != nil {
+		t.Fatalf("Failed to process prompt: %v", err)
+	}
+	if response != "FAKE_RESPONSE:Hello" {
+		t.Errorf("Expected the prompt without memories, got %q", response)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	providerRequestID := ""
+	cleverChattyObj := newTestAssistant(t, CleverChattyConfig{}, func(next llm.CreateMessageFunc) llm.CreateMessageFunc {
+		return func(ctx context.Context, prompt string, messages []llm.Message, tools []llm.Tool) (llm.Message, error) {
+			providerRequestID = RequestIDFromContext(ctx)
+			return next(ctx, prompt, messages, tools)
+		}
+	})
+
+	callbackRequestID := ""
+	cleverChattyObj.Callbacks.SetStartedPromptProcessing(func(prompt string) error {
+		callbackRequestID = cleverChattyObj.RequestID()
+		return nil
+	})
+
+	if _, err := cleverChattyObj.PromptCtx(WithRequestID(context.Background(), "task-1"), "Hello"); err != nil {
+		t.Fatalf("Failed to prompt: %v", err)
+	}
+	if providerRequestID != "task-1" || callbackRequestID != "task-1" {
+		t.Errorf("Expected the given request ID, got %q in the provider and %q in the callback", providerRequestID, callbackRequestID)
+	}
+
+	if _, err := cleverChattyObj.Prompt("Hello again"); err != nil {
+		t.Fatalf("Failed to prompt: %v", err)
+	}
+	if providerRequestID == "" || providerRequestID == "task-1" {
+		t.Errorf("Expected a generated request ID, got %q", providerRequestID)
+	}
+}
@@ -0,0 +1,155 @@
+package core
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gelembjuk/cleverchatty/core/history"
+)
+
+// defaultAttachmentMaxSize is the size limit of attached files when it is not configured
+const defaultAttachmentMaxSize = 10 * 1024 * 1024
+
+// defaultAttachmentMimeTypes are allowed when the allow-list is not configured
+var defaultAttachmentMimeTypes = []string{
+	"text/*",
+	"application/json",
+	"application/pdf",
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/webp",
+}
+
+// AttachFile loads a local file into the file cache and returns a file reference.
+// The reference can be included in a prompt, when the LLM passes it to a tool
+// argument it is replaced with the file content. Text files are passed as is,
+// other files are passed base64 encoded.
+func (assistant *CleverChatty) AttachFile(path string) (string, error) {
+	if assistant.toolsHost == nil || assistant.toolsHost.fileCache == nil {
+		return "", fmt.Errorf("file cache is not initialized, call Init() first")
+	}
+
+	maxSize := assistant.config.AttachmentsConfig.MaxSize
+	if maxSize <= 0 {
+		maxSize = defaultAttachmentMaxSize
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", path)
+	}
+	if info.Size() > maxSize {
+		return "", fmt.Errorf("file %s is too large: %d bytes, the limit is %d bytes", path, info.Size(), maxSize)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+
+	reference, err := assistant.AttachContent(filepath.Base(path), detectMimeType(path, data), data)
+	if err != nil {
+		return "", fmt.Errorf("failed to attach file %s: %w", path, err)
+	}
+	return reference, nil
+}
+
+// AttachContent stores file content received from a client in the file cache and returns
+// a file reference, like AttachFile does for local files. The same size and type limits apply.
+func (assistant *CleverChatty) AttachContent(name string, mimeType string, data []byte) (string, error) {
+	if assistant.toolsHost == nil || assistant.toolsHost.fileCache == nil {
+		return "", fmt.Errorf("file cache is not initialized, call Init() first")
+	}
+
+	maxSize := assistant.config.AttachmentsConfig.MaxSize
+	if maxSize <= 0 {
+		maxSize = defaultAttachmentMaxSize
+	}
+	if int64(len(data)) > maxSize {
+		return "", fmt.Errorf("file %s is too large: %d bytes, the limit is %d bytes", name, len(data), maxSize)
+	}
+
+	if mimeType == "" {
+		mimeType = detectMimeType(name, data)
+	}
+	allowed := assistant.config.AttachmentsConfig.AllowedMimeTypes
+	if len(allowed) == 0 {
+		allowed = defaultAttachmentMimeTypes
+	}
+	if !isMimeTypeAllowed(mimeType, allowed) {
+		return "", fmt.Errorf("files of type %s are not allowed to be attached", mimeType)
+	}
+
+	fileCache := assistant.toolsHost.fileCache
+	var filename string
+	var err error
+	if strings.HasPrefix(mimeType, "text/") || mimeType == "application/json" {
+		filename, err = fileCache.SaveContent(data, mimeType)
+	} else {
+		filename, err = fileCache.SaveBase64Content(base64.StdEncoding.EncodeToString(data), mimeType)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to store file %s: %w", name, err)
+	}
+
+	assistant.logger.Printf("Attached file %s as %s (mime: %s)", name, filename, mimeType)
+	return encodeFileRef(filename, mimeType), nil
+}
+
+// detectMimeType detects the MIME type by the file extension, or by the content if the extension is unknown
+func detectMimeType(path string, data []byte) string {
+	mimeType := mime.TypeByExtension(filepath.Ext(path))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil {
+		return mediaType
+	}
+	return mimeType
+}
+
+func isMimeTypeAllowed(mimeType string, allowed []string) bool {
+	for _, pattern := range allowed {
+		if pattern == mimeType || pattern == "*/*" {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok && strings.HasPrefix(mimeType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// imageBlocks returns the images referenced by the file blocks of a user's message.
+// The references stay in the prompt, so tools can still receive the files
+func (assistant *CleverChatty) imageBlocks(msg history.HistoryMessage) ([]history.ContentBlock, error) {
+	images := []history.ContentBlock{}
+	for _, block := range msg.Content {
+		if block.Type != "file" {
+			continue
+		}
+		file, ok := assistant.DescribeFileRef(block.Text)
+		if !ok || !strings.HasPrefix(file.MimeType, "image/") {
+			continue
+		}
+		if !assistant.capabilities.Images {
+			return nil, fmt.Errorf("%w: %s can not see the attached image %s", ErrImagesNotSupported, assistant.config.Model, block.Name)
+		}
+		// Images are stored base64 encoded in the file cache
+		data, err := assistant.toolsHost.fileCache.ReadFile(file.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the attached image %s: %w", block.Name, err)
+		}
+		images = append(images, history.NewImageBlock(block.Name, file.MimeType, data))
+	}
+	return images, nil
+}
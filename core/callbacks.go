@@ -1,15 +1,32 @@
 package core
 
+import (
+	"sync"
+	"time"
+)
+
 var (
 	CallbackCodePromptProcessing = "prompt_accepted"
 	CallbackCodeStartedThinking  = "thinking"
 	CallbackCodeResponseReceived = "response_received"
 	CallbackCodeToolCalling      = "tool_calling"
 	CallbackCodeToolCallFailed   = "tool_error"
+	CallbackCodeToolCallFinished = "tool_finished"
+	CallbackCodeToolsChanged     = "tools_changed"
 	CallbackCodeMemoryRetrieval  = "memory_retrieval"
 	CallbackCodeRAGRetrieval     = "rag_retrieval"
+	CallbackCodeRAGPreprocessing = "rag_preprocessing"
+	CallbackCodeNotification     = "notification"
+	CallbackCodeReasoning        = "reasoning"
+	CallbackCodeToolArguments    = "tool_arguments"
+	CallbackCodeToolResult       = "tool_result"
 )
 
+// callbacksMux guards the functions of all UICallbacks. Callbacks are set by the prompt
+// processing and called from goroutines of tools servers too. UICallbacks is copied by
+// value, so the lock can not be its field
+var callbacksMux sync.RWMutex
+
 type UICallbacks struct {
 	// Is called when a prompt processing started. It could be used to display a user's prompt in a progressing state.
 	startedPromptProcessing func(prompt string) error
@@ -19,102 +36,291 @@ type UICallbacks struct {
 	responseReceived func(response string) error
 	// Tool is called
 	toolCalling func(tool string) error
+	// Tool is called with the arguments (JSON)
+	toolArguments func(tool string, arguments string) error
+	// Tool returned the result
+	toolResultReceived func(tool string, result string) error
 	// Tool call failed. After this the empty response is reported
 	// NOTE. This can be changed later to have something more intelligent here
 	toolCallFailed func(tool string, err error) error
+	// Tool call returned, successfully or not, after the elapsed time
+	toolCallFinished func(tool string, elapsed time.Duration) error
+	// tools list of a server changed (the server sent notifications/tools/list_changed)
+	toolsChanged func(server string) error
 	// request to the memory server started
 	memoryRetrievalStarted func() error
 	// request to the RAG server started
 	ragRetrievalStarted func() error
+	// the prompt is refined by LLM before the RAG request
+	ragPreprocessing func() error
+	// notification received from a tools server (e.g. progress of a running tool)
+	notificationReceived func(notification Notification) error
+	// the reasoning of the model in the extended thinking mode
+	reasoningReceived func(text string) error
 }
 
 // SetStartedPromptProcessing sets the callback function to be called when a prompt processing starts
 func (c *UICallbacks) SetStartedPromptProcessing(f func(prompt string) error) {
+	callbacksMux.Lock()
+	defer callbacksMux.Unlock()
 	c.startedPromptProcessing = f
 }
 
 // call startedPromptProcessing if it is set
 func (c *UICallbacks) CallStartedPromptProcessing(prompt string) error {
-	if c.startedPromptProcessing != nil {
-		return c.startedPromptProcessing(prompt)
+	callbacksMux.RLock()
+	f := c.startedPromptProcessing
+	callbacksMux.RUnlock()
+
+	if f != nil {
+		return f(prompt)
 	}
 	return nil
 }
 
 // SetStartedThinking sets the callback function to be called when a prompt processing starts
 func (c *UICallbacks) SetStartedThinking(f func() error) {
+	callbacksMux.Lock()
+	defer callbacksMux.Unlock()
 	c.startedThinking = f
 }
 
 // call startedThinking if it is set
 func (c *UICallbacks) CallStartedThinking() error {
-	if c.startedThinking != nil {
-		return c.startedThinking()
+	callbacksMux.RLock()
+	f := c.startedThinking
+	callbacksMux.RUnlock()
+
+	if f != nil {
+		return f()
 	}
 	return nil
 }
 
 // SetResponseReceived sets the callback function to be called when a response is received
 func (c *UICallbacks) SetResponseReceived(f func(response string) error) {
+	callbacksMux.Lock()
+	defer callbacksMux.Unlock()
 	c.responseReceived = f
 }
 
 // call responseReceived if it is set
 func (c *UICallbacks) CallResponseReceived(response string) error {
-	if c.responseReceived != nil {
-		return c.responseReceived(response)
+	callbacksMux.RLock()
+	f := c.responseReceived
+	callbacksMux.RUnlock()
+
+	if f != nil {
+		return f(response)
 	}
 	return nil
 }
 
 // SetToolCalling sets the callback function to be called when a tool is called
 func (c *UICallbacks) SetToolCalling(f func(tool string) error) {
+	callbacksMux.Lock()
+	defer callbacksMux.Unlock()
 	c.toolCalling = f
 }
 
 // call toolCalling if it is set
 func (c *UICallbacks) CallToolCalling(tool string) error {
-	if c.toolCalling != nil {
-		return c.toolCalling(tool)
+	callbacksMux.RLock()
+	f := c.toolCalling
+	callbacksMux.RUnlock()
+
+	if f != nil {
+		return f(tool)
+	}
+	return nil
+}
+
+// SetToolArguments sets the callback function to be called with the JSON arguments of a tool call
+func (c *UICallbacks) SetToolArguments(f func(tool string, arguments string) error) {
+	callbacksMux.Lock()
+	defer callbacksMux.Unlock()
+	c.toolArguments = f
+}
+
+// call toolArguments if it is set
+func (c *UICallbacks) CallToolArguments(tool string, arguments string) error {
+	callbacksMux.RLock()
+	f := c.toolArguments
+	callbacksMux.RUnlock()
+
+	if f != nil {
+		return f(tool, arguments)
+	}
+	return nil
+}
+
+// SetToolResultReceived sets the callback function to be called when a tool returns the result
+func (c *UICallbacks) SetToolResultReceived(f func(tool string, result string) error) {
+	callbacksMux.Lock()
+	defer callbacksMux.Unlock()
+	c.toolResultReceived = f
+}
+
+// call toolResultReceived if it is set
+func (c *UICallbacks) CallToolResultReceived(tool string, result string) error {
+	callbacksMux.RLock()
+	f := c.toolResultReceived
+	callbacksMux.RUnlock()
+
+	if f != nil {
+		return f(tool, result)
 	}
 	return nil
 }
 
 // SetToolCallFailed sets the callback function to be called when a tool call fails
 func (c *UICallbacks) SetToolCallFailed(f func(tool string, err error) error) {
+	callbacksMux.Lock()
+	defer callbacksMux.Unlock()
 	c.toolCallFailed = f
 }
 
 // call toolCallFailed if it is set
 func (c *UICallbacks) CallToolCallFailed(tool string, err error) error {
-	if c.toolCallFailed != nil {
-		return c.toolCallFailed(tool, err)
+	callbacksMux.RLock()
+	f := c.toolCallFailed
+	callbacksMux.RUnlock()
+
+	if f != nil {
+		return f(tool, err)
+	}
+	return nil
+}
+
+// SetToolCallFinished sets the callback function to be called when a tool call returns
+func (c *UICallbacks) SetToolCallFinished(f func(tool string, elapsed time.Duration) error) {
+	callbacksMux.Lock()
+	defer callbacksMux.Unlock()
+	c.toolCallFinished = f
+}
+
+// call toolCallFinished if it is set
+func (c *UICallbacks) CallToolCallFinished(tool string, elapsed time.Duration) error {
+	callbacksMux.RLock()
+	f := c.toolCallFinished
+	callbacksMux.RUnlock()
+
+	if f != nil {
+		return f(tool, elapsed)
+	}
+	return nil
+}
+
+// SetToolsChanged sets the callback function to be called when the tools list of a server changed
+func (c *UICallbacks) SetToolsChanged(f func(server string) error) {
+	callbacksMux.Lock()
+	defer callbacksMux.Unlock()
+	c.toolsChanged = f
+}
+
+// call toolsChanged if it is set
+func (c *UICallbacks) CallToolsChanged(server string) error {
+	callbacksMux.RLock()
+	f := c.toolsChanged
+	callbacksMux.RUnlock()
+
+	if f != nil {
+		return f(server)
 	}
 	return nil
 }
 
 // SetMemoryRetrievalStarted sets the callback function to be called when a memory retrieval starts
 func (c *UICallbacks) SetMemoryRetrievalStarted(f func() error) {
+	callbacksMux.Lock()
+	defer callbacksMux.Unlock()
 	c.memoryRetrievalStarted = f
 }
 
 // call memoryRetrievalStarted if it is set
 func (c *UICallbacks) CallMemoryRetrievalStarted() error {
-	if c.memoryRetrievalStarted != nil {
-		return c.memoryRetrievalStarted()
+	callbacksMux.RLock()
+	f := c.memoryRetrievalStarted
+	callbacksMux.RUnlock()
+
+	if f != nil {
+		return f()
 	}
 	return nil
 }
 
 // SetRAGRetrievalStarted sets the callback function to be called when a RAG retrieval starts
 func (c *UICallbacks) SetRAGRetrievalStarted(f func() error) {
+	callbacksMux.Lock()
+	defer callbacksMux.Unlock()
 	c.ragRetrievalStarted = f
 }
 
 // call ragRetrievalStarted if it is set
 func (c *UICallbacks) CallRAGRetrievalStarted() error {
-	if c.ragRetrievalStarted != nil {
-		return c.ragRetrievalStarted()
+	callbacksMux.RLock()
+	f := c.ragRetrievalStarted
+	callbacksMux.RUnlock()
+
+	if f != nil {
+		return f()
+	}
+	return nil
+}
+
+// SetRAGPreprocessing sets the callback function to be called when the prompt is refined for the RAG request
+func (c *UICallbacks) SetRAGPreprocessing(f func() error) {
+	callbacksMux.Lock()
+	defer callbacksMux.Unlock()
+	c.ragPreprocessing = f
+}
+
+// call ragPreprocessing if it is set
+func (c *UICallbacks) CallRAGPreprocessing() error {
+	callbacksMux.RLock()
+	f := c.ragPreprocessing
+	callbacksMux.RUnlock()
+
+	if f != nil {
+		return f()
+	}
+	return nil
+}
+
+// SetNotificationReceived sets the callback function to be called when a tools server sends a notification
+func (c *UICallbacks) SetNotificationReceived(f func(notification Notification) error) {
+	callbacksMux.Lock()
+	defer callbacksMux.Unlock()
+	c.notificationReceived = f
+}
+
+// call notificationReceived if it is set
+func (c *UICallbacks) CallNotificationReceived(notification Notification) error {
+	callbacksMux.RLock()
+	f := c.notificationReceived
+	callbacksMux.RUnlock()
+
+	if f != nil {
+		return f(notification)
+	}
+	return nil
+}
+
+// SetReasoningReceived sets the callback function to be called when the model shares its reasoning
+func (c *UICallbacks) SetReasoningReceived(f func(text string) error) {
+	callbacksMux.Lock()
+	defer callbacksMux.Unlock()
+	c.reasoningReceived = f
+}
+
+// call reasoningReceived if it is set
+func (c *UICallbacks) CallReasoningReceived(text string) error {
+	callbacksMux.RLock()
+	f := c.reasoningReceived
+	callbacksMux.RUnlock()
+
+	if f != nil {
+		return f(text)
 	}
 	return nil
 }
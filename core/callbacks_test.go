@@ -0,0 +1,27 @@
+package core
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestCallbacksConcurrentUse(t *testing.T) {
+	callbacks := UICallbacks{}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		// Notifications of tools servers arrive while the callbacks are replaced
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			callbacks.CallNotificationReceived(Notification{Method: "notifications/progress"})
+		}
+	}()
+
+	for i := 0; i < 1000; i++ {
+		callbacks.SetNotificationReceived(func(notification Notification) error {
+			return nil
+		})
+		callbacks.SetNotificationReceived(nil)
+	}
+	wg.Wait()
+}
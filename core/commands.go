@@ -133,6 +133,10 @@ func (assistant *CleverChatty) handleHistoryCommand() string {
 			switch block.Type {
 			case "text":
 				result.WriteString(block.Text + "\n")
+			case "thinking":
+				result.WriteString("[Thinking]\n" + block.Text + "\n")
+			case "image":
+				result.WriteString(fmt.Sprintf("[Image: %s (%s)]\n", block.Name, block.MimeType))
 			case "tool_use":
 				result.WriteString(fmt.Sprintf("[Tool Use: %s]\n", block.Name))
 				if block.Input != nil {
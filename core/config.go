@@ -1,11 +1,15 @@
 package core
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"path"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -24,34 +28,75 @@ const (
 	defaultMessagesWindow      = 10
 	initialBackoff             = 1 * time.Second
 	maxBackoff                 = 30 * time.Second
-	maxRetries                 = 5    // Will reach close to max backoff
+	maxRetries                 = 5 // Will reach close to max backoff
+	maxProviderTimeoutRetries  = 1
+	defaultProviderTimeout     = 120  // Seconds
 	defaultSessionTimeout      = 3600 // Default session timeout
+	legacyToolsServersKey      = "mcpServers"
+	memoryTemplatePlaceholder  = "{MEMORIES}"
 )
 
+// Memory injection modes define where the recalled memories are placed
+const (
+	// MemoryInjectionModeNote adds memories as a separate note right before the user's prompt
+	MemoryInjectionModeNote = "note"
+	// MemoryInjectionModeSystem adds memories as a system message after the system instruction
+	MemoryInjectionModeSystem = "system"
+	// MemoryInjectionModePrompt prepends memories to the user's prompt
+	MemoryInjectionModePrompt = "prompt"
+)
+
+// defaultNotificationDrainTimeout is the number of seconds to wait for queued notifications on shutdown
+const defaultNotificationDrainTimeout = 30
+
+// defaultRAGPreprocessingTimeout is the number of seconds to wait for the LLM to refine the RAG query
+const defaultRAGPreprocessingTimeout = 30
+
+// defaultMaxRepeatedToolCalls is the number of identical tool calls in a row after which the call is not executed
+const defaultMaxRepeatedToolCalls = 3
+
 const (
 // this will be changed in the future. The text will be removed from here
 // commentOnNotificationReceived = "Notification received from server: %s. The tool %s has been called. The next message is the content of the notification."
 )
 
 type ServerConfig struct {
-	SessionTimeout int `json:"session_timeout"`
+	SessionTimeout   int  `json:"session_timeout"`
+	MaxSessions      int  `json:"max_sessions,omitempty"`       // 0 means unlimited
+	EvictLRUSessions bool `json:"evict_lru_sessions,omitempty"` // Evict the least recently used session instead of rejecting new ones
 }
 
 type OpenAIConfig struct {
-	APIKey       string `json:"apikey"`
-	BaseURL      string `json:"base_url"`
-	DefaultModel string `json:"default_model"`
+	APIKey            string   `json:"apikey"`
+	BaseURL           string   `json:"base_url"`
+	DefaultModel      string   `json:"default_model"`
+	Timeout           int      `json:"timeout,omitempty"`             // Seconds. Overrides provider_timeout
+	ParallelToolCalls *bool    `json:"parallel_tool_calls,omitempty"` // Nil keeps the API default
+	Stop              []string `json:"stop,omitempty"`
+	Seed              *int     `json:"seed,omitempty"`
 }
 
 type AnthropicConfig struct {
-	APIKey       string `json:"apikey"`
-	BaseURL      string `json:"base_url"`
-	DefaultModel string `json:"default_model"`
+	APIKey               string   `json:"apikey"`
+	BaseURL              string   `json:"base_url"`
+	DefaultModel         string   `json:"default_model"`
+	Timeout              int      `json:"timeout,omitempty"`                // Seconds. Overrides provider_timeout
+	ExtendedThinking     bool     `json:"extended_thinking,omitempty"`      // The model reasons before answering
+	ThinkingBudgetTokens int      `json:"thinking_budget_tokens,omitempty"` // 0 means the default (4096), at least 1024
+	Stop                 []string `json:"stop,omitempty"`
 }
 
 type GoogleConfig struct {
-	APIKey       string `json:"apikey"`
-	DefaultModel string `json:"default_model"`
+	APIKey       string   `json:"apikey"`
+	DefaultModel string   `json:"default_model"`
+	Timeout      int      `json:"timeout,omitempty"` // Seconds. Overrides provider_timeout
+	Stop         []string `json:"stop,omitempty"`
+}
+
+type OllamaConfig struct {
+	Timeout int      `json:"timeout,omitempty"` // Seconds. Overrides provider_timeout
+	Stop    []string `json:"stop,omitempty"`
+	Seed    *int     `json:"seed,omitempty"`
 }
 
 type ToolsServerConfig interface {
@@ -59,18 +104,26 @@ type ToolsServerConfig interface {
 }
 
 type STDIOMCPServerConfig struct {
-	Command string            `json:"command"`
-	Args    []string          `json:"args"`
-	Env     map[string]string `json:"env,omitempty"`
+	Command        string            `json:"command"`
+	Args           []string          `json:"args"`
+	Env            map[string]string `json:"env,omitempty"`
+	RestartOnCrash bool              `json:"restart_on_crash,omitempty"` // Start the process again with a backoff if it exits
+	InheritEnv     *bool             `json:"inherit_env,omitempty"`      // Pass the environment of this process to the server. Default true
 }
 
 func (s STDIOMCPServerConfig) GetType() string {
 	return transportStdio
 }
 
+// inheritsEnv returns true if the server process gets the environment of this process
+func (s STDIOMCPServerConfig) inheritsEnv() bool {
+	return s.InheritEnv == nil || *s.InheritEnv
+}
+
 type HTTPStreamingMCPServerConfig struct {
 	Url     string   `json:"url"`
 	Headers []string `json:"headers,omitempty"`
+	TLSClientConfig
 }
 
 func (s HTTPStreamingMCPServerConfig) GetType() string {
@@ -80,6 +133,7 @@ func (s HTTPStreamingMCPServerConfig) GetType() string {
 type SSEMCPServerConfig struct {
 	Url     string   `json:"url"`
 	Headers []string `json:"headers,omitempty"`
+	TLSClientConfig
 }
 
 func (s SSEMCPServerConfig) GetType() string {
@@ -87,8 +141,13 @@ func (s SSEMCPServerConfig) GetType() string {
 }
 
 type A2AToolsServerConfig struct {
-	Endpoint string            `json:"endpoint"`
-	Metadata map[string]string `json:"metadata,omitempty"`
+	Endpoint            string            `json:"endpoint"`
+	Metadata            map[string]string `json:"metadata,omitempty"`
+	TaskPollInterval    int               `json:"task_poll_interval,omitempty"`     // Milliseconds between checks of an unfinished task. 0 means 1000
+	TaskPollMaxAttempts int               `json:"task_poll_max_attempts,omitempty"` // Checks of an unfinished task before giving up. 0 means 5
+	Headers             []string          `json:"headers,omitempty"`                // Sent with every request to the agent, including the agent card request
+	CardTimeout         int               `json:"card_timeout,omitempty"`           // Seconds to wait for the agent card. 0 means 10
+	CardRefreshInterval int               `json:"card_refresh_interval,omitempty"`  // Seconds between checks of the agent card for changed skills. 0 disables
 }
 
 func (s A2AToolsServerConfig) GetType() string {
@@ -125,6 +184,25 @@ type ServerConfigWrapper struct {
 	Disabled                 bool                      `json:"disabled"`
 	Required                 bool                      `json:"required"`
 	NotificationInstructions []NotificationInstruction `json:"notification_instructions,omitempty"`
+	CacheTTL                 int                       `json:"cache_ttl,omitempty"`       // Seconds to cache results of cacheable tools
+	CacheableTools           []string                  `json:"cacheable_tools,omitempty"` // Tools that return the same result for the same arguments
+	SkipArgsValidation       bool                      `json:"skip_args_validation,omitempty"`
+	Timeout                  int                       `json:"timeout,omitempty"`          // Seconds to wait for a tool call result
+	Priority                 int                       `json:"priority,omitempty"`         // Tools of servers with higher priority are listed to the LLM first
+	ProtocolVersion          string                    `json:"protocol_version,omitempty"` // MCP protocol version to request. Empty means the latest
+}
+
+// isToolCacheable returns true if results of the tool can be cached
+func (w ServerConfigWrapper) isToolCacheable(toolName string) bool {
+	if w.CacheTTL <= 0 {
+		return false
+	}
+	for _, name := range w.CacheableTools {
+		if name == toolName {
+			return true
+		}
+	}
+	return false
 }
 
 // GetNotificationInstructions returns the instructions for a given notification method
@@ -147,6 +225,19 @@ type RAGConfig struct {
 	ContextPrefix        string `json:"context_prefix"`
 	RequirePreprocessing bool   `json:"require_preprocessing"`
 	PreprocessingPrompt  string `json:"preprocessing_prompt"`
+	PreprocessingTimeout int    `json:"preprocessing_timeout,omitempty"` // Seconds
+}
+
+// AttachmentsConfig limits the local files that can be attached to prompts
+type AttachmentsConfig struct {
+	MaxSize          int64    `json:"max_size,omitempty"`           // Bytes
+	AllowedMimeTypes []string `json:"allowed_mime_types,omitempty"` // Wildcards like "text/*" are supported
+}
+
+// SamplingConfig controls which MCP servers may request LLM completions (sampling/createMessage)
+type SamplingConfig struct {
+	Enabled        bool     `json:"enabled"`
+	AllowedServers []string `json:"allowed_servers"`
 }
 
 type A2AServerConfig struct {
@@ -159,14 +250,53 @@ type A2AServerConfig struct {
 	Organization         string `json:"organization"`
 	ChatSkillName        string `json:"chat_skill_name,omitempty"`
 	ChatSkillDescription string `json:"chat_skill_description,omitempty"`
+	GreetingPrompt       string `json:"greeting_prompt,omitempty"` // Runs once when a session is created to produce a greeting
+	// AllowSystemInstruction lets clients replace the system instruction of a new session
+	// with the "system_instruction" message metadata
+	AllowSystemInstruction bool `json:"allow_system_instruction,omitempty"`
+	// AllowToolContext lets clients add values to the tool context of a new session
+	// with the "tool_context" message metadata
+	AllowToolContext bool `json:"allow_tool_context,omitempty"`
+	// PromptTimeout limits the processing of a client's message, in seconds. 0 means no limit
+	PromptTimeout int `json:"prompt_timeout,omitempty"`
+	// StreamResumeTimeout is the number of seconds a streaming task waits for its disconnected
+	// client to resubscribe. 0 means the default, negative cancels the task immediately
+	StreamResumeTimeout int `json:"stream_resume_timeout,omitempty"`
+	// PushNotifications lets clients set a webhook to receive the events of their tasks
+	PushNotifications bool `json:"push_notifications,omitempty"`
+	// PushNotificationTimeout is the number of seconds to wait for a webhook. 0 means the default
+	PushNotificationTimeout int `json:"push_notification_timeout,omitempty"`
+	// PushNotificationRetries is the number of retries of a failed delivery. 0 means the default
+	PushNotificationRetries int `json:"push_notification_retries,omitempty"`
+	// PushNotificationAllowedHosts limits webhooks to these hosts. If empty, any host is allowed
+	// except internal addresses
+	PushNotificationAllowedHosts []string `json:"push_notification_allowed_hosts,omitempty"`
+}
+
+// AdminServerConfig defines the HTTP server used by operators to inspect the running daemon
+type AdminServerConfig struct {
+	Enabled           bool   `json:"enabled"`
+	ListenHost        string `json:"listen_host"`
+	AuthToken         string `json:"auth_token"`                    // Required. Sent as "Authorization: Bearer <token>"
+	CheckProvider     bool   `json:"check_provider,omitempty"`      // Check the LLM provider is reachable at startup
+	ProviderHealthTTL int    `json:"provider_health_ttl,omitempty"` // Seconds the provider check result is reused. 0 means default
+	BatchConcurrency  int    `json:"batch_concurrency,omitempty"`   // Prompts of a batch processed at the same time. 0 means default
+}
+
+// TUIConfig customizes the terminal UI of the CLI, for example for a branded deployment
+type TUIConfig struct {
+	WelcomeMessage string   `json:"welcome_message,omitempty"` // Markdown shown when the TUI starts. Empty means the default
+	HelpExtra      []string `json:"help_extra,omitempty"`      // Lines added to the /help output, markdown is supported
 }
 
 // ReverseMCPListenerConfig defines the configuration for the reverse MCP listener
 // This server accepts incoming MCP connections from remote MCP servers via WebSocket
 type ReverseMCPListenerConfig struct {
-	Enabled    bool      `json:"enabled"`
-	ListenHost string    `json:"listen_host"`
-	TLS        TLSConfig `json:"tls,omitempty"` // TLS configuration for secure connections
+	Enabled        bool      `json:"enabled"`
+	ListenHost     string    `json:"listen_host"`
+	TLS            TLSConfig `json:"tls,omitempty"`             // TLS configuration for secure connections
+	MaxConnections int       `json:"max_connections,omitempty"` // Connected servers at most. 0 means unlimited
+	IdleTimeout    int       `json:"idle_timeout,omitempty"`    // Seconds without a message or pong before the connection is closed. 0 means default
 }
 
 // TLSConfig defines TLS/SSL certificate configuration
@@ -188,10 +318,36 @@ type CleverChattyConfig struct {
 	Anthropic                AnthropicConfig                `json:"anthropic"`
 	OpenAI                   OpenAIConfig                   `json:"openai"`
 	Google                   GoogleConfig                   `json:"google"`
+	Ollama                   OllamaConfig                   `json:"ollama,omitempty"`
 	ToolsServers             map[string]ServerConfigWrapper `json:"tools_servers,omitempty"`
 	RAGConfig                RAGConfig                      `json:"rag_settings"`
 	A2AServerConfig          A2AServerConfig                `json:"a2a_settings"`
 	ReverseMCPListenerConfig ReverseMCPListenerConfig       `json:"reverse_mcp_settings"`
+	NotificationDrainTimeout int                            `json:"notification_drain_timeout,omitempty"` // Seconds
+	SamplingConfig           SamplingConfig                 `json:"sampling_settings"`
+	AttachmentsConfig        AttachmentsConfig              `json:"attachments,omitempty"`
+	AdminServerConfig        AdminServerConfig              `json:"admin_settings,omitempty"`
+	TUIConfig                TUIConfig                      `json:"tui_settings,omitempty"`
+	MaxRepeatedToolCalls     int                            `json:"max_repeated_tool_calls,omitempty"`   // 0 means default, negative disables the check
+	ConversationSearch       bool                           `json:"conversation_search,omitempty"`       // Keep pruned messages and let the model search them
+	ListToolsTool            bool                           `json:"list_tools_tool,omitempty"`           // Let the model list its current tools with custom__list_tools
+	FormatJSONToolResults    bool                           `json:"format_json_tool_results,omitempty"`  // Pretty-print JSON tool results in a fenced code block
+	MemoryInjectionMode      string                         `json:"memory_injection_mode,omitempty"`     // note (default), system or prompt
+	MemoryTemplate           string                         `json:"memory_template,omitempty"`           // {MEMORIES} is replaced with the recalled memories
+	MemoryQueueSize          int                            `json:"memory_queue_size,omitempty"`         // Messages waiting to be sent to the memory server. 0 means default
+	StripToolOutputEscapes   bool                           `json:"strip_tool_output_escapes,omitempty"` // Remove ANSI escape sequences and control characters from tool results
+	MaxPromptBytes           int                            `json:"max_prompt_bytes,omitempty"`          // 0 means unlimited
+	ProviderTimeout          int                            `json:"provider_timeout,omitempty"`          // Seconds, limits every LLM request
+	AllToolsFailedGuidance   bool                           `json:"all_tools_failed_guidance,omitempty"` // Tell the model how to continue when every tool call of a turn failed
+	AllToolsFailedMessage    string                         `json:"all_tools_failed_message,omitempty"`  // Empty means the default guidance
+	IdentityInstruction      bool                           `json:"identity_instruction,omitempty"`      // Tell the model its name and organization from a2a_settings
+	IdentityTemplate         string                         `json:"identity_template,omitempty"`         // Empty means the default identity instruction
+	ToolContext              map[string]interface{}         `json:"tool_context,omitempty"`              // Added to the arguments of every tool call, the model can not change it
+	RememberToolTurns        *bool                          `json:"remember_tool_turns,omitempty"`       // Remember the text of responses with tool calls. Default true
+	UserAgent                string                         `json:"user_agent,omitempty"`                // Of requests to tools servers. Empty means CleverChatty/<version>
+	// Ask the model to continue a response cut by the output token limit
+	ContinueTruncatedReplies bool `json:"continue_truncated_replies,omitempty"`
+	MaxReplyContinuations    int  `json:"max_reply_continuations,omitempty"` // 0 means the default (3)
 }
 
 func CreateStandardConfigFile(configPath string) (*CleverChattyConfig, error) {
@@ -240,6 +396,20 @@ func LoadConfig(configPath string) (*CleverChattyConfig, error) {
 	if err := json.Unmarshal(configData, &config); err != nil {
 		return nil, fmt.Errorf("error parsing config file: %w", err)
 	}
+	// A server listed twice would silently replace the first one
+	if err := checkDuplicateServerNames(configData); err != nil {
+		return nil, fmt.Errorf("error parsing config file: %w", err)
+	}
+
+	legacyFound, err := applyLegacyMCPServers(configData, &config)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing config file: %w", err)
+	}
+	if legacyFound {
+		log.Printf("Warning: config file %s uses the deprecated \"%s\" key. "+
+			"Rename it to \"tools_servers\" or run `cleverchatty-server migrate-config`.",
+			configPath, legacyToolsServersKey)
+	}
 
 	if config.MessageWindow <= 0 {
 		config.MessageWindow = defaultMessagesWindow
@@ -251,6 +421,164 @@ func LoadConfig(configPath string) (*CleverChattyConfig, error) {
 	return &config, nil
 }
 
+// checkDuplicateServerNames returns an error if a server name is repeated in the tools servers lists
+func checkDuplicateServerNames(configData []byte) error {
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(configData, &sections); err != nil {
+		return err
+	}
+	for _, key := range []string{"tools_servers", legacyToolsServersKey} {
+		data, ok := sections[key]
+		if !ok {
+			continue
+		}
+		duplicates, err := duplicateJSONKeys(data)
+		if err != nil {
+			return err
+		}
+		if len(duplicates) > 0 {
+			return fmt.Errorf("tools servers are listed more than once in \"%s\": %s", key, strings.Join(duplicates, ", "))
+		}
+	}
+	return nil
+}
+
+// duplicateJSONKeys returns the keys repeated in the JSON object. It returns nothing for other JSON values
+func duplicateJSONKeys(data []byte) ([]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, nil
+	}
+	seen := map[string]bool{}
+	duplicates := []string{}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := token.(string)
+		if seen[key] {
+			duplicates = append(duplicates, key)
+		}
+		seen[key] = true
+
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+	}
+	return duplicates, nil
+}
+
+// applyLegacyMCPServers maps the servers listed under the deprecated "mcpServers" key
+// into ToolsServers. Servers defined in "tools_servers" take precedence on name conflicts.
+// Returns true if the legacy key was present in the config data.
+func applyLegacyMCPServers(configData []byte, config *CleverChattyConfig) (bool, error) {
+	var legacy struct {
+		MCPServers map[string]ServerConfigWrapper `json:"mcpServers"`
+	}
+	if err := json.Unmarshal(configData, &legacy); err != nil {
+		return false, err
+	}
+	if legacy.MCPServers == nil {
+		return false, nil
+	}
+	if config.ToolsServers == nil {
+		config.ToolsServers = make(map[string]ServerConfigWrapper)
+	}
+	for name, server := range legacy.MCPServers {
+		if _, exists := config.ToolsServers[name]; exists {
+			log.Printf("Warning: server %s is listed in both \"tools_servers\" and \"%s\", the \"tools_servers\" one is used",
+				name, legacyToolsServersKey)
+			continue
+		}
+		config.ToolsServers[name] = server
+	}
+	return true, nil
+}
+
+// MigrateConfigFile rewrites a config file that uses the deprecated "mcpServers" key
+// into the current schema. The original file is kept with a ".bak" suffix.
+// Only the key is renamed, the servers and other settings are kept as they are written.
+// Returns false if the config file already uses the current schema.
+func MigrateConfigFile(configPath string) (bool, error) {
+	configData, err := os.ReadFile(configPath)
+	if err != nil {
+		return false, fmt.Errorf(
+			"error reading config file %s: %w",
+			configPath,
+			err,
+		)
+	}
+
+	var config CleverChattyConfig
+	if err := json.Unmarshal(configData, &config); err != nil {
+		return false, fmt.Errorf("error parsing config file: %w", err)
+	}
+
+	// The raw JSON is migrated, re-encoding the config would add the default values
+	// and lose the fields the servers are recognized by
+	var rawConfig map[string]json.RawMessage
+	if err := json.Unmarshal(configData, &rawConfig); err != nil {
+		return false, fmt.Errorf("error parsing config file: %w", err)
+	}
+	legacyData, legacyFound := rawConfig[legacyToolsServersKey]
+	if !legacyFound {
+		return false, nil
+	}
+
+	var legacyServers map[string]json.RawMessage
+	if err := json.Unmarshal(legacyData, &legacyServers); err != nil {
+		return false, fmt.Errorf("error parsing config file: %w", err)
+	}
+	var servers map[string]json.RawMessage
+	if serversData, ok := rawConfig["tools_servers"]; ok {
+		if err := json.Unmarshal(serversData, &servers); err != nil {
+			return false, fmt.Errorf("error parsing config file: %w", err)
+		}
+	}
+	if servers == nil {
+		servers = make(map[string]json.RawMessage)
+	}
+	for name, server := range legacyServers {
+		if _, exists := servers[name]; exists {
+			log.Printf("Warning: server %s is listed in both \"tools_servers\" and \"%s\", the \"tools_servers\" one is kept",
+				name, legacyToolsServersKey)
+			continue
+		}
+		servers[name] = server
+	}
+
+	serversData, err := json.Marshal(servers)
+	if err != nil {
+		return false, fmt.Errorf("error encoding migrated config: %w", err)
+	}
+	rawConfig["tools_servers"] = serversData
+	delete(rawConfig, legacyToolsServersKey)
+
+	newConfigData, err := json.MarshalIndent(rawConfig, "", "  ")
+	if err != nil {
+		return false, fmt.Errorf("error encoding migrated config: %w", err)
+	}
+
+	backupPath := configPath + ".bak"
+	if err := os.WriteFile(backupPath, configData, 0644); err != nil {
+		return false, fmt.Errorf("error writing backup file %s: %w", backupPath, err)
+	}
+	if err := os.WriteFile(configPath, newConfigData, 0644); err != nil {
+		return false, fmt.Errorf(
+			"error writing config file %s: %w",
+			configPath,
+			err,
+		)
+	}
+	return true, nil
+}
+
 func (w *ServerConfigWrapper) UnmarshalJSON(data []byte) error {
 	var typeField struct {
 		Url                      string                    `json:"url"`
@@ -261,6 +589,12 @@ func (w *ServerConfigWrapper) UnmarshalJSON(data []byte) error {
 		Disabled                 bool                      `json:"disabled"`
 		Required                 bool                      `json:"required"`
 		NotificationInstructions []NotificationInstruction `json:"notification_instructions,omitempty"`
+		CacheTTL                 int                       `json:"cache_ttl,omitempty"`
+		CacheableTools           []string                  `json:"cacheable_tools,omitempty"`
+		SkipArgsValidation       bool                      `json:"skip_args_validation,omitempty"`
+		Timeout                  int                       `json:"timeout,omitempty"`
+		Priority                 int                       `json:"priority,omitempty"`
+		ProtocolVersion          string                    `json:"protocol_version,omitempty"`
 	}
 
 	if err := json.Unmarshal(data, &typeField); err != nil {
@@ -270,6 +604,12 @@ func (w *ServerConfigWrapper) UnmarshalJSON(data []byte) error {
 	w.Disabled = typeField.Disabled
 	w.Required = typeField.Required
 	w.NotificationInstructions = typeField.NotificationInstructions
+	w.CacheTTL = typeField.CacheTTL
+	w.CacheableTools = typeField.CacheableTools
+	w.SkipArgsValidation = typeField.SkipArgsValidation
+	w.Timeout = typeField.Timeout
+	w.Priority = typeField.Priority
+	w.ProtocolVersion = typeField.ProtocolVersion
 
 	if typeField.Transport == transportReverseMCP {
 		// Reverse MCP server - remote server connects to us
@@ -325,6 +665,11 @@ func (w ServerConfigWrapper) MarshalJSON() ([]byte, error) {
 		return nil, err
 	}
 
+	// The transport is not detected from the fields for SSE and reverse MCP servers
+	if w.Config.GetType() != transportStdio {
+		result["transport"] = w.Config.GetType()
+	}
+
 	// Add wrapper-level fields
 	if w.Interface != "" {
 		result["interface"] = w.Interface
@@ -338,6 +683,24 @@ func (w ServerConfigWrapper) MarshalJSON() ([]byte, error) {
 	if len(w.NotificationInstructions) > 0 {
 		result["notification_instructions"] = w.NotificationInstructions
 	}
+	if w.CacheTTL > 0 {
+		result["cache_ttl"] = w.CacheTTL
+	}
+	if len(w.CacheableTools) > 0 {
+		result["cacheable_tools"] = w.CacheableTools
+	}
+	if w.SkipArgsValidation {
+		result["skip_args_validation"] = w.SkipArgsValidation
+	}
+	if w.Timeout > 0 {
+		result["timeout"] = w.Timeout
+	}
+	if w.Priority != 0 {
+		result["priority"] = w.Priority
+	}
+	if w.ProtocolVersion != "" {
+		result["protocol_version"] = w.ProtocolVersion
+	}
 
 	return json.Marshal(result)
 }
@@ -364,6 +727,40 @@ func (w ServerConfigWrapper) IsReverseMCPServer() bool {
 	return w.Config.GetType() == transportReverseMCP
 }
 
+// FindReverseMCPServerConfig returns the config key and the config of the reverse MCP server
+// connecting with the name. Keys can be glob patterns like "worker-*", so a fleet of servers
+// with dynamic names can share one config and token. An exact key takes precedence over
+// patterns, patterns are tried in sorted order
+func FindReverseMCPServerConfig(servers map[string]ServerConfigWrapper, serverName string) (string, ServerConfigWrapper, bool) {
+	if config, exists := servers[serverName]; exists {
+		// The name is taken, a pattern can not give it to another server
+		return serverName, config, config.IsReverseMCPServer()
+	}
+	// The name is the prefix of the tools of the server, it must not break them
+	// or give the server the tools names of custom tools
+	if strings.Contains(serverName, "__") || serverName == customToolsServerName {
+		return "", ServerConfigWrapper{}, false
+	}
+
+	patterns := []string{}
+	for key, config := range servers {
+		if config.IsReverseMCPServer() && isReverseMCPPattern(key) {
+			patterns = append(patterns, key)
+		}
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, serverName); err == nil && matched {
+			return pattern, servers[pattern], true
+		}
+	}
+	return "", ServerConfigWrapper{}, false
+}
+
+func isReverseMCPPattern(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
 // GetReverseMCPAuthToken returns the auth token for a reverse MCP server config
 // Returns empty string if the server is not a reverse MCP server or has no token
 func (w ServerConfigWrapper) GetReverseMCPAuthToken() string {
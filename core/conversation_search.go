@@ -0,0 +1,145 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gelembjuk/cleverchatty/core/history"
+)
+
+const (
+	// Built-in tools are custom tools, so the model sees the tool as custom__conversation_search
+	conversationSearchToolName = "conversation_search"
+	// maxArchivedMessages limits the number of pruned messages kept for the search
+	maxArchivedMessages = 1000
+	// maxConversationSearchResults is the number of snippets returned by the search
+	maxConversationSearchResults = 10
+	// conversationSearchSnippetRadius is the number of characters around a match included in a snippet
+	conversationSearchSnippetRadius = 150
+)
+
+const conversationSearchToolDescription = "Search the whole conversation history, including the earlier messages " +
+	"that are not in your context anymore. Returns the matching snippets with the author of each message. " +
+	"Use it when the user refers to something discussed earlier that you do not see."
+
+// archivePrunedMessages keeps the messages removed from the context, so they can be searched
+func (assistant *CleverChatty) archivePrunedMessages(messages []history.HistoryMessage) {
+	if !assistant.config.ConversationSearch {
+		return
+	}
+	assistant.archivedMessages = append(assistant.archivedMessages, messages...)
+	if len(assistant.archivedMessages) > maxArchivedMessages {
+		assistant.archivedMessages = assistant.archivedMessages[len(assistant.archivedMessages)-maxArchivedMessages:]
+	}
+}
+
+// registerConversationSearchTool adds the tool searching the conversation history
+func (assistant *CleverChatty) registerConversationSearchTool() error {
+	return assistant.SetTool(CustomTool{
+		Name:        conversationSearchToolName,
+		Description: conversationSearchToolDescription,
+		Arguments: []ToolArgument{
+			{
+				Name:        "query",
+				Type:        "string",
+				Description: "A word or a phrase to search for",
+				Required:    true,
+			},
+		},
+		Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			query, _ := args["query"].(string)
+			if strings.TrimSpace(query) == "" {
+				return "", fmt.Errorf("query is required")
+			}
+			snippets := assistant.searchConversation(query)
+			if len(snippets) == 0 {
+				return "Nothing found in the conversation history", nil
+			}
+			return strings.Join(snippets, "\n\n"), nil
+		},
+	})
+}
+
+// searchConversation returns snippets of the messages containing the query, the most recent first.
+// Both the archived and the current messages are searched, system messages are skipped.
+func (assistant *CleverChatty) searchConversation(query string) []string {
+	query = strings.ToLower(strings.TrimSpace(query))
+
+	messages := append(append([]history.HistoryMessage{}, assistant.archivedMessages...), assistant.messages...)
+
+	snippets := []string{}
+	for i := len(messages) - 1; i >= 0 && len(snippets) < maxConversationSearchResults; i-- {
+		msg := messages[i]
+		if msg.Role == "system" {
+			continue
+		}
+		for _, block := range msg.Content {
+			text := block.Text
+			switch block.Type {
+			case "tool_use":
+				text = string(block.Input)
+			case "tool_result":
+				text = toolResultText(block)
+			}
+			if snippet, ok := conversationSnippet(text, query); ok {
+				snippets = append(snippets, fmt.Sprintf("[%s] %s", msg.Role, snippet))
+				break
+			}
+		}
+	}
+	return snippets
+}
+
+// toolResultText returns the text of the tool result block. The text is taken from the content
+// when the block has none, for example in the history of a provider filling only the content
+func toolResultText(block history.ContentBlock) string {
+	if block.Text != "" {
+		return block.Text
+	}
+	texts := []string{}
+	switch content := block.Content.(type) {
+	case string:
+		texts = append(texts, content)
+	case []history.ContentBlock:
+		for _, item := range content {
+			if item.Type == "text" {
+				texts = append(texts, item.Text)
+			}
+		}
+	case []history.Content:
+		for _, item := range content {
+			if text, ok := item.(history.TextContent); ok {
+				texts = append(texts, text.Text)
+			}
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// conversationSnippet returns the part of the text around the first match of the lowercased query
+func conversationSnippet(text string, query string) (string, bool) {
+	// Lowercasing can change byte lengths of some characters, search in runes to keep positions aligned
+	runes := []rune(text)
+	lowered := []rune(strings.ToLower(text))
+	if len(lowered) != len(runes) {
+		lowered = runes
+	}
+	idx := strings.Index(string(lowered), query)
+	if idx < 0 {
+		return "", false
+	}
+	pos := len([]rune(string(lowered)[:idx]))
+
+	start := max(pos-conversationSearchSnippetRadius, 0)
+	end := min(pos+len([]rune(query))+conversationSearchSnippetRadius, len(runes))
+
+	snippet := string(runes[start:end])
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(runes) {
+		snippet += "…"
+	}
+	return snippet, true
+}
@@ -98,16 +98,17 @@ func (host *ToolsHost) callCustomTool(toolName string, toolArgs map[string]inter
 
 	if !ok {
 		return ToolCallResult{
-			Error: fmt.Errorf("custom tool %s not found", toolName),
+			Error: fmt.Errorf("%w: custom tool %s", ErrToolNotFound, toolName),
 		}
 	}
 
-	host.logger.Printf("Calling custom tool %s", toolName)
+	host.logger.Printf("%sCalling custom tool %s", logPrefix(ctx), toolName)
 
 	result, err := tool.Handler(ctx, toolArgs)
 	if err != nil {
+		// The error is reported by the tool itself, a timeout of the handler is still classified as a timeout
 		return ToolCallResult{
-			Error: fmt.Errorf("custom tool %s failed: %w", toolName, err),
+			Error: fmt.Errorf("%w: custom tool %s failed: %w", ErrToolReported, toolName, err),
 		}
 	}
 
@@ -148,6 +149,15 @@ func (host *ToolsHost) RemoveCustomTool(name string) {
 	host.logger.Printf("Custom tool %s removed", name)
 }
 
+// hasCustomTool checks if a custom tool with the name is registered
+func (host *ToolsHost) hasCustomTool(name string) bool {
+	host.customToolsMux.RLock()
+	defer host.customToolsMux.RUnlock()
+
+	_, ok := host.customTools[name]
+	return ok
+}
+
 // getCustomToolsForLLM returns all custom tools in llm.Tool format
 func (host *ToolsHost) getCustomToolsForLLM() []llm.Tool {
 	host.customToolsMux.RLock()
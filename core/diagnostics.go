@@ -0,0 +1,149 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+)
+
+// DiagnosticCheck is the result of one check of the Diagnose report
+type DiagnosticCheck struct {
+	Component string // "config", "provider" or "server"
+	Name      string
+	Details   string
+	Err       error
+}
+
+// Passed returns true if the check did not find a problem
+func (c DiagnosticCheck) Passed() bool {
+	return c.Err == nil
+}
+
+// Diagnose checks the parts of the config the agent needs to start: the tools servers
+// config, the credentials of the provider and the connection to every enabled tools server.
+// Every server is connected separately, so all failing servers are reported, not only the first one.
+func Diagnose(ctx context.Context, config CleverChattyConfig) []DiagnosticCheck {
+	logger := log.New(io.Discard, "", log.LstdFlags)
+	checks := []DiagnosticCheck{}
+
+	host, err := newToolsHost(config.ToolsServers, logger, ctx, config.WorkDir)
+	if err == nil {
+		err = host.validateServerNames()
+	}
+	if err == nil {
+		err = host.validateInterfaces()
+	}
+	if err == nil {
+		err = host.validateProtocolVersions()
+	}
+	checks = append(checks, DiagnosticCheck{
+		Component: "config",
+		Name:      "tools servers",
+		Details:   fmt.Sprintf("%d servers configured", len(config.ToolsServers)),
+		Err:       err,
+	})
+
+	checks = append(checks, diagnoseProvider(ctx, config))
+
+	names := make([]string, 0, len(config.ToolsServers))
+	for name := range config.ToolsServers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		checks = append(checks, diagnoseServer(ctx, config, name, logger))
+	}
+	return checks
+}
+
+func diagnoseProvider(ctx context.Context, config CleverChattyConfig) DiagnosticCheck {
+	check := DiagnosticCheck{Component: "provider", Name: config.Model}
+
+	parts := strings.SplitN(config.Model, ":", 2)
+	if len(parts) < 2 {
+		check.Err = fmt.Errorf("invalid model format. Expected provider:model, got %s", config.Model)
+		return check
+	}
+	switch parts[0] {
+	case "anthropic", "openai", "google", "ollama", "mock":
+	default:
+		check.Err = fmt.Errorf("unsupported provider: %s", parts[0])
+		return check
+	}
+	assistant := &CleverChatty{config: config}
+	check.Err = assistant.checkProviderConfig(ctx, parts[0])
+	if check.Err == nil {
+		check.Details = "credentials are set"
+		if parts[0] == "ollama" {
+			check.Details = "server is reachable"
+		}
+	}
+	return check
+}
+
+// diagnoseServer connects to the tools server alone and checks the tools
+// required by its memory or RAG interface
+func diagnoseServer(ctx context.Context, config CleverChattyConfig, name string, logger *log.Logger) DiagnosticCheck {
+	server := config.ToolsServers[name]
+	check := DiagnosticCheck{Component: "server", Name: name}
+
+	if server.Disabled {
+		check.Details = "disabled"
+		return check
+	}
+	if server.IsReverseMCPServer() {
+		check.Details = "waits for the server to connect to the reverse MCP listener"
+		return check
+	}
+
+	host, err := newToolsHost(map[string]ServerConfigWrapper{name: server}, logger, ctx, config.WorkDir)
+	if err == nil {
+		err = host.Init()
+	}
+	if err != nil {
+		check.Err = err
+		return check
+	}
+	defer host.Close()
+
+	if !server.isMCPServer() {
+		check.Details = "connected"
+		return check
+	}
+
+	tools := map[string]bool{}
+	for _, info := range host.getToolsInfo() {
+		if info.Name != name {
+			continue
+		}
+		if info.Err != nil {
+			check.Err = info.Err
+			return check
+		}
+		for _, tool := range info.Tools {
+			tools[tool.Name] = true
+		}
+	}
+	check.Details = fmt.Sprintf("connected, %d tools", len(tools))
+
+	required := []string{}
+	if server.isMemoryServer() {
+		required = append(required, memoryToolRememberName, memoryToolRecallName)
+	}
+	if server.isRAGServer() {
+		required = append(required, ragToolName)
+	}
+	missing := []string{}
+	for _, tool := range required {
+		if !tools[tool] {
+			missing = append(missing, tool)
+		}
+	}
+	if len(missing) > 0 {
+		check.Err = fmt.Errorf("the server does not provide the tools of its interface: %s", strings.Join(missing, ", "))
+	}
+	return check
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gelembjuk/cleverchatty/core/llm"
+)
+
+// Errors returned by the package. Use errors.Is to check them, returned errors
+// are usually wrapped with details.
+var (
+	// ErrProviderOverloaded is returned when the LLM provider is temporarily overloaded
+	ErrProviderOverloaded = llm.ErrProviderOverloaded
+	// ErrProviderNotConfigured is returned when the credentials of the selected LLM provider
+	// are missing or its server is not reachable
+	ErrProviderNotConfigured = errors.New("LLM provider is not configured")
+	// ErrProviderTimeout is returned when the LLM provider does not respond within the provider timeout
+	ErrProviderTimeout = errors.New("LLM provider request timed out")
+	// ErrToolTimeout is returned when a tool does not respond within the server timeout
+	ErrToolTimeout = errors.New("tool call timed out")
+	// ErrToolNotFound is returned when a called tool is not provided by any tools server
+	ErrToolNotFound = errors.New("tool not found")
+	// ErrServerUnavailable is returned when the tools server of a tool is not connected
+	ErrServerUnavailable = errors.New("tools server unavailable")
+	// ErrToolReported is returned when the tool itself reported that the call failed
+	ErrToolReported = errors.New("the tool reported an error")
+	// ErrInvalidToolArguments is returned when the arguments of a tool call do not match the tool schema
+	ErrInvalidToolArguments = errors.New("invalid arguments")
+	// ErrSessionNotFound is returned when there is no session with the requested ID
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrTooManySessions is returned when a new session is requested but the server
+	// already serves the maximum number of sessions
+	ErrTooManySessions = errors.New("too many sessions")
+	// ErrToolCallLoop is returned when the model keeps calling the same tool with the same
+	// arguments after it was told the result is unchanged
+	ErrToolCallLoop = errors.New("tool call loop detected")
+	// ErrEmptyResponse is returned when the model returns neither text nor tool calls,
+	// even after it was asked again
+	ErrEmptyResponse = errors.New("the model returned an empty response")
+	// ErrContentFiltered is returned when the provider blocked the response with its safety filter
+	ErrContentFiltered = errors.New("the response was blocked by the content filter of the provider")
+	// ErrPromptTooLarge is returned when a prompt is larger than the configured limit
+	ErrPromptTooLarge = errors.New("prompt is too large")
+	// ErrImagesNotSupported is returned when an image is attached to a prompt but the model
+	// does not accept images
+	ErrImagesNotSupported = llm.ErrImagesNotSupported
+	// ErrForgetNotSupported is returned when there is no memory server that can delete memories
+	ErrForgetNotSupported = errors.New("forgetting memories is not supported")
+)
+
+// ToolTimeoutError is returned when a tool does not respond within the server timeout.
+// Unlike a cancellation it does not abort the turn, the model is told to proceed without the result.
+// It matches ErrToolTimeout with errors.Is.
+type ToolTimeoutError struct {
+	ToolName string
+	Timeout  time.Duration
+}
+
+func (e *ToolTimeoutError) Error() string {
+	return fmt.Sprintf("Tool %s timed out after %s; proceed without its result or try again", e.ToolName, e.Timeout)
+}
+
+func (e *ToolTimeoutError) Unwrap() error {
+	return ErrToolTimeout
+}
+
+// CheckPromptSize returns ErrPromptTooLarge if the size of a prompt in bytes is over the limit.
+// Zero limit means unlimited.
+func CheckPromptSize(size int64, limit int) error {
+	if limit > 0 && size > int64(limit) {
+		return fmt.Errorf("%w: %d bytes, the limit is %d bytes", ErrPromptTooLarge, size, limit)
+	}
+	return nil
+}
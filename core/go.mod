@@ -7,6 +7,7 @@ require (
 	github.com/mark3labs/mcp-go v0.43.2
 	github.com/ollama/ollama v0.5.1
 	google.golang.org/api v0.228.0
+	google.golang.org/grpc v1.71.0
 	trpc.group/trpc-go/trpc-a2a-go v0.2.0
 )
 
@@ -58,7 +59,6 @@ require (
 	golang.org/x/time v0.11.0 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20250106144421-5f5ef82da422 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250313205543-e70fdf4c4cb4 // indirect
-	google.golang.org/grpc v1.71.0 // indirect
 	google.golang.org/protobuf v1.36.6 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -36,6 +36,9 @@ type ContentBlock struct {
 	Name      string          `json:"name,omitempty"`
 	Input     json.RawMessage `json:"input,omitempty"`
 	Content   interface{}     `json:"content,omitempty"`
+	Signature string          `json:"signature,omitempty"` // Of a "thinking" block
+	MimeType  string          `json:"mime_type,omitempty"` // Of an "image" block
+	Data      string          `json:"data,omitempty"`      // Base64 encoded content of an "image" block
 }
 
 type Content interface {
@@ -115,6 +118,26 @@ func NewAgentNotificationMessage(content string) HistoryMessage {
 	}
 }
 
+// NewFileReferenceBlock creates a block referencing a cached file (see CleverChatty.AttachContent).
+// It is used in a user's message passed to PromptMessage, the reference is added to the prompt text
+func NewFileReferenceBlock(name string, reference string) ContentBlock {
+	return ContentBlock{
+		Type: "file",
+		Name: name,
+		Text: reference,
+	}
+}
+
+// NewImageBlock creates a block with an image the model can see. The data is base64 encoded
+func NewImageBlock(name string, mimeType string, data string) ContentBlock {
+	return ContentBlock{
+		Type:     "image",
+		Name:     name,
+		MimeType: mimeType,
+		Data:     data,
+	}
+}
+
 func NewTextContent(content string) []Content {
 	return []Content{
 		TextContent{
@@ -209,6 +232,44 @@ func (m *HistoryMessage) GetUsage() (int, int) {
 	return 0, 0 // History doesn't track usage
 }
 
+func (m *HistoryMessage) GetFinishReason() string {
+	return llm.FinishReasonUnknown
+}
+
+// GetImages returns the images of the "image" blocks
+func (m *HistoryMessage) GetImages() []llm.Image {
+	var images []llm.Image
+	for _, block := range m.Content {
+		if block.Type == "image" {
+			images = append(images, llm.Image{
+				MimeType: block.MimeType,
+				Data:     block.Data,
+			})
+		}
+	}
+	return images
+}
+
+// GetThinkingBlocks returns the reasoning of the model kept in "thinking" and
+// "redacted_thinking" blocks, the text of a redacted block is the encrypted reasoning
+func (m *HistoryMessage) GetThinkingBlocks() []llm.ThinkingBlock {
+	var blocks []llm.ThinkingBlock
+	for _, block := range m.Content {
+		switch block.Type {
+		case "thinking":
+			blocks = append(blocks, llm.ThinkingBlock{
+				Text:      block.Text,
+				Signature: block.Signature,
+			})
+		case "redacted_thinking":
+			blocks = append(blocks, llm.ThinkingBlock{
+				Redacted: block.Text,
+			})
+		}
+	}
+	return blocks
+}
+
 // HistoryToolCall implements llm.ToolCall for stored tool calls
 type HistoryToolCall struct {
 	id   string
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+
+	"github.com/gelembjuk/cleverchatty/core/llm"
 )
 
 type Client struct {
@@ -61,7 +63,7 @@ func (c *Client) CreateMessage(ctx context.Context, req CreateRequest) (*APIMess
 		}
 
 		if errResp.Error.Type == "overloaded_error" {
-			return nil, fmt.Errorf("overloaded_error: %s", errResp.Error.Message)
+			return nil, fmt.Errorf("%w: %s", llm.ErrProviderOverloaded, errResp.Error.Message)
 		}
 
 		return nil, fmt.Errorf("%s: %s", errResp.Error.Type, errResp.Error.Message)
@@ -74,3 +76,38 @@ func (c *Client) CreateMessage(ctx context.Context, req CreateRequest) (*APIMess
 
 	return &message, nil
 }
+
+// ListModels returns the IDs of the models available with the API key
+func (c *Client) ListModels(ctx context.Context) ([]string, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/models?limit=1000", c.baseURL), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	httpReq.Header.Set("X-Api-Key", c.apiKey)
+	httpReq.Header.Set("anthropic-version", "2023-06-01")
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error response with status %d", resp.StatusCode)
+	}
+
+	var response struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	models := make([]string, 0, len(response.Data))
+	for _, model := range response.Data {
+		models = append(models, model.ID)
+	}
+	return models, nil
+}
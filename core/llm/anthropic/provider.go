@@ -12,10 +12,19 @@ import (
 	"github.com/gelembjuk/cleverchatty/core/llm"
 )
 
+const (
+	defaultMaxTokens      = 4096
+	defaultThinkingBudget = 4096
+	minThinkingBudget     = 1024 // The smallest budget accepted by the API
+)
+
 type Provider struct {
-	client *Client
-	model  string
-	logger *log.Logger
+	client            *Client
+	model             string
+	logger            *log.Logger
+	thinking          bool
+	thinkingBudget    int
+	generationOptions llm.GenerationOptions
 }
 
 func NewProvider(apiKey string, baseURL string, model string) *Provider {
@@ -41,6 +50,8 @@ func (p *Provider) CreateMessage(
 		len(tools))
 
 	anthropicMessages := make([]MessageParam, 0, len(messages))
+	// System messages go to the top-level system parameter, the API does not accept them in the list
+	systemParts := []string{}
 
 	for _, msg := range messages {
 		p.logger.Printf("converting message for Anthropic provider with role: %s, content: %s, is_tool_response: %t\n",
@@ -48,8 +59,21 @@ func (p *Provider) CreateMessage(
 			msg.GetContent(),
 			msg.IsToolResponse())
 
+		if msg.GetRole() == "system" {
+			if textContent := strings.TrimSpace(msg.GetContent()); textContent != "" {
+				systemParts = append(systemParts, textContent)
+			}
+			continue
+		}
+
 		content := []ContentBlock{}
 
+		// The reasoning must precede the tool calls it led to, otherwise the API
+		// rejects the tool results when the thinking mode is on
+		if thinkingMsg, ok := msg.(llm.ThinkingMessage); ok && p.thinking {
+			content = append(content, thinkingContent(thinkingMsg.GetThinkingBlocks())...)
+		}
+
 		// Add regular text content if present
 		if textContent := strings.TrimSpace(msg.GetContent()); textContent != "" {
 			content = append(content, ContentBlock{
@@ -58,6 +82,24 @@ func (p *Provider) CreateMessage(
 			})
 		}
 
+		if imageMsg, ok := msg.(llm.ImageMessage); ok && msg.GetRole() == "user" {
+			if images := imageMsg.GetImages(); len(images) > 0 {
+				if !p.Capabilities().Images {
+					return nil, fmt.Errorf("%w: %s", llm.ErrImagesNotSupported, p.model)
+				}
+				for _, image := range images {
+					content = append(content, ContentBlock{
+						Type: "image",
+						Source: &ImageSource{
+							Type:      "base64",
+							MediaType: image.MimeType,
+							Data:      image.Data,
+						},
+					})
+				}
+			}
+		}
+
 		// Add tool calls if present
 		for _, call := range msg.GetToolCalls() {
 			input, _ := json.Marshal(call.GetArguments())
@@ -132,13 +174,25 @@ func (p *Provider) CreateMessage(
 		anthropicMessages,
 		len(tools))
 
+	req := CreateRequest{
+		Model:         p.model,
+		System:        strings.Join(systemParts, "\n\n"),
+		Messages:      anthropicMessages,
+		MaxTokens:     defaultMaxTokens,
+		Tools:         anthropicTools,
+		StopSequences: p.generationOptions.Stop,
+	}
+	if p.thinking {
+		// The budget is a part of max_tokens, the answer needs its own room
+		req.Thinking = &ThinkingConfig{
+			Type:         "enabled",
+			BudgetTokens: p.thinkingBudget,
+		}
+		req.MaxTokens = p.thinkingBudget + defaultMaxTokens
+	}
+
 	// Make the API call
-	resp, err := p.client.CreateMessage(ctx, CreateRequest{
-		Model:     p.model,
-		Messages:  anthropicMessages,
-		MaxTokens: 4096,
-		Tools:     anthropicTools,
-	})
+	resp, err := p.client.CreateMessage(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -146,10 +200,62 @@ func (p *Provider) CreateMessage(
 	return &Message{Msg: *resp}, nil
 }
 
+// thinkingContent converts the reasoning blocks back to the API format
+func thinkingContent(blocks []llm.ThinkingBlock) []ContentBlock {
+	content := []ContentBlock{}
+	for _, block := range blocks {
+		if block.Redacted != "" {
+			content = append(content, ContentBlock{
+				Type: "redacted_thinking",
+				Data: block.Redacted,
+			})
+			continue
+		}
+		content = append(content, ContentBlock{
+			Type:      "thinking",
+			Thinking:  block.Text,
+			Signature: block.Signature,
+		})
+	}
+	return content
+}
+
+// SetThinking enables the extended thinking mode. The budget is the number of tokens
+// the model can use for the reasoning, 0 means the default
+func (p *Provider) SetThinking(enabled bool, budgetTokens int) {
+	if budgetTokens <= 0 {
+		budgetTokens = defaultThinkingBudget
+	}
+	if budgetTokens < minThinkingBudget {
+		budgetTokens = minThinkingBudget
+	}
+	p.thinking = enabled
+	p.thinkingBudget = budgetTokens
+}
+
+// SetGenerationOptions sets the stop sequences of the requests. The seed is ignored,
+// the API does not support it
+func (p *Provider) SetGenerationOptions(options llm.GenerationOptions) {
+	p.generationOptions = options
+}
+
 func (p *Provider) SupportsTools() bool {
 	return true
 }
 
+func (p *Provider) Capabilities() llm.ProviderCapabilities {
+	// Claude 2 and Instant models have no vision, all newer models have it.
+	// Extended thinking is supported since Claude 3.7
+	model := strings.ToLower(p.model)
+	legacy := strings.HasPrefix(model, "claude-2") || strings.HasPrefix(model, "claude-instant")
+	claude3 := strings.HasPrefix(model, "claude-3-") && !strings.HasPrefix(model, "claude-3-7")
+	return llm.ProviderCapabilities{
+		Tools:     true,
+		Images:    !legacy,
+		Reasoning: !legacy && !claude3,
+	}
+}
+
 func (p *Provider) Name() string {
 	return "anthropic"
 }
@@ -0,0 +1,146 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gelembjuk/cleverchatty/core/history"
+	"github.com/gelembjuk/cleverchatty/core/llm"
+)
+
+func TestCreateMessageSystemAndThinking(t *testing.T) {
+	var received CreateRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Failed to decode the request: %v", err)
+		}
+		json.NewEncoder(w).Encode(APIMessage{
+			Role: "assistant",
+			Content: []ContentBlock{
+				{Type: "thinking", Thinking: "The user greets me", Signature: "sig"},
+				{Type: "text", Text: "Hello!"},
+			},
+		})
+	}))
+	defer server.Close()
+
+	provider := NewProvider("key", server.URL, "claude-test")
+	provider.SetThinking(true, 100)
+
+	instruction := history.NewSystemInstructionMessage("You are a helpful assistant")
+	previous := history.HistoryMessage{
+		Role: "assistant",
+		Content: []history.ContentBlock{
+			{Type: "thinking", Text: "Earlier reasoning", Signature: "old-sig"},
+			{Type: "text", Text: "Earlier answer"},
+		},
+	}
+
+	message, err := provider.CreateMessage(context.Background(), "Hello", []llm.Message{&instruction, &previous}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+
+	if received.System != "You are a helpful assistant" {
+		t.Errorf("Expected the instruction in the system parameter, got %q", received.System)
+	}
+	if len(received.Messages) != 2 || received.Messages[0].Role != "assistant" {
+		t.Fatalf("Expected the system message to be removed from the list, got %+v", received.Messages)
+	}
+	if first := received.Messages[0].Content[0]; first.Type != "thinking" || first.Signature != "old-sig" {
+		t.Errorf("Expected the reasoning to be sent back first, got %+v", first)
+	}
+	if received.Thinking == nil || received.Thinking.BudgetTokens != minThinkingBudget {
+		t.Errorf("Expected the thinking budget raised to the minimum, got %+v", received.Thinking)
+	}
+	if received.MaxTokens <= minThinkingBudget {
+		t.Errorf("Expected max_tokens above the thinking budget, got %d", received.MaxTokens)
+	}
+
+	thinkingMsg, ok := message.(llm.ThinkingMessage)
+	if !ok {
+		t.Fatalf("Expected the message to carry the reasoning")
+	}
+	blocks := thinkingMsg.GetThinkingBlocks()
+	if len(blocks) != 1 || blocks[0].Text != "The user greets me" || blocks[0].Signature != "sig" {
+		t.Errorf("Unexpected reasoning blocks %+v", blocks)
+	}
+	if message.GetContent() != "Hello!" {
+		t.Errorf("Expected the reasoning to be excluded from the content, got %q", message.GetContent())
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	tests := []struct {
+		model     string
+		images    bool
+		reasoning bool
+	}{
+		{"claude-2.1", false, false},
+		{"claude-3-5-sonnet-latest", true, false},
+		{"claude-3-7-sonnet-latest", true, true},
+		{"claude-sonnet-4-0", true, true},
+	}
+	for _, tt := range tests {
+		capabilities := NewProvider("key", "", tt.model).Capabilities()
+		if !capabilities.Tools {
+			t.Errorf("%s: expected tools to be supported", tt.model)
+		}
+		if capabilities.Images != tt.images || capabilities.Reasoning != tt.reasoning {
+			t.Errorf("%s: expected images=%t reasoning=%t, got %+v", tt.model, tt.images, tt.reasoning, capabilities)
+		}
+	}
+}
+
+func TestCreateMessageWithImage(t *testing.T) {
+	var received CreateRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		json.NewEncoder(w).Encode(APIMessage{
+			Role:    "assistant",
+			Content: []ContentBlock{{Type: "text", Text: "A cat"}},
+		})
+	}))
+	defer server.Close()
+
+	message := history.NewUserPromptMessage("What is in the picture?")
+	message.Content = append(message.Content, history.NewImageBlock("cat.png", "image/png", "aW1hZ2U="))
+
+	provider := NewProvider("key", server.URL, "claude-sonnet-4-0")
+	if _, err := provider.CreateMessage(context.Background(), "", []llm.Message{&message}, nil); err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	content := received.Messages[0].Content
+	if len(content) != 2 || content[1].Type != "image" || content[1].Source == nil ||
+		content[1].Source.MediaType != "image/png" || content[1].Source.Data != "aW1hZ2U=" {
+		t.Errorf("Expected the image block after the text, got %+v", content)
+	}
+
+	legacy := NewProvider("key", server.URL, "claude-2.1")
+	if _, err := legacy.CreateMessage(context.Background(), "", []llm.Message{&message}, nil); !errors.Is(err, llm.ErrImagesNotSupported) {
+		t.Errorf("Expected ErrImagesNotSupported, got %v", err)
+	}
+}
+
+func TestFinishReason(t *testing.T) {
+	tests := map[string]string{
+		"end_turn":   llm.FinishReasonStop,
+		"max_tokens": llm.FinishReasonLength,
+		"tool_use":   llm.FinishReasonToolCalls,
+		"refusal":    llm.FinishReasonContentFilter,
+		"pause_turn": llm.FinishReasonUnknown,
+	}
+	for stopReason, expected := range tests {
+		message := &Message{Msg: APIMessage{StopReason: &stopReason}}
+		if reason := message.GetFinishReason(); reason != expected {
+			t.Errorf("%s: expected %s, got %s", stopReason, expected, reason)
+		}
+	}
+	if reason := (&Message{}).GetFinishReason(); reason != llm.FinishReasonUnknown {
+		t.Errorf("Expected unknown without the stop reason, got %s", reason)
+	}
+}
@@ -9,10 +9,19 @@ import (
 )
 
 type CreateRequest struct {
-	Model     string         `json:"model"`
-	Messages  []MessageParam `json:"messages"`
-	MaxTokens int            `json:"max_tokens"`
-	Tools     []Tool         `json:"tools,omitempty"`
+	Model         string          `json:"model"`
+	System        string          `json:"system,omitempty"`
+	Messages      []MessageParam  `json:"messages"`
+	MaxTokens     int             `json:"max_tokens"`
+	Tools         []Tool          `json:"tools,omitempty"`
+	Thinking      *ThinkingConfig `json:"thinking,omitempty"`
+	StopSequences []string        `json:"stop_sequences,omitempty"`
+}
+
+// ThinkingConfig enables the extended thinking mode. The budget is a part of max_tokens
+type ThinkingConfig struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
 }
 
 type MessageParam struct {
@@ -28,6 +37,17 @@ type ContentBlock struct {
 	Name      string          `json:"name,omitempty"`
 	Input     json.RawMessage `json:"input,omitempty"`
 	Content   interface{}     `json:"content,omitempty"`
+	Thinking  string          `json:"thinking,omitempty"`
+	Signature string          `json:"signature,omitempty"`
+	Data      string          `json:"data,omitempty"` // Encrypted reasoning of a redacted_thinking block
+	Source    *ImageSource    `json:"source,omitempty"`
+}
+
+// ImageSource is the content of an image block
+type ImageSource struct {
+	Type      string `json:"type"` // Always "base64", images are sent inline
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
 }
 
 type Tool struct {
@@ -146,6 +166,41 @@ func (m *Message) GetUsage() (input int, output int) {
 	return m.Msg.Usage.InputTokens, m.Msg.Usage.OutputTokens
 }
 
+func (m *Message) GetFinishReason() string {
+	if m.Msg.StopReason == nil {
+		return llm.FinishReasonUnknown
+	}
+	switch *m.Msg.StopReason {
+	case "end_turn", "stop_sequence":
+		return llm.FinishReasonStop
+	case "max_tokens":
+		return llm.FinishReasonLength
+	case "tool_use":
+		return llm.FinishReasonToolCalls
+	case "refusal":
+		return llm.FinishReasonContentFilter
+	}
+	return llm.FinishReasonUnknown
+}
+
+func (m *Message) GetThinkingBlocks() []llm.ThinkingBlock {
+	var blocks []llm.ThinkingBlock
+	for _, block := range m.Msg.Content {
+		switch block.Type {
+		case "thinking":
+			blocks = append(blocks, llm.ThinkingBlock{
+				Text:      block.Thinking,
+				Signature: block.Signature,
+			})
+		case "redacted_thinking":
+			blocks = append(blocks, llm.ThinkingBlock{
+				Redacted: block.Data,
+			})
+		}
+	}
+	return blocks
+}
+
 // ToolCall implements the llm.ToolCall interface
 type ToolCall struct {
 	id   string
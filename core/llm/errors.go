@@ -0,0 +1,11 @@
+package llm
+
+import "errors"
+
+// ErrProviderOverloaded is returned by providers when the LLM service is temporarily
+// overloaded. The request can be retried later.
+var ErrProviderOverloaded = errors.New("provider is overloaded")
+
+// ErrImagesNotSupported is returned when a message has images but the model
+// does not accept images in the input
+var ErrImagesNotSupported = errors.New("the model does not support images")
@@ -2,22 +2,33 @@ package google
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/gelembjuk/cleverchatty/core/history"
 	"github.com/gelembjuk/cleverchatty/core/llm"
 	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+const roleSystem = "system"
+
 type Provider struct {
-	client *genai.Client
-	model  *genai.GenerativeModel
-	chat   *genai.ChatSession
-	logger *log.Logger
+	client    *genai.Client
+	model     *genai.GenerativeModel
+	modelName string // Capabilities depend on the model
+	chat      *genai.ChatSession
+	logger    *log.Logger
 
 	toolCallID int
 }
@@ -29,48 +40,26 @@ func NewProvider(ctx context.Context, apiKey string, model string) (*Provider, e
 	}
 	m := client.GenerativeModel(model)
 	return &Provider{
-		client: client,
-		model:  m,
-		chat:   m.StartChat(),
-		logger: log.New(io.Discard, "", log.LstdFlags),
+		client:    client,
+		model:     m,
+		modelName: model,
+		chat:      m.StartChat(),
+		logger:    log.New(io.Discard, "", log.LstdFlags),
 	}, nil
 }
 
-func (p *Provider) CreateMessage(ctx context.Context, prompt string, messages []llm.Message, tools []llm.Tool) (llm.Message, error) {
-	var hist []*genai.Content
-	for _, msg := range messages {
-		for _, call := range msg.GetToolCalls() {
-			hist = append(hist, &genai.Content{
-				Role: msg.GetRole(),
-				Parts: []genai.Part{
-					genai.FunctionCall{
-						Name: call.GetName(),
-						Args: call.GetArguments(),
-					},
-				},
-			})
-		}
-
-		if msg.IsToolResponse() {
-			if historyMsg, ok := msg.(*history.HistoryMessage); ok {
-				for _, block := range historyMsg.Content {
-					if block.Type == "tool_result" {
-						hist = append(hist, &genai.Content{
-							Role:  msg.GetRole(),
-							Parts: []genai.Part{genai.Text(block.Text)},
-						})
-					}
-				}
-			}
-		}
+// SetGenerationOptions sets the stop sequences of the requests. The seed is ignored,
+// the library does not support it
+func (p *Provider) SetGenerationOptions(options llm.GenerationOptions) {
+	p.model.StopSequences = options.Stop
+}
 
-		if text := strings.TrimSpace(msg.GetContent()); text != "" {
-			hist = append(hist, &genai.Content{
-				Role:  msg.GetRole(),
-				Parts: []genai.Part{genai.Text(text)},
-			})
-		}
+func (p *Provider) CreateMessage(ctx context.Context, prompt string, messages []llm.Message, tools []llm.Tool) (llm.Message, error) {
+	systemInstruction, hist, err := buildHistory(messages)
+	if err != nil {
+		return nil, err
 	}
+	p.model.SystemInstruction = systemInstruction
 
 	p.model.Tools = nil
 	for _, tool := range tools {
@@ -90,6 +79,9 @@ func (p *Provider) CreateMessage(ctx context.Context, prompt string, messages []
 	// so we just call SendMessage with an empty string that will be trimmed by the server.
 	resp, err := p.chat.SendMessage(ctx, genai.Text(""))
 	if err != nil {
+		if isOverloaded(err) {
+			return nil, fmt.Errorf("%w: %v", llm.ErrProviderOverloaded, err)
+		}
 		return nil, err
 	}
 
@@ -107,6 +99,74 @@ func (p *Provider) CreateMessage(ctx context.Context, prompt string, messages []
 	return m, nil
 }
 
+// buildHistory converts the messages to the Gemini chat history. Messages with the system
+// role are collected separately to be sent as the system instruction of the model.
+// Images of user messages are sent as inline data next to the text
+func buildHistory(messages []llm.Message) (*genai.Content, []*genai.Content, error) {
+	var systemInstruction *genai.Content
+	var hist []*genai.Content
+	for _, msg := range messages {
+		// Gemini does not accept the system role in the chat history,
+		// system messages are passed with the native system instruction
+		if msg.GetRole() == roleSystem {
+			if text := strings.TrimSpace(msg.GetContent()); text != "" {
+				if systemInstruction == nil {
+					systemInstruction = &genai.Content{}
+				}
+				systemInstruction.Parts = append(systemInstruction.Parts, genai.Text(text))
+			}
+			continue
+		}
+
+		for _, call := range msg.GetToolCalls() {
+			hist = append(hist, &genai.Content{
+				Role: msg.GetRole(),
+				Parts: []genai.Part{
+					genai.FunctionCall{
+						Name: call.GetName(),
+						Args: call.GetArguments(),
+					},
+				},
+			})
+		}
+
+		if msg.IsToolResponse() {
+			if historyMsg, ok := msg.(*history.HistoryMessage); ok {
+				for _, block := range historyMsg.Content {
+					if block.Type == "tool_result" {
+						hist = append(hist, &genai.Content{
+							Role:  msg.GetRole(),
+							Parts: []genai.Part{genai.Text(block.Text)},
+						})
+					}
+				}
+			}
+		}
+
+		parts := []genai.Part{}
+		if text := strings.TrimSpace(msg.GetContent()); text != "" {
+			parts = append(parts, genai.Text(text))
+		}
+		if imageMsg, ok := msg.(llm.ImageMessage); ok && msg.GetRole() == "user" {
+			for _, image := range imageMsg.GetImages() {
+				data, err := base64.StdEncoding.DecodeString(image.Data)
+				if err != nil {
+					return nil, nil, fmt.Errorf("invalid image data: %w", err)
+				}
+				parts = append(parts, genai.Blob{MIMEType: image.MimeType, Data: data})
+			}
+		}
+		if len(parts) > 0 {
+			hist = append(hist, &genai.Content{
+				Role:  msg.GetRole(),
+				Parts: parts,
+			})
+		}
+	}
+
+	return systemInstruction, hist, nil
+}
+
 func (p *Provider) CreateToolResponse(toolCallID string, content any) (llm.Message, error) {
 	// UNUSED: Nothing in root.go calls this.
 	return nil, nil
@@ -117,6 +177,14 @@ func (p *Provider) SupportsTools() bool {
 	return true
 }
 
+func (p *Provider) Capabilities() llm.ProviderCapabilities {
+	return llm.ProviderCapabilities{
+		Tools:     true,
+		Images:    true,
+		Reasoning: strings.Contains(p.modelName, "thinking") || strings.HasPrefix(p.modelName, "gemini-2.5"),
+	}
+}
+
 func (p *Provider) Name() string {
 	return "Google"
 }
@@ -133,7 +201,12 @@ func translateToGoogleSchema(schema llm.Schema) *genai.Schema {
 	}
 
 	for name, prop := range schema.Properties {
-		s.Properties[name] = propertyToGoogleSchema(prop.(map[string]any))
+		propMap, ok := prop.(map[string]any)
+		if !ok {
+			// A malformed property must not break the whole tool declaration
+			continue
+		}
+		s.Properties[name] = propertyToGoogleSchema(propMap)
 	}
 
 	if len(s.Properties) == 0 {
@@ -150,32 +223,83 @@ func translateToGoogleSchema(schema llm.Schema) *genai.Schema {
 }
 
 func propertyToGoogleSchema(properties map[string]any) *genai.Schema {
-	s := &genai.Schema{Type: toType(properties["type"].(string))}
+	s := &genai.Schema{Type: toType(propertyType(properties))}
 	if desc, ok := properties["description"].(string); ok {
 		s.Description = desc
 	}
+	if enum := stringList(properties["enum"]); len(enum) > 0 && s.Type == genai.TypeString {
+		// Gemini accepts enum values only for strings with the "enum" format
+		s.Format = "enum"
+		s.Enum = enum
+	}
 
 	// Objects and arrays need to have their properties recursively mapped.
 	if s.Type == genai.TypeObject {
-		objectProperties := properties["properties"].(map[string]any)
 		s.Properties = make(map[string]*genai.Schema)
+		s.Required = stringList(properties["required"])
+		objectProperties, _ := properties["properties"].(map[string]any)
 		for name, prop := range objectProperties {
-			s.Properties[name] = propertyToGoogleSchema(prop.(map[string]any))
+			propMap, ok := prop.(map[string]any)
+			if !ok {
+				continue
+			}
+			s.Properties[name] = propertyToGoogleSchema(propMap)
 		}
 	} else if s.Type == genai.TypeArray {
-		itemProperties := properties["items"].(map[string]any)
-		s.Items = propertyToGoogleSchema(itemProperties)
+		if itemProperties, ok := properties["items"].(map[string]any); ok {
+			s.Items = propertyToGoogleSchema(itemProperties)
+		} else {
+			// Gemini requires the items schema for arrays
+			s.Items = &genai.Schema{Type: genai.TypeString}
+		}
 	}
 
 	return s
 }
 
+// propertyType returns the JSON schema type of the property. A missing type is treated as "string".
+// For a list of types, like ["string", "null"], the first non-null type is used.
+func propertyType(properties map[string]any) string {
+	switch typ := properties["type"].(type) {
+	case string:
+		return typ
+	case []any:
+		for _, item := range typ {
+			if name, ok := item.(string); ok && name != "null" {
+				return name
+			}
+		}
+	}
+	return "string"
+}
+
+// stringList converts a JSON schema list of strings, like "enum" or "required", skipping other values
+func stringList(value any) []string {
+	switch list := value.(type) {
+	case []string:
+		return list
+	case []any:
+		result := []string{}
+		for _, item := range list {
+			if str, ok := item.(string); ok {
+				result = append(result, str)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
 func toType(typ string) genai.Type {
 	switch typ {
 	case "string":
 		return genai.TypeString
 	case "boolean":
 		return genai.TypeBoolean
+	case "number":
+		return genai.TypeNumber
+	case "integer":
+		return genai.TypeInteger
 	case "object":
 		return genai.TypeObject
 	case "array":
@@ -184,3 +308,45 @@ func toType(typ string) genai.Type {
 		return genai.TypeUnspecified
 	}
 }
+
+// ListModels returns the models that can generate content with the API key
+func ListModels(ctx context.Context, apiKey string) ([]string, error) {
+	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	models := []string{}
+	it := client.ListModels(ctx)
+	for {
+		model, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(model.SupportedGenerationMethods, "generateContent") {
+			continue
+		}
+		models = append(models, strings.TrimPrefix(model.Name, "models/"))
+	}
+	return models, nil
+}
+
+// isOverloaded checks if the API rejected the request because of the load, with the
+// RESOURCE_EXHAUSTED or UNAVAILABLE status of gRPC or their HTTP codes 429 and 503
+func isOverloaded(err error) bool {
+	if st, ok := status.FromError(err); ok {
+		switch st.Code() {
+		case codes.ResourceExhausted, codes.Unavailable:
+			return true
+		}
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == http.StatusTooManyRequests || apiErr.Code == http.StatusServiceUnavailable
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+package google
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/gelembjuk/cleverchatty/core/history"
+	"github.com/gelembjuk/cleverchatty/core/llm"
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBuildHistorySystemInstruction(t *testing.T) {
+	instruction := history.NewSystemInstructionMessage("You are a helpful assistant")
+	question := history.HistoryMessage{
+		Role:    "user",
+		Content: []history.ContentBlock{{Type: "text", Text: "Hello"}},
+	}
+
+	systemInstruction, hist, err := buildHistory([]llm.Message{&instruction, &question})
+	if err != nil {
+		t.Fatalf("Failed to build the history: %v", err)
+	}
+
+	if systemInstruction == nil || len(systemInstruction.Parts) != 1 {
+		t.Fatalf("Expected the system instruction to be set, got %v", systemInstruction)
+	}
+	if text, ok := systemInstruction.Parts[0].(genai.Text); !ok || string(text) != "You are a helpful assistant" {
+		t.Errorf("Unexpected system instruction %v", systemInstruction.Parts[0])
+	}
+	if len(hist) != 1 || hist[0].Role != "user" {
+		t.Fatalf("Expected only the user message in the history, got %d messages", len(hist))
+	}
+	for _, content := range hist {
+		if content.Role == "system" {
+			t.Errorf("System role must not be sent in the history")
+		}
+	}
+}
+
+func TestBuildHistoryImages(t *testing.T) {
+	question := history.HistoryMessage{
+		Role: "user",
+		Content: []history.ContentBlock{
+			{Type: "text", Text: "What is on the picture?"},
+			{Type: "image", MimeType: "image/png", Data: base64.StdEncoding.EncodeToString([]byte("png data"))},
+		},
+	}
+
+	_, hist, err := buildHistory([]llm.Message{&question})
+	if err != nil {
+		t.Fatalf("Failed to build the history: %v", err)
+	}
+	if len(hist) != 1 || len(hist[0].Parts) != 2 {
+		t.Fatalf("Expected one message with the text and the image, got %v", hist)
+	}
+	blob, ok := hist[0].Parts[1].(genai.Blob)
+	if !ok || blob.MIMEType != "image/png" || string(blob.Data) != "png data" {
+		t.Errorf("Expected the decoded image, got %v", hist[0].Parts[1])
+	}
+}
+
+func TestTranslateSchemaMissingType(t *testing.T) {
+	schema := llm.Schema{
+		Type: "object",
+		Properties: map[string]interface{}{
+			"query":     map[string]interface{}{"description": "Search query"},
+			"malformed": "not a schema",
+		},
+	}
+
+	s := translateToGoogleSchema(schema)
+
+	if s.Properties["query"] == nil || s.Properties["query"].Type != genai.TypeString {
+		t.Fatalf("Expected a property without type to be a string, got %v", s.Properties["query"])
+	}
+	if _, ok := s.Properties["malformed"]; ok {
+		t.Errorf("Expected the malformed property to be skipped")
+	}
+}
+
+func TestTranslateSchemaArrayOfObjects(t *testing.T) {
+	schema := llm.Schema{
+		Type: "object",
+		Properties: map[string]interface{}{
+			"contacts": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"name":  map[string]interface{}{"type": "string"},
+						"extra": 42,
+					},
+				},
+			},
+			"nested": map[string]interface{}{
+				"type": "object",
+			},
+		},
+	}
+
+	s := translateToGoogleSchema(schema)
+
+	contacts := s.Properties["contacts"]
+	if contacts == nil || contacts.Type != genai.TypeArray || contacts.Items == nil {
+		t.Fatalf("Expected an array with items, got %v", contacts)
+	}
+	if contacts.Items.Type != genai.TypeObject || contacts.Items.Properties["name"] == nil {
+		t.Errorf("Expected object items with the name property, got %v", contacts.Items)
+	}
+	if _, ok := contacts.Items.Properties["extra"]; ok {
+		t.Errorf("Expected the malformed nested property to be skipped")
+	}
+	if nested := s.Properties["nested"]; nested == nil || nested.Type != genai.TypeObject {
+		t.Errorf("Expected an object without properties to be translated, got %v", nested)
+	}
+}
+
+func TestTranslateSchemaArrayItems(t *testing.T) {
+	schema := llm.Schema{
+		Type: "object",
+		Properties: map[string]interface{}{
+			"tags": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "string",
+					"enum": []interface{}{"work", "home"},
+				},
+			},
+			"tasks": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"title":    map[string]interface{}{"type": "string"},
+						"priority": map[string]interface{}{"type": "integer"},
+					},
+					"required": []interface{}{"title"},
+				},
+			},
+		},
+		Required: []string{"tags"},
+	}
+
+	s := translateToGoogleSchema(schema)
+
+	tags := s.Properties["tags"]
+	if tags == nil || tags.Type != genai.TypeArray || tags.Items == nil {
+		t.Fatalf("Expected an array of strings, got %v", tags)
+	}
+	if tags.Items.Type != genai.TypeString || tags.Items.Format != "enum" || len(tags.Items.Enum) != 2 {
+		t.Errorf("Expected string items with enum values, got %v", tags.Items)
+	}
+
+	tasks := s.Properties["tasks"]
+	if tasks == nil || tasks.Items == nil || tasks.Items.Type != genai.TypeObject {
+		t.Fatalf("Expected an array of objects, got %v", tasks)
+	}
+	if priority := tasks.Items.Properties["priority"]; priority == nil || priority.Type != genai.TypeInteger {
+		t.Errorf("Expected an integer priority property, got %v", priority)
+	}
+	if len(tasks.Items.Required) != 1 || tasks.Items.Required[0] != "title" {
+		t.Errorf("Expected required fields of items to be kept, got %v", tasks.Items.Required)
+	}
+}
+
+func TestIsOverloaded(t *testing.T) {
+	tests := []struct {
+		err        error
+		overloaded bool
+	}{
+		{status.Error(codes.ResourceExhausted, "quota exceeded"), true},
+		{status.Error(codes.Unavailable, "the model is overloaded"), true},
+		{status.Error(codes.InvalidArgument, "bad request"), false},
+		{&googleapi.Error{Code: 429}, true},
+		{&googleapi.Error{Code: 503}, true},
+		{&googleapi.Error{Code: 400}, false},
+		{errors.New("connection refused"), false},
+	}
+	for _, test := range tests {
+		if overloaded := isOverloaded(test.err); overloaded != test.overloaded {
+			t.Errorf("Expected %v overloaded %v, got %v", test.err, test.overloaded, overloaded)
+		}
+	}
+}
@@ -70,3 +70,7 @@ func (m *Message) GetToolResponseID() string {
 func (m *Message) GetUsage() (input int, output int) {
 	return 0, 0
 }
+
+func (m *Message) GetFinishReason() string {
+	return llm.FinishReasonUnknown
+}
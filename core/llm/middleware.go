@@ -0,0 +1,135 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+// CreateMessageFunc has the signature of Provider.CreateMessage
+type CreateMessageFunc func(ctx context.Context, prompt string, messages []Message, tools []Tool) (Message, error)
+
+// ProviderMiddleware wraps a CreateMessageFunc. A middleware can inspect or modify
+// the request, call next, inspect the response, or return its own response
+// without calling next at all.
+type ProviderMiddleware func(next CreateMessageFunc) CreateMessageFunc
+
+// middlewareProvider is a Provider whose CreateMessage is routed through a middleware chain
+type middlewareProvider struct {
+	Provider
+	createMessage CreateMessageFunc
+}
+
+// WrapProvider returns a provider where CreateMessage goes through the given middlewares.
+// The first middleware is the outermost one, it sees the request first and the response last.
+func WrapProvider(provider Provider, middlewares ...ProviderMiddleware) Provider {
+	if len(middlewares) == 0 {
+		return provider
+	}
+	createMessage := CreateMessageFunc(provider.CreateMessage)
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		createMessage = middlewares[i](createMessage)
+	}
+	return &middlewareProvider{
+		Provider:      provider,
+		createMessage: createMessage,
+	}
+}
+
+func (p *middlewareProvider) CreateMessage(ctx context.Context, prompt string, messages []Message, tools []Tool) (Message, error) {
+	return p.createMessage(ctx, prompt, messages, tools)
+}
+
+// LoggingMiddleware logs the full request and response payloads. Intended for debugging.
+func LoggingMiddleware(logger *log.Logger) ProviderMiddleware {
+	return func(next CreateMessageFunc) CreateMessageFunc {
+		return func(ctx context.Context, prompt string, messages []Message, tools []Tool) (Message, error) {
+			toolNames := make([]string, 0, len(tools))
+			for _, tool := range tools {
+				toolNames = append(toolNames, tool.Name)
+			}
+			history := make([]map[string]interface{}, 0, len(messages))
+			for _, msg := range messages {
+				history = append(history, map[string]interface{}{
+					"role":    msg.GetRole(),
+					"content": msg.GetContent(),
+				})
+			}
+			requestData, _ := json.Marshal(map[string]interface{}{
+				"prompt":   prompt,
+				"messages": history,
+				"tools":    toolNames,
+			})
+			logger.Printf("LLM request: %s", requestData)
+
+			response, err := next(ctx, prompt, messages, tools)
+			if err != nil {
+				logger.Printf("LLM request failed: %v", err)
+				return response, err
+			}
+
+			toolCalls := make([]map[string]interface{}, 0)
+			for _, call := range response.GetToolCalls() {
+				toolCalls = append(toolCalls, map[string]interface{}{
+					"name":      call.GetName(),
+					"arguments": call.GetArguments(),
+				})
+			}
+			responseData, _ := json.Marshal(map[string]interface{}{
+				"role":       response.GetRole(),
+				"content":    response.GetContent(),
+				"tool_calls": toolCalls,
+			})
+			logger.Printf("LLM response: %s", responseData)
+			return response, nil
+		}
+	}
+}
+
+// LatencyMiddleware logs how long each CreateMessage call takes
+func LatencyMiddleware(logger *log.Logger) ProviderMiddleware {
+	return func(next CreateMessageFunc) CreateMessageFunc {
+		return func(ctx context.Context, prompt string, messages []Message, tools []Tool) (Message, error) {
+			start := time.Now()
+			response, err := next(ctx, prompt, messages, tools)
+			logger.Printf("LLM call took %v", time.Since(start))
+			return response, err
+		}
+	}
+}
+
+// TokenCounter accumulates token usage reported by the provider across calls
+type TokenCounter struct {
+	inputTokens  int
+	outputTokens int
+	calls        int
+	mu           sync.Mutex
+}
+
+// Middleware returns the middleware that feeds this counter
+func (c *TokenCounter) Middleware() ProviderMiddleware {
+	return func(next CreateMessageFunc) CreateMessageFunc {
+		return func(ctx context.Context, prompt string, messages []Message, tools []Tool) (Message, error) {
+			response, err := next(ctx, prompt, messages, tools)
+			if err != nil || response == nil {
+				return response, err
+			}
+			input, output := response.GetUsage()
+			c.mu.Lock()
+			c.inputTokens += input
+			c.outputTokens += output
+			c.calls++
+			c.mu.Unlock()
+			return response, nil
+		}
+	}
+}
+
+// Totals returns the accumulated input tokens, output tokens and number of calls
+func (c *TokenCounter) Totals() (input int, output int, calls int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.inputTokens, c.outputTokens, c.calls
+}
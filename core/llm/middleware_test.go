@@ -0,0 +1,114 @@
+package llm_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gelembjuk/cleverchatty/core/llm"
+	"github.com/gelembjuk/cleverchatty/core/test"
+)
+
+// recordingMiddleware appends its name to calls before and after calling next
+func recordingMiddleware(name string, calls *[]string) llm.ProviderMiddleware {
+	return func(next llm.CreateMessageFunc) llm.CreateMessageFunc {
+		return func(ctx context.Context, prompt string, messages []llm.Message, tools []llm.Tool) (llm.Message, error) {
+			*calls = append(*calls, name+":before")
+			response, err := next(ctx, prompt, messages, tools)
+			*calls = append(*calls, name+":after")
+			return response, err
+		}
+	}
+}
+
+func TestWrapProviderOrder(t *testing.T) {
+	calls := []string{}
+	provider := test.NewMockProvider()
+	wrapped := llm.WrapProvider(provider,
+		recordingMiddleware("first", &calls),
+		recordingMiddleware("second", &calls),
+	)
+
+	response, err := wrapped.CreateMessage(context.Background(), "hello", nil, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if response.GetContent() != "FAKE_RESPONSE:hello" {
+		t.Errorf("Expected the provider response, got %q", response.GetContent())
+	}
+
+	expected := "first:before,second:before,second:after,first:after"
+	if got := strings.Join(calls, ","); got != expected {
+		t.Errorf("Expected calls %s, got %s", expected, got)
+	}
+	if wrapped.Name() != provider.Name() {
+		t.Errorf("Expected other methods to reach the provider, got name %q", wrapped.Name())
+	}
+}
+
+func TestWrapProviderShortCircuit(t *testing.T) {
+	calls := []string{}
+	provider := test.NewMockProvider()
+	cached, _ := test.NewMockProvider(test.MockResponse{Content: "cached"}).CreateMessage(context.Background(), "", nil, nil)
+
+	shortCircuit := func(next llm.CreateMessageFunc) llm.CreateMessageFunc {
+		return func(ctx context.Context, prompt string, messages []llm.Message, tools []llm.Tool) (llm.Message, error) {
+			return cached, nil
+		}
+	}
+	wrapped := llm.WrapProvider(provider,
+		recordingMiddleware("outer", &calls),
+		shortCircuit,
+		recordingMiddleware("inner", &calls),
+	)
+
+	response, err := wrapped.CreateMessage(context.Background(), "hello", nil, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if response.GetContent() != "cached" {
+		t.Errorf("Expected the response of the middleware, got %q", response.GetContent())
+	}
+	if len(provider.Requests()) != 0 {
+		t.Errorf("Expected the provider not to be called, got %d requests", len(provider.Requests()))
+	}
+	expected := "outer:before,outer:after"
+	if got := strings.Join(calls, ","); got != expected {
+		t.Errorf("Expected calls %s, got %s", expected, got)
+	}
+}
+
+func TestWrapProviderErrorPropagation(t *testing.T) {
+	providerErr := errors.New("provider failed")
+	provider := test.NewMockProvider(test.MockResponse{Err: providerErr})
+	counter := &llm.TokenCounter{}
+
+	var seenErr error
+	observer := func(next llm.CreateMessageFunc) llm.CreateMessageFunc {
+		return func(ctx context.Context, prompt string, messages []llm.Message, tools []llm.Tool) (llm.Message, error) {
+			response, err := next(ctx, prompt, messages, tools)
+			seenErr = err
+			return response, err
+		}
+	}
+	wrapped := llm.WrapProvider(provider, observer, counter.Middleware())
+
+	_, err := wrapped.CreateMessage(context.Background(), "hello", nil, nil)
+	if !errors.Is(err, providerErr) {
+		t.Errorf("Expected the provider error, got %v", err)
+	}
+	if !errors.Is(seenErr, providerErr) {
+		t.Errorf("Expected the outer middleware to see the provider error, got %v", seenErr)
+	}
+	if _, _, calls := counter.Totals(); calls != 0 {
+		t.Errorf("Expected failed calls not to be counted, got %d", calls)
+	}
+}
+
+func TestWrapProviderWithoutMiddlewares(t *testing.T) {
+	provider := test.NewMockProvider()
+	if wrapped := llm.WrapProvider(provider); wrapped != llm.Provider(provider) {
+		t.Errorf("Expected the provider to be returned unchanged")
+	}
+}
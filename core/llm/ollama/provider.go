@@ -2,26 +2,34 @@ package ollama
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gelembjuk/cleverchatty/core/history"
 	"github.com/gelembjuk/cleverchatty/core/llm"
 	api "github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/envconfig"
 )
 
 func boolPtr(b bool) *bool {
 	return &b
 }
 
+const roleSystem = "system"
+
 // Provider implements the Provider interface for Ollama
 type Provider struct {
-	client *api.Client
-	model  string
-	logger *log.Logger
+	client            *api.Client
+	model             string
+	logger            *log.Logger
+	generationOptions llm.GenerationOptions
 }
 
 // NewProvider creates a new Ollama provider
@@ -37,6 +45,21 @@ func NewProvider(model string) (*Provider, error) {
 	}, nil
 }
 
+// CheckServer verifies that the Ollama server (OLLAMA_HOST or the local default) responds
+func CheckServer(ctx context.Context) error {
+	client, err := api.ClientFromEnvironment()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := client.Heartbeat(ctx); err != nil {
+		return fmt.Errorf("ollama server at %s is not reachable: %w", envconfig.Host(), err)
+	}
+	return nil
+}
+
 func (p *Provider) CreateMessage(
 	ctx context.Context,
 	prompt string,
@@ -48,7 +71,125 @@ func (p *Provider) CreateMessage(
 		len(messages),
 		len(tools))
 
-	// Convert generic messages to Ollama format
+	if hasImages(messages) && !p.Capabilities().Images {
+		return nil, fmt.Errorf("%w: %s", llm.ErrImagesNotSupported, p.model)
+	}
+	ollamaMessages, err := convertMessages(messages, prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert tools to Ollama format
+	ollamaTools := make([]api.Tool, len(tools))
+	for i, tool := range tools {
+		ollamaTools[i] = api.Tool{
+			Type: "function",
+			Function: api.ToolFunction{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters: struct {
+					Type       string   `json:"type"`
+					Required   []string `json:"required"`
+					Properties map[string]struct {
+						Type        string   `json:"type"`
+						Description string   `json:"description"`
+						Enum        []string `json:"enum,omitempty"`
+					} `json:"properties"`
+				}{
+					Type:       tool.InputSchema.Type,
+					Required:   tool.InputSchema.Required,
+					Properties: convertProperties(tool.InputSchema.Properties),
+				},
+			},
+		}
+	}
+
+	var response api.Message
+	p.logger.Printf("creating message with prompt: %s, num_messages: %d, num_tools: %d\n",
+		prompt,
+		len(messages),
+		len(tools))
+
+	p.logger.Printf("sending messages to Ollama message API: %v, num_tools: %d\n",
+		ollamaMessages,
+		len(tools))
+
+	err = p.client.Chat(ctx, &api.ChatRequest{
+		Model:    p.model,
+		Messages: ollamaMessages,
+		Tools:    ollamaTools,
+		Stream:   boolPtr(false),
+		Options:  p.requestOptions(),
+	}, func(r api.ChatResponse) error {
+		if r.Done {
+			response = r.Message
+		}
+		return nil
+	})
+
+	if err != nil {
+		if isOverloaded(err) {
+			return nil, fmt.Errorf("%w: %v", llm.ErrProviderOverloaded, err)
+		}
+		return nil, err
+	}
+
+	return &OllamaMessage{Message: response}, nil
+}
+
+// ollamaBusyMessage starts the error of the server responding with 503 when its queue of requests is full
+const ollamaBusyMessage = "server busy"
+
+// isOverloaded checks if the server rejected the request with 503 because it is busy. The client
+// returns the status code only when the response has no error message, otherwise only the message
+func isOverloaded(err error) bool {
+	var statusErr api.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusServiceUnavailable
+	}
+	return strings.HasPrefix(err.Error(), ollamaBusyMessage)
+}
+
+// SetGenerationOptions sets the stop sequences and the seed of the requests
+func (p *Provider) SetGenerationOptions(options llm.GenerationOptions) {
+	p.generationOptions = options
+}
+
+// requestOptions returns the model options of a chat request. Nil keeps the model defaults
+func (p *Provider) requestOptions() map[string]interface{} {
+	options := map[string]interface{}{}
+	if len(p.generationOptions.Stop) > 0 {
+		options["stop"] = p.generationOptions.Stop
+	}
+	if p.generationOptions.Seed != nil {
+		options["seed"] = *p.generationOptions.Seed
+	}
+	if len(options) == 0 {
+		return nil
+	}
+	return options
+}
+
+// userImages returns the images of a user message
+func userImages(msg llm.Message) []llm.Image {
+	if imageMsg, ok := msg.(llm.ImageMessage); ok && msg.GetRole() == "user" {
+		return imageMsg.GetImages()
+	}
+	return nil
+}
+
+func hasImages(messages []llm.Message) bool {
+	for _, msg := range messages {
+		if len(userImages(msg)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// convertMessages converts generic messages and the new prompt to Ollama chat messages.
+// Images of user messages are sent in the images field of the message
+func convertMessages(messages []llm.Message, prompt string) ([]api.Message, error) {
 	ollamaMessages := make([]api.Message, 0, len(messages)+1)
 
 	// Add existing messages
@@ -84,8 +225,19 @@ func (p *Provider) CreateMessage(
 			continue
 		}
 
-		// Skip completely empty messages (no content and no tool calls)
-		if msg.GetContent() == "" && len(msg.GetToolCalls()) == 0 {
+		images := userImages(msg)
+
+		// Skip completely empty messages (no content, no tool calls and no images)
+		if msg.GetContent() == "" && len(msg.GetToolCalls()) == 0 && len(images) == 0 {
+			continue
+		}
+
+		// System instructions and memory notes use the native system role of Ollama
+		if msg.GetRole() == roleSystem {
+			ollamaMessages = append(ollamaMessages, api.Message{
+				Role:    roleSystem,
+				Content: msg.GetContent(),
+			})
 			continue
 		}
 
@@ -93,6 +245,13 @@ func (p *Provider) CreateMessage(
 			Role:    msg.GetRole(),
 			Content: msg.GetContent(),
 		}
+		for _, image := range images {
+			data, err := base64.StdEncoding.DecodeString(image.Data)
+			if err != nil {
+				return nil, fmt.Errorf("invalid image data: %w", err)
+			}
+			ollamaMsg.Images = append(ollamaMsg.Images, api.ImageData(data))
+		}
 
 		// Add tool calls for assistant messages
 		if msg.GetRole() == "assistant" {
@@ -123,69 +282,26 @@ func (p *Provider) CreateMessage(
 		})
 	}
 
-	// Convert tools to Ollama format
-	ollamaTools := make([]api.Tool, len(tools))
-	for i, tool := range tools {
-		ollamaTools[i] = api.Tool{
-			Type: "function",
-			Function: api.ToolFunction{
-				Name:        tool.Name,
-				Description: tool.Description,
-				Parameters: struct {
-					Type       string   `json:"type"`
-					Required   []string `json:"required"`
-					Properties map[string]struct {
-						Type        string   `json:"type"`
-						Description string   `json:"description"`
-						Enum        []string `json:"enum,omitempty"`
-					} `json:"properties"`
-				}{
-					Type:       tool.InputSchema.Type,
-					Required:   tool.InputSchema.Required,
-					Properties: convertProperties(tool.InputSchema.Properties),
-				},
-			},
-		}
-	}
-
-	var response api.Message
-	p.logger.Printf("creating message with prompt: %s, num_messages: %d, num_tools: %d\n",
-		prompt,
-		len(messages),
-		len(tools))
-
-	p.logger.Printf("sending messages to Ollama message API: %v, num_tools: %d\n",
-		ollamaMessages,
-		len(tools))
-
-	err := p.client.Chat(ctx, &api.ChatRequest{
-		Model:    p.model,
-		Messages: ollamaMessages,
-		Tools:    ollamaTools,
-		Stream:   boolPtr(false),
-	}, func(r api.ChatResponse) error {
-		if r.Done {
-			response = r.Message
-		}
-		return nil
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	return &OllamaMessage{Message: response}, nil
+	return ollamaMessages, nil
 }
 
 func (p *Provider) SupportsTools() bool {
-	// Check if model supports function calling
+	return p.Capabilities().Tools
+}
+
+// Capabilities asks the Ollama server about the model. Tools are supported if the template
+// of the model has a tools section, images if the model has a vision projector
+func (p *Provider) Capabilities() llm.ProviderCapabilities {
+	capabilities := llm.ProviderCapabilities{}
 	resp, err := p.client.Show(context.Background(), &api.ShowRequest{
 		Model: p.model,
 	})
 	if err != nil {
-		return false
+		return capabilities
 	}
-	return strings.Contains(resp.Modelfile, "<tools>")
+	capabilities.Tools = strings.Contains(resp.Modelfile, "<tools>")
+	capabilities.Images = len(resp.ProjectorInfo) > 0
+	return capabilities
 }
 
 func (p *Provider) Name() string {
@@ -288,3 +404,20 @@ func getString(m map[string]interface{}, key string) string {
 	}
 	return ""
 }
+
+// ListModels returns the models pulled to the local Ollama server
+func ListModels(ctx context.Context) ([]string, error) {
+	client, err := api.ClientFromEnvironment()
+	if err != nil {
+		return nil, err
+	}
+	response, err := client.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	models := make([]string, 0, len(response.Models))
+	for _, model := range response.Models {
+		models = append(models, model.Name)
+	}
+	return models, nil
+}
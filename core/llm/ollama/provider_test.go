@@ -0,0 +1,117 @@
+package ollama
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gelembjuk/cleverchatty/core/history"
+	"github.com/gelembjuk/cleverchatty/core/llm"
+	api "github.com/ollama/ollama/api"
+)
+
+// newTestProvider returns the provider sending the requests to the test server
+func newTestProvider(t *testing.T, handler http.HandlerFunc) *Provider {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	serverURL, _ := url.Parse(server.URL)
+	return &Provider{
+		client: api.NewClient(serverURL, server.Client()),
+		model:  "test",
+		logger: log.New(io.Discard, "", 0),
+	}
+}
+
+func TestConvertMessagesSystemInstruction(t *testing.T) {
+	instruction := history.NewSystemInstructionMessage("You are a helpful assistant")
+
+	messages, err := convertMessages([]llm.Message{&instruction}, "Hello")
+	if err != nil {
+		t.Fatalf("Failed to convert messages: %v", err)
+	}
+
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(messages))
+	}
+	if messages[0].Role != "system" || messages[0].Content != "You are a helpful assistant" {
+		t.Errorf("Expected the system instruction as a system message, got %+v", messages[0])
+	}
+	if messages[1].Role != "user" || messages[1].Content != "Hello" {
+		t.Errorf("Expected the prompt as a user message, got %+v", messages[1])
+	}
+}
+
+func TestConvertMessagesImages(t *testing.T) {
+	question := history.HistoryMessage{
+		Role: "user",
+		Content: []history.ContentBlock{
+			{Type: "text", Text: "What is on the picture?"},
+			{Type: "image", MimeType: "image/png", Data: base64.StdEncoding.EncodeToString([]byte("png data"))},
+		},
+	}
+
+	messages, err := convertMessages([]llm.Message{&question}, "")
+	if err != nil {
+		t.Fatalf("Failed to convert messages: %v", err)
+	}
+	if len(messages) != 1 || len(messages[0].Images) != 1 || string(messages[0].Images[0]) != "png data" {
+		t.Fatalf("Expected the decoded image in the user message, got %+v", messages)
+	}
+	if !hasImages([]llm.Message{&question}) {
+		t.Errorf("Expected the message to be detected as having images")
+	}
+}
+
+func TestCreateMessageOverloaded(t *testing.T) {
+	tests := []struct {
+		status     int
+		body       string
+		overloaded bool
+	}{
+		{http.StatusServiceUnavailable, `{"error": "server busy, please try again.  maximum pending requests exceeded"}`, true},
+		{http.StatusServiceUnavailable, `{}`, true},
+		{http.StatusNotFound, `{"error": "model not found"}`, false},
+	}
+	for _, test := range tests {
+		provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.status)
+			w.Write([]byte(test.body))
+		})
+
+		_, err := provider.CreateMessage(context.Background(), "Hello", nil, nil)
+		if err == nil {
+			t.Fatalf("Expected the error of status %d", test.status)
+		}
+		if overloaded := errors.Is(err, llm.ErrProviderOverloaded); overloaded != test.overloaded {
+			t.Errorf("Expected %d %s overloaded %v, got %v", test.status, test.body, test.overloaded, err)
+		}
+	}
+}
+
+func TestCreateMessageGenerationOptions(t *testing.T) {
+	var request api.ChatRequest
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&request)
+		w.Write([]byte(`{"message": {"role": "assistant", "content": "Hi"}, "done": true}`))
+	})
+	seed := 42
+	provider.SetGenerationOptions(llm.GenerationOptions{Stop: []string{"\nUser:"}, Seed: &seed})
+
+	if _, err := provider.CreateMessage(context.Background(), "Hello", nil, nil); err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	stop, _ := request.Options["stop"].([]interface{})
+	if len(stop) != 1 || stop[0] != "\nUser:" {
+		t.Errorf("Expected the stop sequences in the request options, got %v", request.Options["stop"])
+	}
+	if request.Options["seed"] != float64(42) {
+		t.Errorf("Expected the seed in the request options, got %v", request.Options["seed"])
+	}
+}
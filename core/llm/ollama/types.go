@@ -36,6 +36,10 @@ func (m *OllamaMessage) GetUsage() (int, int) {
 	return 0, 0 // Ollama doesn't provide token usage info
 }
 
+func (m *OllamaMessage) GetFinishReason() string {
+	return llm.FinishReasonUnknown
+}
+
 func (m *OllamaMessage) IsToolResponse() bool {
 	return m.Message.Role == "tool"
 }
@@ -6,6 +6,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+
+	"github.com/gelembjuk/cleverchatty/core/llm"
 )
 
 type Client struct {
@@ -58,7 +60,11 @@ func (c *Client) CreateChatCompletion(ctx context.Context, req CreateRequest) (*
 				Code    string `json:"code"`
 			} `json:"error"`
 		}
-		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
+		decodeErr := json.NewDecoder(resp.Body).Decode(&errResp)
+		if resp.StatusCode == http.StatusServiceUnavailable {
+			return nil, fmt.Errorf("%w: %s", llm.ErrProviderOverloaded, errResp.Error.Message)
+		}
+		if decodeErr != nil {
 			return nil, fmt.Errorf("error response with status %d", resp.StatusCode)
 		}
 		return nil, fmt.Errorf("%s: %s", errResp.Error.Type, errResp.Error.Message)
@@ -71,3 +77,37 @@ func (c *Client) CreateChatCompletion(ctx context.Context, req CreateRequest) (*
 
 	return &response, nil
 }
+
+// ListModels returns the IDs of the models available with the API key
+func (c *Client) ListModels(ctx context.Context) ([]string, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/models", c.baseURL), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error response with status %d", resp.StatusCode)
+	}
+
+	var response struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	models := make([]string, 0, len(response.Data))
+	for _, model := range response.Data {
+		models = append(models, model.ID)
+	}
+	return models, nil
+}
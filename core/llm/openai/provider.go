@@ -13,9 +13,11 @@ import (
 )
 
 type Provider struct {
-	client *Client
-	model  string
-	logger *log.Logger
+	client            *Client
+	model             string
+	logger            *log.Logger
+	parallelToolCalls *bool
+	generationOptions llm.GenerationOptions
 }
 
 func convertSchema(schema llm.Schema) map[string]interface{} {
@@ -69,6 +71,15 @@ func (p *Provider) CreateMessage(
 			param.Content = &content
 		}
 
+		if imageMsg, ok := msg.(llm.ImageMessage); ok && msg.GetRole() == "user" {
+			if images := imageMsg.GetImages(); len(images) > 0 {
+				if !p.isVisionModel() {
+					return nil, fmt.Errorf("%w: %s", llm.ErrImagesNotSupported, p.model)
+				}
+				param.ContentParts = imageContentParts(msg.GetContent(), images)
+			}
+		}
+
 		// Handle function/tool calls
 		toolCalls := msg.GetToolCalls()
 		if len(toolCalls) > 0 {
@@ -165,16 +176,23 @@ func (p *Provider) CreateMessage(
 		Messages: openaiMessages,
 		Tools:    openaiTools,
 	}
+	// The API rejects parallel_tool_calls when no tools are given
+	if len(openaiTools) > 0 {
+		req.ParallelToolCalls = p.parallelToolCalls
+	}
+
+	req.Seed = p.generationOptions.Seed
 
 	// Use max_completion_tokens for newer models (o1, o3, etc.) that don't support max_tokens
 	maxTokens := 4096
 	if p.isReasoningModel() {
 		req.MaxCompletionTokens = &maxTokens
-		// Temperature is not supported for reasoning models
+		// Temperature and stop sequences are not supported for reasoning models
 	} else {
 		req.MaxTokens = &maxTokens
 		temp := float32(0.7)
 		req.Temperature = &temp
+		req.Stop = p.generationOptions.Stop
 	}
 
 	resp, err := p.client.CreateChatCompletion(ctx, req)
@@ -189,6 +207,26 @@ func (p *Provider) CreateMessage(
 	return &Message{Resp: resp, Choice: &resp.Choices[0]}, nil
 }
 
+// imageContentParts builds the multimodal content of a message, the text goes first
+func imageContentParts(text string, images []llm.Image) []ContentPart {
+	parts := []ContentPart{}
+	if text != "" {
+		parts = append(parts, ContentPart{
+			Type: "text",
+			Text: text,
+		})
+	}
+	for _, image := range images {
+		parts = append(parts, ContentPart{
+			Type: "image_url",
+			ImageURL: &ImageURL{
+				URL: fmt.Sprintf("data:%s;base64,%s", image.MimeType, image.Data),
+			},
+		})
+	}
+	return parts
+}
+
 func (p *Provider) SupportsTools() bool {
 	return true
 }
@@ -197,6 +235,26 @@ func (p *Provider) Name() string {
 	return "openai"
 }
 
+func (p *Provider) Capabilities() llm.ProviderCapabilities {
+	return llm.ProviderCapabilities{
+		Tools:     true,
+		Images:    p.isVisionModel(),
+		Reasoning: p.isReasoningModel(),
+	}
+}
+
+// isVisionModel returns true if the model accepts images in the input
+func (p *Provider) isVisionModel() bool {
+	model := strings.ToLower(p.model)
+	return strings.HasPrefix(model, "gpt-4o") ||
+		strings.HasPrefix(model, "gpt-4.1") ||
+		strings.HasPrefix(model, "gpt-4-turbo") ||
+		strings.HasPrefix(model, "gpt-5") ||
+		(strings.HasPrefix(model, "o1") && !strings.HasPrefix(model, "o1-mini")) ||
+		(strings.HasPrefix(model, "o3") && !strings.HasPrefix(model, "o3-mini")) ||
+		strings.HasPrefix(model, "o4")
+}
+
 // isNewAPIModel returns true if the model requires max_completion_tokens instead of max_tokens
 // This includes reasoning models (o1, o3) and newer GPT models (gpt-5+)
 func (p *Provider) isReasoningModel() bool {
@@ -207,6 +265,18 @@ func (p *Provider) isReasoningModel() bool {
 		strings.HasPrefix(model, "gpt-5")
 }
 
+// SetParallelToolCalls sets whether the model may return several tool calls in one response.
+// With false the model returns at most one tool call per turn. Nil keeps the API default
+func (p *Provider) SetParallelToolCalls(parallel *bool) {
+	p.parallelToolCalls = parallel
+}
+
+// SetGenerationOptions sets the stop sequences and the seed of the requests.
+// Reasoning models do not accept stop sequences, they are not sent for them
+func (p *Provider) SetGenerationOptions(options llm.GenerationOptions) {
+	p.generationOptions = options
+}
+
 func (p *Provider) SetLogger(logger *log.Logger) {
 	p.logger = logger
 }
@@ -336,6 +406,20 @@ func (m *Message) GetUsage() (int, int) {
 	return m.Resp.Usage.PromptTokens, m.Resp.Usage.CompletionTokens
 }
 
+func (m *Message) GetFinishReason() string {
+	switch m.Choice.FinishReason {
+	case "stop":
+		return llm.FinishReasonStop
+	case "length":
+		return llm.FinishReasonLength
+	case "tool_calls", "function_call":
+		return llm.FinishReasonToolCalls
+	case "content_filter":
+		return llm.FinishReasonContentFilter
+	}
+	return llm.FinishReasonUnknown
+}
+
 // ToolCallWrapper implements llm.ToolCall
 type ToolCallWrapper struct {
 	Call ToolCall
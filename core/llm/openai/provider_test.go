@@ -0,0 +1,53 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gelembjuk/cleverchatty/core/llm"
+)
+
+// newTestServer returns the URL of the API answering with a text and the last request body
+func newTestServer(t *testing.T) (string, *CreateRequest) {
+	request := &CreateRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(request)
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Hi"}, "finish_reason": "stop"}]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, request
+}
+
+func TestCreateMessageGenerationOptions(t *testing.T) {
+	url, request := newTestServer(t)
+	provider := NewProvider("key", url, "gpt-4o")
+	seed := 42
+	provider.SetGenerationOptions(llm.GenerationOptions{Stop: []string{"\nUser:"}, Seed: &seed})
+
+	if _, err := provider.CreateMessage(context.Background(), "Hello", nil, nil); err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	if len(request.Stop) != 1 || request.Stop[0] != "\nUser:" {
+		t.Errorf("Expected the stop sequences in the request, got %v", request.Stop)
+	}
+	if request.Seed == nil || *request.Seed != 42 {
+		t.Errorf("Expected the seed in the request, got %v", request.Seed)
+	}
+
+	// Reasoning models do not accept stop sequences, the seed is still sent
+	url, request = newTestServer(t)
+	provider = NewProvider("key", url, "o3-mini")
+	provider.SetGenerationOptions(llm.GenerationOptions{Stop: []string{"\nUser:"}, Seed: &seed})
+	if _, err := provider.CreateMessage(context.Background(), "Hello", nil, nil); err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	if len(request.Stop) != 0 {
+		t.Errorf("Expected no stop sequences for the reasoning model, got %v", request.Stop)
+	}
+	if request.Seed == nil || *request.Seed != 42 {
+		t.Errorf("Expected the seed in the request of the reasoning model, got %v", request.Seed)
+	}
+}
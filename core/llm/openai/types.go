@@ -1,5 +1,7 @@
 package openai
 
+import "encoding/json"
+
 type CreateRequest struct {
 	Model               string         `json:"model"`
 	Messages            []MessageParam `json:"messages"`
@@ -7,6 +9,9 @@ type CreateRequest struct {
 	MaxTokens           *int           `json:"max_tokens,omitempty"`
 	MaxCompletionTokens *int           `json:"max_completion_tokens,omitempty"`
 	Temperature         *float32       `json:"temperature,omitempty"`
+	ParallelToolCalls   *bool          `json:"parallel_tool_calls,omitempty"`
+	Stop                []string       `json:"stop,omitempty"`
+	Seed                *int           `json:"seed,omitempty"`
 }
 
 type MessageParam struct {
@@ -17,6 +22,35 @@ type MessageParam struct {
 	ToolCalls        []ToolCall    `json:"tool_calls,omitempty"`
 	Name             string        `json:"name,omitempty"`
 	ToolCallID       string        `json:"tool_call_id,omitempty"`
+	// ContentParts replace Content in a request when a message has images
+	ContentParts []ContentPart `json:"-"`
+}
+
+// MarshalJSON sends the content parts as the content when they are set
+func (m MessageParam) MarshalJSON() ([]byte, error) {
+	type messageParam MessageParam
+	if len(m.ContentParts) == 0 {
+		return json.Marshal(messageParam(m))
+	}
+	return json.Marshal(struct {
+		messageParam
+		Content []ContentPart `json:"content"`
+	}{
+		messageParam: messageParam(m),
+		Content:      m.ContentParts,
+	})
+}
+
+// ContentPart is a part of a multimodal message content
+type ContentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+// ImageURL is an image given by a URL. Inline images use a data URL
+type ImageURL struct {
+	URL string `json:"url"`
 }
 
 type ToolCall struct {
@@ -24,8 +24,21 @@ type Message interface {
 
 	// GetUsage returns token usage statistics if available
 	GetUsage() (input int, output int)
+
+	// GetFinishReason returns why the model stopped generating, one of the FinishReason
+	// constants. FinishReasonUnknown if the provider does not report it
+	GetFinishReason() string
 }
 
+// Reasons why the model stopped generating a response
+const (
+	FinishReasonStop          = "stop"           // The response is complete
+	FinishReasonLength        = "length"         // The response reached the output token limit
+	FinishReasonToolCalls     = "tool_calls"     // The model called tools
+	FinishReasonContentFilter = "content_filter" // The response was blocked by the safety filter of the provider
+	FinishReasonUnknown       = "unknown"
+)
+
 // ToolCall represents a tool invocation
 type ToolCall interface {
 	// GetName returns the tool's name
@@ -38,6 +51,38 @@ type ToolCall interface {
 	GetID() string
 }
 
+// ThinkingBlock is a piece of the reasoning of a model in the extended thinking mode
+type ThinkingBlock struct {
+	Text      string // The reasoning summary. Empty for a redacted block
+	Signature string // Verifies the block when it is sent back to the provider
+	Redacted  string // Encrypted reasoning of a redacted block
+}
+
+// ThinkingMessage is implemented by messages that can carry the reasoning of the model
+type ThinkingMessage interface {
+	// GetThinkingBlocks returns the reasoning blocks in the order the model produced them
+	GetThinkingBlocks() []ThinkingBlock
+}
+
+// GenerationOptions control how a response is generated. Providers ignore the options
+// their API does not support
+type GenerationOptions struct {
+	Stop []string // The generation stops when the model produces one of the sequences
+	Seed *int     // Makes the sampling repeatable. Nil keeps the API default
+}
+
+// Image is an image in the input of the model
+type Image struct {
+	MimeType string
+	Data     string // Base64 encoded
+}
+
+// ImageMessage is implemented by messages that can carry images
+type ImageMessage interface {
+	// GetImages returns the images of the message
+	GetImages() []Image
+}
+
 // Tool represents a tool definition
 type Tool struct {
 	Name        string `json:"name"`
@@ -52,6 +97,13 @@ type Schema struct {
 	Required   []string               `json:"required"`
 }
 
+// ProviderCapabilities describes the features a provider supports with its model
+type ProviderCapabilities struct {
+	Tools     bool // Tool/function calling
+	Images    bool // Images in the input messages
+	Reasoning bool // The model reasons before answering and can share the reasoning
+}
+
 // Provider defines the interface for LLM providers
 type Provider interface {
 	// CreateMessage sends a message to the LLM and returns the response
@@ -63,6 +115,9 @@ type Provider interface {
 	// SupportsTools returns whether this provider supports tool/function calling
 	SupportsTools() bool
 
+	// Capabilities returns the features supported by the provider with its model
+	Capabilities() ProviderCapabilities
+
 	// Name returns the provider's name
 	Name() string
 	// set custom logger
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const forgetMemoryToolName = "forget_memory"
+
+const forgetMemoryToolDescription = "Delete facts from your long-term memory. " +
+	"Use it only when the user asks you to forget something, describe what to forget in the query."
+
+// Forget requests the memory server to delete the memories matching the query and returns
+// the response of the server. ErrForgetNotSupported is returned when the memory server can not forget
+func (assistant *CleverChatty) Forget(ctx context.Context, query string) (string, error) {
+	if strings.TrimSpace(query) == "" {
+		return "", fmt.Errorf("query is required")
+	}
+	return assistant.toolsHost.Forget(ctx, query)
+}
+
+// syncForgetTool registers the forget tool when there is a memory server and removes it
+// when there is none, so the model is not offered a tool that can not work
+func (assistant *CleverChatty) syncForgetTool() error {
+	registered := assistant.toolsHost.hasCustomTool(forgetMemoryToolName)
+	hasMemoryServer := assistant.toolsHost.HasMemoryServer()
+	if hasMemoryServer && !registered {
+		return assistant.registerForgetTool()
+	}
+	if !hasMemoryServer && registered {
+		assistant.RemoveTool(forgetMemoryToolName)
+	}
+	return nil
+}
+
+// registerForgetTool adds the tool the model uses to delete memories on the user's request
+func (assistant *CleverChatty) registerForgetTool() error {
+	return assistant.SetTool(CustomTool{
+		Name:        forgetMemoryToolName,
+		Description: forgetMemoryToolDescription,
+		Arguments: []ToolArgument{
+			{
+				Name:        "query",
+				Type:        "string",
+				Description: "The facts to forget",
+				Required:    true,
+			},
+		},
+		Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			query, _ := args["query"].(string)
+			result, err := assistant.Forget(ctx, query)
+			if errors.Is(err, ErrForgetNotSupported) {
+				// Tell the model, so it does not promise the user the facts are gone
+				return "Forgetting is not supported by the memory server, nothing was deleted", nil
+			}
+			if err != nil {
+				return "", err
+			}
+			if result == "" {
+				result = "Done"
+			}
+			return result, nil
+		},
+	})
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultMemoryQueueSize  = 100
+	memoryWriteTimeout      = 30 * time.Second
+	memoryQueueFlushTimeout = 30 * time.Second
+	memoryQueueDrainPoll    = 10 * time.Millisecond
+)
+
+// MemoryQueueStats describes the messages waiting to be sent to the memory server
+type MemoryQueueStats struct {
+	Pending int64 `json:"pending_writes"` // Messages in the queue or being sent
+	Dropped int64 `json:"dropped_writes"` // Messages dropped because the queue was full
+}
+
+// memoryWrite is a message to send to the memory server
+type memoryWrite struct {
+	role     string
+	text     string
+	metadata map[string]interface{} // Additional arguments of the remember tool. Optional
+	ctx      context.Context
+}
+
+// memoryQueue sends messages to the memory server in the background, so a slow memory server
+// does not delay responses. Messages are sent one by one in the order they were added.
+type memoryQueue struct {
+	writes  chan memoryWrite
+	done    chan struct{}
+	send    func(memoryWrite)
+	pending atomic.Int64
+	dropped atomic.Int64
+	queued  atomic.Int64 // Messages accepted since the start
+	sent    atomic.Int64 // Messages sent since the start
+	mu      sync.Mutex // Protects closed and sending to writes
+	closed  bool
+}
+
+func newMemoryQueue(size int, send func(memoryWrite)) *memoryQueue {
+	if size <= 0 {
+		size = defaultMemoryQueueSize
+	}
+	q := &memoryQueue{
+		writes: make(chan memoryWrite, size),
+		done:   make(chan struct{}),
+		send:   send,
+	}
+	go q.run()
+	return q
+}
+
+func (q *memoryQueue) run() {
+	defer close(q.done)
+	for write := range q.writes {
+		q.send(write)
+		q.pending.Add(-1)
+		q.sent.Add(1)
+	}
+}
+
+// add queues the message. It returns false if the queue is full or closed and the message is dropped
+func (q *memoryQueue) add(write memoryWrite) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		q.dropped.Add(1)
+		return false
+	}
+	q.pending.Add(1)
+	q.queued.Add(1)
+	select {
+	case q.writes <- write:
+		return true
+	default:
+		q.pending.Add(-1)
+		q.queued.Add(-1)
+		q.dropped.Add(1)
+		return false
+	}
+}
+
+// drain waits until the messages queued before the call are sent, the queue keeps accepting
+// new ones. It returns the error of the context if it is done first
+func (q *memoryQueue) drain(ctx context.Context) error {
+	target := q.queued.Load()
+	if q.sent.Load() >= target {
+		return nil
+	}
+	ticker := time.NewTicker(memoryQueueDrainPoll)
+	defer ticker.Stop()
+	for q.sent.Load() < target {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// flush stops accepting messages and waits until the queued ones are sent or the timeout expires.
+// It returns the number of messages that were not sent in time
+func (q *memoryQueue) flush(timeout time.Duration) int64 {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.writes)
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-q.done:
+		return 0
+	case <-time.After(timeout):
+		return q.pending.Load()
+	}
+}
+
+func (q *memoryQueue) stats() MemoryQueueStats {
+	return MemoryQueueStats{
+		Pending: q.pending.Load(),
+		Dropped: q.dropped.Load(),
+	}
+}
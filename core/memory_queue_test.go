@@ -0,0 +1,127 @@
+package core
+
+import (
+	"context"
+	"io"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gelembjuk/cleverchatty/core/history"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestMemoryQueueOrderAndOverflow(t *testing.T) {
+	taken := make(chan struct{}, 4)
+	release := make(chan struct{})
+	var mu sync.Mutex
+	sent := []string{}
+
+	queue := newMemoryQueue(2, func(write memoryWrite) {
+		taken <- struct{}{}
+		<-release // A slow memory server
+		mu.Lock()
+		sent = append(sent, write.text)
+		mu.Unlock()
+	})
+
+	// The worker takes the first message, so it does not occupy the queue
+	queue.add(memoryWrite{role: "user", text: "first", ctx: context.Background()})
+	<-taken
+	for _, text := range []string{"second", "third", "fourth"} {
+		queue.add(memoryWrite{role: "user", text: text, ctx: context.Background()})
+	}
+
+	stats := queue.stats()
+	if stats.Pending != 3 || stats.Dropped != 1 {
+		t.Errorf("Expected 3 pending and 1 dropped writes, got %+v", stats)
+	}
+
+	close(release)
+	if lost := queue.flush(time.Second); lost != 0 {
+		t.Fatalf("Expected all writes to be flushed, %d lost", lost)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sent) != 3 || sent[0] != "first" || sent[1] != "second" || sent[2] != "third" {
+		t.Errorf("Expected writes in order, got %v", sent)
+	}
+	if queue.add(memoryWrite{role: "user", text: "late"}) {
+		t.Errorf("Expected writes after the flush to be rejected")
+	}
+}
+
+func TestMemoryQueueDrain(t *testing.T) {
+	release := make(chan struct{})
+	queue := newMemoryQueue(2, func(write memoryWrite) {
+		<-release // A slow memory server
+	})
+	defer queue.flush(time.Second)
+
+	queue.add(memoryWrite{role: "user", text: "first", ctx: context.Background()})
+	queue.add(memoryWrite{role: "user", text: "second", ctx: context.Background()})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := queue.drain(ctx); err == nil {
+		t.Fatalf("Expected the drain to wait for the slow memory server")
+	}
+
+	close(release)
+	if err := queue.drain(context.Background()); err != nil {
+		t.Fatalf("Failed to drain the queue: %v", err)
+	}
+	if stats := queue.stats(); stats.Pending != 0 {
+		t.Errorf("Expected no pending writes after the drain, got %+v", stats)
+	}
+	// The queue still accepts messages
+	if !queue.add(memoryWrite{role: "user", text: "third", ctx: context.Background()}) {
+		t.Errorf("Expected the drained queue to accept messages")
+	}
+}
+
+func TestForgetAfterQueuedMemories(t *testing.T) {
+	var mu sync.Mutex
+	calls := []string{}
+	record := func(call string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, call)
+	}
+	mcpServer := server.NewMCPServer("memory", "1.0.0")
+	mcpServer.AddTool(mcp.NewTool(memoryToolRememberName), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		time.Sleep(50 * time.Millisecond) // A slow memory server
+		record(memoryToolRememberName)
+		return mcp.NewToolResultText("OK"), nil
+	})
+	mcpServer.AddTool(mcp.NewTool(memoryToolForgetName), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		record(memoryToolForgetName)
+		return mcp.NewToolResultText("Forgotten"), nil
+	})
+	testServer := server.NewTestStreamableHTTPServer(mcpServer)
+	defer testServer.Close()
+
+	config := map[string]ServerConfigWrapper{"memory": {
+		Config:    HTTPStreamingMCPServerConfig{Url: testServer.URL + "/mcp"},
+		Interface: toolsServerInterfaceMemory,
+	}}
+	host, _ := newToolsHost(config, log.New(io.Discard, "", 0), context.Background(), t.TempDir())
+	if err := host.Init(); err != nil {
+		t.Fatalf("Failed to init the tools host: %v", err)
+	}
+	defer host.Close()
+
+	host.Remember("user", history.ContentBlock{Type: "text", Text: "My address is 1 Main St"}, context.Background(), nil)
+	if _, err := host.Forget(context.Background(), "my address"); err != nil {
+		t.Fatalf("Failed to forget: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(calls) != 2 || calls[0] != memoryToolRememberName || calls[1] != memoryToolForgetName {
+		t.Errorf("Expected the queued memory to be sent before forgetting, got %v", calls)
+	}
+}
@@ -0,0 +1,434 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gelembjuk/cleverchatty/core/history"
+	"github.com/gelembjuk/cleverchatty/core/llm"
+	"github.com/gelembjuk/cleverchatty/core/test"
+)
+
+func TestTwoToolsConversationWithScriptedProvider(t *testing.T) {
+	provider := test.NewMockProvider(
+		test.MockResponse{ToolCalls: []test.MockToolCall{
+			{ID: "call_1", Name: "custom__get_city", Arguments: map[string]interface{}{}},
+		}, Usage: [2]int{100, 10}},
+		test.MockResponse{ToolCalls: []test.MockToolCall{
+			{ID: "call_2", Name: "custom__get_weather", Arguments: map[string]interface{}{"city": "Paris"}},
+		}, Usage: [2]int{120, 12}},
+		test.MockResponse{Content: "It is sunny in Paris", Usage: [2]int{140, 14}},
+	)
+
+	assistant := newTestAssistantWithProvider(t, CleverChattyConfig{}, provider)
+
+	assistant.SetTool(CustomTool{
+		Name:        "get_city",
+		Description: "Returns the city of the user",
+		Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			return "Paris", nil
+		},
+	})
+	assistant.SetTool(CustomTool{
+		Name:        "get_weather",
+		Description: "Returns the weather in a city",
+		Arguments:   []ToolArgument{{Name: "city", Type: "string", Required: true}},
+		Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			return fmt.Sprintf("Sunny in %v", args["city"]), nil
+		},
+	})
+
+	calledTools := []string{}
+	assistant.Callbacks.SetToolCalling(func(tool string) error {
+		calledTools = append(calledTools, tool)
+		return nil
+	})
+	finishedTools := []string{}
+	assistant.Callbacks.SetToolCallFinished(func(tool string, elapsed time.Duration) error {
+		finishedTools = append(finishedTools, tool)
+		return nil
+	})
+
+	response, err := assistant.Prompt("What is the weather?")
+	if err != nil {
+		t.Fatalf("Failed to prompt: %v", err)
+	}
+	if response != "It is sunny in Paris" {
+		t.Errorf("Unexpected response '%s'", response)
+	}
+	if len(calledTools) != 2 || calledTools[0] != "custom__get_city" || calledTools[1] != "custom__get_weather" {
+		t.Errorf("Unexpected tool calls %v", calledTools)
+	}
+	if len(finishedTools) != 2 || finishedTools[1] != "custom__get_weather" {
+		t.Errorf("Expected the finish of both tool calls reported, got %v", finishedTools)
+	}
+
+	stats := assistant.LastPromptStats()
+	if stats.Model != "mock:scripted" || stats.ToolCalls != 2 || stats.InputTokens != 360 || stats.OutputTokens != 36 {
+		t.Errorf("Unexpected prompt stats %+v", stats)
+	}
+
+	requests := provider.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("Expected 3 requests to the provider, got %d", len(requests))
+	}
+	if requests[0].Prompt != "What is the weather?" || len(requests[0].Tools) != 2 {
+		t.Errorf("Unexpected first request: prompt '%s', %d tools", requests[0].Prompt, len(requests[0].Tools))
+	}
+	// Follow-up requests end with the result of the previous tool call
+	for i, expected := range []string{"Paris", "Sunny in Paris"} {
+		messages := requests[i+1].Messages
+		last := messages[len(messages)-1].(*history.HistoryMessage)
+		if !last.IsToolResponse() || last.Content[0].Text != expected {
+			t.Errorf("Expected request %d to end with tool result '%s', got %+v", i+2, expected, last.Content)
+		}
+	}
+}
+
+// lastRequestMessage returns the text of the last message sent to the provider with the request
+func lastRequestMessage(request test.MockRequest) string {
+	if len(request.Messages) == 0 {
+		return ""
+	}
+	last := request.Messages[len(request.Messages)-1]
+	if last.GetRole() != "user" {
+		return ""
+	}
+	return last.GetContent()
+}
+
+func TestAllToolsFailedGuidance(t *testing.T) {
+	provider := test.NewMockProvider(
+		test.MockResponse{ToolCalls: []test.MockToolCall{
+			{ID: "call_1", Name: "custom__get_weather", Arguments: map[string]interface{}{}},
+		}},
+		test.MockResponse{Content: "I can not check the weather now"},
+	)
+
+	assistant := newTestAssistantWithProvider(t, CleverChattyConfig{
+		AllToolsFailedGuidance: true,
+	}, provider)
+
+	assistant.SetTool(CustomTool{
+		Name:        "get_weather",
+		Description: "Returns the weather",
+		Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			return "", fmt.Errorf("weather service is down")
+		},
+	})
+
+	if _, err := assistant.Prompt("What is the weather?"); err != nil {
+		t.Fatalf("Failed to prompt: %v", err)
+	}
+
+	requests := provider.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 requests to the provider, got %d", len(requests))
+	}
+	if instruction := lastRequestMessage(requests[1]); instruction != defaultAllToolsFailedMessage {
+		t.Errorf("Expected the guidance in the follow-up request, got '%s'", instruction)
+	}
+	for _, message := range assistant.messages {
+		if message.GetContent() == defaultAllToolsFailedMessage {
+			t.Errorf("Expected the guidance not to be kept in the history")
+		}
+	}
+}
+
+func TestToolErrorClassification(t *testing.T) {
+	provider := test.NewMockProvider(
+		test.MockResponse{ToolCalls: []test.MockToolCall{
+			{ID: "call_1", Name: "custom__get_weather", Arguments: map[string]interface{}{"city": "Paris"}},
+			{ID: "call_2", Name: "custom__get_weather", Arguments: map[string]interface{}{}},
+			{ID: "call_3", Name: "custom__get_forecast", Arguments: map[string]interface{}{}},
+		}},
+		test.MockResponse{Content: "I can not check the weather now"},
+	)
+
+	assistant := newTestAssistantWithProvider(t, CleverChattyConfig{}, provider)
+
+	assistant.SetTool(CustomTool{
+		Name:        "get_weather",
+		Description: "Returns the weather in a city",
+		Arguments:   []ToolArgument{{Name: "city", Type: "string", Required: true}},
+		Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			return "", fmt.Errorf("weather service is down")
+		},
+	})
+
+	if _, err := assistant.Prompt("What is the weather?"); err != nil {
+		t.Fatalf("Failed to prompt: %v", err)
+	}
+
+	requests := provider.Requests()
+	messages := requests[1].Messages
+	results := messages[len(messages)-1].(*history.HistoryMessage).Content
+	expected := []string{
+		`{"error_kind":"tool_error","retryable":false}`,
+		`{"error_kind":"tool_error","retryable":false}`,
+		`{"error_kind":"not_found","retryable":false}`,
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d tool results, got %+v", len(expected), results)
+	}
+	for i, classification := range expected {
+		lines := strings.Split(results[i].Text, "\n")
+		if len(lines) != 2 || !strings.HasPrefix(lines[0], "Error calling tool") || lines[1] != classification {
+			t.Errorf("Expected the readable error with %s, got %q", classification, results[i].Text)
+		}
+	}
+
+	// A handler that ran out of time is still classified as a timeout
+	assistant.SetTool(CustomTool{
+		Name:        "slow_tool",
+		Description: "Waits for a slow service",
+		Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			return "", context.DeadlineExceeded
+		},
+	})
+	if kind := assistant.toolsHost.callCustomTool("slow_tool", nil, context.Background()).ErrorKind(); kind != ToolErrorTimeout {
+		t.Errorf("Expected the handler timeout to be classified as a timeout, got %s", kind)
+	}
+
+	timeout := ToolCallResult{Error: &ToolTimeoutError{ToolName: "slow__tool", Timeout: time.Second}}
+	if kind := timeout.ErrorKind(); kind != ToolErrorTimeout || !kind.Retryable() {
+		t.Errorf("Expected a retryable timeout, got %s", kind)
+	}
+	if kind := (ToolCallResult{}).ErrorKind(); kind != "" {
+		t.Errorf("Expected no error kind of a successful call, got %s", kind)
+	}
+}
+
+func TestToolContext(t *testing.T) {
+	provider := test.NewMockProvider(
+		test.MockResponse{ToolCalls: []test.MockToolCall{
+			{ID: "call_1", Name: "custom__get_orders", Arguments: map[string]interface{}{"status": "open", "tenant_id": "other"}},
+		}},
+		test.MockResponse{Content: "You have no open orders"},
+	)
+
+	assistant := newTestAssistantWithProvider(t, CleverChattyConfig{}, provider)
+	assistant.WithToolContext(map[string]interface{}{"tenant_id": "acme", "locale": "de"})
+
+	var received map[string]interface{}
+	assistant.SetTool(CustomTool{
+		Name:        "get_orders",
+		Description: "Returns the orders of the customer",
+		Arguments:   []ToolArgument{{Name: "status", Type: "string", Required: true}},
+		Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			received = args
+			return "No orders", nil
+		},
+	})
+
+	if _, err := assistant.Prompt("Do I have open orders?"); err != nil {
+		t.Fatalf("Failed to prompt: %v", err)
+	}
+	// The context keys are reserved, the value of the model is replaced
+	if received["status"] != "open" || received["tenant_id"] != "acme" || received["locale"] != "de" {
+		t.Errorf("Expected the tool context in the arguments, got %v", received)
+	}
+	if schema := provider.Requests()[0].Tools[0].InputSchema; schema.Properties["tenant_id"] != nil || schema.Properties["locale"] != nil {
+		t.Errorf("Expected the tool context not shown to the model, got %+v", schema)
+	}
+	call := assistant.messages[len(assistant.messages)-3].Content[0]
+	if string(call.Input) != `{"status":"open","tenant_id":"other"}` {
+		t.Errorf("Expected the arguments of the model kept in the history, got %s", call.Input)
+	}
+
+	result, err := assistant.CallTool(context.Background(), "custom__get_orders", map[string]interface{}{"status": "closed"})
+	if err != nil || result.getTextContent() != "No orders" || received["tenant_id"] != "acme" {
+		t.Errorf("Expected the tool context in a direct call, got %v, %v", received, err)
+	}
+}
+
+func TestRememberToolTurns(t *testing.T) {
+	provider := test.NewMockProvider(
+		test.MockResponse{Content: "Let me check the weather", ToolCalls: []test.MockToolCall{
+			{ID: "call_1", Name: "custom__get_weather", Arguments: map[string]interface{}{}},
+		}},
+		test.MockResponse{Content: "It is sunny"},
+		test.MockResponse{Content: "The code is 1234"},
+	)
+
+	rememberToolTurns := false
+	assistant := newTestAssistantWithProvider(t, CleverChattyConfig{
+		RememberToolTurns: &rememberToolTurns,
+	}, provider)
+
+	memory := newTestMemoryServer(t, assistant, "")
+	assistant.SetTool(CustomTool{
+		Name:        "get_weather",
+		Description: "Returns the weather",
+		Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			return "Sunny", nil
+		},
+	})
+
+	if _, err := assistant.Prompt("What is the weather?"); err != nil {
+		t.Fatalf("Failed to prompt: %v", err)
+	}
+	_, err := assistant.PromptWithOptions(context.Background(), history.NewUserPromptMessage("Remind me the code"), PromptOptions{Ephemeral: true})
+	if err != nil {
+		t.Fatalf("Failed to prompt: %v", err)
+	}
+	assistant.toolsHost.memoryQueue.flush(time.Second)
+
+	remembered := []string{}
+	for _, args := range memory.Remembered() {
+		remembered = append(remembered, fmt.Sprintf("%v: %v", args["role"], args["contents"]))
+	}
+	expected := []string{"user: What is the weather?", "assistant: It is sunny"}
+	if strings.Join(remembered, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected only the final answer of the tool turn and no ephemeral prompt remembered, got %v", remembered)
+	}
+}
+
+func TestRememberMetadata(t *testing.T) {
+	provider := test.NewMockProvider(
+		test.MockResponse{Content: "Let me check the weather", ToolCalls: []test.MockToolCall{
+			{ID: "call_1", Name: "custom__get_weather", Arguments: map[string]interface{}{}},
+		}},
+		test.MockResponse{Content: "It is sunny"},
+	)
+	assistant := newTestAssistantWithProvider(t, CleverChattyConfig{
+		AgentID: "weather-agent",
+	}, provider)
+
+	memory := newTestMemoryServer(t, assistant, "")
+	assistant.SetTool(CustomTool{
+		Name:        "get_weather",
+		Description: "Returns the weather",
+		Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			return "Sunny", nil
+		},
+	})
+
+	if _, err := assistant.PromptCtx(WithRequestID(context.Background(), "req-1"), "What is the weather?"); err != nil {
+		t.Fatalf("Failed to prompt: %v", err)
+	}
+	assistant.toolsHost.memoryQueue.flush(time.Second)
+
+	remembered := memory.Remembered()
+	if len(remembered) != 3 {
+		t.Fatalf("Expected 3 remembered messages, got %v", remembered)
+	}
+	for _, args := range remembered {
+		if args["request_id"] != "req-1" || args["agent_id"] != "weather-agent" {
+			t.Errorf("Expected the request and agent IDs in the metadata, got %v", args)
+		}
+		if _, err := time.Parse(time.RFC3339, fmt.Sprint(args["timestamp"])); err != nil {
+			t.Errorf("Expected the timestamp in the metadata, got %v", args["timestamp"])
+		}
+	}
+	if _, ok := remembered[0]["tools"]; ok {
+		t.Errorf("Expected no tools in the metadata of the prompt, got %v", remembered[0])
+	}
+	if fmt.Sprint(remembered[1]["tools"]) != "[custom__get_weather]" {
+		t.Errorf("Expected the called tool in the metadata of the tool turn, got %v", remembered[1])
+	}
+	if remembered[2]["contents"] != "It is sunny" {
+		t.Errorf("Expected the final answer remembered, got %v", remembered[2])
+	}
+}
+
+func TestEmptyModelResponse(t *testing.T) {
+	// An empty response is retried once with a nudge
+	provider := test.NewMockProvider(test.MockResponse{}, test.MockResponse{Content: "Hello!"})
+	assistant := newTestAssistantWithProvider(t, CleverChattyConfig{}, provider)
+	response, err := assistant.Prompt("Hi")
+	if err != nil || response != "Hello!" {
+		t.Fatalf("Expected the response of the retry, got %q, %v", response, err)
+	}
+	if requests := provider.Requests(); len(requests) != 2 || lastRequestMessage(requests[1]) != emptyResponseNudge {
+		t.Errorf("Expected the retry with the nudge, got %+v", requests)
+	}
+
+	// A second empty response is an error
+	assistant = newTestAssistantWithProvider(t, CleverChattyConfig{}, test.NewMockProvider(test.MockResponse{}, test.MockResponse{}))
+	if _, err := assistant.Prompt("Hi"); !errors.Is(err, ErrEmptyResponse) {
+		t.Errorf("Expected ErrEmptyResponse, got %v", err)
+	}
+
+	// Nothing to add after the tool results is a normal end of the turn
+	provider = test.NewMockProvider(
+		test.MockResponse{Content: "Let me check", ToolCalls: []test.MockToolCall{
+			{ID: "call_1", Name: "custom__get_weather", Arguments: map[string]interface{}{}},
+		}},
+		test.MockResponse{},
+	)
+	assistant = newTestAssistantWithProvider(t, CleverChattyConfig{}, provider)
+	assistant.SetTool(CustomTool{
+		Name:        "get_weather",
+		Description: "Returns the weather",
+		Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			return "Sunny", nil
+		},
+	})
+	if _, err := assistant.Prompt("What is the weather?"); err != nil {
+		t.Fatalf("Expected no error for the empty response after the tool results, got %v", err)
+	}
+	if requests := provider.Requests(); len(requests) != 2 {
+		t.Errorf("Expected no retry after the tool results, got %d requests", len(requests))
+	}
+}
+
+func TestFinishReasons(t *testing.T) {
+	// A filtered response is reported, it is not retried as an empty one
+	provider := test.NewMockProvider(test.MockResponse{FinishReason: llm.FinishReasonContentFilter})
+	if _, err := newTestAssistantWithProvider(t, CleverChattyConfig{}, provider).Prompt("Hi"); !errors.Is(err, ErrContentFiltered) {
+		t.Errorf("Expected ErrContentFiltered, got %v", err)
+	}
+	if len(provider.Requests()) != 1 {
+		t.Errorf("Expected no retry of the filtered response, got %d requests", len(provider.Requests()))
+	}
+
+	// A truncated response is marked when it is not continued
+	provider = test.NewMockProvider(test.MockResponse{Content: "Once upon", FinishReason: llm.FinishReasonLength})
+	response, err := newTestAssistantWithProvider(t, CleverChattyConfig{}, provider).Prompt("Tell a story")
+	if err != nil || response != "Once upon"+truncatedResponseNote {
+		t.Errorf("Expected the truncated response with the note, got %q, %v", response, err)
+	}
+
+	// The model is asked to continue
+	provider = test.NewMockProvider(
+		test.MockResponse{Content: "Once upon", FinishReason: llm.FinishReasonLength},
+		test.MockResponse{Content: " a time", FinishReason: llm.FinishReasonStop},
+	)
+	response, err = newTestAssistantWithProvider(t, CleverChattyConfig{ContinueTruncatedReplies: true}, provider).Prompt("Tell a story")
+	if err != nil || response != "Once upon a time" {
+		t.Errorf("Expected the continued response, got %q, %v", response, err)
+	}
+	if requests := provider.Requests(); len(requests) != 2 || lastRequestMessage(requests[1]) != continueResponsePrompt {
+		t.Errorf("Expected the request to continue, got %+v", requests)
+	}
+}
+
+func TestReplyContinuationsCap(t *testing.T) {
+	provider := test.NewMockProvider(
+		test.MockResponse{Content: "One", FinishReason: llm.FinishReasonLength},
+		test.MockResponse{Content: " two", FinishReason: llm.FinishReasonLength},
+		test.MockResponse{Content: " three", FinishReason: llm.FinishReasonStop},
+	)
+	assistant := newTestAssistantWithProvider(t, CleverChattyConfig{
+		ContinueTruncatedReplies: true,
+		MaxReplyContinuations:    1,
+	}, provider)
+
+	response, err := assistant.Prompt("Count")
+	if err != nil || response != "One two"+truncatedResponseNote {
+		t.Errorf("Expected one continuation and the note, got %q, %v", response, err)
+	}
+	if len(provider.Requests()) != 2 {
+		t.Errorf("Expected 2 requests, got %d", len(provider.Requests()))
+	}
+	// The partial responses are kept in the history in order
+	last := assistant.messages[len(assistant.messages)-2:]
+	if last[0].GetContent() != "One" || last[1].GetContent() != "two" {
+		t.Errorf("Expected the partial responses in the history, got %+v", last)
+	}
+}
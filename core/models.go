@@ -0,0 +1,77 @@
+package core
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/gelembjuk/cleverchatty/core/llm/anthropic"
+	"github.com/gelembjuk/cleverchatty/core/llm/google"
+	"github.com/gelembjuk/cleverchatty/core/llm/ollama"
+	"github.com/gelembjuk/cleverchatty/core/llm/openai"
+)
+
+const listModelsTimeout = 15 * time.Second
+
+// ProviderModels is the list of models available from a provider.
+// Models are in the provider:model format accepted by the "model" config option.
+type ProviderModels struct {
+	Provider string
+	Models   []string
+	Err      error
+}
+
+type modelsLister struct {
+	provider string
+	list     func(ctx context.Context) ([]string, error)
+}
+
+// ListModels queries the models endpoints of the providers. Providers without
+// credentials in the config are skipped. Ollama lists the locally pulled models.
+func ListModels(ctx context.Context, config CleverChattyConfig) []ProviderModels {
+	listers := []modelsLister{}
+
+	if config.Anthropic.APIKey != "" {
+		listers = append(listers, modelsLister{"anthropic", modelsListerOf(config, "anthropic")})
+	}
+	if config.OpenAI.APIKey != "" {
+		listers = append(listers, modelsLister{"openai", modelsListerOf(config, "openai")})
+	}
+	if config.Google.APIKey != "" {
+		listers = append(listers, modelsLister{"google", modelsListerOf(config, "google")})
+	}
+	listers = append(listers, modelsLister{"ollama", modelsListerOf(config, "ollama")})
+
+	results := []ProviderModels{}
+	for _, lister := range listers {
+		ctx, cancel := context.WithTimeout(ctx, listModelsTimeout)
+		models, err := lister.list(ctx)
+		cancel()
+
+		result := ProviderModels{Provider: lister.provider, Err: err}
+		for _, model := range models {
+			result.Models = append(result.Models, lister.provider+":"+model)
+		}
+		sort.Strings(result.Models)
+		results = append(results, result)
+	}
+	return results
+}
+
+// modelsListerOf returns the function listing the models of the provider with the credentials
+// from the config, or nil if the provider has no models endpoint
+func modelsListerOf(config CleverChattyConfig, provider string) func(ctx context.Context) ([]string, error) {
+	switch provider {
+	case "anthropic":
+		return anthropic.NewClient(config.Anthropic.APIKey, config.Anthropic.BaseURL).ListModels
+	case "openai":
+		return openai.NewClient(config.OpenAI.APIKey, config.OpenAI.BaseURL).ListModels
+	case "google":
+		return func(ctx context.Context) ([]string, error) {
+			return google.ListModels(ctx, config.Google.APIKey)
+		}
+	case "ollama":
+		return ollama.ListModels
+	}
+	return nil
+}
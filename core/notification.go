@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"path"
 	"strings"
 	"sync"
 	"time"
@@ -25,6 +26,9 @@ const (
 	notificationSubAgentFeedbackToolDescription = "Send a message to the user about this notification. " +
 		"Use this tool when the user's instructions ask you to tell, report, summarize, or inform them about something. " +
 		"The user will see the message you provide. This is the ONLY way to communicate with the user."
+
+	// notificationQueueSize is the number of notifications waiting to be processed
+	notificationQueueSize = 100
 )
 
 // MonitoringStatus indicates whether a notification is being monitored for processing
@@ -56,6 +60,8 @@ const (
 // Notification represents a unified notification structure
 // independent of the underlying protocol (MCP, A2A, etc.)
 type Notification struct {
+	// ID identifies a monitored notification in the notification store
+	ID string `json:"id,omitempty"`
 	// ServerName is the name of the server that sent the notification
 	ServerName string `json:"server_name"`
 	// Method is the notification method/type (e.g., "notifications/progress", "task/started")
@@ -79,6 +85,20 @@ type NotificationCallback func(notification Notification)
 // send a message to the user
 type AgentMessageCallback func(message string)
 
+// NotificationFeedback is a message for the user produced while processing a notification
+type NotificationFeedback struct {
+	// Message is the text for the user. It can be Markdown formatted
+	Message string `json:"message"`
+	// ServerName is the name of the server that sent the processed notification
+	ServerName string `json:"server_name"`
+	// Method is the method of the processed notification
+	Method string `json:"method"`
+}
+
+// NotificationFeedbackCallback is called when processing a notification produces
+// a message for the user. Unlike AgentMessageCallback it carries the notification origin
+type NotificationFeedbackCallback func(feedback NotificationFeedback)
+
 // NewNotification creates a new Notification with default values
 func NewNotification(serverName, method string, params map[string]interface{}) Notification {
 	return Notification{
@@ -116,6 +136,13 @@ func (n *Notification) SetMonitored() {
 	n.ProcessingStatus = ProcessingStatusPending
 }
 
+// SetPending marks the notification as waiting for processing again
+func (n *Notification) SetPending() {
+	if n.MonitoringStatus == MonitoringStatusMonitored {
+		n.ProcessingStatus = ProcessingStatusPending
+	}
+}
+
 // SetProcessing marks the notification as currently being processed
 func (n *Notification) SetProcessing() {
 	if n.MonitoringStatus == MonitoringStatusMonitored {
@@ -242,6 +269,73 @@ func generateDescription(method string, params map[string]interface{}) string {
 	}
 }
 
+// MatchNotificationMethod reports whether the notification method matches the pattern.
+// A pattern starting with "*" and without other wildcards matches by suffix,
+// the same way generateDescription recognizes methods (e.g. "*/progress").
+// Other patterns are matched as globs with path.Match, or literally.
+func MatchNotificationMethod(pattern string, method string) bool {
+	if pattern == method {
+		return true
+	}
+	if strings.HasPrefix(pattern, "*") && !strings.ContainsAny(pattern[1:], "*?[") {
+		return strings.HasSuffix(method, pattern[1:])
+	}
+	matched, err := path.Match(pattern, method)
+	return err == nil && matched
+}
+
+// ParseNotificationFilter splits a comma or space separated list of notification filter patterns
+func ParseNotificationFilter(value string) []string {
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+	patterns := []string{}
+	for _, field := range fields {
+		if field = strings.TrimSpace(field); field != "" {
+			patterns = append(patterns, field)
+		}
+	}
+	return patterns
+}
+
+// NotificationFilterShows checks the method against the display filter patterns. Patterns
+// prefixed with "!" hide matching methods. If there are patterns without the prefix, only
+// methods matching one of them are shown
+func NotificationFilterShows(patterns []string, method string) bool {
+	shown := true
+	for _, pattern := range patterns {
+		if !strings.HasPrefix(pattern, "!") {
+			shown = false
+			break
+		}
+	}
+	for _, pattern := range patterns {
+		if !strings.HasPrefix(pattern, "!") && MatchNotificationMethod(pattern, method) {
+			shown = true
+			break
+		}
+	}
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "!") && MatchNotificationMethod(strings.TrimPrefix(pattern, "!"), method) {
+			return false
+		}
+	}
+	return shown
+}
+
+// IsFinalProgress returns true for a progress notification reporting the whole work done
+func (n Notification) IsFinalProgress() bool {
+	if !strings.HasSuffix(n.Method, "/progress") {
+		return false
+	}
+	progress, ok := n.Params["progress"].(float64)
+	if !ok {
+		return false
+	}
+	total, ok := n.Params["total"].(float64)
+	return ok && total > 0 && progress >= total
+}
+
 // FormatForDisplay returns a formatted string for display purposes
 func (n *Notification) FormatForDisplay() string {
 	var sb strings.Builder
@@ -271,6 +365,14 @@ type NotificationProcessor struct {
 	stopped              bool
 	mu                   sync.Mutex
 	agentMessageCallback AgentMessageCallback
+	ctx                  context.Context    // Context of the processing agent, cancelled when draining times out
+	cancel               context.CancelFunc // Cancels in-flight processing
+	drainTimeout         time.Duration      // How long Stop waits for queued notifications to be processed
+	store                *NotificationStore // Persists queued notifications across restarts. Optional
+	feedbackCallback     NotificationFeedbackCallback
+	current              Notification  // Notification being processed, used to attribute feedback
+	stopping             chan struct{} // Closed when the processor is stopped
+	slotFreed            chan struct{} // Signalled when the worker takes a notification from the queue
 }
 
 // NewNotificationProcessor creates a new notification processor
@@ -280,8 +382,13 @@ func NewNotificationProcessor(parentConfig CleverChattyConfig, ctx context.Conte
 	config := parentConfig
 	config.SystemInstruction = notificationSubAgentSystemInstructions
 
-	agent, err := GetCleverChattyWithLogger(config, ctx, logger)
+	// The agent works in its own cancellable context so that in-flight LLM and tool calls
+	// can be aborted when the processor is stopped
+	processCtx, cancel := context.WithCancel(ctx)
+
+	agent, err := GetCleverChattyWithLogger(config, processCtx, logger)
 	if err != nil {
+		cancel()
 		return nil, fmt.Errorf("failed to create notification processor agent: %w", err)
 	}
 
@@ -290,14 +397,25 @@ func NewNotificationProcessor(parentConfig CleverChattyConfig, ctx context.Conte
 
 	// Initialize the agent
 	if err := agent.Init(); err != nil {
+		cancel()
 		return nil, fmt.Errorf("failed to initialize notification processor agent: %w", err)
 	}
 
+	drainTimeout := time.Duration(parentConfig.NotificationDrainTimeout) * time.Second
+	if drainTimeout <= 0 {
+		drainTimeout = defaultNotificationDrainTimeout * time.Second
+	}
+
 	processor := &NotificationProcessor{
 		agent:                agent,
-		queue:                make(chan notificationWithInstructions, 100), // Buffer up to 100 notifications
+		queue:                make(chan notificationWithInstructions, notificationQueueSize),
 		logger:               logger,
 		agentMessageCallback: agentMessageCallback,
+		ctx:                  processCtx,
+		cancel:               cancel,
+		drainTimeout:         drainTimeout,
+		stopping:             make(chan struct{}),
+		slotFreed:            make(chan struct{}, 1),
 	}
 
 	// Register the feedback tool
@@ -321,11 +439,25 @@ func NewNotificationProcessor(parentConfig CleverChattyConfig, ctx context.Conte
 				processor.agentMessageCallback(message)
 			}
 
+			processor.mu.Lock()
+			current := processor.current
+			feedbackCallback := processor.feedbackCallback
+			processor.mu.Unlock()
+
+			if feedbackCallback != nil {
+				feedbackCallback(NotificationFeedback{
+					Message:    message,
+					ServerName: current.ServerName,
+					Method:     current.Method,
+				})
+			}
+
 			return "Message delivered to user", nil
 		},
 	})
 	if err != nil {
 		agent.Finish()
+		cancel()
 		return nil, fmt.Errorf("failed to register feedback tool: %w", err)
 	}
 
@@ -339,53 +471,203 @@ func (p *NotificationProcessor) Start() {
 		defer p.wg.Done()
 		p.logger.Printf("Notification processor started")
 
+		dropped := 0
 		for item := range p.queue {
+			select {
+			case p.slotFreed <- struct{}{}:
+			default:
+			}
+			if p.ctx.Err() != nil {
+				// Draining timed out, skip the rest of the queue.
+				// Stored notifications stay in the store and are replayed by the next processor
+				p.releaseInStore(item.notification)
+				dropped++
+				continue
+			}
 			p.process(item)
 		}
 
+		if dropped > 0 {
+			p.logger.Printf("Notification processor dropped %d queued notifications on shutdown", dropped)
+		}
 		p.logger.Printf("Notification processor stopped")
 	}()
+
+	p.replayStored()
 }
 
-// Stop gracefully shuts down the processor
+// SetFeedbackCallback sets the callback receiving user-facing feedback with the notification origin
+func (p *NotificationProcessor) SetFeedbackCallback(callback NotificationFeedbackCallback) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.feedbackCallback = callback
+}
+
+// SetStore sets the store used to persist queued notifications. Must be called before Start
+func (p *NotificationProcessor) SetStore(store *NotificationStore) {
+	p.store = store
+}
+
+// replayStored enqueues notifications left unprocessed before the last shutdown or by
+// a stopped processor sharing the store. There can be more of them than the queue holds,
+// so they are enqueued in the background as the queue frees up. Replayed notifications are
+// never removed from the store here, the ones not enqueued before a stop are released
+// and replayed by the next processor
+func (p *NotificationProcessor) replayStored() {
+	if p.store == nil {
+		return
+	}
+	items, err := p.store.Recover()
+	if err != nil {
+		p.logger.Printf("Failed to recover stored notifications: %v", err)
+		return
+	}
+	if len(items) == 0 {
+		return
+	}
+	p.logger.Printf("Replaying %d stored notifications", len(items))
+	// Waited by the stop, so the notifications left are released when it returns
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		for i, item := range items {
+			if !p.enqueueStored(item) {
+				p.logger.Printf("Notification processor stopped, %d stored notifications are left for the next start", len(items)-i)
+				for _, left := range items[i:] {
+					p.releaseInStore(left.notification)
+				}
+				return
+			}
+		}
+	}()
+}
+
+// enqueueStored waits until the stored notification is queued. It returns false if the processor is stopped
+func (p *NotificationProcessor) enqueueStored(item notificationWithInstructions) bool {
+	for {
+		queued, stopped := p.offer(item)
+		if queued {
+			return true
+		}
+		if stopped {
+			return false
+		}
+		select {
+		case <-p.slotFreed:
+		case <-p.stopping:
+			return false
+		}
+	}
+}
+
+// offer adds the notification to the queue if there is room and the processor is not stopped.
+// The lock makes sure the queue is not closed while sending to it
+func (p *NotificationProcessor) offer(item notificationWithInstructions) (queued bool, stopped bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return false, true
+	}
+	select {
+	case p.queue <- item:
+		return true, false
+	default:
+		return false, false
+	}
+}
+
+// saveToStore persists the notification state if the store is configured
+func (p *NotificationProcessor) saveToStore(notification Notification, instructions []string) {
+	if p.store == nil {
+		return
+	}
+	if err := p.store.Save(notification, instructions); err != nil {
+		p.logger.Printf("Failed to persist notification %s: %v", notification.ID, err)
+	}
+}
+
+// releaseInStore gives up the notification left in the store, so the next processor replays it
+func (p *NotificationProcessor) releaseInStore(notification Notification) {
+	if p.store != nil {
+		p.store.Release(notification.ID)
+	}
+}
+
+// removeFromStore deletes the notification from the store if the store is configured
+func (p *NotificationProcessor) removeFromStore(notification Notification) {
+	if p.store == nil {
+		return
+	}
+	if err := p.store.Remove(notification.ID); err != nil {
+		p.logger.Printf("Failed to remove stored notification %s: %v", notification.ID, err)
+	}
+}
+
+// Stop gracefully shuts down the processor using the configured drain timeout
 func (p *NotificationProcessor) Stop() {
+	p.StopWithTimeout(p.drainTimeout)
+}
+
+// StopWithTimeout shuts down the processor. Queued notifications are processed
+// until the timeout expires. After that the processing context is cancelled,
+// the in-flight LLM call is aborted and the remaining notifications are dropped.
+func (p *NotificationProcessor) StopWithTimeout(timeout time.Duration) {
 	p.mu.Lock()
 	if p.stopped {
 		p.mu.Unlock()
 		return
 	}
 	p.stopped = true
+	close(p.stopping)
+	close(p.queue)
 	p.mu.Unlock()
 
-	p.logger.Printf("Stopping notification processor, closing queue...")
-	close(p.queue)
-	p.wg.Wait()
+	p.logger.Printf("Stopping notification processor, closed queue (%d queued)...", len(p.queue))
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		p.logger.Printf("Notification processor did not drain in %v, cancelling in-flight processing", timeout)
+		p.cancel()
+		<-done
+	}
 
 	p.logger.Printf("Finishing notification processor agent...")
 	if err := p.agent.Finish(); err != nil {
 		p.logger.Printf("Error finishing notification processor agent: %v", err)
 	}
+	p.cancel()
 }
 
 // Enqueue adds a notification to the processing queue
 // Returns false if the processor is stopped or queue is full
 func (p *NotificationProcessor) Enqueue(notification Notification, instructions []string) bool {
-	p.mu.Lock()
-	if p.stopped {
-		p.mu.Unlock()
-		p.logger.Printf("Notification processor is stopped, dropping notification: %s", notification.Method)
-		return false
+	if notification.ID == "" {
+		notification.ID = newNotificationID()
 	}
-	p.mu.Unlock()
+	notification.SetMonitored()
 
-	select {
-	case p.queue <- notificationWithInstructions{notification: notification, instructions: instructions}:
-		p.logger.Printf("Notification enqueued: server=%s, method=%s", notification.ServerName, notification.Method)
-		return true
-	default:
-		p.logger.Printf("Notification queue full, dropping notification: %s", notification.Method)
+	// Saved before it is queued, so the worker can not remove it before it is saved
+	p.saveToStore(notification, instructions)
+
+	queued, stopped := p.offer(notificationWithInstructions{notification: notification, instructions: instructions})
+	if !queued {
+		if stopped {
+			p.logger.Printf("Notification processor is stopped, dropping notification: %s", notification.Method)
+		} else {
+			p.logger.Printf("Notification queue full, dropping notification: %s", notification.Method)
+		}
+		p.removeFromStore(notification)
 		return false
 	}
+	p.logger.Printf("Notification enqueued: server=%s, method=%s", notification.ServerName, notification.Method)
+	return true
 }
 
 // QueueLength returns the current number of notifications waiting in the queue
@@ -400,10 +682,18 @@ func (p *NotificationProcessor) process(item notificationWithInstructions) {
 
 	p.logger.Printf("Processing notification: server=%s, method=%s", notification.ServerName, notification.Method)
 
+	notification.SetProcessing()
+	p.saveToStore(notification, instructions)
+
+	p.mu.Lock()
+	p.current = notification
+	p.mu.Unlock()
+
 	// Serialize notification to JSON for the prompt
 	notificationJSON, err := json.Marshal(notification)
 	if err != nil {
 		p.logger.Printf("Error serializing notification to JSON: %v", err)
+		p.removeFromStore(notification)
 		return
 	}
 
@@ -411,11 +701,21 @@ func (p *NotificationProcessor) process(item notificationWithInstructions) {
 	instructionsText := strings.Join(instructions, "\n")
 	prompt := fmt.Sprintf("Instructions from the user:\n%s\n\nNotification content:\n%s", instructionsText, string(notificationJSON))
 
-	// Prompt the agent
+	// Prompt the agent. The agent runs in the processor context, so the call
+	// is aborted when the processor is stopped with a timeout
 	_, err = p.agent.Prompt(prompt)
 	if err != nil {
+		if p.ctx.Err() != nil {
+			// Processing was aborted by shutdown, keep it stored to be retried by the next processor
+			notification.SetPending()
+			p.saveToStore(notification, instructions)
+			p.releaseInStore(notification)
+		} else {
+			p.removeFromStore(notification)
+		}
 		p.logger.Printf("Error processing notification: %v", err)
 		return
 	}
+	p.removeFromStore(notification)
 
 }
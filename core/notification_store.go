@@ -0,0 +1,162 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const notificationStoreDirName = "notifications"
+
+// notificationRecord is a monitored notification persisted on disk until it is processed
+type notificationRecord struct {
+	Notification Notification `json:"notification"`
+	Instructions []string     `json:"instructions"`
+}
+
+// NotificationStore persists monitored notifications so that unprocessed ones
+// survive a restart. Each notification is stored in its own file named by the notification ID.
+// A stored notification is claimed by the processor that saved or recovered it, until it is
+// removed or released, so processors sharing the store do not process it twice
+type NotificationStore struct {
+	dir     string
+	logger  *log.Logger
+	mu      sync.Mutex
+	claimed map[string]bool // By notification ID
+}
+
+func NewNotificationStore(workDir string, logger *log.Logger) *NotificationStore {
+	if workDir == "" {
+		workDir = "."
+	}
+	return &NotificationStore{
+		dir:     filepath.Join(workDir, notificationStoreDirName),
+		logger:  logger,
+		claimed: map[string]bool{},
+	}
+}
+
+// newNotificationID generates an ID that sorts in the order notifications were received
+func newNotificationID() string {
+	return fmt.Sprintf("%d-%s", time.Now().UnixNano(), generateRandomString(8))
+}
+
+func (s *NotificationStore) recordPath(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// Save writes the notification with its current processing status and claims it
+func (s *NotificationStore) Save(notification Notification, instructions []string) error {
+	if notification.ID == "" {
+		return fmt.Errorf("notification has no ID")
+	}
+	data, err := json.Marshal(notificationRecord{
+		Notification: notification,
+		Instructions: instructions,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create notifications dir: %w", err)
+	}
+	// Write to a temp file first so a crash never leaves a partially written record
+	tmpPath := s.recordPath(notification.ID) + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write notification: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.recordPath(notification.ID)); err != nil {
+		return fmt.Errorf("failed to write notification: %w", err)
+	}
+	s.claimed[notification.ID] = true
+	return nil
+}
+
+// Release gives up the claim of the notification left stored, so it is recovered again
+// by the next processor, for example one of a session created later
+func (s *NotificationStore) Release(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.claimed, id)
+}
+
+// Remove deletes the stored notification
+func (s *NotificationStore) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.claimed, id)
+	if err := os.Remove(s.recordPath(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove notification %s: %w", id, err)
+	}
+	return nil
+}
+
+// Recover returns the stored notifications that are not processed and not claimed, in the
+// order they were received, and claims them. These are the ones left before the last shutdown
+// or released by a stopped processor. Notifications left in the "processing" state were
+// interrupted by a crash, they are marked as failed and returned for a retry as well.
+func (s *NotificationStore) Recover() ([]notificationWithInstructions, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read notifications dir: %w", err)
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	items := []notificationWithInstructions{}
+	for _, name := range names {
+		if s.claimed[strings.TrimSuffix(name, ".json")] {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, name))
+		if err != nil {
+			s.logger.Printf("Failed to read stored notification %s: %v", name, err)
+			continue
+		}
+		var record notificationRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			s.logger.Printf("Failed to parse stored notification %s: %v", name, err)
+			continue
+		}
+
+		switch record.Notification.ProcessingStatus {
+		case ProcessingStatusProcessing:
+			s.logger.Printf("Notification %s was interrupted while processing, marking as failed for retry", record.Notification.ID)
+			record.Notification.SetFailed()
+		case ProcessingStatusPending:
+		default:
+			continue
+		}
+
+		s.claimed[record.Notification.ID] = true
+		items = append(items, notificationWithInstructions{
+			notification: record.Notification,
+			instructions: record.Instructions,
+		})
+	}
+	return items, nil
+}
@@ -0,0 +1,176 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func storedNotificationsCount(t *testing.T, workDir string) int {
+	entries, err := os.ReadDir(filepath.Join(workDir, notificationStoreDirName))
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("Failed to read the store: %v", err)
+	}
+	return len(entries)
+}
+
+func TestNotificationStoreRecover(t *testing.T) {
+	workDir := t.TempDir()
+	store := NewNotificationStore(workDir, log.New(io.Discard, "", 0))
+
+	for i, status := range []ProcessingStatus{ProcessingStatusPending, ProcessingStatusProcessing, ProcessingStatusProcessed} {
+		notification := Notification{ID: fmt.Sprintf("%d-test", i), Method: string(status)}
+		notification.SetMonitored()
+		notification.ProcessingStatus = status
+		if err := store.Save(notification, []string{"Tell me"}); err != nil {
+			t.Fatalf("Failed to save notification: %v", err)
+		}
+	}
+
+	items, err := NewNotificationStore(workDir, log.New(io.Discard, "", 0)).Recover()
+	if err != nil {
+		t.Fatalf("Failed to recover notifications: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("Expected the pending and the interrupted notifications, got %d", len(items))
+	}
+	if items[0].notification.ID != "0-test" || items[0].notification.ProcessingStatus != ProcessingStatusPending {
+		t.Errorf("Expected the pending notification first, got %+v", items[0].notification)
+	}
+	if items[1].notification.ID != "1-test" || items[1].notification.ProcessingStatus != ProcessingStatusFailed {
+		t.Errorf("Expected the interrupted notification marked as failed, got %+v", items[1].notification)
+	}
+	if len(items[0].instructions) != 1 || items[0].instructions[0] != "Tell me" {
+		t.Errorf("Expected the instructions to be recovered, got %v", items[0].instructions)
+	}
+
+	if err := store.Remove("0-test"); err != nil {
+		t.Fatalf("Failed to remove notification: %v", err)
+	}
+	if err := store.Remove("missing"); err != nil {
+		t.Errorf("Expected no error removing a missing notification, got %v", err)
+	}
+	if count := storedNotificationsCount(t, workDir); count != 2 {
+		t.Errorf("Expected 2 stored notifications after the removal, got %d", count)
+	}
+
+	// The saved notifications are claimed by the store that saved them
+	if items, _ := store.Recover(); len(items) != 0 {
+		t.Errorf("Expected the claimed notifications not to be recovered, got %d", len(items))
+	}
+	store.Release("1-test")
+	if items, _ := store.Recover(); len(items) != 1 || items[0].notification.ID != "1-test" {
+		t.Errorf("Expected the released notification to be recovered, got %d", len(items))
+	}
+	if items, _ := store.Recover(); len(items) != 0 {
+		t.Errorf("Expected the recovered notification not to be recovered again, got %d", len(items))
+	}
+}
+
+func TestNotificationReplayKeepsStoredNotifications(t *testing.T) {
+	workDir := t.TempDir()
+	logger := log.New(io.Discard, "", 0)
+	config := CleverChattyConfig{
+		Model:        "mock:mock",
+		ToolsServers: map[string]ServerConfigWrapper{},
+	}
+
+	// More notifications than the queue holds
+	total := notificationQueueSize + 20
+	store := NewNotificationStore(workDir, logger)
+	for i := 0; i < total; i++ {
+		notification := Notification{ID: fmt.Sprintf("%05d-test", i), Method: "test/event"}
+		notification.SetMonitored()
+		if err := store.Save(notification, nil); err != nil {
+			t.Fatalf("Failed to save notification: %v", err)
+		}
+	}
+
+	// Nothing is processed, the notifications that do not fit in the queue must stay stored
+	processor, err := NewNotificationProcessor(config, context.Background(), logger, "", nil)
+	if err != nil {
+		t.Fatalf("Failed to create the processor: %v", err)
+	}
+	processor.SetStore(NewNotificationStore(workDir, logger))
+	processor.replayStored()
+	deadline := time.Now().Add(5 * time.Second)
+	for processor.QueueLength() < notificationQueueSize && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	processor.StopWithTimeout(time.Second)
+	if count := storedNotificationsCount(t, workDir); count != total {
+		t.Fatalf("Expected all %d notifications to stay stored, got %d", total, count)
+	}
+
+	// On the next start all of them are replayed and processed
+	processor, err = NewNotificationProcessor(config, context.Background(), logger, "", nil)
+	if err != nil {
+		t.Fatalf("Failed to create the processor: %v", err)
+	}
+	processor.SetStore(NewNotificationStore(workDir, logger))
+	processor.Start()
+	deadline = time.Now().Add(10 * time.Second)
+	for storedNotificationsCount(t, workDir) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	processor.Stop()
+	if count := storedNotificationsCount(t, workDir); count != 0 {
+		t.Errorf("Expected all replayed notifications to be processed, %d left", count)
+	}
+}
+
+func TestNotificationsReplayedByNextProcessor(t *testing.T) {
+	workDir := t.TempDir()
+	logger := log.New(io.Discard, "", 0)
+	config := CleverChattyConfig{
+		Model:        "mock:mock",
+		ToolsServers: map[string]ServerConfigWrapper{},
+	}
+
+	// Left by the previous run
+	total := 5
+	previous := NewNotificationStore(workDir, logger)
+	for i := 0; i < total; i++ {
+		notification := Notification{ID: fmt.Sprintf("%05d-test", i), Method: "test/event"}
+		notification.SetMonitored()
+		if err := previous.Save(notification, nil); err != nil {
+			t.Fatalf("Failed to save notification: %v", err)
+		}
+	}
+
+	// The store is shared by the processors of sessions. The first session finishes
+	// before its processor can process the replayed notifications
+	store := NewNotificationStore(workDir, logger)
+	processor, err := NewNotificationProcessor(config, context.Background(), logger, "", nil)
+	if err != nil {
+		t.Fatalf("Failed to create the processor: %v", err)
+	}
+	processor.SetStore(store)
+	processor.cancel()
+	processor.Start()
+	processor.StopWithTimeout(time.Second)
+	if count := storedNotificationsCount(t, workDir); count != total {
+		t.Fatalf("Expected all %d notifications to stay stored, got %d", total, count)
+	}
+
+	// The processor of the session created next replays them
+	processor, err = NewNotificationProcessor(config, context.Background(), logger, "", nil)
+	if err != nil {
+		t.Fatalf("Failed to create the processor: %v", err)
+	}
+	processor.SetStore(store)
+	processor.Start()
+	deadline := time.Now().Add(10 * time.Second)
+	for storedNotificationsCount(t, workDir) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	processor.Stop()
+	if count := storedNotificationsCount(t, workDir); count != 0 {
+		t.Errorf("Expected the notifications to be replayed by the next processor, %d left", count)
+	}
+}
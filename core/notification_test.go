@@ -0,0 +1,74 @@
+package core
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/gelembjuk/cleverchatty/core/llm"
+)
+
+func TestNotificationProcessorStopCancelsInFlightPrompt(t *testing.T) {
+	workDir := t.TempDir()
+	logger := log.New(io.Discard, "", 0)
+	config := CleverChattyConfig{
+		Model:        "mock:mock",
+		ToolsServers: map[string]ServerConfigWrapper{},
+	}
+
+	processor, err := NewNotificationProcessor(config, context.Background(), logger, "", nil)
+	if err != nil {
+		t.Fatalf("Failed to create the processor: %v", err)
+	}
+	processor.SetStore(NewNotificationStore(workDir, logger))
+
+	// The LLM call does not finish until it is cancelled
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	processor.agent.provider = llm.WrapProvider(processor.agent.provider, func(next llm.CreateMessageFunc) llm.CreateMessageFunc {
+		return func(ctx context.Context, prompt string, messages []llm.Message, tools []llm.Tool) (llm.Message, error) {
+			close(started)
+			<-ctx.Done()
+			close(cancelled)
+			return nil, ctx.Err()
+		}
+	})
+	processor.Start()
+
+	if !processor.Enqueue(NewNotification("server", "test/event", nil), nil) {
+		t.Fatalf("Expected the notification to be enqueued")
+	}
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the notification to be processed")
+	}
+
+	stopStarted := time.Now()
+	stopped := make(chan struct{})
+	go func() {
+		processor.StopWithTimeout(100 * time.Millisecond)
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected Stop to return after the drain timeout")
+	}
+	if elapsed := time.Since(stopStarted); elapsed < 100*time.Millisecond {
+		t.Errorf("Expected Stop to wait for the drain timeout, returned after %v", elapsed)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Errorf("Expected the in-flight LLM call to be cancelled")
+	}
+
+	// The aborted notification is kept to be retried by the next processor
+	if count := storedNotificationsCount(t, workDir); count != 1 {
+		t.Errorf("Expected the aborted notification to stay stored, got %d", count)
+	}
+}
@@ -0,0 +1,45 @@
+package core
+
+// Keys of the A2A message metadata that set the PromptOptions of the message.
+// The values are booleans:
+//
+//	"metadata": {"skip_memory": true, "skip_rag": true}
+const (
+	MetadataSkipMemory = "skip_memory"
+	MetadataSkipRAG    = "skip_rag"
+	MetadataEphemeral  = "ephemeral"
+)
+
+// PromptOptions change how a single prompt is processed
+type PromptOptions struct {
+	// SkipMemory disables recalling memories for the prompt. The prompt is still remembered
+	SkipMemory bool
+	// SkipRAG disables the RAG context for the prompt
+	SkipRAG bool
+	// Ephemeral disables remembering the prompt and the responses to it in the memory server
+	Ephemeral bool
+}
+
+// Metadata returns the message metadata carrying the options. Only the enabled options are included
+func (o PromptOptions) Metadata() map[string]interface{} {
+	metadata := map[string]interface{}{}
+	if o.SkipMemory {
+		metadata[MetadataSkipMemory] = true
+	}
+	if o.SkipRAG {
+		metadata[MetadataSkipRAG] = true
+	}
+	if o.Ephemeral {
+		metadata[MetadataEphemeral] = true
+	}
+	return metadata
+}
+
+// PromptOptionsFromMetadata reads the options from the message metadata
+func PromptOptionsFromMetadata(metadata map[string]interface{}) PromptOptions {
+	options := PromptOptions{}
+	options.SkipMemory, _ = metadata[MetadataSkipMemory].(bool)
+	options.SkipRAG, _ = metadata[MetadataSkipRAG].(bool)
+	options.Ephemeral, _ = metadata[MetadataEphemeral].(bool)
+	return options
+}
@@ -0,0 +1,44 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PromptPreprocessor transforms a user's prompt before it is sent to the LLM.
+// It can expand macros, apply templates etc. Returning an error aborts the prompt.
+type PromptPreprocessor func(ctx context.Context, prompt string) (string, error)
+
+// DateTimePreprocessor replaces the {{date}} and {{time}} placeholders in a prompt
+// with the current local date (2006-01-02) and time (15:04)
+func DateTimePreprocessor(ctx context.Context, prompt string) (string, error) {
+	if !strings.Contains(prompt, "{{") {
+		return prompt, nil
+	}
+	now := time.Now()
+
+	return strings.NewReplacer(
+		"{{date}}", now.Format("2006-01-02"),
+		"{{time}}", now.Format("15:04"),
+	).Replace(prompt), nil
+}
+
+// WithPromptPreprocessor adds preprocessors applied to every user's prompt.
+// Preprocessors run in the order they are added, each gets the result of the previous one.
+func (assistant *CleverChatty) WithPromptPreprocessor(preprocessors ...PromptPreprocessor) {
+	assistant.promptPreprocessors = append(assistant.promptPreprocessors, preprocessors...)
+}
+
+// preprocessPrompt runs the prompt through the registered preprocessors
+func (assistant *CleverChatty) preprocessPrompt(ctx context.Context, prompt string) (string, error) {
+	for i, preprocessor := range assistant.promptPreprocessors {
+		var err error
+		prompt, err = preprocessor(ctx, prompt)
+		if err != nil {
+			return "", fmt.Errorf("prompt preprocessor %d failed: %w", i+1, err)
+		}
+	}
+	return prompt, nil
+}
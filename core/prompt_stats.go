@@ -0,0 +1,15 @@
+package core
+
+// PromptStats describes the processing of one prompt
+type PromptStats struct {
+	Model        string // provider:model
+	ToolCalls    int    // Tool calls requested by the model, including failed ones
+	InputTokens  int    // Summed over all requests to the LLM, 0 if the provider does not report usage
+	OutputTokens int
+}
+
+// LastPromptStats returns the counters of the prompt being processed, or of the last processed one.
+// They are reset when a new prompt starts.
+func (assistant *CleverChatty) LastPromptStats() PromptStats {
+	return assistant.promptStats
+}
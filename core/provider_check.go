@@ -0,0 +1,39 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gelembjuk/cleverchatty/core/llm/ollama"
+)
+
+// checkProviderConfig verifies that the selected provider can be used before it is created,
+// so the first run fails with a clear message instead of an error of the provider library.
+// Hosted providers need the API key, the Ollama server must be reachable.
+func (assistant *CleverChatty) checkProviderConfig(ctx context.Context, provider string) error {
+	switch provider {
+	case "anthropic":
+		if assistant.config.Anthropic.APIKey == "" {
+			return missingAPIKeyError("ANTHROPIC_API_KEY", "--anthropic-api-key", "anthropic")
+		}
+	case "openai":
+		if assistant.config.OpenAI.APIKey == "" {
+			return missingAPIKeyError("OPENAI_API_KEY", "--openai-api-key", "openai")
+		}
+	case "google":
+		if assistant.config.Google.APIKey == "" {
+			return missingAPIKeyError("GOOGLE_API_KEY (or GEMINI_API_KEY)", "--google-api-key", "google")
+		}
+	case "ollama":
+		if err := ollama.CheckServer(ctx); err != nil {
+			return fmt.Errorf("%w: %v. Start it with \"ollama serve\" or set OLLAMA_HOST to the address of the server",
+				ErrProviderNotConfigured, err)
+		}
+	}
+	return nil
+}
+
+func missingAPIKeyError(envVariable string, flag string, configSection string) error {
+	return fmt.Errorf("%w: %s not set. Set the environment variable, use the %s flag or set \"apikey\" in the \"%s\" section of the config",
+		ErrProviderNotConfigured, envVariable, flag, configSection)
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	providerHealthTimeout    = 10 * time.Second
+	defaultProviderHealthTTL = 30 * time.Second
+)
+
+// ProviderHealth is the result of the reachability check of the LLM provider
+type ProviderHealth struct {
+	Model     string    `json:"model"`
+	Reachable bool      `json:"reachable"`
+	LatencyMs int64     `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// CheckProviderHealth checks that the provider of the configured model can be reached and
+// accepts the credentials. The models endpoint of the provider is requested, so the check
+// costs no tokens. A wrong API key or a network problem is found before the first prompt
+func CheckProviderHealth(ctx context.Context, config CleverChattyConfig) ProviderHealth {
+	health := ProviderHealth{Model: config.Model, CheckedAt: time.Now()}
+
+	provider, _, found := strings.Cut(config.Model, ":")
+	if !found {
+		health.Error = fmt.Sprintf("invalid model format. Expected provider:model, got %s", config.Model)
+		return health
+	}
+	assistant := &CleverChatty{config: config}
+	if err := assistant.checkProviderConfig(ctx, provider); err != nil {
+		health.Error = err.Error()
+		return health
+	}
+
+	list := modelsListerOf(config, provider)
+	if list == nil {
+		if provider != "mock" {
+			health.Error = fmt.Sprintf("unsupported provider: %s", provider)
+			return health
+		}
+		// The mock provider works without a network
+		health.Reachable = true
+		return health
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, providerHealthTimeout)
+	defer cancel()
+
+	started := time.Now()
+	_, err := list(ctx)
+	health.LatencyMs = time.Since(started).Milliseconds()
+	if err != nil {
+		health.Error = err.Error()
+		return health
+	}
+	health.Reachable = true
+	return health
+}
@@ -0,0 +1,45 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+type requestIDKey struct{}
+
+// WithRequestID returns the context carrying the ID of a prompt request. The ID is included
+// in the log lines of the request, so the logs of concurrent requests can be told apart.
+// Prompts without an ID in the context get a generated one.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID from the context or an empty string
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// newRequestID generates a short random request ID
+func newRequestID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// logPrefix returns the prefix of the log lines of the request in the context
+func logPrefix(ctx context.Context) string {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		return "[" + requestID + "] "
+	}
+	return ""
+}
+
+// RequestID returns the ID of the prompt being processed, or of the last processed one.
+// Callbacks are called during the processing, so UIs can use it to display the ID.
+func (assistant *CleverChatty) RequestID() string {
+	return assistant.requestID
+}
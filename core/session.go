@@ -2,9 +2,12 @@ package core
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"log"
+	"maps"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -12,6 +15,8 @@ type Session struct {
 	ID        string
 	CreatedAt int64
 	AI        *CleverChatty
+	greeting  string // Produced by the greeting prompt, until it is delivered to the client
+	lastUsed  atomic.Int64 // Unix nanoseconds, updated under the read lock
 }
 
 type SessionManager struct {
@@ -23,14 +28,30 @@ type SessionManager struct {
 	reverseMCPClient     ReverseMCPClient
 	notificationCallback NotificationCallback
 	agentMessageCallback AgentMessageCallback
+	notificationStore    *NotificationStore
+	feedbackCallback     NotificationFeedbackCallback
+	providerHealth       *ProviderHealth // The last provider check, reused for the TTL
+	providerHealthMux    sync.Mutex
+	toolsServers         map[string]ServerConfigWrapper // Replaces the tools servers of the config when applied
+	toolsServersVersion  uint64 // Increased by every applied tools servers config
+	toolsServersMux      sync.RWMutex
+	toolsReport          *ToolsReport // Of a temporary assistant, reused for toolsReportTTL
+	toolsReportAt        time.Time
+	toolsReportVersion   uint64 // Of the tools servers config the report was made with
+	toolsReportMux       sync.Mutex
 }
 
+// toolsReportTTL is the time the tools report made without sessions is reused. A temporary
+// assistant connects to every tools server, so it is not done on every request
+const toolsReportTTL = 30 * time.Second
+
 func NewSessionManager(config *CleverChattyConfig, ctx context.Context, logger *log.Logger) *SessionManager {
 	return &SessionManager{
-		sessions: make(map[string]*Session),
-		config:   config,
-		context:  ctx,
-		logger:   logger,
+		sessions:          make(map[string]*Session),
+		config:            config,
+		context:           ctx,
+		logger:            logger,
+		notificationStore: NewNotificationStore(config.WorkDir, logger),
 	}
 }
 
@@ -50,35 +71,77 @@ func (sm *SessionManager) SetAgentMessageCallback(callback AgentMessageCallback)
 	sm.agentMessageCallback = callback
 }
 
+// SetNotificationFeedbackCallback sets the callback for user-facing feedback produced
+// while processing notifications
+func (sm *SessionManager) SetNotificationFeedbackCallback(callback NotificationFeedbackCallback) {
+	sm.feedbackCallback = callback
+}
+
 // GetSession retrieves a session by ID. Returns nil if not found.
 func (sm *SessionManager) GetSession(id string) (*Session, error) {
 	sm.mutex.RLock()
 	defer sm.mutex.RUnlock()
 	session, ok := sm.sessions[id]
 	if !ok {
-		return nil, errors.New("session not found")
+		return nil, ErrSessionNotFound
 	}
+	session.touch()
 	return session, nil
 }
 
 // GetOrCreateSession retrieves an existing session or creates a new one if it doesn't exist.
 func (sm *SessionManager) GetOrCreateSession(id string, clientAgentID string) (*Session, error) {
+	return sm.GetOrCreateSessionWithInstruction(id, clientAgentID, "")
+}
+
+// GetOrCreateSessionWithInstruction is like GetOrCreateSession, a new session uses the given
+// system instruction instead of the configured one. The instruction is ignored if the
+// session already exists or it is empty.
+func (sm *SessionManager) GetOrCreateSessionWithInstruction(id string, clientAgentID string, systemInstruction string) (*Session, error) {
+	return sm.GetOrCreateSessionWithOptions(id, clientAgentID, SessionOptions{SystemInstruction: systemInstruction})
+}
+
+// SessionOptions are the settings of a client for a new session
+type SessionOptions struct {
+	SystemInstruction string                 // Replaces the configured instruction if not empty
+	ToolContext       map[string]interface{} // Added to the configured tool context, the configured keys are kept
+}
+
+// GetOrCreateSessionWithOptions is like GetOrCreateSession, a new session uses the given options.
+// The options are ignored if the session already exists.
+func (sm *SessionManager) GetOrCreateSessionWithOptions(id string, clientAgentID string, options SessionOptions) (*Session, error) {
 	sm.mutex.RLock()
 	sm.logger.Printf("GetOrCreateSession called for ID: %s. There are %d active sessions", id, len(sm.sessions))
 	session, ok := sm.sessions[id]
+	if ok {
+		session.touch()
+	}
+	sessionsCount := len(sm.sessions)
 	sm.mutex.RUnlock()
 
 	if ok {
 		return session, nil
 	}
 
-	ai, err := GetCleverChattyWithLogger(*sm.config, sm.context, sm.logger)
+	// Check the limit before the expensive initialization. It is checked again when the session is added
+	if sm.isSessionsLimitReached(sessionsCount) && !sm.config.ServerConfig.EvictLRUSessions {
+		sm.logger.Printf("Sessions limit reached (%d). Session %s rejected", sm.config.ServerConfig.MaxSessions, id)
+		return nil, fmt.Errorf("%w: limit is %d", ErrTooManySessions, sm.config.ServerConfig.MaxSessions)
+	}
+
+	config, toolsServersVersion := sm.versionedSessionConfig()
+	ai, err := GetCleverChattyWithLogger(config, sm.context, sm.logger)
 	if err != nil {
 		return nil, err
 	}
 
 	ai.WithClientAgentID(clientAgentID)
 
+	if options.SystemInstruction != "" {
+		ai.WithSystemInstruction(options.SystemInstruction)
+	}
+	ai.WithToolContext(mergeToolContext(sm.config.ToolContext, options.ToolContext))
+
 	err = ai.Init()
 	if err != nil {
 		return nil, err
@@ -94,8 +157,16 @@ func (sm *SessionManager) GetOrCreateSession(id string, clientAgentID string) (*
 		ai.SetAgentMessageCallback(sm.agentMessageCallback)
 	}
 
+	// Set notification feedback callback if available
+	if sm.feedbackCallback != nil {
+		ai.SetNotificationFeedbackCallback(sm.feedbackCallback)
+	}
+
 	// Set notification callback if available
 	if sm.notificationCallback != nil {
+		// Monitored notifications are persisted, so ones left unprocessed after a restart
+		// or by a finished session are replayed by the next session created
+		ai.WithNotificationStore(sm.notificationStore)
 		ai.SetNotificationCallback(sm.notificationCallback)
 	}
 
@@ -105,14 +176,250 @@ func (sm *SessionManager) GetOrCreateSession(id string, clientAgentID string) (*
 		CreatedAt: time.Now().Unix(),
 		AI:        ai,
 	}
+	newSession.touch()
 
+	if greetingPrompt := sm.config.A2AServerConfig.GreetingPrompt; greetingPrompt != "" {
+		// A failed greeting must not prevent the session from working
+		newSession.greeting, err = ai.Greet(greetingPrompt)
+		if err != nil {
+			sm.logger.Printf("Failed to create greeting for session %s: %v", id, err)
+		}
+	}
+
+	var evicted *Session
 	sm.mutex.Lock()
+	if sm.isSessionsLimitReached(len(sm.sessions)) {
+		if !sm.config.ServerConfig.EvictLRUSessions {
+			sm.mutex.Unlock()
+			ai.Finish()
+			sm.logger.Printf("Sessions limit reached (%d). Session %s rejected", sm.config.ServerConfig.MaxSessions, id)
+			return nil, fmt.Errorf("%w: limit is %d", ErrTooManySessions, sm.config.ServerConfig.MaxSessions)
+		}
+		evicted = sm.leastRecentlyUsedSession()
+		if evicted != nil {
+			delete(sm.sessions, evicted.ID)
+		}
+	}
 	sm.sessions[id] = newSession
 	sm.mutex.Unlock()
 
+	sm.applyMissedToolsServersConfig(newSession, toolsServersVersion)
+
+	if evicted != nil {
+		sm.logger.Printf("Sessions limit reached (%d). Session %s evicted for new session %s", sm.config.ServerConfig.MaxSessions, evicted.ID, id)
+		evicted.AI.Finish()
+	}
+
 	return newSession, nil
 }
 
+// sessionConfig returns the config of new sessions with the last applied tools servers
+func (sm *SessionManager) sessionConfig() CleverChattyConfig {
+	config, _ := sm.versionedSessionConfig()
+	return config
+}
+
+// versionedSessionConfig is sessionConfig with the version of the applied tools servers config
+func (sm *SessionManager) versionedSessionConfig() (CleverChattyConfig, uint64) {
+	config := *sm.config
+	sm.toolsServersMux.RLock()
+	defer sm.toolsServersMux.RUnlock()
+	if sm.toolsServers != nil {
+		config.ToolsServers = sm.toolsServers
+	}
+	return config, sm.toolsServersVersion
+}
+
+// applyMissedToolsServersConfig applies the tools servers config applied while the session
+// was created. ApplyToolsServersConfig only updates the sessions added before it, so a session
+// created from an older config is updated here after it is added
+func (sm *SessionManager) applyMissedToolsServersConfig(session *Session, version uint64) {
+	for {
+		sm.toolsServersMux.RLock()
+		servers, current := sm.toolsServers, sm.toolsServersVersion
+		sm.toolsServersMux.RUnlock()
+		if current == version {
+			return
+		}
+		if err := session.AI.ApplyToolsServersConfig(servers); err != nil {
+			sm.logger.Printf("Failed to apply the tools servers config to session %s: %v", session.ID, err)
+		}
+		version = current
+	}
+}
+
+// ApplyToolsServersConfig applies the new tools servers config to all sessions without restarting them,
+// new sessions are created with it. Only the changed servers are reconnected, see ToolsHost.ApplyConfig.
+// An invalid config is rejected and nothing is changed
+func (sm *SessionManager) ApplyToolsServersConfig(servers map[string]ServerConfigWrapper) error {
+	servers = maps.Clone(servers)
+	if servers == nil {
+		servers = map[string]ServerConfigWrapper{}
+	}
+	if err := ValidateToolsServersConfig(servers); err != nil {
+		return err
+	}
+
+	sm.toolsServersMux.Lock()
+	sm.toolsServers = servers
+	sm.toolsServersVersion++
+	sm.toolsServersMux.Unlock()
+
+	sm.mutex.RLock()
+	sessions := make([]*Session, 0, len(sm.sessions))
+	for _, session := range sm.sessions {
+		sessions = append(sessions, session)
+	}
+	sm.mutex.RUnlock()
+
+	failures := []string{}
+	for _, session := range sessions {
+		if err := session.AI.ApplyToolsServersConfig(servers); err != nil {
+			failures = append(failures, fmt.Sprintf("session %s: %v", session.ID, err))
+		}
+	}
+	sm.logger.Printf("Tools servers config applied to %d sessions\n", len(sessions))
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to apply the tools servers config: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// touch marks the session as used now
+func (s *Session) touch() {
+	s.lastUsed.Store(time.Now().UnixNano())
+}
+
+func (sm *SessionManager) isSessionsLimitReached(sessionsCount int) bool {
+	return sm.config.ServerConfig.MaxSessions > 0 && sessionsCount >= sm.config.ServerConfig.MaxSessions
+}
+
+// leastRecentlyUsedSession must be called with the mutex locked
+func (sm *SessionManager) leastRecentlyUsedSession() *Session {
+	var oldest *Session
+	for _, s := range sm.sessions {
+		if oldest == nil || s.lastUsed.Load() < oldest.lastUsed.Load() {
+			oldest = s
+		}
+	}
+	return oldest
+}
+
+// TakeGreeting returns the greeting produced when the session was created.
+// The greeting is returned only once, so it is delivered to the client a single time.
+func (sm *SessionManager) TakeGreeting(id string) string {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	session, ok := sm.sessions[id]
+	if !ok {
+		return ""
+	}
+	greeting := session.greeting
+	session.greeting = ""
+	return greeting
+}
+
+// GetToolsReport returns the tools available to sessions. An existing session is used
+// if there is one, otherwise a temporary assistant is initialized to collect the tools.
+// Its report is reused for toolsReportTTL, or until the tools servers config is applied
+func (sm *SessionManager) GetToolsReport() (ToolsReport, error) {
+	var existing *CleverChatty
+	sm.mutex.RLock()
+	for _, session := range sm.sessions {
+		existing = session.AI
+		break
+	}
+	sm.mutex.RUnlock()
+
+	if existing != nil {
+		return existing.GetToolsReport(), nil
+	}
+
+	// Concurrent requests wait for one report instead of starting an assistant each
+	sm.toolsReportMux.Lock()
+	defer sm.toolsReportMux.Unlock()
+
+	config, version := sm.versionedSessionConfig()
+	if sm.toolsReport != nil && sm.toolsReportVersion == version && time.Since(sm.toolsReportAt) < toolsReportTTL {
+		return *sm.toolsReport, nil
+	}
+
+	ai, err := GetCleverChattyWithLogger(config, sm.context, sm.logger)
+	if err != nil {
+		return ToolsReport{}, err
+	}
+	err = ai.Init()
+	if err != nil {
+		return ToolsReport{}, err
+	}
+	defer ai.Finish()
+
+	if sm.reverseMCPClient != nil {
+		ai.SetReverseMCPClient(sm.reverseMCPClient)
+	}
+
+	report := ai.GetToolsReport()
+	sm.toolsReport = &report
+	sm.toolsReportAt = time.Now()
+	sm.toolsReportVersion = version
+	return report, nil
+}
+
+// GetMemoryQueueStats returns the memory queue state summed over all sessions
+func (sm *SessionManager) GetMemoryQueueStats() MemoryQueueStats {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+
+	stats := MemoryQueueStats{}
+	for _, session := range sm.sessions {
+		sessionStats := session.AI.GetMemoryQueueStats()
+		stats.Pending += sessionStats.Pending
+		stats.Dropped += sessionStats.Dropped
+	}
+	return stats
+}
+
+// GetToolStats returns the tool calls statistics summed over all sessions
+func (sm *SessionManager) GetToolStats() []ToolStats {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+
+	lists := [][]ToolStats{}
+	for _, session := range sm.sessions {
+		lists = append(lists, session.AI.GetToolStats())
+	}
+	return mergeToolStats(lists...)
+}
+
+// GetProviderHealth checks that the LLM provider is reachable. The result is reused for
+// provider_health_ttl seconds, so frequent health requests do not hit the provider API
+func (sm *SessionManager) GetProviderHealth(ctx context.Context) ProviderHealth {
+	sm.providerHealthMux.Lock()
+	defer sm.providerHealthMux.Unlock()
+
+	ttl := defaultProviderHealthTTL
+	if sm.config.AdminServerConfig.ProviderHealthTTL > 0 {
+		ttl = time.Duration(sm.config.AdminServerConfig.ProviderHealthTTL) * time.Second
+	}
+	if sm.providerHealth != nil && time.Since(sm.providerHealth.CheckedAt) < ttl {
+		return *sm.providerHealth
+	}
+	health := CheckProviderHealth(ctx, *sm.config)
+	sm.providerHealth = &health
+	return health
+}
+
+// ResetToolStats clears the tool calls statistics of all sessions
+func (sm *SessionManager) ResetToolStats() {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+
+	for _, session := range sm.sessions {
+		session.AI.ResetToolStats()
+	}
+}
+
 func (sm *SessionManager) StartCleanupLoop() {
 	ticker := time.NewTicker(1 * time.Minute)
 	go func() {
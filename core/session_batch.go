@@ -0,0 +1,101 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gelembjuk/cleverchatty/core/history"
+)
+
+const (
+	defaultBatchConcurrency   = 4
+	defaultBatchPromptTimeout = 5 * time.Minute
+	maxBatchPromptTimeout     = 30 * time.Minute
+)
+
+// BatchPrompt is one of the independent prompts of a batch
+type BatchPrompt struct {
+	Prompt            string `json:"prompt"`
+	SystemInstruction string `json:"system_instruction,omitempty"` // Replaces the configured instruction if not empty
+	Timeout           int    `json:"timeout,omitempty"`            // Seconds. 0 means default, capped at maxBatchPromptTimeout
+}
+
+// BatchResult is the response to a prompt of a batch, or the error if the prompt failed
+type BatchResult struct {
+	Response string `json:"response,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// PromptBatch runs every prompt in a fresh session that is finished after the response,
+// at most concurrency prompts at a time. The prompts are ephemeral, they are not remembered
+// in the memory server. Batch sessions are not counted against the sessions limit, the
+// concurrency limits them. The results are in the order of the prompts.
+// A failed prompt does not stop the others, its result carries the error
+func (sm *SessionManager) PromptBatch(ctx context.Context, prompts []BatchPrompt, concurrency int) []BatchResult {
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+	results := make([]BatchResult, len(prompts))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, prompt := range prompts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				results[i] = BatchResult{Error: ctx.Err().Error()}
+				return
+			}
+			defer func() { <-slots }()
+
+			response, err := sm.promptInBatch(ctx, prompt)
+			if err != nil {
+				results[i] = BatchResult{Error: err.Error()}
+				return
+			}
+			results[i] = BatchResult{Response: response}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// promptInBatch processes one prompt of a batch in its own session with its own timeout
+func (sm *SessionManager) promptInBatch(ctx context.Context, prompt BatchPrompt) (string, error) {
+	if prompt.Prompt == "" {
+		return "", errors.New("prompt is empty")
+	}
+	ctx, cancel := context.WithTimeout(ctx, batchPromptTimeout(prompt))
+	defer cancel()
+
+	ai, err := GetCleverChattyWithLogger(sm.sessionConfig(), sm.context, sm.logger)
+	if err != nil {
+		return "", err
+	}
+	if prompt.SystemInstruction != "" {
+		ai.WithSystemInstruction(prompt.SystemInstruction)
+	}
+	if err := ai.Init(); err != nil {
+		return "", err
+	}
+	defer ai.Finish()
+
+	// Notifications and agent messages are left to the regular sessions
+	if sm.reverseMCPClient != nil {
+		ai.SetReverseMCPClient(sm.reverseMCPClient)
+	}
+	return ai.PromptWithOptions(ctx, history.NewUserPromptMessage(prompt.Prompt), PromptOptions{Ephemeral: true})
+}
+
+// batchPromptTimeout returns the timeout of the prompt, the default one if it is not set
+func batchPromptTimeout(prompt BatchPrompt) time.Duration {
+	if prompt.Timeout <= 0 {
+		return defaultBatchPromptTimeout
+	}
+	return min(time.Duration(prompt.Timeout)*time.Second, maxBatchPromptTimeout)
+}
@@ -0,0 +1,269 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionsLimit(t *testing.T) {
+	config := &CleverChattyConfig{
+		Model:        "mock:mock",
+		WorkDir:      t.TempDir(),
+		ToolsServers: map[string]ServerConfigWrapper{},
+		ServerConfig: ServerConfig{MaxSessions: 2},
+	}
+	sm := NewSessionManager(config, context.Background(), log.New(io.Discard, "", 0))
+
+	for _, id := range []string{"first", "second"} {
+		if _, err := sm.GetOrCreateSession(id, ""); err != nil {
+			t.Fatalf("Failed to create session %s: %v", id, err)
+		}
+	}
+
+	if _, err := sm.GetOrCreateSession("third", ""); !errors.Is(err, ErrTooManySessions) {
+		t.Fatalf("Expected ErrTooManySessions, got %v", err)
+	}
+	// Existing sessions are still served
+	if _, err := sm.GetOrCreateSession("first", ""); err != nil {
+		t.Fatalf("Expected existing session to be served, got %v", err)
+	}
+
+	// With eviction the least recently used session makes room for the new one
+	config.ServerConfig.EvictLRUSessions = true
+	if _, err := sm.GetOrCreateSession("third", ""); err != nil {
+		t.Fatalf("Failed to create session with eviction: %v", err)
+	}
+	if _, err := sm.GetSession("second"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected the least recently used session to be evicted, got %v", err)
+	}
+	if _, err := sm.GetSession("first"); err != nil {
+		t.Errorf("Expected recently used session to be kept, got %v", err)
+	}
+}
+
+func TestSessionSystemInstruction(t *testing.T) {
+	config := &CleverChattyConfig{
+		Model:             "mock:mock",
+		WorkDir:           t.TempDir(),
+		ToolsServers:      map[string]ServerConfigWrapper{},
+		SystemInstruction: "You are a helpful assistant",
+	}
+	sm := NewSessionManager(config, context.Background(), log.New(io.Discard, "", 0))
+
+	session, err := sm.GetOrCreateSessionWithInstruction("s1", "client1", "You are a pirate talking to {CLIENT_AGENT_ID}")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if _, err := session.AI.Prompt("Hello"); err != nil {
+		t.Fatalf("Failed to prompt: %v", err)
+	}
+	if got := session.AI.messages[0].GetContent(); got != "You are a pirate talking to client1" {
+		t.Errorf("Unexpected system instruction '%s'", got)
+	}
+
+	// The instruction is ignored for an existing session
+	again, err := sm.GetOrCreateSessionWithInstruction("s1", "client1", "You are a robot")
+	if err != nil {
+		t.Fatalf("Failed to get session: %v", err)
+	}
+	if again != session || session.AI.config.SystemInstruction == "You are a robot" {
+		t.Errorf("Expected the existing session to keep its instruction")
+	}
+}
+
+func TestSessionToolContext(t *testing.T) {
+	config := &CleverChattyConfig{
+		Model:        "mock:mock",
+		WorkDir:      t.TempDir(),
+		ToolsServers: map[string]ServerConfigWrapper{},
+		ToolContext:  map[string]interface{}{"tenant_id": "acme"},
+	}
+	sm := NewSessionManager(config, context.Background(), log.New(io.Discard, "", 0))
+
+	session, err := sm.GetOrCreateSessionWithOptions("s1", "client1", SessionOptions{
+		ToolContext: map[string]interface{}{"tenant_id": "other", "locale": "de"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	toolContext := session.AI.config.ToolContext
+	if len(toolContext) != 2 || toolContext["tenant_id"] != "acme" || toolContext["locale"] != "de" {
+		t.Errorf("Expected the client values added to the configured ones, got %v", toolContext)
+	}
+	if len(config.ToolContext) != 1 {
+		t.Errorf("Expected the configured tool context untouched, got %v", config.ToolContext)
+	}
+}
+
+func TestSessionsApplyToolsServersConfig(t *testing.T) {
+	config := &CleverChattyConfig{
+		Model:        "mock:mock",
+		WorkDir:      t.TempDir(),
+		ToolsServers: map[string]ServerConfigWrapper{},
+	}
+	sm := NewSessionManager(config, context.Background(), log.New(io.Discard, "", 0))
+
+	existing, err := sm.GetOrCreateSession("s1", "client1")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	defer sm.FinishSession("s1")
+
+	alpha := ServerConfigWrapper{Config: HTTPStreamingMCPServerConfig{Url: newTestMCPServer(t, "alpha").URL + "/mcp"}}
+	if err := sm.ApplyToolsServersConfig(map[string]ServerConfigWrapper{"a": alpha}); err != nil {
+		t.Fatalf("Failed to apply the config: %v", err)
+	}
+	if names := toolNames(existing.AI.toolsHost); len(names) != 1 || names[0] != "a__alpha" {
+		t.Errorf("Expected the tools of the new server in the existing session, got %v", names)
+	}
+
+	created, err := sm.GetOrCreateSession("s2", "client1")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	defer sm.FinishSession("s2")
+	if names := toolNames(created.AI.toolsHost); len(names) != 1 || names[0] != "a__alpha" {
+		t.Errorf("Expected the tools of the new server in a new session, got %v", names)
+	}
+
+	if err := sm.ApplyToolsServersConfig(map[string]ServerConfigWrapper{"bad__name": alpha}); err == nil {
+		t.Errorf("Expected the invalid config to be rejected")
+	}
+	if names := toolNames(existing.AI.toolsHost); len(names) != 1 {
+		t.Errorf("Expected the tools to be kept after the rejected config, got %v", names)
+	}
+	if len(config.ToolsServers) != 0 {
+		t.Errorf("Expected the configured tools servers untouched, got %v", config.ToolsServers)
+	}
+}
+
+func TestSessionCreatedDuringToolsServersReload(t *testing.T) {
+	config := &CleverChattyConfig{
+		Model:        "mock:mock",
+		WorkDir:      t.TempDir(),
+		ToolsServers: map[string]ServerConfigWrapper{},
+	}
+	sm := NewSessionManager(config, context.Background(), log.New(io.Discard, "", 0))
+
+	// The session is initialized from the config before the reload and added after it
+	sessionConfig, version := sm.versionedSessionConfig()
+	ai, err := GetCleverChattyWithLogger(sessionConfig, context.Background(), sm.logger)
+	if err != nil {
+		t.Fatalf("Failed to create CleverChatty object: %v", err)
+	}
+	if err = ai.Init(); err != nil {
+		t.Fatalf("Failed to init CleverChatty object: %v", err)
+	}
+	defer ai.Finish()
+
+	alpha := ServerConfigWrapper{Config: HTTPStreamingMCPServerConfig{Url: newTestMCPServer(t, "alpha").URL + "/mcp"}}
+	if err := sm.ApplyToolsServersConfig(map[string]ServerConfigWrapper{"a": alpha}); err != nil {
+		t.Fatalf("Failed to apply the config: %v", err)
+	}
+
+	session := &Session{ID: "s1", AI: ai}
+	sm.mutex.Lock()
+	sm.sessions[session.ID] = session
+	sm.mutex.Unlock()
+	sm.applyMissedToolsServersConfig(session, version)
+
+	if names := toolNames(ai.toolsHost); len(names) != 1 || names[0] != "a__alpha" {
+		t.Errorf("Expected the tools of the config applied during the creation, got %v", names)
+	}
+}
+
+func TestToolsReportWithoutSessions(t *testing.T) {
+	config := &CleverChattyConfig{
+		Model:        "mock:mock",
+		WorkDir:      t.TempDir(),
+		ToolsServers: map[string]ServerConfigWrapper{},
+	}
+	sm := NewSessionManager(config, context.Background(), log.New(io.Discard, "", 0))
+
+	if _, err := sm.GetToolsReport(); err != nil {
+		t.Fatalf("Failed to get the tools report: %v", err)
+	}
+	cached := sm.toolsReport
+	if cached == nil {
+		t.Fatalf("Expected the report to be kept")
+	}
+	if _, err := sm.GetToolsReport(); err != nil {
+		t.Fatalf("Failed to get the tools report: %v", err)
+	}
+	if sm.toolsReport != cached {
+		t.Errorf("Expected the kept report to be reused")
+	}
+
+	// The applied config makes a new report
+	alpha := ServerConfigWrapper{Config: HTTPStreamingMCPServerConfig{Url: newTestMCPServer(t, "alpha").URL + "/mcp"}}
+	if err := sm.ApplyToolsServersConfig(map[string]ServerConfigWrapper{"a": alpha}); err != nil {
+		t.Fatalf("Failed to apply the config: %v", err)
+	}
+	report, err := sm.GetToolsReport()
+	if err != nil {
+		t.Fatalf("Failed to get the tools report: %v", err)
+	}
+	if len(report.Tools) != 1 || report.Tools[0].Name != "a__alpha" {
+		t.Errorf("Expected the tools of the applied config, got %+v", report.Tools)
+	}
+}
+
+func TestPromptBatch(t *testing.T) {
+	config := &CleverChattyConfig{
+		Model:        "mock:mock",
+		WorkDir:      t.TempDir(),
+		ToolsServers: map[string]ServerConfigWrapper{},
+	}
+	sm := NewSessionManager(config, context.Background(), log.New(io.Discard, "", 0))
+
+	results := sm.PromptBatch(context.Background(), []BatchPrompt{
+		{Prompt: "first"},
+		{Prompt: ""},
+		{Prompt: "third", SystemInstruction: "Be brief"},
+	}, 2)
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+	if results[0].Error != "" || !strings.Contains(results[0].Response, "first") {
+		t.Errorf("Expected the response to the first prompt, got %+v", results[0])
+	}
+	if results[1].Error == "" {
+		t.Errorf("Expected the empty prompt to fail, got %+v", results[1])
+	}
+	if results[2].Error != "" || !strings.Contains(results[2].Response, "third") {
+		t.Errorf("Expected the response to the third prompt, got %+v", results[2])
+	}
+	if len(sm.sessions) != 0 {
+		t.Errorf("Expected the batch sessions not to be kept, got %d sessions", len(sm.sessions))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, result := range sm.PromptBatch(ctx, []BatchPrompt{{Prompt: "cancelled"}}, 1) {
+		if result.Error == "" {
+			t.Errorf("Expected the prompt of the cancelled batch to fail, got %+v", result)
+		}
+	}
+}
+
+func TestBatchPromptTimeout(t *testing.T) {
+	tests := []struct {
+		timeout  int
+		expected time.Duration
+	}{
+		{0, defaultBatchPromptTimeout},
+		{-1, defaultBatchPromptTimeout},
+		{60, time.Minute},
+		{24 * 3600, maxBatchPromptTimeout},
+	}
+	for _, test := range tests {
+		if timeout := batchPromptTimeout(BatchPrompt{Timeout: test.timeout}); timeout != test.expected {
+			t.Errorf("Expected the timeout %v for %d seconds, got %v", test.expected, test.timeout, timeout)
+		}
+	}
+}
@@ -31,6 +31,21 @@ type CleverChatty struct {
 	onFinishCallback      func()     // Called when Finish() is invoked, used to notify parent
 	notificationProcessor *NotificationProcessor
 	agentMessageCallback  AgentMessageCallback // Callback for agent-generated messages
+	providerMiddlewares   []llm.ProviderMiddleware
+	notificationStore     *NotificationStore           // Persists monitored notifications across restarts. Optional
+	feedbackCallback      NotificationFeedbackCallback // Callback for attributed notification feedback
+	toolsSupported        bool                         // False when the model does not support function calling
+	capabilities          llm.ProviderCapabilities     // Features of the provider with the configured model
+	lastToolCall          string                       // Signature of the last tool call, to detect repeated calls
+	toolCallRepeats       int                          // How many times in a row the last tool call was requested
+	promptPreprocessors   []PromptPreprocessor         // Applied to every user's prompt before it is processed
+	injectedProvider      llm.Provider                 // Used instead of the provider from the model config. Optional
+	archivedMessages      []history.HistoryMessage     // Messages removed from the context, kept for the conversation search
+	systemInstructionSet  bool                         // The system instruction was changed and must replace the one in the history
+	requestID             string                       // ID of the prompt being processed, see RequestID
+	promptStats           PromptStats                  // Counters of the prompt being processed, see LastPromptStats
+	continuations         int                          // Continuations of truncated responses in the prompt being processed
+	ephemeralPrompt       bool                         // The prompt being processed and its responses are not remembered
 }
 
 func GetCleverChatty(config CleverChattyConfig, ctx context.Context) (*CleverChatty, error) {
@@ -65,12 +80,41 @@ func GetCleverChattyWithLogger(config CleverChattyConfig, ctx context.Context, l
 	return assistant, nil
 }
 
+// GetCleverChattyWithProvider creates the assistant using the given LLM provider instead of
+// the one from the "model" config option. It is useful for tests with a scripted provider
+// (see test.NewMockProvider). Middlewares are applied to the provider as usual.
+func GetCleverChattyWithProvider(config CleverChattyConfig, ctx context.Context, provider llm.Provider) (*CleverChatty, error) {
+	assistant, err := GetCleverChatty(config, ctx)
+	if err != nil {
+		return nil, err
+	}
+	assistant.injectedProvider = provider
+	return assistant, nil
+}
+
 func (assistant *CleverChatty) Init() error {
 	var err error
-	assistant.provider, err = assistant.createProvider(assistant.context, assistant.config.Model)
+	if assistant.injectedProvider != nil {
+		assistant.provider = assistant.injectedProvider
+	} else {
+		assistant.provider, err = assistant.createProvider(assistant.context, assistant.config.Model)
 
-	if err != nil {
-		return fmt.Errorf("error creating provider: %v", err)
+		if err != nil {
+			return fmt.Errorf("error creating provider: %w", err)
+		}
+	}
+	assistant.provider = llm.WrapProvider(assistant.provider, assistant.providerMiddlewares...)
+
+	switch assistant.config.MemoryInjectionMode {
+	case "", MemoryInjectionModeNote, MemoryInjectionModeSystem, MemoryInjectionModePrompt:
+	default:
+		return fmt.Errorf("unsupported memory_injection_mode: %s", assistant.config.MemoryInjectionMode)
+	}
+
+	assistant.capabilities = assistant.provider.Capabilities()
+	assistant.toolsSupported = assistant.capabilities.Tools
+	for _, warning := range assistant.capabilityWarnings() {
+		assistant.logger.Printf("Warning: %s", warning)
 	}
 
 	assistant.toolsHost, err = newToolsHost(assistant.config.ToolsServers, assistant.logger, assistant.context, assistant.config.WorkDir)
@@ -82,6 +126,16 @@ func (assistant *CleverChatty) Init() error {
 	assistant.toolsHost.clientAgentID = assistant.ClientAgentID
 	assistant.toolsHost.AgentID = assistant.config.AgentID
 	assistant.toolsHost.AgentName = assistant.config.A2AServerConfig.Title
+	assistant.toolsHost.debugMode = assistant.config.DebugMode
+	assistant.toolsHost.samplingProvider = assistant.provider
+	assistant.toolsHost.samplingModel = assistant.config.Model
+	assistant.toolsHost.samplingConfig = assistant.config.SamplingConfig
+	assistant.toolsHost.stripEscapes = assistant.config.StripToolOutputEscapes
+	assistant.toolsHost.memoryQueueSize = assistant.config.MemoryQueueSize
+	assistant.toolsHost.userAgent = assistant.config.UserAgent
+	assistant.toolsHost.toolsChangedCallback = func(serverName string) {
+		assistant.Callbacks.CallToolsChanged(serverName)
+	}
 
 	err = assistant.toolsHost.Init()
 
@@ -89,6 +143,22 @@ func (assistant *CleverChatty) Init() error {
 		return fmt.Errorf("error initializing MCP host: %v", err)
 	}
 
+	if assistant.config.ConversationSearch {
+		if err := assistant.registerConversationSearchTool(); err != nil {
+			return fmt.Errorf("error registering conversation search tool: %w", err)
+		}
+	}
+
+	if assistant.config.ListToolsTool {
+		if err := assistant.registerListToolsTool(); err != nil {
+			return fmt.Errorf("error registering list tools tool: %w", err)
+		}
+	}
+
+	if err := assistant.syncForgetTool(); err != nil {
+		return fmt.Errorf("error registering forget tool: %w", err)
+	}
+
 	return nil
 }
 
@@ -99,6 +169,19 @@ func (assistant *CleverChatty) WithLogger(logger *log.Logger) {
 	}
 }
 
+// WithProviderMiddleware adds middlewares wrapping every LLM CreateMessage call.
+// Middlewares are applied in the order they are added, the first one is the outermost.
+// Must be called before Init(). Subagents inherit the middlewares.
+func (assistant *CleverChatty) WithProviderMiddleware(middlewares ...llm.ProviderMiddleware) {
+	assistant.providerMiddlewares = append(assistant.providerMiddlewares, middlewares...)
+}
+
+// WithNotificationStore sets the store used to persist monitored notifications
+// until they are processed. Must be called before SetNotificationCallback.
+func (assistant *CleverChatty) WithNotificationStore(store *NotificationStore) {
+	assistant.notificationStore = store
+}
+
 func (assistant *CleverChatty) WithCallbacks(callbacks UICallbacks) {
 	assistant.Callbacks = callbacks
 }
@@ -111,6 +194,33 @@ func (assistant *CleverChatty) WithAgentID(agentID string) {
 	assistant.config.AgentID = agentID
 }
 
+// WithSystemInstruction replaces the system instruction from the config. It is used when
+// the history is started, so it has no effect after the first prompt.
+// The placeholders ({AGENT_ID}, {AGENT_NAME}, etc.) are replaced as in the config value.
+func (assistant *CleverChatty) WithSystemInstruction(instruction string) {
+	assistant.config.SystemInstruction = instruction
+}
+
+// SetSystemInstruction replaces the system instruction during a session. The new instruction
+// replaces the system message in the history on the next prompt.
+// The placeholders ({AGENT_ID}, {AGENT_NAME}, etc.) are replaced as in the config value.
+func (assistant *CleverChatty) SetSystemInstruction(instruction string) {
+	assistant.config.SystemInstruction = instruction
+	assistant.systemInstructionSet = true
+}
+
+// WithToolContext sets the values added to the arguments of every tool call, for example
+// a tenant ID or a locale. They are not shown to the model and replace the arguments
+// of the model with the same names
+func (assistant *CleverChatty) WithToolContext(toolContext map[string]interface{}) {
+	assistant.config.ToolContext = toolContext
+}
+
+// GetSystemInstruction returns the active system instruction with the placeholders replaced
+func (assistant *CleverChatty) GetSystemInstruction() string {
+	return assistant.systemInstruction()
+}
+
 // SetReverseMCPClient sets the reverse MCP client for dynamic tool registration
 func (assistant *CleverChatty) SetReverseMCPClient(client ReverseMCPClient) {
 	if assistant.toolsHost != nil {
@@ -123,6 +233,16 @@ func (assistant *CleverChatty) SetAgentMessageCallback(callback AgentMessageCall
 	assistant.agentMessageCallback = callback
 }
 
+// SetNotificationFeedbackCallback sets the callback for messages to the user produced
+// while processing monitored notifications. The feedback carries the server name
+// and method of the notification, so UIs can attribute it.
+func (assistant *CleverChatty) SetNotificationFeedbackCallback(callback NotificationFeedbackCallback) {
+	assistant.feedbackCallback = callback
+	if assistant.notificationProcessor != nil {
+		assistant.notificationProcessor.SetFeedbackCallback(callback)
+	}
+}
+
 // SetNotificationCallback sets a callback for notifications from all MCP servers.
 // The callback receives a unified Notification structure instead of the raw MCP notification.
 // If a notification is monitored and has instructions configured, it will be queued
@@ -149,6 +269,8 @@ func (assistant *CleverChatty) SetNotificationCallback(callback NotificationCall
 			assistant.logger.Printf("Failed to create notification processor: %v", err)
 		} else {
 			assistant.notificationProcessor = processor
+			assistant.notificationProcessor.SetStore(assistant.notificationStore)
+			assistant.notificationProcessor.SetFeedbackCallback(assistant.feedbackCallback)
 			assistant.notificationProcessor.Start()
 			assistant.logger.Printf("Notification processor started")
 		}
@@ -162,13 +284,16 @@ func (assistant *CleverChatty) SetNotificationCallback(callback NotificationCall
 		// Queue monitored notifications for processing
 		if assistant.processNotifications && notification.IsMonitored() && assistant.notificationProcessor != nil {
 			// Get the server config to retrieve instructions
-			if serverConfig, ok := assistant.config.ToolsServers[notification.ServerName]; ok {
+			if serverConfig, ok := assistant.toolsHost.serverConfig(notification.ServerName); ok {
 				if instructions := serverConfig.GetNotificationInstructions(notification.Method); instructions != nil && len(instructions) > 0 {
 					assistant.notificationProcessor.Enqueue(notification, instructions)
 				}
 			}
 		}
 
+		// Let the UI of the current prompt show it (e.g. progress of a running tool)
+		assistant.Callbacks.CallNotificationReceived(notification)
+
 		// Always call the original callback
 		if callback != nil {
 			assistant.logger.Printf("Calling original notification callback for server=%s", notification.ServerName)
@@ -189,6 +314,7 @@ func (assistant *CleverChatty) getSubagent(alias string) (*CleverChatty, error)
 	}
 
 	subAgent.ClientAgentID = assistant.ClientAgentID
+	subAgent.providerMiddlewares = assistant.providerMiddlewares
 	subAgent.processNotifications = false // Disable notification processing for subagents
 
 	if alias == "" {
@@ -239,8 +365,57 @@ func (assistant *CleverChatty) RemoveTool(name string) {
 	}
 }
 
+// CallTool calls a tool by its full name (server__tool) without the LLM, for example to test
+// a tools server. The server timeout is applied. ErrToolNotFound is returned if no server
+// provides the tool, the error of the call is returned next to the result
+func (assistant *CleverChatty) CallTool(ctx context.Context, name string, args map[string]interface{}) (ToolCallResult, error) {
+	if assistant.toolsHost == nil {
+		return ToolCallResult{}, fmt.Errorf("toolsHost not initialized, call Init() first")
+	}
+	serverName, toolName, ok := strings.Cut(name, "__")
+	if !ok || serverName == "" || toolName == "" {
+		return ToolCallResult{}, fmt.Errorf("%w: %s, the name must be in the server__tool format", ErrToolNotFound, name)
+	}
+	found := false
+	for _, tool := range assistant.toolsHost.GetAllToolsForLLM() {
+		if tool.Name == name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return ToolCallResult{}, fmt.Errorf("%w: %s", ErrToolNotFound, name)
+	}
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+
+	result := assistant.toolsHost.callTool(serverName, toolName, assistant.toolArguments(ctx, name, args), ctx)
+	return result, result.Error
+}
+
+// ApplyToolsServersConfig applies the new tools servers config to the tools of the assistant and of
+// its notification processor, without restarting them. See ToolsHost.ApplyConfig
+func (assistant *CleverChatty) ApplyToolsServersConfig(servers map[string]ServerConfigWrapper) error {
+	if assistant.toolsHost == nil {
+		return fmt.Errorf("toolsHost not initialized, call Init() first")
+	}
+	// A server failing to start does not stop the config from being applied, so both are updated
+	err := assistant.toolsHost.ApplyConfig(servers)
+	// The memory server can be added or removed by the new config
+	if forgetErr := assistant.syncForgetTool(); forgetErr != nil && err == nil {
+		err = fmt.Errorf("error registering forget tool: %w", forgetErr)
+	}
+	if assistant.notificationProcessor != nil {
+		if processorErr := assistant.notificationProcessor.agent.ApplyToolsServersConfig(servers); processorErr != nil && err == nil {
+			err = fmt.Errorf("notification processor: %w", processorErr)
+		}
+	}
+	return err
+}
+
 // Add new function to create provider
-func (assistant CleverChatty) createProvider(ctx context.Context, modelString string) (llm.Provider, error) {
+func (assistant *CleverChatty) createProvider(ctx context.Context, modelString string) (llm.Provider, error) {
 	parts := strings.SplitN(modelString, ":", 2)
 	if len(parts) < 2 {
 		return nil, fmt.Errorf(
@@ -252,37 +427,57 @@ func (assistant CleverChatty) createProvider(ctx context.Context, modelString st
 	provider := parts[0]
 	model := parts[1]
 
+	if err := assistant.checkProviderConfig(ctx, provider); err != nil {
+		return nil, err
+	}
+
 	switch provider {
 	case "anthropic":
 		apiKey := assistant.config.Anthropic.APIKey
 
-		if apiKey == "" {
-			return nil, fmt.Errorf(
-				"anthropic API key not provided. Use --anthropic-api-key flag or ANTHROPIC_API_KEY environment variable",
-			)
-		}
-		return anthropic.NewProvider(apiKey, assistant.config.Anthropic.BaseURL, model), nil
+		anthropicProvider := anthropic.NewProvider(apiKey, assistant.config.Anthropic.BaseURL, model)
+		anthropicProvider.SetThinking(assistant.config.Anthropic.ExtendedThinking, assistant.config.Anthropic.ThinkingBudgetTokens)
+		anthropicProvider.SetGenerationOptions(llm.GenerationOptions{
+			Stop: assistant.config.Anthropic.Stop,
+		})
+		return anthropicProvider, nil
 
 	case "ollama":
-		return ollama.NewProvider(model)
+		ollamaProvider, err := ollama.NewProvider(model)
+		if err != nil {
+			return nil, err
+		}
+		ollamaProvider.SetGenerationOptions(llm.GenerationOptions{
+			Stop: assistant.config.Ollama.Stop,
+			Seed: assistant.config.Ollama.Seed,
+		})
+		return ollamaProvider, nil
 
 	case "openai":
 		apiKey := assistant.config.OpenAI.APIKey
 
-		if apiKey == "" {
-			return nil, fmt.Errorf(
-				"OpenAI API key not provided. Use --openai-api-key flag or OPENAI_API_KEY environment variable",
-			)
-		}
-		return openai.NewProvider(apiKey, assistant.config.OpenAI.BaseURL, model), nil
+		openaiProvider := openai.NewProvider(apiKey, assistant.config.OpenAI.BaseURL, model)
+		openaiProvider.SetParallelToolCalls(assistant.config.OpenAI.ParallelToolCalls)
+		openaiProvider.SetGenerationOptions(llm.GenerationOptions{
+			Stop: assistant.config.OpenAI.Stop,
+			Seed: assistant.config.OpenAI.Seed,
+		})
+		return openaiProvider, nil
 
 	case "google":
 		apiKey := assistant.config.Google.APIKey
 
-		return google.NewProvider(ctx, apiKey, model)
+		googleProvider, err := google.NewProvider(ctx, apiKey, model)
+		if err != nil {
+			return nil, err
+		}
+		googleProvider.SetGenerationOptions(llm.GenerationOptions{
+			Stop: assistant.config.Google.Stop,
+		})
+		return googleProvider, nil
 
 	case "mock":
-		return test.MockProvider{}, nil
+		return &test.MockProvider{}, nil
 	default:
 		return nil, fmt.Errorf("unsupported provider: %s", provider)
 	}
@@ -354,6 +549,70 @@ func (assistant *CleverChatty) Finish() error {
 	return nil
 }
 
+// GetMemoryQueueStats returns the number of messages waiting to be sent to the memory server
+// and the number of messages dropped because the queue was full
+func (assistant *CleverChatty) GetMemoryQueueStats() MemoryQueueStats {
+	if assistant.toolsHost == nil {
+		return MemoryQueueStats{}
+	}
+	return assistant.toolsHost.MemoryQueueStats()
+}
+
+// GetToolStats returns the calls statistics of the tools called by this assistant
+func (assistant *CleverChatty) GetToolStats() []ToolStats {
+	if assistant.toolsHost == nil {
+		return []ToolStats{}
+	}
+	return assistant.toolsHost.GetToolStats()
+}
+
+// ResetToolStats clears the tool calls statistics
+func (assistant *CleverChatty) ResetToolStats() {
+	if assistant.toolsHost != nil {
+		assistant.toolsHost.ResetToolStats()
+	}
+}
+
+// ToolsSupported returns false if the model does not support function calling.
+// In that case prompts are sent without tools
+func (assistant *CleverChatty) ToolsSupported() bool {
+	return assistant.toolsSupported
+}
+
+// Capabilities returns the features supported by the provider with the configured model
+func (assistant *CleverChatty) Capabilities() llm.ProviderCapabilities {
+	return assistant.capabilities
+}
+
+// capabilityWarnings lists the features requested in the config that the model does not support
+func (assistant *CleverChatty) capabilityWarnings() []string {
+	warnings := []string{}
+	if !assistant.capabilities.Tools && assistant.hasEnabledToolsServers() {
+		warnings = append(warnings, fmt.Sprintf("model %s does not support function calling, tools are disabled", assistant.config.Model))
+	}
+	if !assistant.capabilities.Reasoning && assistant.provider.Name() == "anthropic" && assistant.config.Anthropic.ExtendedThinking {
+		warnings = append(warnings, fmt.Sprintf("model %s does not support extended thinking", assistant.config.Model))
+	}
+	return warnings
+}
+
+func (assistant *CleverChatty) hasEnabledToolsServers() bool {
+	for _, server := range assistant.config.ToolsServers {
+		if !server.Disabled {
+			return true
+		}
+	}
+	return false
+}
+
+// toolsForLLM returns the tools to send with a request, none if the model does not support them
+func (assistant *CleverChatty) toolsForLLM() []llm.Tool {
+	if !assistant.toolsSupported {
+		return []llm.Tool{}
+	}
+	return assistant.toolsHost.GetAllToolsForLLM()
+}
+
 func (assistant *CleverChatty) GetServersInfo() []ServerInfo {
 	return assistant.toolsHost.getServersInfo()
 }
@@ -362,6 +621,29 @@ func (assistant *CleverChatty) GetToolsInfo() []ServerInfo {
 	return assistant.toolsHost.getToolsInfo()
 }
 
+// TakeProducedFiles returns the files returned by tools while processing the last prompt.
+// Each file is returned once.
+func (assistant *CleverChatty) TakeProducedFiles() []ProducedFile {
+	if assistant.toolsHost == nil || assistant.toolsHost.fileCache == nil {
+		return []ProducedFile{}
+	}
+	return assistant.toolsHost.fileCache.TakeProducedFiles()
+}
+
+// DescribeFileRef returns the file referenced by a [FILE OBJECT ...] reference found
+// in tool results. Returns false if the text is not a file reference.
+func (assistant *CleverChatty) DescribeFileRef(text string) (FileRefInfo, bool) {
+	if assistant.toolsHost == nil || assistant.toolsHost.fileCache == nil {
+		return FileRefInfo{}, false
+	}
+	return assistant.toolsHost.fileCache.DescribeFileRef(text)
+}
+
+// GetToolsReport returns all tools with the servers connection status
+func (assistant *CleverChatty) GetToolsReport() ToolsReport {
+	return assistant.toolsHost.getToolsReport(assistant.toolsSupported)
+}
+
 func (assistant *CleverChatty) GetMessages() []history.HistoryMessage {
 	return assistant.messages
 }
@@ -2,28 +2,113 @@ package core
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/gelembjuk/cleverchatty/core/llm"
 	"github.com/gelembjuk/cleverchatty/core/test"
+	"github.com/mark3labs/mcp-go/mcp"
 )
 
-func TestObjectCreate(t *testing.T) {
-	cleverChattyObj, err := GetCleverChatty(CleverChattyConfig{
-		Model:        "mock:mock",
-		ToolsServers: map[string]ServerConfigWrapper{},
-	}, context.Background())
-
+// newTestAssistant creates and initializes the assistant for tests. The mock model and
+// no tools servers are used unless the config sets them. Middlewares are added before Init
+func newTestAssistant(t *testing.T, config CleverChattyConfig, middlewares ...llm.ProviderMiddleware) *CleverChatty {
+	t.Helper()
+	if config.Model == "" {
+		config.Model = "mock:mock"
+	}
+	if config.ToolsServers == nil {
+		config.ToolsServers = map[string]ServerConfigWrapper{}
+	}
+	assistant, err := GetCleverChatty(config, context.Background())
 	if err != nil {
 		t.Fatalf("Failed to create CleverChatty object: %v", err)
 	}
-	if cleverChattyObj == nil {
-		t.Fatal("CleverChatty object is nil")
+	assistant.WithProviderMiddleware(middlewares...)
+	if err := assistant.Init(); err != nil {
+		t.Fatalf("Failed to init CleverChatty object: %v", err)
 	}
+	return assistant
+}
 
-	err = cleverChattyObj.Init()
+// newTestAssistantWithProvider is newTestAssistant answering with the given provider,
+// usually a scripted test.MockProvider
+func newTestAssistantWithProvider(t *testing.T, config CleverChattyConfig, provider llm.Provider) *CleverChatty {
+	t.Helper()
+	if config.Model == "" {
+		config.Model = "mock:scripted"
+	}
+	if config.ToolsServers == nil {
+		config.ToolsServers = map[string]ServerConfigWrapper{}
+	}
+	assistant, err := GetCleverChattyWithProvider(config, context.Background(), provider)
 	if err != nil {
+		t.Fatalf("Failed to create CleverChatty object: %v", err)
+	}
+	if err := assistant.Init(); err != nil {
 		t.Fatalf("Failed to init CleverChatty object: %v", err)
 	}
+	return assistant
+}
+
+// testMemoryServer emulates the memory server of the assistant with custom tools.
+// Recall returns the given memories, the arguments of remember calls are recorded
+type testMemoryServer struct {
+	remembered []map[string]interface{}
+	mux        sync.Mutex
+}
+
+func newTestMemoryServer(t *testing.T, assistant *CleverChatty, memories string) *testMemoryServer {
+	t.Helper()
+	memory := &testMemoryServer{}
+	assistant.toolsHost.memoryServerName = customToolsServerName
+	tools := []CustomTool{
+		{
+			Name:        memoryToolRecallName,
+			Description: "Recalls memories",
+			Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				return memories, nil
+			},
+		},
+		{
+			Name:        memoryToolRememberName,
+			Description: "Remembers a message",
+			Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				memory.mux.Lock()
+				defer memory.mux.Unlock()
+				memory.remembered = append(memory.remembered, args)
+				return "ok", nil
+			},
+		},
+	}
+	for _, tool := range tools {
+		if err := assistant.SetTool(tool); err != nil {
+			t.Fatalf("Failed to set tool: %v", err)
+		}
+	}
+	return memory
+}
+
+// Remembered returns the arguments of the remember calls received so far
+func (m *testMemoryServer) Remembered() []map[string]interface{} {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return append([]map[string]interface{}{}, m.remembered...)
+}
+
+func TestObjectCreate(t *testing.T) {
+	cleverChattyObj := newTestAssistant(t, CleverChattyConfig{})
 
 	if cleverChattyObj.provider == nil {
 		t.Fatal("Provider is nil")
@@ -36,6 +121,63 @@ func TestObjectCreate(t *testing.T) {
 	}
 }
 
+func TestMultipleMemoryServersRejected(t *testing.T) {
+	cleverChattyObj, err := GetCleverChatty(CleverChattyConfig{
+		Model: "mock:mock",
+		ToolsServers: map[string]ServerConfigWrapper{
+			"memory1": {
+				Config:    STDIOMCPServerConfig{Command: "memory-server-1"},
+				Interface: toolsServerInterfaceMemory,
+			},
+			"memory2": {
+				Config:    STDIOMCPServerConfig{Command: "memory-server-2"},
+				Interface: toolsServerInterfaceMemory,
+			},
+		},
+	}, context.Background())
+
+	if err != nil {
+		t.Fatalf("Failed to create CleverChatty object: %v", err)
+	}
+
+	err = cleverChattyObj.Init()
+	if err == nil {
+		t.Fatal("Expected error for multiple memory servers, got nil")
+	}
+	if !strings.Contains(err.Error(), "memory1, memory2") {
+		t.Fatalf("Expected error to name both memory servers, got: %v", err)
+	}
+}
+
+func TestServerNameCollisionsRejected(t *testing.T) {
+	for _, name := range []string{customToolsServerName, "files__local"} {
+		cleverChattyObj, err := GetCleverChatty(CleverChattyConfig{
+			Model: "mock:mock",
+			ToolsServers: map[string]ServerConfigWrapper{
+				name: {Config: STDIOMCPServerConfig{Command: "some-server"}},
+			},
+		}, context.Background())
+		if err != nil {
+			t.Fatalf("Failed to create CleverChatty object: %v", err)
+		}
+		if err := cleverChattyObj.Init(); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("Expected the server name %q to be rejected, got %v", name, err)
+		}
+	}
+
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	configData := `{"model": "mock:mock", "tools_servers": {
+		"files": {"command": "files-server"},
+		"files": {"command": "other-server"}
+	}}`
+	if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if _, err := LoadConfig(configPath); err == nil || !strings.Contains(err.Error(), "files") {
+		t.Errorf("Expected the duplicated server to be rejected, got %v", err)
+	}
+}
+
 func TestObjectWithOneServerCreate(t *testing.T) {
 	// TODO: This test requires proper mock MCP server infrastructure
 	// The internal server config with Kind="mock" is not yet supported
@@ -92,3 +234,411 @@ func TestObjectWithOneServerCreate(t *testing.T) {
 		t.Fatalf("Expected tool name 'tool1', got '%s'", cleverChattyObj.toolsHost.tools[0].Name)
 	}
 }
+
+func TestCallTool(t *testing.T) {
+	cleverChattyObj := newTestAssistant(t, CleverChattyConfig{})
+	err := cleverChattyObj.SetTool(CustomTool{
+		Name:        "echo",
+		Description: "Returns the text",
+		Arguments:   []ToolArgument{{Name: "text", Type: "string", Description: "Text", Required: true}},
+		Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			return args["text"].(string), nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to set tool: %v", err)
+	}
+
+	result, err := cleverChattyObj.CallTool(context.Background(), "custom__echo", map[string]interface{}{"text": "hi"})
+	if err != nil || result.getTextContent() != "hi" {
+		t.Errorf("Expected the tool result, got %q, %v", result.getTextContent(), err)
+	}
+	if _, err := cleverChattyObj.CallTool(context.Background(), "custom__missing", nil); !errors.Is(err, ErrToolNotFound) {
+		t.Errorf("Expected ErrToolNotFound, got %v", err)
+	}
+}
+
+func TestToolsListChanged(t *testing.T) {
+	cleverChattyObj := newTestAssistant(t, CleverChattyConfig{})
+	changed := make(chan string, 1)
+	cleverChattyObj.Callbacks.SetToolsChanged(func(server string) error {
+		changed <- server
+		return nil
+	})
+
+	client := &test.MockMCPClient{}
+	host := cleverChattyObj.toolsHost
+	host.config["test"] = ServerConfigWrapper{Config: STDIOMCPServerConfig{Command: "test-server"}}
+	host.mcpClients["test"] = client
+	host.watchToolsListChanged("test", client)
+
+	client.SendNotification(mcp.MethodNotificationToolsListChanged)
+
+	select {
+	case server := <-changed:
+		if server != "test" {
+			t.Errorf("Expected the change of server 'test', got '%s'", server)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the tools changed callback")
+	}
+	tools := host.GetAllToolsForLLM()
+	if len(tools) != 1 || tools[0].Name != "test__tool1" {
+		t.Errorf("Expected the reloaded tool test__tool1, got %v", tools)
+	}
+}
+
+func TestToolsChangedWhilePrompting(t *testing.T) {
+	cleverChattyObj := newTestAssistant(t, CleverChattyConfig{})
+
+	client := &test.MockMCPClient{}
+	host := cleverChattyObj.toolsHost
+	host.config["test"] = ServerConfigWrapper{Config: STDIOMCPServerConfig{Command: "test-server"}}
+	host.mcpClients["test"] = client
+	host.watchToolsListChanged("test", client)
+
+	// The server reloads the tools in the background while the A2A server
+	// replaces the callbacks for every streamed prompt
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			client.SendNotification(mcp.MethodNotificationToolsListChanged)
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		cleverChattyObj.Callbacks.SetToolsChanged(func(server string) error {
+			return nil
+		})
+		if _, err := cleverChattyObj.Prompt("Hello"); err != nil {
+			t.Fatalf("Failed to prompt: %v", err)
+		}
+		cleverChattyObj.Callbacks.SetToolsChanged(nil)
+	}
+	<-done
+}
+
+func TestProviderPreflightCheck(t *testing.T) {
+	cleverChattyObj, err := GetCleverChatty(CleverChattyConfig{
+		Model:        "openai:gpt-4o",
+		ToolsServers: map[string]ServerConfigWrapper{},
+	}, context.Background())
+	if err != nil {
+		t.Fatalf("Failed to create CleverChatty object: %v", err)
+	}
+	err = cleverChattyObj.Init()
+	if !errors.Is(err, ErrProviderNotConfigured) || !strings.Contains(err.Error(), "OPENAI_API_KEY") {
+		t.Errorf("Expected the missing OPENAI_API_KEY error, got %v", err)
+	}
+
+	ollamaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ollamaURL := ollamaServer.URL
+	ollamaServer.Close()
+	t.Setenv("OLLAMA_HOST", ollamaURL)
+
+	cleverChattyObj, err = GetCleverChatty(CleverChattyConfig{
+		Model:        "ollama:llama3",
+		ToolsServers: map[string]ServerConfigWrapper{},
+	}, context.Background())
+	if err != nil {
+		t.Fatalf("Failed to create CleverChatty object: %v", err)
+	}
+	err = cleverChattyObj.Init()
+	if !errors.Is(err, ErrProviderNotConfigured) || !strings.Contains(err.Error(), "OLLAMA_HOST") {
+		t.Errorf("Expected the unreachable Ollama server error, got %v", err)
+	}
+}
+
+func TestProviderHealth(t *testing.T) {
+	requests := 0
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/v1/models" || r.Header.Get("Authorization") != "Bearer key" {
+			t.Errorf("Expected the models request with the API key, got %s", r.URL.Path)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(`{"data": [{"id": "gpt-4o"}]}`))
+	}))
+	defer server.Close()
+
+	config := &CleverChattyConfig{Model: "openai:gpt-4o", WorkDir: t.TempDir()}
+	config.OpenAI.APIKey = "key"
+	config.OpenAI.BaseURL = server.URL + "/v1"
+	sm := NewSessionManager(config, context.Background(), log.New(io.Discard, "", 0))
+
+	health := sm.GetProviderHealth(context.Background())
+	if !health.Reachable || health.Error != "" || health.Model != "openai:gpt-4o" {
+		t.Fatalf("Expected the provider to be reachable, got %+v", health)
+	}
+
+	// The result is reused within the TTL
+	status = http.StatusUnauthorized
+	if health := sm.GetProviderHealth(context.Background()); !health.Reachable || requests != 1 {
+		t.Errorf("Expected the cached result without a new request, got %+v after %d requests", health, requests)
+	}
+
+	health = CheckProviderHealth(context.Background(), *config)
+	if health.Reachable || !strings.Contains(health.Error, "401") {
+		t.Errorf("Expected the rejected API key to be reported, got %+v", health)
+	}
+
+	config.OpenAI.APIKey = ""
+	health = CheckProviderHealth(context.Background(), *config)
+	if health.Reachable || !strings.Contains(health.Error, "OPENAI_API_KEY") {
+		t.Errorf("Expected the missing API key to be reported, got %+v", health)
+	}
+	if health := CheckProviderHealth(context.Background(), CleverChattyConfig{Model: "mock:mock"}); !health.Reachable {
+		t.Errorf("Expected the mock provider to be reachable, got %+v", health)
+	}
+}
+
+func TestDiagnose(t *testing.T) {
+	checks := Diagnose(context.Background(), CleverChattyConfig{
+		Model: "mock:mock",
+		ToolsServers: map[string]ServerConfigWrapper{
+			"broken": {Config: STDIOMCPServerConfig{Command: "/nonexistent-server-binary"}},
+			"off":    {Config: STDIOMCPServerConfig{Command: "off-server"}, Disabled: true},
+		},
+	})
+	results := map[string]DiagnosticCheck{}
+	for _, check := range checks {
+		results[check.Component+" "+check.Name] = check
+	}
+	if len(results) != 4 {
+		t.Fatalf("Expected 4 checks, got %+v", checks)
+	}
+	for _, name := range []string{"config tools servers", "provider mock:mock", "server off"} {
+		if !results[name].Passed() {
+			t.Errorf("Expected the check %q to pass, got %v", name, results[name].Err)
+		}
+	}
+	if results["server broken"].Passed() {
+		t.Errorf("Expected the check of the broken server to fail")
+	}
+
+	checks = Diagnose(context.Background(), CleverChattyConfig{
+		Model: "unknown:model",
+		ToolsServers: map[string]ServerConfigWrapper{
+			"memory1": {Config: STDIOMCPServerConfig{Command: "memory-server-1"}, Interface: toolsServerInterfaceMemory, Disabled: true},
+			"memory2": {Config: STDIOMCPServerConfig{Command: "memory-server-2"}, Interface: toolsServerInterfaceMemory},
+		},
+	})
+	if !checks[0].Passed() {
+		t.Errorf("Expected disabled memory servers to be ignored, got %v", checks[0].Err)
+	}
+	if checks[1].Passed() {
+		t.Errorf("Expected the unsupported provider to fail")
+	}
+}
+
+func TestToolsOrderedByServerPriority(t *testing.T) {
+	var config CleverChattyConfig
+	err := json.Unmarshal([]byte(`{"model": "mock:mock", "tools_servers": {
+		"alpha": {"command": "alpha-server"},
+		"beta": {"command": "beta-server", "priority": 10}
+	}}`), &config)
+	if err != nil {
+		t.Fatalf("Failed to parse the config: %v", err)
+	}
+	if config.ToolsServers["beta"].Priority != 10 {
+		t.Fatalf("Expected the priority 10, got %d", config.ToolsServers["beta"].Priority)
+	}
+
+	host, err := newToolsHost(config.ToolsServers, log.New(io.Discard, "", 0), context.Background(), "")
+	if err != nil {
+		t.Fatalf("Failed to create the tools host: %v", err)
+	}
+	host.tools = []llm.Tool{{Name: "alpha__search"}, {Name: "beta__search"}, {Name: "alpha__read"}}
+
+	names := []string{}
+	for _, tool := range host.GetAllToolsForLLM() {
+		names = append(names, tool.Name)
+	}
+	if strings.Join(names, ",") != "beta__search,alpha__search,alpha__read" {
+		t.Errorf("Expected the tools of the preferred server first, got %v", names)
+	}
+}
+
+func TestToolStats(t *testing.T) {
+	cleverChattyObj := newTestAssistant(t, CleverChattyConfig{})
+	cleverChattyObj.SetTool(CustomTool{
+		Name:        "echo",
+		Description: "Returns the text",
+		Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			return "ok", nil
+		},
+	})
+	cleverChattyObj.SetTool(CustomTool{
+		Name:        "broken",
+		Description: "Always fails",
+		Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			return "", errors.New("broken")
+		},
+	})
+
+	cleverChattyObj.CallTool(context.Background(), "custom__echo", map[string]interface{}{})
+	cleverChattyObj.CallTool(context.Background(), "custom__echo", map[string]interface{}{})
+	cleverChattyObj.CallTool(context.Background(), "custom__broken", map[string]interface{}{})
+
+	stats := cleverChattyObj.GetToolStats()
+	if len(stats) != 2 {
+		t.Fatalf("Expected stats of 2 tools, got %+v", stats)
+	}
+	if stats[0].Tool != "custom__echo" || stats[0].Calls != 2 || stats[0].Successes != 2 {
+		t.Errorf("Expected 2 successful calls of echo first, got %+v", stats[0])
+	}
+	if stats[1].Tool != "custom__broken" || stats[1].Calls != 1 || stats[1].Failures != 1 {
+		t.Errorf("Expected 1 failed call of broken, got %+v", stats[1])
+	}
+
+	cleverChattyObj.ResetToolStats()
+	if stats := cleverChattyObj.GetToolStats(); len(stats) != 0 {
+		t.Errorf("Expected no stats after the reset, got %+v", stats)
+	}
+}
+
+func TestListToolsTool(t *testing.T) {
+	cleverChattyObj := newTestAssistant(t, CleverChattyConfig{
+		ListToolsTool: true,
+	})
+
+	// Tools added later are listed too
+	cleverChattyObj.SetTool(CustomTool{
+		Name:        "get_time",
+		Description: "Returns the current time",
+		Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			return "12:00", nil
+		},
+	})
+
+	result, err := cleverChattyObj.CallTool(context.Background(), "custom__list_tools", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Failed to call the list tools tool: %v", err)
+	}
+	text := result.getTextContent()
+	if !strings.Contains(text, "- custom__get_time: Returns the current time") || !strings.Contains(text, "custom__list_tools") {
+		t.Errorf("Expected the current tools in the list, got %s", text)
+	}
+
+	result, _ = cleverChattyObj.CallTool(context.Background(), "custom__list_tools", map[string]interface{}{"server": "missing"})
+	if result.getTextContent() != "No tools of the server missing are available" {
+		t.Errorf("Expected no tools of an unknown server, got %s", result.getTextContent())
+	}
+}
+
+func TestUnknownProtocolVersionRejected(t *testing.T) {
+	cleverChattyObj, err := GetCleverChatty(CleverChattyConfig{
+		Model: "mock:mock",
+		ToolsServers: map[string]ServerConfigWrapper{
+			"old_server": {
+				Config:          STDIOMCPServerConfig{Command: "old-server"},
+				ProtocolVersion: "2023-01-01",
+			},
+		},
+	}, context.Background())
+	if err != nil {
+		t.Fatalf("Failed to create CleverChatty object: %v", err)
+	}
+	err = cleverChattyObj.Init()
+	if err == nil || !strings.Contains(err.Error(), "unknown MCP protocol version") {
+		t.Fatalf("Expected an unknown protocol version error, got %v", err)
+	}
+
+	var wrapper ServerConfigWrapper
+	if err := json.Unmarshal([]byte(`{"command": "old-server", "protocol_version": "2024-11-05"}`), &wrapper); err != nil {
+		t.Fatalf("Failed to parse the server config: %v", err)
+	}
+	host := &ToolsHost{config: map[string]ServerConfigWrapper{"old_server": wrapper}}
+	if err := host.validateProtocolVersions(); err != nil {
+		t.Errorf("Expected the known version to be accepted, got %v", err)
+	}
+}
+
+func TestMigrateConfigFile(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	configData := `{"model": "mock:mock", "mcpServers": {
+		"files": {"command": "files-server", "args": ["--root", "/tmp"]},
+		"events": {"url": "http://localhost:8001/sse", "transport": "sse", "headers": ["X-Key: 1"]},
+		"search": {"url": "http://localhost:8002/mcp", "interface": "rag"},
+		"worker": {"transport": "reverse_mcp", "auth_token": "secret"},
+		"agent": {"endpoint": "http://localhost:8003/", "metadata": {"agent_id": "me"}}
+	}}`
+	if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	before, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load the legacy config: %v", err)
+	}
+
+	migrated, err := MigrateConfigFile(configPath)
+	if err != nil || !migrated {
+		t.Fatalf("Expected the config to be migrated, got %v, %v", migrated, err)
+	}
+	newData, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read the migrated config: %v", err)
+	}
+	var rawConfig map[string]json.RawMessage
+	if err := json.Unmarshal(newData, &rawConfig); err != nil {
+		t.Fatalf("Failed to parse the migrated config: %v", err)
+	}
+	if len(rawConfig) != 2 || rawConfig["tools_servers"] == nil {
+		t.Errorf("Expected only the model and the renamed servers key, got %s", newData)
+	}
+
+	after, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load the migrated config: %v", err)
+	}
+	if len(after.ToolsServers) != len(before.ToolsServers) {
+		t.Fatalf("Expected %d servers, got %d", len(before.ToolsServers), len(after.ToolsServers))
+	}
+	for name, server := range before.ToolsServers {
+		if got := after.ToolsServers[name]; !reflect.DeepEqual(got, server) {
+			t.Errorf("Expected server %s to be kept as %+v, got %+v", name, server, got)
+		}
+	}
+	if worker := after.ToolsServers["worker"]; !worker.IsReverseMCPServer() || worker.GetReverseMCPAuthToken() != "secret" {
+		t.Errorf("Expected the reverse MCP server with its token, got %+v", worker)
+	}
+	if events := after.ToolsServers["events"]; events.Config.GetType() != transportSSE {
+		t.Errorf("Expected the SSE server, got %s", events.Config.GetType())
+	}
+
+	// Marshalled servers keep their transport too
+	for name, server := range before.ToolsServers {
+		data, err := json.Marshal(server)
+		if err != nil {
+			t.Fatalf("Failed to marshal server %s: %v", name, err)
+		}
+		var parsed ServerConfigWrapper
+		if err := json.Unmarshal(data, &parsed); err != nil {
+			t.Fatalf("Failed to parse server %s: %v", name, err)
+		}
+		if !reflect.DeepEqual(parsed, server) {
+			t.Errorf("Expected server %s to survive marshalling as %+v, got %+v", name, server, parsed)
+		}
+	}
+}
+
+func TestProtocolVersionRoundTrip(t *testing.T) {
+	var wrapper ServerConfigWrapper
+	if err := json.Unmarshal([]byte(`{"command": "old-server", "protocol_version": "2024-11-05"}`), &wrapper); err != nil {
+		t.Fatalf("Failed to parse the server config: %v", err)
+	}
+	data, err := json.Marshal(wrapper)
+	if err != nil {
+		t.Fatalf("Failed to marshal the server config: %v", err)
+	}
+
+	var parsed ServerConfigWrapper
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("Failed to parse the marshalled server config: %v", err)
+	}
+	if parsed.ProtocolVersion != "2024-11-05" {
+		t.Errorf("Expected the protocol version to be kept, got '%s' in %s", parsed.ProtocolVersion, data)
+	}
+}
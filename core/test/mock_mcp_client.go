@@ -9,6 +9,18 @@ import (
 )
 
 type MockMCPClient struct {
+	notificationHandlers []func(notification mcp.JSONRPCNotification)
+}
+
+// SendNotification passes the notification to the registered handlers as if the server sent it
+func (m *MockMCPClient) SendNotification(method string) {
+	notification := mcp.JSONRPCNotification{
+		JSONRPC:      mcp.JSONRPC_VERSION,
+		Notification: mcp.Notification{Method: method},
+	}
+	for _, handler := range m.notificationHandlers {
+		handler(notification)
+	}
 }
 
 func (m *MockMCPClient) Initialize(
@@ -152,5 +164,5 @@ func (m *MockMCPClient) Close() error {
 	return nil
 }
 func (m *MockMCPClient) OnNotification(handler func(notification mcp.JSONRPCNotification)) {
-	// Do nothing
+	m.notificationHandlers = append(m.notificationHandlers, handler)
 }
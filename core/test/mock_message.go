@@ -9,6 +9,7 @@ type MockMessage struct {
 	content        string
 	toolCalls      []MockToolCall
 	toolResponseID string
+	finishReason   string
 	usage          struct {
 		input  int
 		output int
@@ -49,3 +50,11 @@ func (m MockMessage) GetToolResponseID() string {
 func (m MockMessage) GetUsage() (input int, output int) {
 	return m.usage.input, m.usage.output
 }
+
+// GetFinishReason returns the scripted finish reason, FinishReasonUnknown if it is not set
+func (m MockMessage) GetFinishReason() string {
+	if m.finishReason == "" {
+		return llm.FinishReasonUnknown
+	}
+	return m.finishReason
+}
@@ -5,15 +5,80 @@ import (
 	"log"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/gelembjuk/cleverchatty/core/history"
 	"github.com/gelembjuk/cleverchatty/core/llm"
 )
 
+// MockProvider is an LLM provider for tests. Without a script it answers with
+// FAKE_RESPONSE:<prompt>. With a script it returns the scripted responses in order and
+// falls back to the default behavior when the script is exhausted.
+// Every request is recorded and can be inspected with Requests().
 type MockProvider struct {
+	script   []MockResponse
+	requests []MockRequest
+	mu       sync.Mutex
 }
 
-func (p MockProvider) CreateMessage(ctx context.Context, prompt string, messages []llm.Message, tools []llm.Tool) (llm.Message, error) {
+// MockResponse is a scripted response of the MockProvider
+type MockResponse struct {
+	Content   string
+	ToolCalls []MockToolCall
+	Err       error  // Returned instead of a message when set
+	Usage     [2]int // Input and output tokens reported with the message
+	// FinishReason reported with the message, one of the llm.FinishReason constants
+	FinishReason string
+}
+
+// MockRequest is a request received by the MockProvider
+type MockRequest struct {
+	Prompt   string
+	Messages []llm.Message
+	Tools    []llm.Tool
+}
+
+// NewMockProvider creates a provider returning the responses in the given order
+func NewMockProvider(responses ...MockResponse) *MockProvider {
+	return &MockProvider{script: responses}
+}
+
+// Requests returns the requests received by the provider in order
+func (p *MockProvider) Requests() []MockRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]MockRequest{}, p.requests...)
+}
+
+func (p *MockProvider) CreateMessage(ctx context.Context, prompt string, messages []llm.Message, tools []llm.Tool) (llm.Message, error) {
+	p.mu.Lock()
+	p.requests = append(p.requests, MockRequest{
+		Prompt:   prompt,
+		Messages: append([]llm.Message{}, messages...),
+		Tools:    tools,
+	})
+	var scripted *MockResponse
+	if len(p.script) > 0 {
+		scripted = &p.script[0]
+		p.script = p.script[1:]
+	}
+	p.mu.Unlock()
+
+	if scripted != nil {
+		if scripted.Err != nil {
+			return nil, scripted.Err
+		}
+		message := &MockMessage{
+			role:         "assistant",
+			content:      scripted.Content,
+			toolCalls:    scripted.ToolCalls,
+			finishReason: scripted.FinishReason,
+		}
+		message.usage.input, message.usage.output = scripted.Usage[0], scripted.Usage[1]
+		return message, nil
+	}
+
 	// Simulate a message creation process
 	// This is just a placeholder implementation
 	// if prompt starts with "tool:N:..." then it is a tool call simulated. N is an index of the tool (-1. 1 goes to 0 etc)
@@ -69,24 +134,32 @@ func (p MockProvider) CreateMessage(ctx context.Context, prompt string, messages
 }
 
 // CreateToolResponse creates a message representing a tool response
-func (p MockProvider) CreateToolResponse(toolCallID string, content interface{}) (llm.Message, error) {
+func (p *MockProvider) CreateToolResponse(toolCallID string, content interface{}) (llm.Message, error) {
 	// Simulate creating a tool response
 	// This is just a placeholder implementation
 	return &MockMessage{}, nil
 }
 
 // SupportsTools returns whether this provider supports tool/function calling
-func (p MockProvider) SupportsTools() bool {
+func (p *MockProvider) SupportsTools() bool {
 	// Simulate checking for tool support
 	// This is just a placeholder implementation
 	return true
 }
 
+// Capabilities returns the features of the mock, everything except reasoning is supported
+func (p *MockProvider) Capabilities() llm.ProviderCapabilities {
+	return llm.ProviderCapabilities{
+		Tools:  true,
+		Images: true,
+	}
+}
+
 // Name returns the provider's name
-func (p MockProvider) Name() string {
+func (p *MockProvider) Name() string {
 	return "MockProvider"
 }
 
-func (p MockProvider) SetLogger(logger *log.Logger) {
+func (p *MockProvider) SetLogger(logger *log.Logger) {
 
 }
@@ -1,10 +1,18 @@
 package core
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"os/exec"
+	"slices"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"strings"
@@ -25,6 +33,7 @@ type notificationCallbackWrapper struct {
 const (
 	memoryToolRememberName = "remember"
 	memoryToolRecallName   = "recall"
+	memoryToolForgetName   = "forget"
 	ragToolName            = "knowledge_search"
 )
 
@@ -44,15 +53,45 @@ type ToolsHost struct {
 	AgentName        string
 	logger           *log.Logger
 	mcpClients       map[string]mcpclient.MCPClient
+	mcpClientsMux    sync.RWMutex
 	a2aClients       map[string]A2AAgent
+	a2aClientsMux    sync.RWMutex
 	reverseMCPClient ReverseMCPClient
 	tools            []llm.Tool
 	toolsMux         sync.RWMutex
 	customTools      map[string]CustomTool
 	customToolsMux   sync.RWMutex
-	memoryServerName string
-	ragServerName    string
+	memoryServerName string // Guarded by configMux, see memoryServer
+	ragServerName    string // Guarded by configMux, see ragServer
 	fileCache        *FileCache
+	toolCache        *ToolCache
+	debugMode        bool
+	samplingProvider llm.Provider
+	samplingModel    string
+	samplingConfig   SamplingConfig
+	stripEscapes     bool   // Remove terminal escape sequences from tool results
+	userAgent        string // Configured User-Agent of requests to tools servers, empty means the default
+	memoryQueueSize  int
+	memoryQueue      *memoryQueue // Created with the first message to remember
+	memoryQueueMux   sync.Mutex   // Protects memoryQueue
+	// memoryForgetSupported is true when the memory server provides the forget tool
+	memoryForgetSupported atomic.Bool
+	// notificationCallback is kept to subscribe clients created on reconnect
+	notificationCallback NotificationCallback
+	reconnecting         map[string]bool
+	stopReconnect        chan struct{}
+	stopReconnectOnce    sync.Once
+	// monitorStops stops the monitors of one server, when ApplyConfig removes or changes it
+	monitorStops map[string]chan struct{}
+	// configMux guards the replacement of the config by ApplyConfig, the config map itself is not modified.
+	// It guards the names of the memory and RAG servers as well
+	configMux sync.RWMutex
+	// toolsReloadMux serializes reloads of tools lists after list_changed notifications
+	toolsReloadMux sync.Mutex
+	// toolsChangedCallback is called when the tools list of a server changed
+	toolsChangedCallback func(serverName string)
+	// toolStats counts the calls, failures and latency of every tool
+	toolStats *toolStatsCollector
 }
 
 type ToolCallResult struct {
@@ -60,6 +99,49 @@ type ToolCallResult struct {
 	Error   error
 }
 
+// ToolErrorKind classifies the error of a failed tool call, so the model can decide
+// whether to try the call again or to give up
+type ToolErrorKind string
+
+const (
+	ToolErrorTimeout  ToolErrorKind = "timeout"      // The tool did not respond in time
+	ToolErrorNotFound ToolErrorKind = "not_found"    // There is no such tool
+	ToolErrorServer   ToolErrorKind = "server_error" // The tools server failed or is not connected
+	ToolErrorReported ToolErrorKind = "tool_error"   // The tool rejected the call or reported a failure
+)
+
+// Retryable returns true if the same call can succeed when it is made again
+func (k ToolErrorKind) Retryable() bool {
+	return k == ToolErrorTimeout || k == ToolErrorServer
+}
+
+// ErrorKind returns the classification of the error of the call, or empty string if the call succeeded
+func (tc ToolCallResult) ErrorKind() ToolErrorKind {
+	switch {
+	case tc.Error == nil:
+		return ""
+	case errors.Is(tc.Error, ErrToolTimeout), errors.Is(tc.Error, context.DeadlineExceeded):
+		return ToolErrorTimeout
+	case errors.Is(tc.Error, ErrToolNotFound):
+		return ToolErrorNotFound
+	case errors.Is(tc.Error, ErrToolReported), errors.Is(tc.Error, ErrInvalidToolArguments):
+		return ToolErrorReported
+	default:
+		return ToolErrorServer
+	}
+}
+
+// errorResultText returns the text of the tool result for the failed call. The readable message
+// is followed by the classification of the error as JSON for the model
+func (tc ToolCallResult) errorResultText(message string) string {
+	kind := tc.ErrorKind()
+	classification, _ := json.Marshal(struct {
+		Kind      ToolErrorKind `json:"error_kind"`
+		Retryable bool          `json:"retryable"`
+	}{kind, kind.Retryable()})
+	return message + "\n" + string(classification)
+}
+
 type ServerToolInfo struct {
 	Name        string
 	Description string
@@ -125,12 +207,40 @@ func (tc ToolCallResult) getTextContent() string {
 	var textContent strings.Builder
 	for _, content := range tc.Content {
 		if textC, ok := content.(history.TextContent); ok {
-			textContent.WriteString(textC.Text)
+			textContent.WriteString(strings.ToValidUTF8(textC.Text, "\uFFFD"))
 		}
 	}
 	return strings.TrimSpace(textContent.String())
 }
 
+// formatJSONContent replaces the text content holding a JSON object or array with
+// the pretty-printed JSON in a fenced code block. Other text is left untouched
+func (tc *ToolCallResult) formatJSONContent() {
+	for i, content := range tc.Content {
+		textC, ok := content.(history.TextContent)
+		if !ok {
+			continue
+		}
+		if formatted, ok := formatJSONText(textC.Text); ok {
+			textC.Text = formatted
+			tc.Content[i] = textC
+		}
+	}
+}
+
+// formatJSONText returns the JSON object or array from the text pretty-printed in a fenced code block
+func formatJSONText(text string) (string, bool) {
+	trimmed := strings.TrimSpace(text)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return text, false
+	}
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, []byte(trimmed), "", "  "); err != nil {
+		return text, false
+	}
+	return "```json\n" + pretty.String() + "\n```", true
+}
+
 func (tc *ToolCallResult) validateNotEmpty() {
 	if tc.Content == nil {
 		tc.Error = fmt.Errorf("no content from tool call")
@@ -149,17 +259,40 @@ func newToolsHost(
 	workDir string,
 ) (*ToolsHost, error) {
 	host := &ToolsHost{
-		config:    mcpServersConfig,
-		context:   ctx,
-		logger:    logger,
-		fileCache: NewFileCache(workDir, logger),
+		config:        mcpServersConfig,
+		context:       ctx,
+		logger:        logger,
+		fileCache:     NewFileCache(workDir, logger),
+		toolCache:     NewToolCache(defaultToolCacheSize),
+		toolStats:     newToolStatsCollector(),
+		reconnecting:  map[string]bool{},
+		stopReconnect: make(chan struct{}),
+		monitorStops:  map[string]chan struct{}{},
 	}
 
 	return host, nil
 }
 
 func (host *ToolsHost) Init() error {
-	err := host.createMCPClients()
+	err := host.validateServerNames()
+
+	if err != nil {
+		return err
+	}
+
+	err = host.validateInterfaces()
+
+	if err != nil {
+		return err
+	}
+
+	err = host.validateProtocolVersions()
+
+	if err != nil {
+		return err
+	}
+
+	err = host.createMCPClients()
 
 	if err != nil {
 		return fmt.Errorf("failed to create MCP clients: %w", err)
@@ -184,6 +317,11 @@ func (host *ToolsHost) Init() error {
 		return fmt.Errorf("failed to load A2A tools: %w", err)
 	}
 
+	host.warnDuplicateToolNames()
+
+	host.startReconnectMonitors()
+	host.startA2ACardRefresh()
+
 	return nil
 }
 
@@ -192,37 +330,139 @@ func (host *ToolsHost) Init() error {
 // If a notification method is configured in notification_instructions for the server,
 // the notification will be marked as monitored.
 func (host *ToolsHost) SetNotificationCallback(callback NotificationCallback) {
+	host.mcpClientsMux.Lock()
+	defer host.mcpClientsMux.Unlock()
+
+	host.notificationCallback = callback
+
 	for serverName, client := range host.mcpClients {
-		// Get the server config to check for notification instructions
-		serverConfig := host.config[serverName]
+		host.subscribeToNotifications(serverName, client, callback)
+	}
+}
+
+// validateServerNames checks that tools of a server can not be confused with tools of other
+// servers. Tool names are prefixed with the server name and "__"
+func (host *ToolsHost) validateServerNames() error {
+	for name := range host.config {
+		if name == customToolsServerName {
+			return fmt.Errorf("tools server name %q is reserved for custom tools", name)
+		}
+		if strings.Contains(name, "__") {
+			return fmt.Errorf("tools server name %q must not contain \"__\", it separates the server and tool names", name)
+		}
+	}
+	return nil
+}
+
+// warnDuplicateToolNames logs the tools having the same name, only one of them can be called
+func (host *ToolsHost) warnDuplicateToolNames() {
+	host.toolsMux.RLock()
+	defer host.toolsMux.RUnlock()
 
-		// Create a wrapper to capture serverName and config in the closure
-		wrapper := notificationCallbackWrapper{
-			serverName: serverName,
-			callback:   callback,
+	seen := map[string]bool{}
+	for _, tool := range host.tools {
+		if seen[tool.Name] {
+			host.logger.Printf("Warning: tool %s is provided more than once, only one of them can be called\n", tool.Name)
 		}
-		client.OnNotification(func(mcpNotification mcp.JSONRPCNotification) {
-			// Convert MCP notification to unified Notification
-			notification := NewNotificationFromMCP(wrapper.serverName, mcpNotification)
+		seen[tool.Name] = true
+	}
+}
 
-			// Check if this notification method is monitored
-			if instructions := serverConfig.GetNotificationInstructions(mcpNotification.Method); instructions != nil {
-				notification.SetMonitored()
-			}
+// validateInterfaces checks that at most one enabled server declares the memory interface
+// and at most one declares the RAG interface
+func (host *ToolsHost) validateInterfaces() error {
+	memoryServers := []string{}
+	ragServers := []string{}
+
+	for name, server := range host.config {
+		if server.Disabled {
+			continue
+		}
+		if server.isMemoryServer() {
+			memoryServers = append(memoryServers, name)
+		}
+		if server.isRAGServer() {
+			ragServers = append(ragServers, name)
+		}
+	}
 
-			wrapper.callback(notification)
-		})
+	if len(memoryServers) > 1 {
+		sort.Strings(memoryServers)
+		return fmt.Errorf("multiple memory servers configured: %s. Only one server can have the memory interface",
+			strings.Join(memoryServers, ", "))
+	}
+	if len(ragServers) > 1 {
+		sort.Strings(ragServers)
+		return fmt.Errorf("multiple RAG servers configured: %s. Only one server can have the rag interface",
+			strings.Join(ragServers, ", "))
 	}
+	return nil
 }
+
+// validateProtocolVersions checks the MCP protocol versions pinned in the servers config
+func (host *ToolsHost) validateProtocolVersions() error {
+	for name, server := range host.config {
+		if server.ProtocolVersion == "" {
+			continue
+		}
+		if !server.isMCPServer() {
+			return fmt.Errorf("tools server %s: protocol_version is supported only for MCP servers", name)
+		}
+		if !slices.Contains(mcp.ValidProtocolVersions, server.ProtocolVersion) {
+			return fmt.Errorf("tools server %s: unknown MCP protocol version %q. Known versions: %s",
+				name, server.ProtocolVersion, strings.Join(mcp.ValidProtocolVersions, ", "))
+		}
+	}
+	return nil
+}
+
+func (host *ToolsHost) subscribeToNotifications(serverName string, client mcpclient.MCPClient, callback NotificationCallback) {
+	// Get the server config to check for notification instructions
+	serverConfig, _ := host.serverConfig(serverName)
+
+	// Create a wrapper to capture serverName and config in the closure
+	wrapper := notificationCallbackWrapper{
+		serverName: serverName,
+		callback:   callback,
+	}
+	client.OnNotification(func(mcpNotification mcp.JSONRPCNotification) {
+		// Convert MCP notification to unified Notification
+		notification := NewNotificationFromMCP(wrapper.serverName, mcpNotification)
+
+		// Check if this notification method is monitored
+		if instructions := serverConfig.GetNotificationInstructions(mcpNotification.Method); instructions != nil {
+			notification.SetMonitored()
+		}
+
+		wrapper.callback(notification)
+	})
+}
+
+// getMCPClient returns the connected client of the MCP server
+func (host *ToolsHost) getMCPClient(serverName string) (mcpclient.MCPClient, bool) {
+	host.mcpClientsMux.RLock()
+	defer host.mcpClientsMux.RUnlock()
+	client, ok := host.mcpClients[serverName]
+	return client, ok
+}
+
 func (host *ToolsHost) isMCPServer(serverName string) bool {
-	_, ok := host.mcpClients[serverName]
+	_, ok := host.getMCPClient(serverName)
 	return ok
 }
 func (host *ToolsHost) isA2AServer(serverName string) bool {
-	_, ok := host.a2aClients[serverName]
+	_, ok := host.getA2AClient(serverName)
 	return ok
 }
 
+// getA2AClient returns the agent of the server, it is replaced when the agent card changes
+func (host *ToolsHost) getA2AClient(serverName string) (A2AAgent, bool) {
+	host.a2aClientsMux.RLock()
+	defer host.a2aClientsMux.RUnlock()
+	agent, ok := host.a2aClients[serverName]
+	return agent, ok
+}
+
 func (host *ToolsHost) isReverseMCPServer(serverName string) bool {
 	if host.reverseMCPClient == nil {
 		return false
@@ -231,6 +471,38 @@ func (host *ToolsHost) isReverseMCPServer(serverName string) bool {
 	return len(tools) > 0
 }
 
+// serverConfig returns the config of the server. A reverse MCP server connected with
+// a name matching a pattern gets the config of the pattern
+func (host *ToolsHost) serverConfig(serverName string) (ServerConfigWrapper, bool) {
+	servers := host.currentConfig()
+	if config, ok := servers[serverName]; ok {
+		return config, true
+	}
+	_, config, ok := FindReverseMCPServerConfig(servers, serverName)
+	return config, ok
+}
+
+// currentConfig returns the config of the tools servers. It can be replaced by ApplyConfig
+// at any time, so it is read once for a loop over the servers
+func (host *ToolsHost) currentConfig() map[string]ServerConfigWrapper {
+	host.configMux.RLock()
+	defer host.configMux.RUnlock()
+	return host.config
+}
+
+// reverseMCPServersOfPattern returns the names of the connected reverse MCP servers
+// using the config of the pattern, sorted
+func (host *ToolsHost) reverseMCPServersOfPattern(pattern string) []string {
+	names := []string{}
+	for serverName := range host.reverseMCPClient.GetAllTools() {
+		if key, _, ok := FindReverseMCPServerConfig(host.currentConfig(), serverName); ok && key == pattern {
+			names = append(names, serverName)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 // SetReverseMCPClient sets the reverse MCP client for dynamic tool registration
 func (host *ToolsHost) SetReverseMCPClient(client ReverseMCPClient) {
 	host.reverseMCPClient = client
@@ -257,9 +529,28 @@ func (host *ToolsHost) GetAllToolsForLLM() []llm.Tool {
 	customTools := host.getCustomToolsForLLM()
 	allTools = append(allTools, customTools...)
 
+	host.sortToolsByPriority(allTools)
+
 	return allTools
 }
 
+// sortToolsByPriority orders the tools by the priority of their servers, highest first.
+// Models tend to prefer the tools listed earlier. Servers with the same priority are
+// ordered by name, the order of the tools of one server is kept
+func (host *ToolsHost) sortToolsByPriority(tools []llm.Tool) {
+	sort.SliceStable(tools, func(i, j int) bool {
+		serverI, _, _ := strings.Cut(tools[i].Name, "__")
+		serverJ, _, _ := strings.Cut(tools[j].Name, "__")
+		configI, _ := host.serverConfig(serverI)
+		configJ, _ := host.serverConfig(serverJ)
+		priorityI, priorityJ := configI.Priority, configJ.Priority
+		if priorityI != priorityJ {
+			return priorityI > priorityJ
+		}
+		return serverI < serverJ
+	})
+}
+
 func (host *ToolsHost) mcpToolsToAnthropicTools(
 	serverName string,
 	mcpTools []mcp.Tool,
@@ -307,6 +598,35 @@ func (host *ToolsHost) filterConfigValue(value string) string {
 	return value
 }
 
+// parseConfigHeaders parses the "Name: value" headers from the config of a server.
+// Placeholders in the values are replaced. The User-Agent is added unless the server
+// config sets its own
+func (host *ToolsHost) parseConfigHeaders(configHeaders []string) map[string]string {
+	headers := map[string]string{"User-Agent": host.outboundUserAgent()}
+	for _, header := range configHeaders {
+		parts := strings.SplitN(header, ":", 2)
+		if len(parts) == 2 {
+			key := http.CanonicalHeaderKey(strings.TrimSpace(parts[0]))
+			value := strings.TrimSpace(parts[1])
+			headers[key] = host.filterConfigValue(value)
+		}
+	}
+	return headers
+}
+
+// outboundUserAgent returns the User-Agent of requests to tools servers, so their operators
+// can tell the traffic of the agent
+func (host *ToolsHost) outboundUserAgent() string {
+	if host.userAgent != "" {
+		return host.filterConfigValue(host.userAgent)
+	}
+	userAgent := "CleverChatty/" + ThisAppVersion
+	if host.AgentID != "" {
+		userAgent += " (agent " + host.AgentID + ")"
+	}
+	return userAgent
+}
+
 // Create MCP servers instances
 func (host *ToolsHost) createMCPClients() error {
 	clients := make(map[string]mcpclient.MCPClient)
@@ -321,83 +641,9 @@ func (host *ToolsHost) createMCPClients() error {
 			continue
 		}
 
-		var client mcpclient.MCPClient
-		var err error
-
-		if server.Config.GetType() == transportSSE {
-			sseConfig := server.Config.(SSEMCPServerConfig)
-
-			options := []transport.ClientOption{}
-
-			if sseConfig.Headers != nil {
-				// Parse headers from the config
-				headers := make(map[string]string)
-				for _, header := range sseConfig.Headers {
-					parts := strings.SplitN(header, ":", 2)
-					if len(parts) == 2 {
-						key := strings.TrimSpace(parts[0])
-						value := strings.TrimSpace(parts[1])
-						// Replace placeholders in header values
-						value = host.filterConfigValue(value)
-						headers[key] = value
-					}
-				}
-				options = append(options, transport.WithHeaders(headers))
-			}
-
-			client, err = mcpclient.NewSSEMCPClient(
-				sseConfig.Url,
-				options...,
-			)
-		} else if server.Config.GetType() == transportHTTPStreaming {
-			httpConfig := server.Config.(HTTPStreamingMCPServerConfig)
-
-			options := []transport.StreamableHTTPCOption{}
-
-			if httpConfig.Headers != nil {
-				// Parse headers from the config
-				headers := make(map[string]string)
-				for _, header := range httpConfig.Headers {
-					parts := strings.SplitN(header, ":", 2)
-					if len(parts) == 2 {
-						key := strings.TrimSpace(parts[0])
-						value := strings.TrimSpace(parts[1])
-						value = host.filterConfigValue(value)
-						headers[key] = value
-					}
-				}
-				options = append(options, transport.WithHTTPHeaders(headers))
-			}
-			options = append(options, transport.WithContinuousListening())
-
-			client, err = mcpclient.NewStreamableHttpClient(
-				httpConfig.Url,
-				options...,
-			)
-		} else if server.Config.GetType() == transportInternal {
-			internalConfig := server.Config.(InternalServerConfig)
-
-			err = fmt.Errorf("unknown internal server kind: %s", internalConfig.Kind)
-		} else {
-			stdioConfig := server.Config.(STDIOMCPServerConfig)
-			var env []string
-			for k, v := range stdioConfig.Env {
-				// Replace placeholders in environment variables
-				v = host.filterConfigValue(v)
-				env = append(env, fmt.Sprintf("%s=%s", k, v))
-			}
-			var stdioArgs []string
-			for _, arg := range stdioConfig.Args {
-				arg = host.filterConfigValue(arg)
-				stdioArgs = append(stdioArgs, arg)
-			}
-			client, err = mcpclient.NewStdioMCPClient(
-				stdioConfig.Command,
-				env,
-				stdioArgs...)
-		}
+		client, err := host.newMCPClient(name, server)
 		if err == nil {
-			err = client.(*mcpclient.Client).Start(context.Background())
+			err = startMCPClient(client)
 		}
 		if err != nil {
 			for _, c := range clients {
@@ -409,20 +655,7 @@ func (host *ToolsHost) createMCPClients() error {
 				err,
 			)
 		}
-		// TODO
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
-
-		host.logger.Printf("Initializing server...%s\n", name)
-		initRequest := mcp.InitializeRequest{}
-		initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
-		initRequest.Params.ClientInfo = mcp.Implementation{
-			Name:    ThisAppName,
-			Version: ThisAppVersion,
-		}
-		initRequest.Params.Capabilities = mcp.ClientCapabilities{}
-
-		_, err = client.Initialize(ctx, initRequest)
+		err = host.initializeMCPClient(name, client)
 		if err != nil {
 			client.Close()
 			for _, c := range clients {
@@ -435,14 +668,15 @@ func (host *ToolsHost) createMCPClients() error {
 			)
 		}
 
+		host.watchToolsListChanged(name, client)
 		clients[name] = client
 
 		if server.isMemoryServer() {
-			host.memoryServerName = name
+			host.setMemoryServer(name)
 			host.logger.Printf("Memory server connected %s\n", name)
 		}
 		if server.isRAGServer() {
-			host.ragServerName = name
+			host.setRAGServer(name)
 			host.logger.Printf("RAG server connected %s\n", name)
 		}
 
@@ -454,6 +688,138 @@ func (host *ToolsHost) createMCPClients() error {
 	return nil
 }
 
+// newMCPClient creates a client for the MCP server, the client is not started yet
+func (host *ToolsHost) newMCPClient(name string, server ServerConfigWrapper) (mcpclient.MCPClient, error) {
+	switch config := server.Config.(type) {
+	case SSEMCPServerConfig:
+		return host.newSSEClient(name, config)
+	case HTTPStreamingMCPServerConfig:
+		return host.newHTTPStreamingClient(name, config)
+	case InternalServerConfig:
+		return nil, fmt.Errorf("unknown internal server kind: %s", config.Kind)
+	case STDIOMCPServerConfig:
+		return host.newStdioClient(config)
+	default:
+		return nil, fmt.Errorf("server %s is not an MCP server", name)
+	}
+}
+
+// newHTTPStreamingClient creates a client for the HTTP streaming server
+func (host *ToolsHost) newHTTPStreamingClient(name string, httpConfig HTTPStreamingMCPServerConfig) (mcpclient.MCPClient, error) {
+	options := []transport.StreamableHTTPCOption{}
+
+	options = append(options, transport.WithHTTPHeaders(host.parseConfigHeaders(httpConfig.Headers)))
+	options = append(options, transport.WithContinuousListening())
+
+	httpClient, err := host.newTLSHTTPClient(name, httpConfig.TLSClientConfig)
+	if err != nil {
+		return nil, err
+	}
+	if httpClient != nil {
+		options = append(options, transport.WithHTTPBasicClient(httpClient))
+	}
+	return mcpclient.NewStreamableHttpClient(
+		httpConfig.Url,
+		options...,
+	)
+}
+
+// newSSEClient creates a client for the SSE server. It is used for the initial
+// connection and for reconnects after the SSE stream was dropped.
+func (host *ToolsHost) newSSEClient(name string, sseConfig SSEMCPServerConfig) (mcpclient.MCPClient, error) {
+	options := []transport.ClientOption{}
+
+	httpClient, err := host.newTLSHTTPClient(name, sseConfig.TLSClientConfig)
+	if err != nil {
+		return nil, err
+	}
+	if httpClient != nil {
+		options = append(options, transport.WithHTTPClient(httpClient))
+	}
+
+	options = append(options, transport.WithHeaders(host.parseConfigHeaders(sseConfig.Headers)))
+
+	return mcpclient.NewSSEMCPClient(
+		sseConfig.Url,
+		options...,
+	)
+}
+
+// newStdioClient starts the process of the STDIO server and creates a client for it.
+// It is used for the initial connection and for restarts after the process crashed.
+func (host *ToolsHost) newStdioClient(stdioConfig STDIOMCPServerConfig) (mcpclient.MCPClient, error) {
+	var env []string
+	for k, v := range stdioConfig.Env {
+		// Replace placeholders in environment variables
+		v = host.filterConfigValue(v)
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+	var stdioArgs []string
+	for _, arg := range stdioConfig.Args {
+		arg = host.filterConfigValue(arg)
+		stdioArgs = append(stdioArgs, arg)
+	}
+	options := []transport.StdioOption{}
+	if !stdioConfig.inheritsEnv() {
+		options = append(options, transport.WithCommandFunc(
+			func(ctx context.Context, command string, env []string, args []string) (*exec.Cmd, error) {
+				cmd := exec.CommandContext(ctx, command, args...)
+				// A nil Env would inherit the environment
+				cmd.Env = append([]string{}, env...)
+				return cmd, nil
+			}))
+	}
+	// The configured env is appended after the inherited one, so it wins on conflicts
+	return mcpclient.NewStdioMCPClientWithOptions(
+		stdioConfig.Command,
+		env,
+		stdioArgs,
+		options...)
+}
+
+// startMCPClient starts the transport of the client. Clients without the Start method are started already
+func startMCPClient(client mcpclient.MCPClient) error {
+	starter, ok := client.(interface{ Start(context.Context) error })
+	if !ok {
+		return nil
+	}
+	return starter.Start(context.Background())
+}
+
+// initializeMCPClient sends the initialize request to the started MCP client
+func (host *ToolsHost) initializeMCPClient(name string, client mcpclient.MCPClient) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	host.logger.Printf("Initializing server...%s\n", name)
+	initRequest := mcp.InitializeRequest{}
+	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	if config, _ := host.serverConfig(name); config.ProtocolVersion != "" {
+		initRequest.Params.ProtocolVersion = config.ProtocolVersion
+	}
+	initRequest.Params.ClientInfo = mcp.Implementation{
+		Name:    ThisAppName,
+		Version: ThisAppVersion,
+	}
+	initRequest.Params.Capabilities = mcp.ClientCapabilities{}
+
+	if host.isSamplingAllowed(name) {
+		// Must be set before initialization so the sampling capability is advertised
+		if mcpClient, ok := client.(*mcpclient.Client); ok {
+			mcpclient.WithSamplingHandler(&samplingHandler{host: host, serverName: name})(mcpClient)
+		} else {
+			host.logger.Printf("Sampling is not supported by the client of server %s, it is disabled\n", name)
+		}
+	}
+
+	result, err := client.Initialize(ctx, initRequest)
+	if err != nil {
+		return err
+	}
+	host.logger.Printf("Server %s uses MCP protocol version %s\n", name, result.ProtocolVersion)
+	return nil
+}
+
 func (host *ToolsHost) createA2AClients() error {
 	clients := make(map[string]A2AAgent)
 
@@ -467,25 +833,19 @@ func (host *ToolsHost) createA2AClients() error {
 			continue
 		}
 
-		config := server.Config.(A2AToolsServerConfig)
-
-		agent, err := NewA2AAgent(config.Endpoint, config.Metadata, host.logger)
+		agent, err := host.newA2AAgent(server.Config.(A2AToolsServerConfig))
 		if err != nil {
 			return fmt.Errorf("failed to fetch agent card for %s: %w", name, err)
 		}
 
-		agent.filterFunc = host.filterConfigValue
-		agent.HostingAgentID = host.AgentID
-		agent.HostingAgentTitle = host.AgentName
-
 		clients[name] = *agent
 
 		if server.isMemoryServer() {
-			host.memoryServerName = name
+			host.setMemoryServer(name)
 			host.logger.Printf("Memory server connected %s\n", name)
 		}
 		if server.isRAGServer() {
-			host.ragServerName = name
+			host.setRAGServer(name)
 			host.logger.Printf("RAG server connected %s\n", name)
 		}
 
@@ -497,15 +857,82 @@ func (host *ToolsHost) createA2AClients() error {
 	return nil
 }
 
+// newA2AAgent fetches the agent card of the A2A server and creates the client for it
+func (host *ToolsHost) newA2AAgent(config A2AToolsServerConfig) (*A2AAgent, error) {
+	agent, err := NewA2AAgentWithOptions(config.Endpoint, config.Metadata, A2AAgentOptions{
+		Headers:     host.parseConfigHeaders(config.Headers),
+		CardTimeout: time.Duration(config.CardTimeout) * time.Second,
+	}, host.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	agent.filterFunc = host.filterConfigValue
+	if config.TaskPollInterval > 0 {
+		agent.TaskPollInterval = time.Duration(config.TaskPollInterval) * time.Millisecond
+	}
+	if config.TaskPollMaxAttempts > 0 {
+		agent.TaskPollMaxAttempts = config.TaskPollMaxAttempts
+	}
+	agent.HostingAgentID = host.AgentID
+	agent.HostingAgentTitle = host.AgentName
+	return agent, nil
+}
+
 // Check if the host has a RAG server connected
 func (host *ToolsHost) HasRagServer() bool {
-	return host.ragServerName != ""
+	return host.ragServer() != ""
+}
+
+// Check if the host has a memory server connected
+func (host *ToolsHost) HasMemoryServer() bool {
+	return host.memoryServer() != ""
 }
+
+// memoryServer returns the name of the memory server, empty if there is none.
+// It changes when the config is applied, so it is read under configMux
+func (host *ToolsHost) memoryServer() string {
+	host.configMux.RLock()
+	defer host.configMux.RUnlock()
+	return host.memoryServerName
+}
+
+func (host *ToolsHost) setMemoryServer(name string) {
+	host.configMux.Lock()
+	defer host.configMux.Unlock()
+	host.memoryServerName = name
+}
+
+// ragServer returns the name of the RAG server, empty if there is none
+func (host *ToolsHost) ragServer() string {
+	host.configMux.RLock()
+	defer host.configMux.RUnlock()
+	return host.ragServerName
+}
+
+func (host *ToolsHost) setRAGServer(name string) {
+	host.configMux.Lock()
+	defer host.configMux.Unlock()
+	host.ragServerName = name
+}
+
 func (host *ToolsHost) Close() error {
+	// Queued messages must reach the memory server before its client is closed
+	if queue := host.getMemoryQueue(false); queue != nil {
+		if lost := queue.flush(memoryQueueFlushTimeout); lost > 0 {
+			host.logger.Printf("Memory server did not receive %d queued messages before closing\n", lost)
+		}
+	}
+
 	if host.fileCache != nil {
 		host.fileCache.Cleanup()
 	}
 
+	host.stopReconnectMonitors()
+
+	host.mcpClientsMux.RLock()
+	defer host.mcpClientsMux.RUnlock()
+
 	errors := []error{}
 	for _, client := range host.mcpClients {
 		err := client.Close()
@@ -523,16 +950,7 @@ func (host *ToolsHost) Close() error {
 func (host *ToolsHost) loadMCPTools(ctx context.Context) error {
 	var allTools []llm.Tool
 	for serverName, mcpClient := range host.mcpClients {
-		config, ok := host.config[serverName]
-
-		if !ok {
-			host.logger.Printf("Server %s not found in config\n", serverName)
-			continue
-		}
-		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-		toolsResult, err := mcpClient.ListTools(ctx, mcp.ListToolsRequest{})
-		cancel()
-
+		serverTools, err := host.listMCPServerTools(ctx, serverName, mcpClient)
 		if err != nil {
 			host.logger.Printf(
 				"Error fetching tools from server %s: %v\n",
@@ -541,81 +959,68 @@ func (host *ToolsHost) loadMCPTools(ctx context.Context) error {
 			)
 			continue
 		}
+		allTools = append(allTools, serverTools...)
+	}
+	host.tools = append(host.tools, allTools...)
+	return nil
+}
 
-		filteredTools := []mcp.Tool{}
+// listMCPServerTools loads tools of one MCP server and converts them to the LLM format
+func (host *ToolsHost) listMCPServerTools(ctx context.Context, serverName string, mcpClient mcpclient.MCPClient) ([]llm.Tool, error) {
+	config, ok := host.serverConfig(serverName)
 
-		for _, tool := range toolsResult.Tools {
-			if config.isMemoryServer() {
-				// Ignore memory-related tools
-				if tool.Name == memoryToolRememberName ||
-					tool.Name == memoryToolRecallName {
-					continue
-				}
+	if !ok {
+		return nil, fmt.Errorf("server %s not found in config", serverName)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	toolsResult, err := mcpClient.ListTools(ctx, mcp.ListToolsRequest{})
+	cancel()
+
+	if err != nil {
+		return nil, err
+	}
+
+	filteredTools := []mcp.Tool{}
+
+	for _, tool := range toolsResult.Tools {
+		if config.isMemoryServer() {
+			// Ignore memory-related tools
+			if tool.Name == memoryToolForgetName {
+				host.memoryForgetSupported.Store(true)
 			}
-			if config.isRAGServer() {
-				// Ignore RAG-related tools
-				if tool.Name == ragToolName {
-					continue
-				}
+			if tool.Name == memoryToolRememberName ||
+				tool.Name == memoryToolRecallName ||
+				tool.Name == memoryToolForgetName {
+				continue
 			}
-			host.logger.Printf("Tool %s loaded from server %s\n", tool.Name, serverName)
-			filteredTools = append(filteredTools, tool)
 		}
-
-		serverTools := host.mcpToolsToAnthropicTools(serverName, filteredTools)
-		allTools = append(allTools, serverTools...)
-
-		host.logger.Printf(
-			"Tools loaded from server %s: %d tools\n",
-			serverName,
-			len(filteredTools),
-		)
+		if config.isRAGServer() {
+			// Ignore RAG-related tools
+			if tool.Name == ragToolName {
+				continue
+			}
+		}
+		host.logger.Printf("Tool %s loaded from server %s\n", tool.Name, serverName)
+		filteredTools = append(filteredTools, tool)
 	}
-	host.tools = append(host.tools, allTools...)
-	return nil
+
+	host.logger.Printf(
+		"Tools loaded from server %s: %d tools\n",
+		serverName,
+		len(filteredTools),
+	)
+	return host.mcpToolsToAnthropicTools(serverName, filteredTools), nil
 }
 
 func (host *ToolsHost) loadA2ATools() error {
 	var allTools []llm.Tool
 	for serverName, a2aClient := range host.a2aClients {
-		config, ok := host.config[serverName]
-
-		if !ok {
+		if _, ok := host.config[serverName]; !ok {
 			host.logger.Printf("Server %s not found in config\n", serverName)
 			continue
 		}
 
-		serverTools := []llm.Tool{}
-
-		for _, a2aSkill := range a2aClient.Card.Skills {
-			if config.isMemoryServer() {
-				// Ignore memory-related tools
-				if a2aSkill.ID == memoryToolRememberName ||
-					a2aSkill.ID == memoryToolRecallName {
-					continue
-				}
-			}
-			if config.isRAGServer() {
-				// Ignore RAG-related tools
-				if a2aSkill.ID == ragToolName {
-					continue
-				}
-			}
-			tool := llm.Tool{
-				Name:        fmt.Sprintf("%s__%s", serverName, a2aSkill.ID),
-				Description: a2aSkill.Name + "\n" + a2aSkill.Description,
-				InputSchema: llm.Schema{
-					Type: "object",
-					Properties: map[string]any{
-						"message": map[string]any{
-							"description": a2aSkill.Name + ". " + a2aSkill.Description,
-						},
-					},
-				},
-			}
-			serverTools = append(serverTools, tool)
-		}
-
+		serverTools := host.a2aServerTools(serverName, a2aClient.Card)
 		allTools = append(allTools, serverTools...)
 
 		host.logger.Printf(
@@ -628,20 +1033,137 @@ func (host *ToolsHost) loadA2ATools() error {
 	return nil
 }
 
-func (host *ToolsHost) callTool(serverName string, toolName string, toolArgs map[string]interface{}, ctx context.Context) ToolCallResult {
+// a2aServerTools converts the skills of the agent to tools
+func (host *ToolsHost) a2aServerTools(serverName string, card AgentCard) []llm.Tool {
+	config, _ := host.serverConfig(serverName)
+	serverTools := []llm.Tool{}
+
+	for _, a2aSkill := range card.Skills {
+		if config.isMemoryServer() {
+			// Ignore memory-related tools
+			if a2aSkill.ID == memoryToolForgetName {
+				host.memoryForgetSupported.Store(true)
+			}
+			if a2aSkill.ID == memoryToolRememberName ||
+				a2aSkill.ID == memoryToolRecallName ||
+				a2aSkill.ID == memoryToolForgetName {
+				continue
+			}
+		}
+		if config.isRAGServer() {
+			// Ignore RAG-related tools
+			if a2aSkill.ID == ragToolName {
+				continue
+			}
+		}
+		tool := llm.Tool{
+			Name:        fmt.Sprintf("%s__%s", serverName, a2aSkill.ID),
+			Description: a2aSkill.Name + "\n" + a2aSkill.Description,
+			InputSchema: llm.Schema{
+				Type: "object",
+				Properties: map[string]any{
+					"message": map[string]any{
+						"description": a2aSkill.Name + ". " + a2aSkill.Description,
+					},
+				},
+			},
+		}
+		serverTools = append(serverTools, tool)
+	}
+	return serverTools
+}
+
+func (host *ToolsHost) callTool(serverName string, toolName string, toolArgs map[string]interface{}, ctx context.Context) (result ToolCallResult) {
+	started := time.Now()
+	defer func() {
+		host.toolStats.record(serverName+"__"+toolName, time.Since(started), result.Error != nil)
+	}()
+
 	// Resolve any cached file references in tool arguments
 	if host.fileCache != nil {
 		host.fileCache.ResolveFileArgs(toolArgs)
 	}
+
+	server, ok := host.serverConfig(serverName)
+
+	if !server.SkipArgsValidation {
+		if schema, found := host.findToolSchema(serverName, toolName); found {
+			if err := validateToolArgs(schema, toolArgs); err != nil {
+				host.logger.Printf("%sTool %s__%s called with invalid arguments: %v", logPrefix(ctx), serverName, toolName, err)
+				return ToolCallResult{
+					Error: err,
+				}
+			}
+		}
+	}
+
+	if !ok || !server.isToolCacheable(toolName) || host.toolCache == nil {
+		return host.dispatchToolCall(serverName, toolName, toolArgs, ctx)
+	}
+
+	cacheKey, err := toolCacheKey(serverName, toolName, toolArgs)
+	if err != nil {
+		host.logger.Printf("%sFailed to build cache key for tool %s__%s: %v", logPrefix(ctx), serverName, toolName, err)
+		return host.dispatchToolCall(serverName, toolName, toolArgs, ctx)
+	}
+	if ctx.Err() != nil {
+		return ToolCallResult{
+			Error: fmt.Errorf("tool call cancelled: %w", ctx.Err()),
+		}
+	}
+	if cached, found := host.toolCache.Get(cacheKey); found {
+		if host.debugMode {
+			host.logger.Printf("%sTool cache hit for %s__%s", logPrefix(ctx), serverName, toolName)
+		}
+		return cached
+	}
+
+	result = host.dispatchToolCall(serverName, toolName, toolArgs, ctx)
+
+	if result.Error == nil {
+		host.toolCache.Set(cacheKey, result, time.Duration(server.CacheTTL)*time.Second)
+	}
+	return result
+}
+
+// dispatchToolCall calls the tool, limiting the call by the server timeout if it is configured.
+// The result is sanitized before it is cached or added to the history.
+func (host *ToolsHost) dispatchToolCall(serverName string, toolName string, toolArgs map[string]interface{}, ctx context.Context) ToolCallResult {
+	server, _ := host.serverConfig(serverName)
+	if server.Timeout <= 0 {
+		return host.sanitizeToolResult(host.routeToolCall(serverName, toolName, toolArgs, ctx))
+	}
+
+	timeout := time.Duration(server.Timeout) * time.Second
+	toolCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result := host.routeToolCall(serverName, toolName, toolArgs, toolCtx)
+
+	// The deadline of the tool context is reached but the caller did not cancel
+	if result.Error != nil && toolCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		host.logger.Printf("%sTool %s__%s timed out after %s", logPrefix(ctx), serverName, toolName, timeout)
+		return ToolCallResult{
+			Error: &ToolTimeoutError{
+				ToolName: serverName + "__" + toolName,
+				Timeout:  timeout,
+			},
+		}
+	}
+	return host.sanitizeToolResult(result)
+}
+
+// routeToolCall routes the tool call to the client of the server type
+func (host *ToolsHost) routeToolCall(serverName string, toolName string, toolArgs map[string]interface{}, ctx context.Context) ToolCallResult {
 	if host.isMCPServer(serverName) {
 		return host.callMCPTool(serverName, toolName, toolArgs, ctx)
 	}
 	if host.isA2AServer(serverName) {
-		if agentCard, ok := host.a2aClients[serverName]; ok {
+		if agentCard, ok := host.getA2AClient(serverName); ok {
 			return agentCard.sendMessage(toolName, toolArgs, ctx)
 		}
 		return ToolCallResult{
-			Error: fmt.Errorf("A2A server %s not found", serverName),
+			Error: fmt.Errorf("%w: A2A server %s not found", ErrServerUnavailable, serverName),
 		}
 	}
 	if host.isReverseMCPServer(serverName) {
@@ -651,7 +1173,7 @@ func (host *ToolsHost) callTool(serverName string, toolName string, toolArgs map
 		return host.callCustomTool(toolName, toolArgs, ctx)
 	}
 	return ToolCallResult{
-		Error: fmt.Errorf("server %s is not a valid MCP, A2A, reverse MCP, or custom tool server", serverName),
+		Error: fmt.Errorf("%w: server %s is not a valid MCP, A2A, reverse MCP, or custom tool server", ErrToolNotFound, serverName),
 	}
 }
 
@@ -659,11 +1181,11 @@ func (host *ToolsHost) callTool(serverName string, toolName string, toolArgs map
 func (host *ToolsHost) callReverseMCPTool(serverName string, toolName string, toolArgs map[string]interface{}, ctx context.Context) ToolCallResult {
 	if host.reverseMCPClient == nil {
 		return ToolCallResult{
-			Error: fmt.Errorf("reverse MCP client not configured"),
+			Error: fmt.Errorf("%w: reverse MCP client not configured", ErrServerUnavailable),
 		}
 	}
 
-	host.logger.Printf("Calling tool %s on reverse MCP server %s", toolName, serverName)
+	host.logger.Printf("%sCalling tool %s on reverse MCP server %s", logPrefix(ctx), toolName, serverName)
 
 	result, err := host.reverseMCPClient.CallTool(serverName, toolName, toolArgs, ctx)
 	if err != nil {
@@ -675,10 +1197,15 @@ func (host *ToolsHost) callReverseMCPTool(serverName string, toolName string, to
 }
 
 func (host *ToolsHost) callMCPTool(serverName string, toolName string, toolArgs map[string]interface{}, ctx context.Context) ToolCallResult {
-	mcpClient, ok := host.mcpClients[serverName]
+	mcpClient, ok := host.getMCPClient(serverName)
 	if !ok {
 		return ToolCallResult{
-			Error: fmt.Errorf("server %s not found", serverName),
+			Error: fmt.Errorf("%w: server %s not found", ErrServerUnavailable, serverName),
+		}
+	}
+	if host.isReconnecting(serverName) {
+		return ToolCallResult{
+			Error: fmt.Errorf("%w: server %s is reconnecting", ErrServerUnavailable, serverName),
 		}
 	}
 
@@ -691,7 +1218,8 @@ func (host *ToolsHost) callMCPTool(serverName string, toolName string, toolArgs
 		req.Params.Arguments = toolArgs
 
 		host.logger.Printf(
-			"Tool %s called on server %s. Waiting response\n",
+			"%sTool %s called on server %s. Waiting response\n",
+			logPrefix(ctx),
 			toolName,
 			serverName,
 		)
@@ -700,7 +1228,8 @@ func (host *ToolsHost) callMCPTool(serverName string, toolName string, toolArgs
 			req,
 		)
 		host.logger.Printf(
-			"Response received for tool %s on server %s\n",
+			"%sResponse received for tool %s on server %s\n",
+			logPrefix(ctx),
 			toolName,
 			serverName,
 		)
@@ -734,7 +1263,11 @@ func (host *ToolsHost) callMCPTool(serverName string, toolName string, toolArgs
 					}
 				}
 			}
-			result.validateNotEmpty()
+			if toolResult.IsError {
+				result.Error = fmt.Errorf("%w: %s", ErrToolReported, result.getTextContent())
+			} else {
+				result.validateNotEmpty()
+			}
 		}
 		resultCh <- result
 
@@ -754,7 +1287,7 @@ func (host *ToolsHost) callMCPTool(serverName string, toolName string, toolArgs
 
 func (host *ToolsHost) getServersInfo() []ServerInfo {
 	var servers []ServerInfo
-	for name, server := range host.config {
+	for name, server := range host.currentConfig() {
 		switch server.Config.(type) {
 		case STDIOMCPServerConfig:
 			stdioServer := server.Config.(STDIOMCPServerConfig)
@@ -797,8 +1330,16 @@ func (host *ToolsHost) getServersInfo() []ServerInfo {
 				Command:   internalServer.Kind,
 			})
 		case ReverseMCPServerConfig:
-			if host.reverseMCPClient != nil {
-				tools := host.reverseMCPClient.GetTools(name)
+			if host.reverseMCPClient == nil {
+				continue
+			}
+			names := []string{name}
+			if isReverseMCPPattern(name) {
+				// Every connected server matching the pattern is listed by its own name
+				names = host.reverseMCPServersOfPattern(name)
+			}
+			for _, serverName := range names {
+				tools := host.reverseMCPClient.GetTools(serverName)
 				if len(tools) > 0 {
 					toolInfos := make([]ServerToolInfo, len(tools))
 					for i, tool := range tools {
@@ -808,7 +1349,7 @@ func (host *ToolsHost) getServersInfo() []ServerInfo {
 						}
 					}
 					servers = append(servers, ServerInfo{
-						Name:      name,
+						Name:      serverName,
 						Transport: transportReverseMCP,
 						Tools:     toolInfos,
 					})
@@ -822,6 +1363,17 @@ func (host *ToolsHost) getServersInfo() []ServerInfo {
 	return servers
 }
 
+// GetToolStats returns the number of calls, failures and the average latency of every called tool,
+// the most used tools first
+func (host *ToolsHost) GetToolStats() []ToolStats {
+	return host.toolStats.snapshot()
+}
+
+// ResetToolStats clears the tool calls statistics
+func (host *ToolsHost) ResetToolStats() {
+	host.toolStats.reset()
+}
+
 func (host *ToolsHost) getToolsInfo() []ServerInfo {
 	servers := host.getServersInfo()
 	for i, server := range servers {
@@ -831,7 +1383,7 @@ func (host *ToolsHost) getToolsInfo() []ServerInfo {
 			continue
 		}
 
-		mcpClient := host.mcpClients[server.Name]
+		mcpClient, _ := host.getMCPClient(server.Name)
 		if mcpClient == nil {
 			servers[i].Err = fmt.Errorf("no MCP client available")
 			continue
@@ -870,32 +1422,83 @@ func (host *ToolsHost) getToolsInfo() []ServerInfo {
 }
 
 // if there is a memory MCP server, then it should be used. Send the messages to it
-// this is async, so the messages are not sent immediately
-func (host *ToolsHost) Remember(role string, content history.ContentBlock, ctx context.Context) {
-	if host.memoryServerName == "" {
+// this is async, the messages are queued and sent in order in the background.
+// When the queue is full the message is dropped with a warning.
+// The metadata (timestamp, source, tags) is passed to the remember tool as additional arguments,
+// so the memory server can filter memories on recall. It can be nil
+func (host *ToolsHost) Remember(role string, content history.ContentBlock, ctx context.Context, metadata map[string]interface{}) {
+	if host.memoryServer() == "" {
 		return
 	}
 	if content.Type != "text" {
 		return
 	}
+	// The message is sent after the turn, it must not be cancelled with the prompt
+	write := memoryWrite{role: role, text: content.Text, metadata: metadata, ctx: context.WithoutCancel(ctx)}
+	if !host.getMemoryQueue(true).add(write) {
+		host.logger.Printf("%sWarning: memory queue is full, the %s message is not remembered\n", logPrefix(ctx), role)
+	}
+}
+
+// MemoryQueueStats returns the state of the queue of messages to remember
+func (host *ToolsHost) MemoryQueueStats() MemoryQueueStats {
+	queue := host.getMemoryQueue(false)
+	if queue == nil {
+		return MemoryQueueStats{}
+	}
+	return queue.stats()
+}
+
+// getMemoryQueue returns the queue of messages to remember, creating it if create is true.
+// It returns nil if the queue is not created yet
+func (host *ToolsHost) getMemoryQueue(create bool) *memoryQueue {
+	host.memoryQueueMux.Lock()
+	defer host.memoryQueueMux.Unlock()
+	if host.memoryQueue == nil && create {
+		host.memoryQueue = newMemoryQueue(host.memoryQueueSize, host.rememberNow)
+	}
+	return host.memoryQueue
+}
+
+// rememberNow sends the message to the memory server
+func (host *ToolsHost) rememberNow(write memoryWrite) {
 	host.logger.Printf(
-		"Remembering message: %s %s\n",
-		role,
-		content.Text,
+		"%sRemembering message: %s %s\n",
+		logPrefix(write.ctx),
+		write.role,
+		write.text,
 	)
+	ctx, cancel := context.WithTimeout(write.ctx, memoryWriteTimeout)
+	defer cancel()
+
+	// The metadata is sent as additional arguments, memory servers not knowing them ignore them
+	args := map[string]interface{}{
+		"role":     write.role,
+		"contents": write.text,
+	}
+	for key, value := range write.metadata {
+		if _, reserved := args[key]; !reserved {
+			args[key] = value
+		}
+	}
+
+	// The memory server can be removed from the config while the message is queued
+	memoryServer := host.memoryServer()
+	if memoryServer == "" {
+		return
+	}
+
 	// call the memory server to remember the messages
 	res := host.callTool(
-		host.memoryServerName,
+		memoryServer,
 		memoryToolRememberName,
-		map[string]interface{}{
-			"role":     role,
-			"contents": content.Text,
-		},
+		args,
 		ctx,
 	)
 	if res.Error != nil {
 		host.logger.Printf(
-			"Error remembering message: %v\n",
+			"%sError remembering message: %v\n",
+			logPrefix(ctx),
 			res.Error,
 		)
 		return
@@ -904,13 +1507,14 @@ func (host *ToolsHost) Remember(role string, content history.ContentBlock, ctx c
 
 // requests the memory server to recall the messages
 func (host *ToolsHost) Recall(ctx context.Context, prompt string) (string, error) {
-	if host.memoryServerName == "" {
+	memoryServer := host.memoryServer()
+	if memoryServer == "" {
 		return "", nil
 	}
 
 	// call the memory server to recall the messages
 	res := host.callTool(
-		host.memoryServerName,
+		memoryServer,
 		memoryToolRecallName,
 		map[string]interface{}{
 			"query": prompt,
@@ -919,7 +1523,8 @@ func (host *ToolsHost) Recall(ctx context.Context, prompt string) (string, error
 	)
 	if res.Error != nil {
 		host.logger.Printf(
-			"Error recalling messages: %v\n",
+			"%sError recalling messages: %v\n",
+			logPrefix(ctx),
 			res.Error,
 		)
 		return "", res.Error
@@ -934,15 +1539,56 @@ func (host *ToolsHost) Recall(ctx context.Context, prompt string) (string, error
 	return resultText, nil
 }
 
+// Forget requests the memory server to delete the memories matching the query. The messages
+// waiting in the memory queue are sent first, so the facts just told are forgotten too.
+// ErrForgetNotSupported is returned when there is no memory server or it can not forget
+func (host *ToolsHost) Forget(ctx context.Context, query string) (string, error) {
+	memoryServer := host.memoryServer()
+	if memoryServer == "" {
+		return "", fmt.Errorf("%w: no memory server is configured", ErrForgetNotSupported)
+	}
+	if !host.memoryForgetSupported.Load() {
+		return "", fmt.Errorf("%w: the memory server %s has no %s tool", ErrForgetNotSupported, memoryServer, memoryToolForgetName)
+	}
+
+	if queue := host.getMemoryQueue(false); queue != nil {
+		drainCtx, cancel := context.WithTimeout(ctx, memoryQueueFlushTimeout)
+		err := queue.drain(drainCtx)
+		cancel()
+		if err != nil {
+			host.logger.Printf("%sMemory queue is not drained before forgetting: %v\n", logPrefix(ctx), err)
+		}
+	}
+
+	res := host.callTool(
+		memoryServer,
+		memoryToolForgetName,
+		map[string]interface{}{
+			"query": query,
+		},
+		ctx,
+	)
+	if res.Error != nil {
+		host.logger.Printf(
+			"%sError forgetting memories: %v\n",
+			logPrefix(ctx),
+			res.Error,
+		)
+		return "", res.Error
+	}
+	return res.getTextContent(), nil
+}
+
 // requests the memory server to recall the messages
 func (host *ToolsHost) GetRAGContext(ctx context.Context, prompt string) ([]string, error) {
-	if host.ragServerName == "" {
+	ragServer := host.ragServer()
+	if ragServer == "" {
 		return []string{}, nil
 	}
 
 	// call the memory server to recall the messages
 	res := host.callTool(
-		host.ragServerName,
+		ragServer,
 		ragToolName,
 		map[string]interface{}{
 			"query": prompt,
@@ -952,7 +1598,8 @@ func (host *ToolsHost) GetRAGContext(ctx context.Context, prompt string) ([]stri
 	)
 	if res.Error != nil {
 		host.logger.Printf(
-			"Error calling RAG server: %v\n",
+			"%sError calling RAG server: %v\n",
+			logPrefix(ctx),
 			res.Error,
 		)
 		return []string{}, res.Error
@@ -3,11 +3,14 @@ package core
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gelembjuk/cleverchatty/core/history"
@@ -23,6 +26,100 @@ type A2AAgent struct {
 	Logger            *log.Logger
 	Metadata          map[string]string
 	filterFunc        func(value string) string
+	conn              *a2aClientConn // Shared by copies of the agent
+	options           A2AAgentOptions
+	cardValidators    a2aCardValidators // Of the card response, to request the card only if it changed
+	// Checks of a task that is not finished in the response to the message
+	TaskPollInterval    time.Duration
+	TaskPollMaxAttempts int
+}
+
+// Defaults of the checks of an unfinished task
+const (
+	defaultA2ATaskPollInterval    = 1 * time.Second
+	defaultA2ATaskPollMaxAttempts = 5
+)
+
+// defaultA2ACardTimeout limits the request of the agent card
+const defaultA2ACardTimeout = 10 * time.Second
+
+// A2AAgentOptions are the settings of the connection to an A2A agent
+type A2AAgentOptions struct {
+	Headers     map[string]string // Sent with every request, for example the Authorization header
+	CardTimeout time.Duration     // 0 means the default
+}
+
+// a2aClientConn keeps the A2A client of an agent, so tool calls reuse its connections
+type a2aClientConn struct {
+	mux       sync.Mutex
+	endpoint  string
+	headers   map[string]string
+	client    *a2aclient.A2AClient
+	transport *http.Transport
+}
+
+// a2aHeadersTransport adds the configured headers to every request to an agent
+type a2aHeadersTransport struct {
+	base    http.RoundTripper
+	headers map[string]string
+}
+
+func (t *a2aHeadersTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(t.headers) == 0 {
+		return t.base.RoundTrip(req)
+	}
+	// A round tripper must not modify the request
+	req = req.Clone(req.Context())
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// get returns the client of the agent, it is created on the first call or after reset
+func (c *a2aClientConn) get() (*a2aclient.A2AClient, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if c.client != nil {
+		return c.client, nil
+	}
+	// Own transport, so the connections of a stale client can be closed
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	httpClient := &http.Client{
+		Transport: &a2aHeadersTransport{base: transport, headers: c.headers},
+		Timeout:   a2aClientTimeout,
+	}
+	client, err := a2aclient.NewA2AClient(c.endpoint, a2aclient.WithHTTPClient(httpClient))
+	if err != nil {
+		return nil, err
+	}
+	c.client = client
+	c.transport = transport
+	return client, nil
+}
+
+// reset drops the client after a connection failure, the next call creates a new one
+func (c *a2aClientConn) reset(client *a2aclient.A2AClient) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	// Another call may have replaced the client already
+	if c.client != client {
+		return
+	}
+	c.transport.CloseIdleConnections()
+	c.client = nil
+	c.transport = nil
+}
+
+// a2aClientTimeout limits a request to an A2A agent, it is the default of the A2A client
+const a2aClientTimeout = 60 * time.Second
+
+// isA2AConnectionError returns true if the request failed on the HTTP level, not with an error of the agent
+func isA2AConnectionError(err error) bool {
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
 }
 
 // AgentCard represents the structure of the A2A agent.json
@@ -54,34 +151,72 @@ type Skill struct {
 	OutputModes []string `json:"outputModes"`
 }
 
-// fetchAgentCard fetches and parses the agent.json from baseURL
-func fetchA2AAgentCard(baseURL string) (*AgentCard, error) {
+// a2aCardValidators are the cache validators of the agent card response
+type a2aCardValidators struct {
+	etag         string
+	lastModified string
+}
+
+// fetchA2AAgentCard fetches and parses the agent.json from baseURL. With the validators of
+// a cached card the card is requested only if it changed, it is nil if it is not modified
+func fetchA2AAgentCard(baseURL string, options A2AAgentOptions, cached a2aCardValidators) (*AgentCard, a2aCardValidators, error) {
 	url := strings.TrimRight(baseURL, "/") + "/.well-known/agent.json"
 
-	resp, err := http.Get(url)
+	timeout := options.CardTimeout
+	if timeout <= 0 {
+		timeout = defaultA2ACardTimeout
+	}
+	client := &http.Client{
+		Transport: &a2aHeadersTransport{base: http.DefaultTransport, headers: options.Headers},
+		Timeout:   timeout,
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch agent card: %w", err)
+		return nil, cached, err
+	}
+	if cached.etag != "" {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+	if cached.lastModified != "" {
+		req.Header.Set("If-Modified-Since", cached.lastModified)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, cached, fmt.Errorf("failed to fetch agent card: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, cached, nil
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, url)
+		return nil, cached, fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, url)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, cached, fmt.Errorf("failed to read response body: %w", err)
 	}
 
 	var card AgentCard
 	if err := json.Unmarshal(body, &card); err != nil {
-		return nil, fmt.Errorf("failed to parse agent card JSON: %w", err)
+		return nil, cached, fmt.Errorf("failed to parse agent card JSON: %w", err)
+	}
+	validators := a2aCardValidators{
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
 	}
-	return &card, nil
+	return &card, validators, nil
 }
 
 func NewA2AAgent(endpoint string, metadata map[string]string, logger *log.Logger) (*A2AAgent, error) {
-	card, err := fetchA2AAgentCard(endpoint)
+	return NewA2AAgentWithOptions(endpoint, metadata, A2AAgentOptions{}, logger)
+}
+
+// NewA2AAgentWithOptions is like NewA2AAgent, the agent card and messages are requested
+// with the connection options, for example for an agent requiring authentication
+func NewA2AAgentWithOptions(endpoint string, metadata map[string]string, options A2AAgentOptions, logger *log.Logger) (*A2AAgent, error) {
+	card, validators, err := fetchA2AAgentCard(endpoint, options, a2aCardValidators{})
 	if err != nil {
 		return nil, fmt.Errorf("error fetching agent card: %v", err)
 	}
@@ -99,13 +234,20 @@ func NewA2AAgent(endpoint string, metadata map[string]string, logger *log.Logger
 		Card:     *card,
 		Logger:   logger,
 		Metadata: metadata,
+		conn:     &a2aClientConn{endpoint: endpoint, headers: options.Headers},
+		options:  options,
+
+		cardValidators: validators,
+
+		TaskPollInterval:    defaultA2ATaskPollInterval,
+		TaskPollMaxAttempts: defaultA2ATaskPollMaxAttempts,
 	}
 
 	return a2aAgent, nil
 }
 
 func (a *A2AAgent) sendMessage(skill string, toolArgs map[string]interface{}, ctx context.Context) ToolCallResult {
-	a2aClient, err := a2aclient.NewA2AClient(a.Endpoint)
+	a2aClient, err := a.conn.get()
 	if err != nil {
 		return ToolCallResult{Error: fmt.Errorf("error creating A2A client: %v", err)}
 	}
@@ -153,6 +295,10 @@ func (a *A2AAgent) sendMessage(skill string, toolArgs map[string]interface{}, ct
 
 	messageResult, err := a2aClient.SendMessage(ctx, taskParams)
 	if err != nil {
+		if isA2AConnectionError(err) && ctx.Err() == nil {
+			a.Logger.Printf("Connection to A2A agent %s failed, the client will be recreated: %v", a.Endpoint, err)
+			a.conn.reset(a2aClient)
+		}
 		return ToolCallResult{Error: fmt.Errorf("error starting task stream: %v", err)}
 	}
 
@@ -166,42 +312,15 @@ func (a *A2AAgent) sendMessage(skill string, toolArgs map[string]interface{}, ct
 		return a.buildResponseFromMessage(*result)
 	case *a2aprotocol.Task:
 		a.Logger.Printf("Received task response - ID: %s, State: %s", result.ID, result.Status.State)
-		if result.Status.State == a2aprotocol.TaskStateCompleted ||
-			result.Status.State == a2aprotocol.TaskStateFailed ||
-			result.Status.State == a2aprotocol.TaskStateCanceled {
+		if isA2ATaskFinished(result) {
 			return a.buildResponseFromTask(result)
 		}
 
 		a.Logger.Printf("Task %s is %s, fetching final state...", result.ID, result.Status.State)
 
-		// Get the task's final state.
-		queryParams := a2aprotocol.TaskQueryParams{
-			ID: result.ID,
-		}
-		var task *a2aprotocol.Task
-		attemptCount := 0
-		maxAttempts := 5
-		for {
-			// Give the server some time to process.
-			time.Sleep(1 * time.Second)
-
-			task, err := a2aClient.GetTasks(ctx, queryParams)
-			if err != nil {
-				a.Logger.Printf("Failed to get task status: %v", err)
-			}
-
-			a.Logger.Printf("Task %s final state: %s", task.ID, task.Status.State)
-
-			if task.Status.State == a2aprotocol.TaskStateCompleted ||
-				task.Status.State == a2aprotocol.TaskStateFailed ||
-				task.Status.State == a2aprotocol.TaskStateCanceled {
-				break // Exit loop if task is in a terminal state
-			}
-			attemptCount++
-			if attemptCount >= maxAttempts {
-				a.Logger.Printf("Max attempts reached (%d), exiting.", maxAttempts)
-				break
-			}
+		task, err := a.waitForTask(ctx, a2aClient, result)
+		if err != nil {
+			return ToolCallResult{Error: err}
 		}
 		return a.buildResponseFromTask(task)
 	default:
@@ -212,6 +331,47 @@ func (a *A2AAgent) sendMessage(skill string, toolArgs map[string]interface{}, ct
 	}
 }
 
+// isA2ATaskFinished returns true if the task is in a terminal state
+func isA2ATaskFinished(task *a2aprotocol.Task) bool {
+	return task.Status.State == a2aprotocol.TaskStateCompleted ||
+		task.Status.State == a2aprotocol.TaskStateFailed ||
+		task.Status.State == a2aprotocol.TaskStateCanceled
+}
+
+// waitForTask polls the task until it is finished. It is an error if the task is
+// still not finished when the attempts are over
+func (a *A2AAgent) waitForTask(ctx context.Context, a2aClient *a2aclient.A2AClient, task *a2aprotocol.Task) (*a2aprotocol.Task, error) {
+	queryParams := a2aprotocol.TaskQueryParams{
+		ID: task.ID,
+	}
+	for attempt := 1; attempt <= a.TaskPollMaxAttempts; attempt++ {
+		// Give the server some time to process.
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(a.TaskPollInterval):
+		}
+
+		polled, err := a2aClient.GetTasks(ctx, queryParams)
+		if err != nil {
+			a.Logger.Printf("Failed to get task status: %v", err)
+			continue
+		}
+		if polled == nil {
+			a.Logger.Printf("Empty status of task %s", task.ID)
+			continue
+		}
+		task = polled
+		a.Logger.Printf("Task %s state: %s", task.ID, task.Status.State)
+
+		if isA2ATaskFinished(task) {
+			return task, nil
+		}
+	}
+	return nil, fmt.Errorf("task %s is not finished after %d checks, the last state is %s",
+		task.ID, a.TaskPollMaxAttempts, task.Status.State)
+}
+
 func (a *A2AAgent) buildResponseFromMessage(message a2aprotocol.Message) ToolCallResult {
 	result := ToolCallResult{
 		Content: make([]history.Content, 0),
@@ -236,6 +396,13 @@ func (a *A2AAgent) buildResponseFromMessage(message a2aprotocol.Message) ToolCal
 
 // printTaskResult prints the contents of a task result.
 func (a *A2AAgent) buildResponseFromTask(task *a2aprotocol.Task) ToolCallResult {
+	if task.Status.State == a2aprotocol.TaskStateFailed {
+		reason := "no details"
+		if task.Status.Message != nil {
+			reason = a.buildResponseFromMessage(*task.Status.Message).getTextContent()
+		}
+		return ToolCallResult{Error: fmt.Errorf("%w: task %s failed: %s", ErrToolReported, task.ID, reason)}
+	}
 	if task.Status.Message != nil {
 		return a.buildResponseFromMessage(*task.Status.Message)
 	}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"sort"
+	"time"
+)
+
+// startA2ACardRefresh starts checking the agent cards of the A2A servers with card_refresh_interval,
+// so the tools follow the skills added to or removed from an agent without a restart
+func (host *ToolsHost) startA2ACardRefresh() {
+	for serverName := range host.a2aClients {
+		host.startA2ACardMonitor(serverName)
+	}
+}
+
+// startA2ACardMonitor starts checking the agent card of the server if card_refresh_interval is set
+func (host *ToolsHost) startA2ACardMonitor(serverName string) {
+	server, _ := host.serverConfig(serverName)
+	config, ok := server.Config.(A2AToolsServerConfig)
+	if !ok || config.CardRefreshInterval <= 0 {
+		return
+	}
+	interval := time.Duration(config.CardRefreshInterval) * time.Second
+	go host.monitorA2ACard(serverName, interval, host.newMonitorStop(serverName))
+}
+
+func (host *ToolsHost) monitorA2ACard(serverName string, interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-host.stopReconnect:
+			return
+		case <-stop:
+			return
+		case <-host.context.Done():
+			return
+		case <-ticker.C:
+			host.refreshA2ACard(serverName)
+		}
+	}
+}
+
+// refreshA2ACard fetches the agent card again and replaces the tools of the server
+// when its skills changed
+func (host *ToolsHost) refreshA2ACard(serverName string) {
+	host.toolsReloadMux.Lock()
+	defer host.toolsReloadMux.Unlock()
+
+	agent, ok := host.getA2AClient(serverName)
+	if !ok {
+		return
+	}
+	card, validators, err := fetchA2AAgentCard(agent.Endpoint, agent.options, agent.cardValidators)
+	if err != nil {
+		host.logger.Printf("Failed to refresh the agent card of server %s: %v\n", serverName, err)
+		return
+	}
+	if card == nil {
+		// Not modified
+		return
+	}
+	if card.Name == "" {
+		host.logger.Printf("The refreshed agent card of server %s has no name, it is ignored\n", serverName)
+		return
+	}
+
+	added, removed, changed := diffA2ASkills(agent.Card.Skills, card.Skills)
+
+	agent.Card = *card
+	agent.cardValidators = validators
+	host.a2aClientsMux.Lock()
+	host.a2aClients[serverName] = agent
+	host.a2aClientsMux.Unlock()
+
+	if len(added) == 0 && len(removed) == 0 && len(changed) == 0 {
+		return
+	}
+	for _, id := range added {
+		host.logger.Printf("Skill %s is added to server %s\n", id, serverName)
+	}
+	for _, id := range removed {
+		host.logger.Printf("Skill %s is removed from server %s\n", id, serverName)
+	}
+	for _, id := range changed {
+		host.logger.Printf("Skill %s of server %s is changed\n", id, serverName)
+	}
+
+	tools := host.a2aServerTools(serverName, agent.Card)
+	host.replaceServerTools(serverName, tools)
+	host.logger.Printf("Skills of server %s changed: %d tools\n", serverName, len(tools))
+
+	if host.toolsChangedCallback != nil {
+		host.toolsChangedCallback(serverName)
+	}
+}
+
+// diffA2ASkills returns the IDs of the skills added, removed and changed in the new card
+func diffA2ASkills(old []Skill, new []Skill) (added []string, removed []string, changed []string) {
+	oldSkills := map[string]Skill{}
+	for _, skill := range old {
+		oldSkills[skill.ID] = skill
+	}
+	newSkills := map[string]bool{}
+	for _, skill := range new {
+		newSkills[skill.ID] = true
+		previous, ok := oldSkills[skill.ID]
+		if !ok {
+			added = append(added, skill.ID)
+		} else if previous.Name != skill.Name || previous.Description != skill.Description {
+			changed = append(changed, skill.ID)
+		}
+	}
+	for id := range oldSkills {
+		if !newSkills[id] {
+			removed = append(removed, id)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(changed)
+	return added, removed, changed
+}
@@ -0,0 +1,349 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gelembjuk/cleverchatty/core/history"
+)
+
+func TestA2AAgentReusesClient(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/.well-known/agent.json" {
+			json.NewEncoder(w).Encode(AgentCard{Name: "echo"})
+			return
+		}
+		var request struct {
+			ID any `json:"id"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		json.NewEncoder(w).Encode(map[string]any{
+			"jsonrpc": "2.0",
+			"id":      request.ID,
+			"result": map[string]any{
+				"kind":      "message",
+				"messageId": "1",
+				"role":      "agent",
+				"parts":     []any{map[string]any{"kind": "text", "text": "pong"}},
+			},
+		})
+	}))
+	var connections atomic.Int32
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	agent, err := NewA2AAgent(server.URL, nil, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("Failed to create the agent: %v", err)
+	}
+	afterCard := connections.Load()
+
+	// Copies of the agent share the client, as the tools host keeps the agents by value
+	agentCopy := *agent
+	for _, a := range []*A2AAgent{agent, &agentCopy, agent} {
+		result := a.sendMessage("ping", map[string]interface{}{"text": "ping"}, context.Background())
+		if result.Error != nil {
+			t.Fatalf("Failed to send the message: %v", result.Error)
+		}
+		if len(result.Content) != 1 || result.Content[0].(history.TextContent).Text != "pong" {
+			t.Fatalf("Expected the pong response, got %+v", result.Content)
+		}
+	}
+	if opened := connections.Load() - afterCard; opened != 1 {
+		t.Errorf("Expected the calls to reuse one connection, got %d connections", opened)
+	}
+
+	client, _ := agent.conn.get()
+	agent.conn.reset(client)
+	recreated, err := agent.conn.get()
+	if err != nil || recreated == client {
+		t.Errorf("Expected a new client after reset, got %v", err)
+	}
+}
+
+func TestA2AAgentWaitsForTask(t *testing.T) {
+	var polls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/.well-known/agent.json" {
+			json.NewEncoder(w).Encode(AgentCard{Name: "slow"})
+			return
+		}
+		var request struct {
+			ID     any    `json:"id"`
+			Method string `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		response := map[string]any{"jsonrpc": "2.0", "id": request.ID}
+		task := map[string]any{
+			"kind":      "task",
+			"id":        "task1",
+			"contextId": "context1",
+			"status":    map[string]any{"state": "working"},
+		}
+		if request.Method == "tasks/get" {
+			switch polls.Add(1) {
+			case 1:
+			case 2:
+				// A failed check must not replace the last known state
+				response["error"] = map[string]any{"code": -32603, "message": "temporary failure"}
+				task = nil
+			case 3:
+				task["status"] = map[string]any{
+					"state": "completed",
+					"message": map[string]any{
+						"kind":      "message",
+						"messageId": "2",
+						"role":      "agent",
+						"parts":     []any{map[string]any{"kind": "text", "text": "done"}},
+					},
+				}
+			}
+		}
+		if task != nil {
+			response["result"] = task
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	agent, err := NewA2AAgent(server.URL, nil, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("Failed to create the agent: %v", err)
+	}
+	agent.TaskPollInterval = time.Millisecond
+	agent.TaskPollMaxAttempts = 5
+
+	result := agent.sendMessage("work", map[string]interface{}{"text": "work"}, context.Background())
+	if result.Error != nil {
+		t.Fatalf("Failed to send the message: %v", result.Error)
+	}
+	if len(result.Content) != 1 || result.Content[0].(history.TextContent).Text != "done" {
+		t.Fatalf("Expected the result of the completed task, got %+v", result.Content)
+	}
+	if polls.Load() != 3 {
+		t.Errorf("Expected polling to stop when the task is completed, got %d checks", polls.Load())
+	}
+
+	// The task is not finished for the next checks
+	polls.Store(-10)
+	agent.TaskPollMaxAttempts = 2
+	result = agent.sendMessage("work", map[string]interface{}{"text": "work"}, context.Background())
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "not finished after 2 checks") {
+		t.Errorf("Expected an error for the unfinished task, got %v", result.Error)
+	}
+}
+
+func TestA2AAgentTaskChecksFail(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/.well-known/agent.json" {
+			json.NewEncoder(w).Encode(AgentCard{Name: "broken"})
+			return
+		}
+		var request struct {
+			ID     any    `json:"id"`
+			Method string `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		response := map[string]any{"jsonrpc": "2.0", "id": request.ID}
+		if request.Method == "tasks/get" {
+			response["error"] = map[string]any{"code": -32001, "message": "task not found"}
+		} else {
+			response["result"] = map[string]any{
+				"kind":      "task",
+				"id":        "task1",
+				"contextId": "context1",
+				"status":    map[string]any{"state": "submitted"},
+			}
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	agent, err := NewA2AAgent(server.URL, nil, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("Failed to create the agent: %v", err)
+	}
+	agent.TaskPollInterval = time.Millisecond
+
+	// Every check fails, the task state must not be read from a missing response
+	result := agent.sendMessage("work", map[string]interface{}{"text": "work"}, context.Background())
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "the last state is submitted") {
+		t.Errorf("Expected an error with the last known state, got %v", result.Error)
+	}
+}
+
+func TestA2AAgentWithAuthentication(t *testing.T) {
+	var unauthorized atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret-agent1" {
+			unauthorized.Add(1)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/.well-known/agent.json" {
+			json.NewEncoder(w).Encode(AgentCard{Name: "private"})
+			return
+		}
+		var request struct {
+			ID any `json:"id"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		json.NewEncoder(w).Encode(map[string]any{
+			"jsonrpc": "2.0",
+			"id":      request.ID,
+			"result": map[string]any{
+				"kind":      "message",
+				"messageId": "1",
+				"role":      "agent",
+				"parts":     []any{map[string]any{"kind": "text", "text": "welcome"}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	if _, err := NewA2AAgent(server.URL, nil, log.New(io.Discard, "", 0)); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("Expected the agent card to be rejected without the token, got %v", err)
+	}
+
+	// The placeholders in the headers are replaced as for MCP servers
+	host := &ToolsHost{AgentID: "agent1"}
+	headers := host.parseConfigHeaders([]string{"Authorization: Bearer secret-{AGENT_ID}"})
+	agent, err := NewA2AAgentWithOptions(server.URL, nil, A2AAgentOptions{Headers: headers}, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("Failed to create the agent with the token: %v", err)
+	}
+	unauthorized.Store(0)
+	result := agent.sendMessage("hello", map[string]interface{}{"text": "hello"}, context.Background())
+	if result.Error != nil || unauthorized.Load() != 0 {
+		t.Fatalf("Expected the message to be sent with the token, got %v", result.Error)
+	}
+	if result.Content[0].(history.TextContent).Text != "welcome" {
+		t.Errorf("Expected the response of the agent, got %+v", result.Content)
+	}
+}
+
+func TestA2AAgentCardTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	started := time.Now()
+	_, err := NewA2AAgentWithOptions(server.URL, nil, A2AAgentOptions{CardTimeout: 50 * time.Millisecond}, log.New(io.Discard, "", 0))
+	if err == nil || time.Since(started) > 5*time.Second {
+		t.Errorf("Expected the agent card request to time out, got %v after %s", err, time.Since(started))
+	}
+}
+
+func TestA2AAgentCardRefresh(t *testing.T) {
+	var mux sync.Mutex
+	etag := `"v1"`
+	card := AgentCard{Name: "assistant", Skills: []Skill{{ID: "chat", Name: "Chat"}, {ID: "search", Name: "Search"}}}
+	notModified := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		json.NewEncoder(w).Encode(card)
+	}))
+	defer server.Close()
+
+	agent, err := NewA2AAgent(server.URL, nil, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("Failed to create the agent: %v", err)
+	}
+	host := &ToolsHost{
+		config:     map[string]ServerConfigWrapper{"remote": {Config: A2AToolsServerConfig{Endpoint: server.URL}}},
+		logger:     log.New(io.Discard, "", 0),
+		a2aClients: map[string]A2AAgent{"remote": *agent},
+	}
+	if err := host.loadA2ATools(); err != nil {
+		t.Fatalf("Failed to load the tools: %v", err)
+	}
+	changes := 0
+	host.toolsChangedCallback = func(serverName string) {
+		changes++
+	}
+
+	// The card is not transferred again while it is not modified
+	host.refreshA2ACard("remote")
+	if notModified != 1 || changes != 0 || len(host.GetAllToolsForLLM()) != 2 {
+		t.Errorf("Expected the not modified card to be kept, got %d not modified responses, %d changes", notModified, changes)
+	}
+
+	mux.Lock()
+	etag = `"v2"`
+	card.Skills = []Skill{{ID: "chat", Name: "Chat"}, {ID: "translate", Name: "Translate"}}
+	mux.Unlock()
+	host.refreshA2ACard("remote")
+
+	names := []string{}
+	for _, tool := range host.GetAllToolsForLLM() {
+		names = append(names, tool.Name)
+	}
+	sort.Strings(names)
+	if changes != 1 || strings.Join(names, ",") != "remote__chat,remote__translate" {
+		t.Errorf("Expected the tools of the changed skills, got %v after %d changes", names, changes)
+	}
+	if refreshed, _ := host.getA2AClient("remote"); refreshed.cardValidators.etag != `"v2"` {
+		t.Errorf("Expected the validators of the new card, got %+v", refreshed.cardValidators)
+	}
+
+	added, removed, changed := diffA2ASkills(
+		[]Skill{{ID: "a", Name: "A"}, {ID: "b", Name: "B"}},
+		[]Skill{{ID: "b", Name: "B2"}, {ID: "c", Name: "C"}},
+	)
+	if strings.Join(added, ",") != "c" || strings.Join(removed, ",") != "a" || strings.Join(changed, ",") != "b" {
+		t.Errorf("Unexpected skills diff: added %v, removed %v, changed %v", added, removed, changed)
+	}
+}
+
+func TestOutboundUserAgent(t *testing.T) {
+	userAgents := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgents <- r.Header.Get("User-Agent")
+		json.NewEncoder(w).Encode(AgentCard{Name: "echo"})
+	}))
+	defer server.Close()
+
+	host := &ToolsHost{AgentID: "agent1"}
+	if _, err := NewA2AAgentWithOptions(server.URL, nil, A2AAgentOptions{Headers: host.parseConfigHeaders(nil)}, log.New(io.Discard, "", 0)); err != nil {
+		t.Fatalf("Failed to create the agent: %v", err)
+	}
+	if userAgent := <-userAgents; userAgent != "CleverChatty/"+ThisAppVersion+" (agent agent1)" {
+		t.Errorf("Expected the default User-Agent with the agent ID, got %q", userAgent)
+	}
+
+	host.userAgent = "AcmeAssistant/1.0 ({AGENT_ID})"
+	if userAgent := host.parseConfigHeaders(nil)["User-Agent"]; userAgent != "AcmeAssistant/1.0 (agent1)" {
+		t.Errorf("Expected the configured User-Agent, got %q", userAgent)
+	}
+	// The header of the server config takes precedence
+	headers := host.parseConfigHeaders([]string{"user-agent: Custom/2.0"})
+	if len(headers) != 1 || headers["User-Agent"] != "Custom/2.0" {
+		t.Errorf("Expected the User-Agent of the server config, got %v", headers)
+	}
+}
@@ -0,0 +1,180 @@
+package core
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ApplyConfig replaces the config of the tools servers without restarting the host.
+// Only the difference is applied: clients of added servers are started, clients of removed
+// servers are stopped and changed servers are reconnected. Other servers, their clients and
+// the conversations using them are not touched. A server that fails to start is reported
+// in the error, the rest of the config is applied anyway
+func (host *ToolsHost) ApplyConfig(newConfig map[string]ServerConfigWrapper) error {
+	config := maps.Clone(newConfig)
+	if config == nil {
+		config = map[string]ServerConfigWrapper{}
+	}
+
+	if err := ValidateToolsServersConfig(config); err != nil {
+		return err
+	}
+
+	// Serialized with the reloads of tools lists and agent cards
+	host.toolsReloadMux.Lock()
+	defer host.toolsReloadMux.Unlock()
+
+	removed, added, changed := diffServersConfig(host.currentConfig(), config)
+	stopped := append(append([]string{}, removed...), changed...)
+	started := append(append([]string{}, changed...), added...)
+
+	for _, serverName := range stopped {
+		host.stopServer(serverName)
+	}
+
+	host.configMux.Lock()
+	host.config = config
+	host.configMux.Unlock()
+
+	failures := []string{}
+	for _, serverName := range started {
+		if err := host.startServer(serverName); err != nil {
+			host.logger.Printf("Failed to start server %s: %v\n", serverName, err)
+			failures = append(failures, fmt.Sprintf("%s: %v", serverName, err))
+		}
+	}
+
+	host.logger.Printf("Tools servers config applied: %d added, %d removed, %d changed\n",
+		len(added), len(removed), len(changed))
+	host.warnDuplicateToolNames()
+
+	if host.toolsChangedCallback != nil {
+		for _, serverName := range append(stopped, added...) {
+			host.toolsChangedCallback(serverName)
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to start tools servers: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// ValidateToolsServersConfig runs the checks of the tools host initialization on the config,
+// without connecting to the servers
+func ValidateToolsServersConfig(config map[string]ServerConfigWrapper) error {
+	candidate := &ToolsHost{config: config}
+	if err := candidate.validateServerNames(); err != nil {
+		return err
+	}
+	if err := candidate.validateInterfaces(); err != nil {
+		return err
+	}
+	return candidate.validateProtocolVersions()
+}
+
+// diffServersConfig returns the sorted names of the servers to stop, to start and to reconnect.
+// A disabled server is the same as a missing one
+func diffServersConfig(old map[string]ServerConfigWrapper, new map[string]ServerConfigWrapper) (removed []string, added []string, changed []string) {
+	for name, oldServer := range old {
+		if oldServer.Disabled {
+			continue
+		}
+		newServer, ok := new[name]
+		if !ok || newServer.Disabled {
+			removed = append(removed, name)
+		} else if !reflect.DeepEqual(oldServer, newServer) {
+			changed = append(changed, name)
+		}
+	}
+	for name, newServer := range new {
+		if newServer.Disabled {
+			continue
+		}
+		if oldServer, ok := old[name]; !ok || oldServer.Disabled {
+			added = append(added, name)
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+	sort.Strings(changed)
+	return removed, added, changed
+}
+
+// stopServer stops the monitors of the server, closes its client and removes its tools
+func (host *ToolsHost) stopServer(serverName string) {
+	host.stopServerMonitors(serverName)
+
+	if _, ok := host.getMCPClient(serverName); ok {
+		host.closeServerClient(serverName)
+
+		host.mcpClientsMux.Lock()
+		delete(host.mcpClients, serverName)
+		host.mcpClientsMux.Unlock()
+	}
+
+	host.a2aClientsMux.Lock()
+	delete(host.a2aClients, serverName)
+	host.a2aClientsMux.Unlock()
+
+	host.replaceServerTools(serverName, nil)
+
+	host.configMux.Lock()
+	if host.memoryServerName == serverName {
+		host.memoryServerName = ""
+		host.memoryForgetSupported.Store(false)
+	}
+	if host.ragServerName == serverName {
+		host.ragServerName = ""
+	}
+	host.configMux.Unlock()
+	host.logger.Printf("Server %s stopped\n", serverName)
+}
+
+// startServer connects the MCP or A2A server from the current config, loads its tools
+// and starts its monitors. Reverse MCP servers connect by themselves
+func (host *ToolsHost) startServer(serverName string) error {
+	server, _ := host.serverConfig(serverName)
+
+	switch {
+	case server.isMCPServer():
+		var lost chan struct{}
+		if server.Config.GetType() == transportSSE {
+			lost = make(chan struct{}, 1)
+		}
+		if err := host.connectMCPServer(serverName, lost, nil); err != nil {
+			return err
+		}
+		host.startMCPServerMonitor(serverName, lost)
+	case server.isA2AServer():
+		agent, err := host.newA2AAgent(server.Config.(A2AToolsServerConfig))
+		if err != nil {
+			return fmt.Errorf("failed to fetch agent card: %w", err)
+		}
+		host.a2aClientsMux.Lock()
+		if host.a2aClients == nil {
+			host.a2aClients = map[string]A2AAgent{}
+		}
+		host.a2aClients[serverName] = *agent
+		host.a2aClientsMux.Unlock()
+
+		host.replaceServerTools(serverName, host.a2aServerTools(serverName, agent.Card))
+		host.startA2ACardMonitor(serverName)
+	default:
+		return nil
+	}
+
+	if server.isMemoryServer() {
+		host.setMemoryServer(serverName)
+		host.logger.Printf("Memory server connected %s\n", serverName)
+	}
+	if server.isRAGServer() {
+		host.setRAGServer(serverName)
+		host.logger.Printf("RAG server connected %s\n", serverName)
+	}
+	host.logger.Printf("Server connected %s\n", serverName)
+	return nil
+}
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// newTestMCPServer starts an HTTP streaming MCP server with one tool returning its name
+func newTestMCPServer(t *testing.T, toolName string) *httptest.Server {
+	mcpServer := server.NewMCPServer("test", "1.0.0")
+	mcpServer.AddTool(mcp.NewTool(toolName), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText(toolName), nil
+	})
+	testServer := server.NewTestStreamableHTTPServer(mcpServer)
+	t.Cleanup(testServer.Close)
+	return testServer
+}
+
+func toolNames(host *ToolsHost) []string {
+	names := []string{}
+	for _, tool := range host.GetAllToolsForLLM() {
+		names = append(names, tool.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestApplyToolsConfig(t *testing.T) {
+	alpha := ServerConfigWrapper{Config: HTTPStreamingMCPServerConfig{Url: newTestMCPServer(t, "alpha").URL + "/mcp"}}
+	betaURL := newTestMCPServer(t, "beta").URL + "/mcp"
+	beta := ServerConfigWrapper{Config: HTTPStreamingMCPServerConfig{Url: betaURL}}
+
+	host, _ := newToolsHost(map[string]ServerConfigWrapper{"a": alpha}, log.New(io.Discard, "", 0), context.Background(), t.TempDir())
+	if err := host.Init(); err != nil {
+		t.Fatalf("Failed to init the tools host: %v", err)
+	}
+	defer host.Close()
+	changes := []string{}
+	host.toolsChangedCallback = func(serverName string) {
+		changes = append(changes, serverName)
+	}
+	clientA, _ := host.getMCPClient("a")
+
+	// A new server is started, the unchanged one keeps its client
+	if err := host.ApplyConfig(map[string]ServerConfigWrapper{"a": alpha, "b": beta}); err != nil {
+		t.Fatalf("Failed to apply the config: %v", err)
+	}
+	if names := toolNames(host); len(names) != 2 || names[0] != "a__alpha" || names[1] != "b__beta" {
+		t.Fatalf("Expected the tools of both servers, got %v", names)
+	}
+	if client, _ := host.getMCPClient("a"); client != clientA {
+		t.Errorf("Expected the client of the unchanged server to be kept")
+	}
+	result := host.callTool("b", "beta", map[string]interface{}{}, context.Background())
+	if result.Error != nil || result.getTextContent() != "beta" {
+		t.Errorf("Expected the tool of the added server to be called, got %q, %v", result.getTextContent(), result.Error)
+	}
+	clientB, _ := host.getMCPClient("b")
+
+	// The removed server is stopped, the changed one is reconnected
+	changedBeta := ServerConfigWrapper{Config: HTTPStreamingMCPServerConfig{Url: betaURL, Headers: []string{"X-Test: 1"}}}
+	if err := host.ApplyConfig(map[string]ServerConfigWrapper{"b": changedBeta}); err != nil {
+		t.Fatalf("Failed to apply the config: %v", err)
+	}
+	if names := toolNames(host); len(names) != 1 || names[0] != "b__beta" {
+		t.Fatalf("Expected only the tools of the changed server, got %v", names)
+	}
+	if _, ok := host.getMCPClient("a"); ok {
+		t.Errorf("Expected the client of the removed server to be closed")
+	}
+	if client, _ := host.getMCPClient("b"); client == clientB {
+		t.Errorf("Expected the changed server to be reconnected")
+	}
+	if len(changes) != 3 {
+		t.Errorf("Expected 3 tools changes, got %v", changes)
+	}
+
+	// An invalid config is rejected and the current one is kept
+	if err := host.ApplyConfig(map[string]ServerConfigWrapper{"bad__name": alpha}); err == nil {
+		t.Errorf("Expected the invalid config to be rejected")
+	}
+	if names := toolNames(host); len(names) != 1 || names[0] != "b__beta" {
+		t.Errorf("Expected the tools to be kept after the rejected config, got %v", names)
+	}
+}
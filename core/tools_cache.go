@@ -0,0 +1,116 @@
+package core
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gelembjuk/cleverchatty/core/history"
+)
+
+const defaultToolCacheSize = 256
+
+// toolCacheEntry is a single cached tool call result
+type toolCacheEntry struct {
+	key       string
+	result    ToolCallResult
+	expiresAt time.Time
+}
+
+// ToolCache is a bounded LRU cache of tool call results.
+// Only successful results are stored, each with its own expiration time.
+type ToolCache struct {
+	maxSize int
+	entries map[string]*list.Element
+	order   *list.List
+	mu      sync.Mutex
+}
+
+func NewToolCache(maxSize int) *ToolCache {
+	if maxSize <= 0 {
+		maxSize = defaultToolCacheSize
+	}
+	return &ToolCache{
+		maxSize: maxSize,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// toolCacheKey builds the cache key from server name, tool name and a hash of the arguments.
+// json.Marshal sorts map keys, so equal arguments always produce the same hash.
+func toolCacheKey(serverName string, toolName string, toolArgs map[string]interface{}) (string, error) {
+	argsData, err := json.Marshal(toolArgs)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(argsData)
+	return serverName + "__" + toolName + ":" + hex.EncodeToString(hash[:]), nil
+}
+
+// Get returns a cached result if it exists and is not expired.
+// The content is copied, so the caller can change the result without affecting the cache
+func (c *ToolCache) Get(key string) (ToolCallResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return ToolCallResult{}, false
+	}
+	entry := element.Value.(*toolCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return ToolCallResult{}, false
+	}
+	c.order.MoveToFront(element)
+	return copyToolCallResult(entry.result), true
+}
+
+// Set stores a result for the given TTL, evicting the least recently used entry when full
+func (c *ToolCache) Set(key string, result ToolCallResult, ttl time.Duration) {
+	result = copyToolCallResult(result)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*toolCacheEntry)
+		entry.result = result
+		entry.expiresAt = time.Now().Add(ttl)
+		c.order.MoveToFront(element)
+		return
+	}
+
+	element := c.order.PushFront(&toolCacheEntry{
+		key:       key,
+		result:    result,
+		expiresAt: time.Now().Add(ttl),
+	})
+	c.entries[key] = element
+
+	for c.order.Len() > c.maxSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*toolCacheEntry).key)
+	}
+}
+
+// copyToolCallResult returns the result with its own copy of the content slice
+func copyToolCallResult(result ToolCallResult) ToolCallResult {
+	if result.Content != nil {
+		result.Content = append([]history.Content{}, result.Content...)
+	}
+	return result
+}
+
+// Len returns the number of entries in the cache, including expired ones not yet evicted
+func (c *ToolCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
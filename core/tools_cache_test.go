@@ -0,0 +1,69 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gelembjuk/cleverchatty/core/history"
+)
+
+func textToolResult(text string) ToolCallResult {
+	return ToolCallResult{Content: []history.Content{history.TextContent{Type: "text", Text: text}}}
+}
+
+func TestToolCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewToolCache(2)
+	cache.Set("a", textToolResult("A"), time.Minute)
+	cache.Set("b", textToolResult("B"), time.Minute)
+
+	// Reading "a" makes "b" the least recently used entry
+	if _, found := cache.Get("a"); !found {
+		t.Fatalf("Expected entry a to be cached")
+	}
+	cache.Set("c", textToolResult("C"), time.Minute)
+
+	if cache.Len() != 2 {
+		t.Fatalf("Expected 2 entries, got %d", cache.Len())
+	}
+	if _, found := cache.Get("b"); found {
+		t.Errorf("Expected entry b to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, found := cache.Get(key); !found {
+			t.Errorf("Expected entry %s to be cached", key)
+		}
+	}
+}
+
+func TestToolCacheExpiresEntries(t *testing.T) {
+	cache := NewToolCache(10)
+	cache.Set("short", textToolResult("short"), 10*time.Millisecond)
+	cache.Set("long", textToolResult("long"), time.Minute)
+
+	time.Sleep(20 * time.Millisecond)
+
+	if _, found := cache.Get("short"); found {
+		t.Errorf("Expected expired entry not to be returned")
+	}
+	if cache.Len() != 1 {
+		t.Errorf("Expected the expired entry to be removed, got %d entries", cache.Len())
+	}
+	if result, found := cache.Get("long"); !found || result.getTextContent() != "long" {
+		t.Errorf("Expected entry long to be cached, got %v", result.Content)
+	}
+}
+
+func TestToolCacheReturnsCopy(t *testing.T) {
+	cache := NewToolCache(10)
+	original := textToolResult("original")
+	cache.Set("key", original, time.Minute)
+
+	// Changing the stored result or a returned result must not change the cache
+	original.Content[0] = history.TextContent{Type: "text", Text: "changed by caller"}
+	result, _ := cache.Get("key")
+	result.Content[0] = history.TextContent{Type: "text", Text: "changed by reader"}
+
+	if result, _ := cache.Get("key"); result.getTextContent() != "original" {
+		t.Errorf("Expected cached text %q, got %q", "original", result.getTextContent())
+	}
+}
@@ -0,0 +1,39 @@
+package core
+
+import "context"
+
+// toolArguments returns the arguments of the tool call with the tool context added.
+// The context keys are reserved, a value set by the model for such a key is replaced
+func (assistant *CleverChatty) toolArguments(ctx context.Context, toolName string, args map[string]interface{}) map[string]interface{} {
+	if len(assistant.config.ToolContext) == 0 {
+		return args
+	}
+	// The arguments of the model stay untouched, they are kept in the history
+	merged := make(map[string]interface{}, len(args)+len(assistant.config.ToolContext))
+	for key, value := range args {
+		merged[key] = value
+	}
+	for key, value := range assistant.config.ToolContext {
+		if _, ok := args[key]; ok {
+			assistant.logger.Printf("%sArgument %s of tool %s is reserved, the tool context value is used\n", logPrefix(ctx), key, toolName)
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// mergeToolContext adds the values of a client to the configured tool context.
+// The configured keys can not be replaced by the client
+func mergeToolContext(configured map[string]interface{}, client map[string]interface{}) map[string]interface{} {
+	if len(client) == 0 {
+		return configured
+	}
+	merged := make(map[string]interface{}, len(configured)+len(client))
+	for key, value := range client {
+		merged[key] = value
+	}
+	for key, value := range configured {
+		merged[key] = value
+	}
+	return merged
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
@@ -38,11 +39,43 @@ func encodeFileRef(filename, mimeType string) string {
 	return base64.StdEncoding.EncodeToString([]byte(plain))
 }
 
+// cachedFile is a content-addressed file shared by all file caches of the process
+type cachedFile struct {
+	hashKey string
+	refs    int
+}
+
+// sharedFiles deduplicates cached files by content. Sessions share the same tmp dir,
+// so a file is removed only when no file cache references it anymore.
+var sharedFiles = struct {
+	mu     sync.Mutex
+	byHash map[string]string      // tmp dir + content hash -> file path
+	files  map[string]*cachedFile // file path -> reference count
+}{
+	byHash: make(map[string]string),
+	files:  make(map[string]*cachedFile),
+}
+
 type FileCache struct {
-	workDir      string
-	logger       *log.Logger
-	trackedFiles []string
-	mu           sync.Mutex
+	workDir       string
+	logger        *log.Logger
+	trackedFiles  []string
+	producedFiles []producedFile
+	mu            sync.Mutex
+}
+
+// producedFile is a file returned by a tool and stored in the cache
+type producedFile struct {
+	filename string
+	mimeType string
+	base64   bool // The content was stored base64 encoded as received from the tool
+}
+
+// ProducedFile is a file returned by a tool while processing a prompt
+type ProducedFile struct {
+	Name     string
+	MimeType string
+	Data     []byte
 }
 
 func NewFileCache(workDir string, logger *log.Logger) *FileCache {
@@ -64,11 +97,30 @@ func (fc *FileCache) ensureTmpDir() error {
 }
 
 // SaveContent saves raw bytes to a temp file and returns the filename (relative to workDir).
+// Identical content is stored once, the existing file is reused and referenced again.
 func (fc *FileCache) SaveContent(data []byte, mimeType string) (string, error) {
 	if err := fc.ensureTmpDir(); err != nil {
 		return "", fmt.Errorf("failed to create tmp dir: %w", err)
 	}
 
+	hash := sha256.Sum256(data)
+	hashKey := fc.tmpDir() + "|" + hex.EncodeToString(hash[:])
+
+	sharedFiles.mu.Lock()
+	defer sharedFiles.mu.Unlock()
+
+	if path, ok := sharedFiles.byHash[hashKey]; ok {
+		if _, err := os.Stat(path); err == nil {
+			sharedFiles.files[path].refs++
+			fc.track(path)
+			fc.logger.Printf("FileCache: reused %s for identical content (mime: %s)", filepath.Base(path), mimeType)
+			return filepath.Base(path), nil
+		}
+		// The file was removed outside of the cache, store it again
+		delete(sharedFiles.byHash, hashKey)
+		delete(sharedFiles.files, path)
+	}
+
 	name := randomName() + ".tmp"
 	path := filepath.Join(fc.tmpDir(), name)
 
@@ -76,21 +128,77 @@ func (fc *FileCache) SaveContent(data []byte, mimeType string) (string, error) {
 		return "", fmt.Errorf("failed to write temp file: %w", err)
 	}
 
+	sharedFiles.byHash[hashKey] = path
+	sharedFiles.files[path] = &cachedFile{hashKey: hashKey, refs: 1}
+	fc.track(path)
+	fc.logger.Printf("FileCache: saved %d bytes to %s (mime: %s)", len(data), name, mimeType)
+	return name, nil
+}
+
+// recordProduced remembers a file returned by a tool, so it can be delivered to the client
+func (fc *FileCache) recordProduced(filename string, mimeType string, encoded bool) {
+	fc.mu.Lock()
+	fc.producedFiles = append(fc.producedFiles, producedFile{filename: filename, mimeType: mimeType, base64: encoded})
+	fc.mu.Unlock()
+}
+
+// TakeProducedFiles returns the files returned by tools since the previous call and forgets them
+func (fc *FileCache) TakeProducedFiles() []ProducedFile {
+	fc.mu.Lock()
+	produced := fc.producedFiles
+	fc.producedFiles = nil
+	fc.mu.Unlock()
+
+	files := []ProducedFile{}
+	for _, file := range produced {
+		content, err := fc.ReadFile(file.filename)
+		if err != nil {
+			fc.logger.Printf("Failed to read produced file %s: %v", file.filename, err)
+			continue
+		}
+		data := []byte(content)
+		if file.base64 {
+			data, err = base64.StdEncoding.DecodeString(content)
+			if err != nil {
+				fc.logger.Printf("Failed to decode produced file %s: %v", file.filename, err)
+				continue
+			}
+		}
+		files = append(files, ProducedFile{
+			Name:     file.filename,
+			MimeType: file.mimeType,
+			Data:     data,
+		})
+	}
+	return files
+}
+
+func (fc *FileCache) track(path string) {
 	fc.mu.Lock()
 	fc.trackedFiles = append(fc.trackedFiles, path)
 	fc.mu.Unlock()
-	fc.logger.Printf("FileCache: saved %d bytes to %s (mime: %s)", len(data), name, mimeType)
-	return name, nil
 }
 
-// Cleanup removes all temp files created during this session.
+// Cleanup releases all temp files referenced during this session.
+// A file is removed when it is not referenced by another session anymore.
 func (fc *FileCache) Cleanup() {
 	fc.mu.Lock()
 	files := fc.trackedFiles
 	fc.trackedFiles = nil
 	fc.mu.Unlock()
 
+	sharedFiles.mu.Lock()
+	defer sharedFiles.mu.Unlock()
+
 	for _, path := range files {
+		if file, ok := sharedFiles.files[path]; ok {
+			file.refs--
+			if file.refs > 0 {
+				continue
+			}
+			delete(sharedFiles.files, path)
+			delete(sharedFiles.byHash, file.hashKey)
+		}
 		if err := os.Remove(path); err != nil {
 			fc.logger.Printf("FileCache: failed to remove %s: %v", path, err)
 		} else {
@@ -105,9 +213,30 @@ func (fc *FileCache) SaveBase64Content(b64Data string, mimeType string) (string,
 	return fc.SaveContent([]byte(b64Data), mimeType)
 }
 
+// cachedFilePath returns the path of a cached file. The filename comes from file references
+// that can be crafted by a model or a tool, so only plain names inside the tmp dir are accepted.
+func (fc *FileCache) cachedFilePath(filename string) (string, error) {
+	if filename == "" || filename == "." || filename == ".." ||
+		strings.ContainsAny(filename, `/\`) || filename != filepath.Base(filename) {
+		return "", fmt.Errorf("invalid cached file name %q", filename)
+	}
+	tmpDir, err := filepath.Abs(fc.tmpDir())
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve tmp dir: %w", err)
+	}
+	path := filepath.Join(tmpDir, filename)
+	if filepath.Dir(path) != tmpDir {
+		return "", fmt.Errorf("invalid cached file name %q", filename)
+	}
+	return path, nil
+}
+
 // ReadFile reads a file from the cache directory and returns its content as a string.
 func (fc *FileCache) ReadFile(filename string) (string, error) {
-	path := filepath.Join(fc.tmpDir(), filename)
+	path, err := fc.cachedFilePath(filename)
+	if err != nil {
+		return "", err
+	}
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return "", fmt.Errorf("failed to read file %s: %w", filename, err)
@@ -125,6 +254,7 @@ func (fc *FileCache) HandleImageContent(content mcp.ImageContent) history.Conten
 			Text: fmt.Sprintf(fileCacheFailedImageMsg, content.MIMEType, err),
 		}
 	}
+	fc.recordProduced(filename, content.MIMEType, true)
 	return history.TextContent{
 		Type: "text",
 		Text: encodeFileRef(filename, content.MIMEType),
@@ -143,6 +273,7 @@ func (fc *FileCache) HandleEmbeddedResource(content mcp.EmbeddedResource) histor
 				Text: fmt.Sprintf(fileCacheFailedResourceMsg, res.URI, err),
 			}
 		}
+		fc.recordProduced(filename, res.MIMEType, true)
 		return history.TextContent{
 			Type: "text",
 			Text: encodeFileRef(filename, res.MIMEType),
@@ -160,6 +291,7 @@ func (fc *FileCache) HandleEmbeddedResource(content mcp.EmbeddedResource) histor
 				Text: fmt.Sprintf(fileCacheFailedResourceMsg, res.URI, err),
 			}
 		}
+		fc.recordProduced(filename, mimeType, false)
 		return history.TextContent{
 			Type: "text",
 			Text: encodeFileRef(filename, mimeType),
@@ -169,6 +301,25 @@ func (fc *FileCache) HandleEmbeddedResource(content mcp.EmbeddedResource) histor
 	}
 }
 
+// HandleBinaryText saves tool output that is not readable text to a temp file and returns
+// a text content referencing the stored file, so the raw bytes never reach the LLM.
+func (fc *FileCache) HandleBinaryText(text string) history.Content {
+	mimeType := "application/octet-stream"
+	filename, err := fc.SaveContent([]byte(text), mimeType)
+	if err != nil {
+		fc.logger.Printf("Failed to save binary content to file: %v", err)
+		return history.TextContent{
+			Type: "text",
+			Text: fmt.Sprintf("Binary content received (%d bytes) but failed to cache locally: %v", len(text), err),
+		}
+	}
+	fc.recordProduced(filename, mimeType, false)
+	return history.TextContent{
+		Type: "text",
+		Text: encodeFileRef(filename, mimeType),
+	}
+}
+
 // ResolveFileArgs walks through tool arguments and replaces any string value
 // containing a [FILE OBJECT ...] reference with the cached file content.
 func (fc *FileCache) ResolveFileArgs(args map[string]interface{}) {
@@ -197,43 +348,79 @@ func (fc *FileCache) ResolveFileArgs(args map[string]interface{}) {
 // to plausibly be an encoded reference. Returns the resolved string and true if
 // a replacement was made.
 func (fc *FileCache) resolveFileRef(val string) (string, bool) {
-	if len(val) > maxFileRefLength {
+	filename, _, ok := parseFileRef(val)
+	if !ok {
 		return val, false
 	}
 
+	fc.logger.Printf("resolveFileRef: found FILE OBJECT reference %s in arg (arg length: %d)", filename, len(val))
+
+	content, err := fc.ReadFile(filename)
+	if err != nil {
+		fc.logger.Printf("Failed to read file ref %s: %v", filename, err)
+		return val, false
+	}
+
+	fc.logger.Printf("Resolved file ref %s (%d bytes)", filename, len(content))
+	return content, true
+}
+
+// parseFileRef checks if a string is a base64-encoded [FILE OBJECT ...] reference
+// and returns the file name and the mime type from it
+func parseFileRef(val string) (string, string, bool) {
+	if len(val) > maxFileRefLength {
+		return "", "", false
+	}
+
 	// Quick pre-check: base64 strings only contain these characters
 	if !isBase64(val) {
-		return val, false
+		return "", "", false
 	}
 
 	decoded, err := base64.StdEncoding.DecodeString(val)
 	if err != nil {
-		return val, false
+		return "", "", false
 	}
 
 	plain := string(decoded)
 	if !strings.HasPrefix(plain, fileCacheObjectPrefix) || !strings.HasSuffix(plain, "]") {
-		return val, false
+		return "", "", false
 	}
 
 	// Extract filename from "[FILE OBJECT filename, mimetype: ...]"
 	inner := plain[len(fileCacheObjectPrefix) : len(plain)-1]
 	commaIdx := strings.Index(inner, ",")
 	if commaIdx < 0 {
-		return val, false
+		return "", "", false
 	}
 	filename := strings.TrimSpace(inner[:commaIdx])
+	mimeType := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(inner[commaIdx+1:]), "mimetype:"))
 
-	fc.logger.Printf("resolveFileRef: found FILE OBJECT reference %s in arg (arg length: %d)", filename, len(val))
+	return filename, mimeType, true
+}
 
-	content, err := fc.ReadFile(filename)
-	if err != nil {
-		fc.logger.Printf("Failed to read file ref %s: %v", filename, err)
-		return val, false
+// FileRefInfo describes a file referenced by a [FILE OBJECT ...] reference
+type FileRefInfo struct {
+	Name     string
+	MimeType string
+	Size     int64 // Size of the cached file, -1 if it is not in the cache anymore
+}
+
+// DescribeFileRef returns the file referenced by a [FILE OBJECT ...] reference.
+// Returns false if the value is not a file reference.
+func (fc *FileCache) DescribeFileRef(val string) (FileRefInfo, bool) {
+	filename, mimeType, ok := parseFileRef(strings.TrimSpace(val))
+	if !ok {
+		return FileRefInfo{}, false
 	}
+	info := FileRefInfo{Name: filename, MimeType: mimeType, Size: -1}
 
-	fc.logger.Printf("Resolved file ref %s (%d bytes)", filename, len(content))
-	return content, true
+	if path, err := fc.cachedFilePath(filename); err == nil {
+		if stat, err := os.Stat(path); err == nil {
+			info.Size = stat.Size()
+		}
+	}
+	return info, true
 }
 
 // isBase64 checks if a string looks like valid base64 (only valid characters and proper padding).
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"encoding/base64"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestFileCacheRejectsPathTraversal(t *testing.T) {
+	workDir := t.TempDir()
+	fc := NewFileCache(filepath.Join(workDir, "agent"), log.New(io.Discard, "", 0))
+
+	// A file outside of the tmp dir that must never be readable through a reference
+	secretPath := filepath.Join(workDir, "secret.txt")
+	if err := os.WriteFile(secretPath, []byte("secret"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	for _, filename := range []string{"../../etc/passwd", "../../secret.txt", "/etc/passwd", ".."} {
+		ref := encodeFileRef(filename, "text/plain")
+		resolved, ok := fc.resolveFileRef(ref)
+		if ok || resolved != ref {
+			t.Errorf("Expected reference to %q to be rejected, got %q", filename, resolved)
+		}
+		if _, err := fc.ReadFile(filename); err == nil {
+			t.Errorf("Expected ReadFile to reject %q", filename)
+		}
+	}
+
+	// Regular cached files are still resolved
+	name, err := fc.SaveContent([]byte("hello"), "text/plain")
+	if err != nil {
+		t.Fatalf("Failed to save content: %v", err)
+	}
+	if resolved, ok := fc.resolveFileRef(encodeFileRef(name, "text/plain")); !ok || resolved != "hello" {
+		t.Errorf("Expected cached file to resolve, got %q", resolved)
+	}
+}
+
+func TestFileCacheTakeProducedFiles(t *testing.T) {
+	fc := NewFileCache(t.TempDir(), log.New(io.Discard, "", 0))
+	defer fc.Cleanup()
+
+	fc.HandleImageContent(mcp.NewImageContent(base64.StdEncoding.EncodeToString([]byte("png data")), "image/png"))
+	fc.HandleBinaryText("raw\x00bytes")
+
+	files := fc.TakeProducedFiles()
+	if len(files) != 2 {
+		t.Fatalf("Expected 2 produced files, got %d", len(files))
+	}
+	if files[0].MimeType != "image/png" || string(files[0].Data) != "png data" {
+		t.Errorf("Expected decoded image, got %s %q", files[0].MimeType, files[0].Data)
+	}
+	if files[1].MimeType != "application/octet-stream" || string(files[1].Data) != "raw\x00bytes" {
+		t.Errorf("Expected raw binary content, got %s %q", files[1].MimeType, files[1].Data)
+	}
+
+	if files := fc.TakeProducedFiles(); len(files) != 0 {
+		t.Errorf("Expected produced files to be returned once, got %d", len(files))
+	}
+}
+
+func TestFileCacheDeduplicatesContent(t *testing.T) {
+	workDir := t.TempDir()
+	first := NewFileCache(workDir, log.New(io.Discard, "", 0))
+	second := NewFileCache(workDir, log.New(io.Discard, "", 0))
+
+	name1, err := first.SaveContent([]byte("same blob"), "image/png")
+	if err != nil {
+		t.Fatalf("Failed to save content: %v", err)
+	}
+	name2, err := second.SaveContent([]byte("same blob"), "image/png")
+	if err != nil {
+		t.Fatalf("Failed to save content: %v", err)
+	}
+	if name1 != name2 {
+		t.Fatalf("Expected identical content to reuse the file, got %s and %s", name1, name2)
+	}
+
+	path := filepath.Join(workDir, "tmp", name1)
+
+	first.Cleanup()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("Expected the file referenced by another cache to be kept: %v", err)
+	}
+
+	second.Cleanup()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("Expected the file to be removed after the last reference is released")
+	}
+}
+
+func TestFileCacheDescribeFileRef(t *testing.T) {
+	fc := NewFileCache(t.TempDir(), log.New(io.Discard, "", 0))
+	defer fc.Cleanup()
+
+	name, err := fc.SaveContent([]byte("hello"), "text/plain")
+	if err != nil {
+		t.Fatalf("Failed to save content: %v", err)
+	}
+	info, ok := fc.DescribeFileRef(encodeFileRef(name, "text/plain"))
+	if !ok {
+		t.Fatalf("Expected file reference to be recognized")
+	}
+	if info.Name != name || info.MimeType != "text/plain" || info.Size != 5 {
+		t.Errorf("Unexpected file info %+v", info)
+	}
+
+	if _, ok := fc.DescribeFileRef("just a text"); ok {
+		t.Errorf("Expected plain text not to be recognized as a file reference")
+	}
+}
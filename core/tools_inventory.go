@@ -0,0 +1,52 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Built-in tools are custom tools, so the model sees the tool as custom__list_tools
+const listToolsToolName = "list_tools"
+
+const listToolsToolDescription = "List the tools you can use right now with their descriptions. " +
+	"Use it to plan a task when you are not sure which tools are available, the list changes when tools servers connect or disconnect."
+
+// registerListToolsTool adds the tool the model uses to see its current tools
+func (assistant *CleverChatty) registerListToolsTool() error {
+	return assistant.SetTool(CustomTool{
+		Name:        listToolsToolName,
+		Description: listToolsToolDescription,
+		Arguments: []ToolArgument{
+			{
+				Name:        "server",
+				Type:        "string",
+				Description: "Optional. List only the tools of this server",
+			},
+		},
+		Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			server, _ := args["server"].(string)
+			return assistant.listToolsForModel(strings.TrimSpace(server)), nil
+		},
+	})
+}
+
+// listToolsForModel describes the tools sent to the model, one per line. The list is built
+// on every call, so it includes the reverse MCP servers connected at the moment
+func (assistant *CleverChatty) listToolsForModel(server string) string {
+	lines := []string{}
+	for _, tool := range assistant.toolsForLLM() {
+		toolServer, _, _ := strings.Cut(tool.Name, "__")
+		if server != "" && toolServer != server {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("- %s: %s", tool.Name, strings.TrimSpace(tool.Description)))
+	}
+	if len(lines) == 0 {
+		if server != "" {
+			return fmt.Sprintf("No tools of the server %s are available", server)
+		}
+		return "No tools are available"
+	}
+	return fmt.Sprintf("%d tools are available:\n%s", len(lines), strings.Join(lines, "\n"))
+}
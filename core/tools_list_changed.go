@@ -0,0 +1,41 @@
+package core
+
+import (
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// watchToolsListChanged reloads the tools of the server when it reports that its tools list changed
+func (host *ToolsHost) watchToolsListChanged(serverName string, client mcpclient.MCPClient) {
+	client.OnNotification(func(notification mcp.JSONRPCNotification) {
+		if notification.Method != mcp.MethodNotificationToolsListChanged {
+			return
+		}
+		// Notifications are handled in the reading loop of the client,
+		// the tools list can not be requested before the handler returns
+		go host.reloadServerTools(serverName, client)
+	})
+}
+
+// reloadServerTools requests the tools of the server again and replaces its tools
+func (host *ToolsHost) reloadServerTools(serverName string, client mcpclient.MCPClient) {
+	host.toolsReloadMux.Lock()
+	defer host.toolsReloadMux.Unlock()
+
+	if current, ok := host.getMCPClient(serverName); !ok || current != client {
+		// The server was reconnected or removed meanwhile, its tools are already loaded
+		return
+	}
+
+	tools, err := host.listMCPServerTools(host.context, serverName, client)
+	if err != nil {
+		host.logger.Printf("Failed to reload tools of server %s: %v\n", serverName, err)
+		return
+	}
+	host.replaceServerTools(serverName, tools)
+	host.logger.Printf("Tools list of server %s changed: %d tools\n", serverName, len(tools))
+
+	if host.toolsChangedCallback != nil {
+		host.toolsChangedCallback(serverName)
+	}
+}
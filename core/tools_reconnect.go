@@ -0,0 +1,332 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/gelembjuk/cleverchatty/core/llm"
+	mcpclient "github.com/mark3labs/mcp-go/client"
+)
+
+const (
+	healthCheckInterval = 15 * time.Second
+	healthCheckTimeout  = 5 * time.Second
+)
+
+// startReconnectMonitors starts a monitor for every connected SSE and STDIO server.
+// The SSE client does not report a dropped stream and the STDIO client does not report
+// the exit of the process, so the monitor pings the server and reacts when the ping fails.
+func (host *ToolsHost) startReconnectMonitors() {
+	host.mcpClientsMux.RLock()
+	defer host.mcpClientsMux.RUnlock()
+
+	for serverName, client := range host.mcpClients {
+		var lost chan struct{}
+		if host.config[serverName].Config.GetType() == transportSSE {
+			lost = make(chan struct{}, 1)
+			host.watchConnectionLost(client, lost)
+		}
+		host.startMCPServerMonitor(serverName, lost)
+	}
+}
+
+// startMCPServerMonitor starts the monitor of the SSE or STDIO server. The lost channel
+// of an SSE server is signaled when its client reports the connection is lost
+func (host *ToolsHost) startMCPServerMonitor(serverName string, lost chan struct{}) {
+	config, _ := host.serverConfig(serverName)
+	switch config.Config.GetType() {
+	case transportSSE:
+		go host.monitorSSEServer(serverName, lost, host.newMonitorStop(serverName))
+	case transportStdio:
+		go host.monitorStdioServer(serverName, host.newMonitorStop(serverName))
+	}
+}
+
+// newMonitorStop returns the channel closed when the monitors of the server must stop
+func (host *ToolsHost) newMonitorStop(serverName string) chan struct{} {
+	host.mcpClientsMux.Lock()
+	defer host.mcpClientsMux.Unlock()
+
+	if host.monitorStops == nil {
+		host.monitorStops = map[string]chan struct{}{}
+	}
+	stop, ok := host.monitorStops[serverName]
+	if !ok {
+		stop = make(chan struct{})
+		host.monitorStops[serverName] = stop
+	}
+	return stop
+}
+
+// stopServerMonitors stops the monitors of the server, the monitors of other servers keep running
+func (host *ToolsHost) stopServerMonitors(serverName string) {
+	host.mcpClientsMux.Lock()
+	defer host.mcpClientsMux.Unlock()
+
+	if stop, ok := host.monitorStops[serverName]; ok {
+		close(stop)
+		delete(host.monitorStops, serverName)
+	}
+}
+
+func (host *ToolsHost) stopReconnectMonitors() {
+	host.stopReconnectOnce.Do(func() {
+		if host.stopReconnect != nil {
+			close(host.stopReconnect)
+		}
+	})
+}
+
+// watchConnectionLost signals the monitor when the client reports the connection is lost
+func (host *ToolsHost) watchConnectionLost(client mcpclient.MCPClient, lost chan struct{}) {
+	c, ok := client.(*mcpclient.Client)
+	if !ok {
+		return
+	}
+	c.OnConnectionLost(func(err error) {
+		host.logger.Printf("MCP connection lost: %v\n", err)
+		select {
+		case lost <- struct{}{}:
+		default:
+		}
+	})
+}
+
+func (host *ToolsHost) monitorSSEServer(serverName string, lost chan struct{}, stop chan struct{}) {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-host.stopReconnect:
+			return
+		case <-stop:
+			return
+		case <-host.context.Done():
+			return
+		case <-lost:
+		case <-ticker.C:
+			err := host.pingMCPServer(serverName)
+			if err == nil {
+				continue
+			}
+			host.logger.Printf("SSE server %s is not responding: %v\n", serverName, err)
+		}
+
+		if !host.reconnectMCPServer(serverName, lost, stop) {
+			return
+		}
+	}
+}
+
+func (host *ToolsHost) monitorStdioServer(serverName string, stop chan struct{}) {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-host.stopReconnect:
+			return
+		case <-stop:
+			return
+		case <-host.context.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := host.pingMCPServer(serverName)
+		if err == nil {
+			continue
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			// The process is alive but busy. Writing to a dead process fails immediately
+			host.logger.Printf("STDIO server %s is not responding: %v\n", serverName, err)
+			continue
+		}
+		if host.context.Err() != nil {
+			return
+		}
+
+		host.logger.Printf("STDIO server %s crashed: %v\n", serverName, err)
+
+		config, _ := host.serverConfig(serverName)
+		if stdioConfig, ok := config.Config.(STDIOMCPServerConfig); !ok || !stdioConfig.RestartOnCrash {
+			host.removeCrashedServer(serverName)
+			return
+		}
+
+		if !host.reconnectMCPServer(serverName, nil, stop) {
+			return
+		}
+	}
+}
+
+func (host *ToolsHost) pingMCPServer(serverName string) error {
+	client, ok := host.getMCPClient(serverName)
+	if !ok {
+		return fmt.Errorf("%w: server %s not found", ErrServerUnavailable, serverName)
+	}
+	ctx, cancel := context.WithTimeout(host.context, healthCheckTimeout)
+	defer cancel()
+
+	return client.Ping(ctx)
+}
+
+// closeServerClient closes the current client of the server. For STDIO servers
+// it waits for the process, so the exit code is logged
+func (host *ToolsHost) closeServerClient(serverName string) {
+	oldClient, ok := host.getMCPClient(serverName)
+	if !ok {
+		return
+	}
+	err := oldClient.Close()
+	if config, _ := host.serverConfig(serverName); config.Config == nil || config.Config.GetType() != transportStdio {
+		return
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		host.logger.Printf("STDIO server %s process exited with code %d (%s)\n", serverName, exitErr.ExitCode(), exitErr.String())
+	} else if err != nil {
+		host.logger.Printf("STDIO server %s process closed with error: %v\n", serverName, err)
+	} else {
+		host.logger.Printf("STDIO server %s process exited with code 0\n", serverName)
+	}
+}
+
+// removeCrashedServer removes the client and the tools of a server that is not restarted.
+// Calls of its tools fail with ErrServerUnavailable.
+func (host *ToolsHost) removeCrashedServer(serverName string) {
+	host.closeServerClient(serverName)
+
+	host.mcpClientsMux.Lock()
+	delete(host.mcpClients, serverName)
+	host.mcpClientsMux.Unlock()
+
+	host.replaceServerTools(serverName, nil)
+	host.logger.Printf("Server %s is unavailable, its tools are removed\n", serverName)
+}
+
+// reconnectMCPServer marks the server unavailable and tries to connect it again
+// with a backoff until it succeeds. Returns false if the host was closed meanwhile.
+func (host *ToolsHost) reconnectMCPServer(serverName string, lost chan struct{}, stop chan struct{}) bool {
+	host.setReconnecting(serverName, true)
+	defer host.setReconnecting(serverName, false)
+
+	host.closeServerClient(serverName)
+
+	backoff := initialBackoff
+	attempt := 1
+
+	for {
+		host.logger.Printf("Reconnecting server %s (attempt %d)\n", serverName, attempt)
+
+		err := host.connectMCPServer(serverName, lost, stop)
+		if err == nil {
+			host.logger.Printf("Server %s reconnected\n", serverName)
+			return true
+		}
+		host.logger.Printf("Failed to reconnect server %s: %v. Retrying in %s\n", serverName, err, backoff.String())
+
+		select {
+		case <-host.stopReconnect:
+			return false
+		case <-stop:
+			return false
+		case <-host.context.Done():
+			return false
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+		attempt++
+	}
+}
+
+// connectMCPServer creates a new client for the MCP server, initializes it and replaces
+// the client and the tools of the server. The client is dropped if stop is closed meanwhile
+func (host *ToolsHost) connectMCPServer(serverName string, lost chan struct{}, stop chan struct{}) error {
+	config, ok := host.serverConfig(serverName)
+	if !ok {
+		return fmt.Errorf("server %s not found in config", serverName)
+	}
+	client, err := host.newMCPClient(serverName, config)
+	if err != nil {
+		return err
+	}
+	if err = startMCPClient(client); err != nil {
+		return err
+	}
+	if err = host.initializeMCPClient(serverName, client); err != nil {
+		client.Close()
+		return err
+	}
+	tools, err := host.listMCPServerTools(host.context, serverName, client)
+	if err != nil {
+		client.Close()
+		return fmt.Errorf("failed to list tools: %w", err)
+	}
+
+	if lost != nil {
+		host.watchConnectionLost(client, lost)
+	}
+	host.watchToolsListChanged(serverName, client)
+
+	host.mcpClientsMux.Lock()
+	stopped := false
+	select {
+	case <-host.stopReconnect:
+		stopped = true
+	case <-stop:
+		stopped = true
+	default:
+	}
+	if stopped {
+		// The host was closed or the server was stopped while connecting
+		host.mcpClientsMux.Unlock()
+		client.Close()
+		return nil
+	}
+	host.mcpClients[serverName] = client
+	if host.notificationCallback != nil {
+		host.subscribeToNotifications(serverName, client, host.notificationCallback)
+	}
+	host.mcpClientsMux.Unlock()
+
+	host.replaceServerTools(serverName, tools)
+
+	return nil
+}
+
+// replaceServerTools replaces the tools of the server with the freshly loaded list
+func (host *ToolsHost) replaceServerTools(serverName string, serverTools []llm.Tool) {
+	host.toolsMux.Lock()
+	defer host.toolsMux.Unlock()
+
+	prefix := serverName + "__"
+	tools := make([]llm.Tool, 0, len(host.tools)+len(serverTools))
+	for _, tool := range host.tools {
+		if !strings.HasPrefix(tool.Name, prefix) {
+			tools = append(tools, tool)
+		}
+	}
+	host.tools = append(tools, serverTools...)
+}
+
+func (host *ToolsHost) setReconnecting(serverName string, reconnecting bool) {
+	host.mcpClientsMux.Lock()
+	defer host.mcpClientsMux.Unlock()
+	host.reconnecting[serverName] = reconnecting
+}
+
+func (host *ToolsHost) isReconnecting(serverName string) bool {
+	host.mcpClientsMux.RLock()
+	defer host.mcpClientsMux.RUnlock()
+	return host.reconnecting[serverName]
+}
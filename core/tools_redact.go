@@ -0,0 +1,100 @@
+package core
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode"
+)
+
+// RedactedValue replaces the values of secret tool arguments
+const RedactedValue = "[REDACTED]"
+
+// secretArgumentNames are words of argument names whose values are not shown. Names are split
+// into words by separators and camel case, so "apiKey" and "access_token" match, but "max_tokens" does not
+var secretArgumentNames = map[string]bool{
+	"password":      true,
+	"passwd":        true,
+	"secret":        true,
+	"token":         true,
+	"apikey":        true,
+	"authorization": true,
+	"credential":    true,
+	"credentials":   true,
+}
+
+// RedactSecretArguments replaces the values of arguments that look like secrets
+// in nested objects and lists. The value is modified in place and returned
+func RedactSecretArguments(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if isSecretArgumentName(key) {
+				v[key] = RedactedValue
+				continue
+			}
+			v[key] = RedactSecretArguments(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = RedactSecretArguments(item)
+		}
+	}
+	return value
+}
+
+// RedactSecretsJSON returns the JSON text, for example tool arguments or a tool result,
+// with the values of secret looking keys redacted. Text that is not JSON is returned as is
+func RedactSecretsJSON(text string) string {
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return text
+	}
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+	default:
+		return text
+	}
+	redacted, err := json.Marshal(RedactSecretArguments(value))
+	if err != nil {
+		return text
+	}
+	return string(redacted)
+}
+
+func isSecretArgumentName(name string) bool {
+	words := nameWords(name)
+	for i, word := range words {
+		// Two words can form a secret name, like "api" and "key"
+		if secretArgumentNames[word] || (i > 0 && secretArgumentNames[words[i-1]+word]) {
+			return true
+		}
+	}
+	return false
+}
+
+// nameWords splits a name into lower case words by non alphanumeric characters and camel case
+func nameWords(name string) []string {
+	words := []string{}
+	var word strings.Builder
+	var previous rune
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+			previous = r
+			continue
+		}
+		if unicode.IsUpper(r) && (unicode.IsLower(previous) || unicode.IsDigit(previous)) && word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
+		word.WriteRune(unicode.ToLower(r))
+		previous = r
+	}
+	if word.Len() > 0 {
+		words = append(words, word.String())
+	}
+	return words
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"sort"
+)
+
+const (
+	ServerStatusConnected    = "connected"
+	ServerStatusReconnecting = "reconnecting"
+	ServerStatusDisabled     = "disabled"
+	ServerStatusError        = "error"
+)
+
+// ToolReport describes a tool known to the agent
+type ToolReport struct {
+	Name        string `json:"name"` // The name presented to the LLM, server__tool
+	Description string `json:"description"`
+	Server      string `json:"server"`
+	Transport   string `json:"transport"`
+	// Allowed is false when the tool is not presented to the LLM. For example, the tools
+	// of the memory and RAG interfaces, or all tools when the model does not support them.
+	Allowed bool `json:"allowed"`
+}
+
+// ServerReport describes the connection status of a tools server
+type ServerReport struct {
+	Name      string `json:"name"`
+	Transport string `json:"transport"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	Tools     int    `json:"tools"`
+}
+
+// ToolsReport is the merged list of tools of all servers with the servers status
+type ToolsReport struct {
+	ToolsSupported bool           `json:"tools_supported"`
+	Servers        []ServerReport `json:"servers"`
+	Tools          []ToolReport   `json:"tools"`
+}
+
+func (host *ToolsHost) getToolsReport(toolsSupported bool) ToolsReport {
+	report := ToolsReport{
+		ToolsSupported: toolsSupported,
+		Servers:        []ServerReport{},
+		Tools:          []ToolReport{},
+	}
+
+	allowed := map[string]bool{}
+	if toolsSupported {
+		for _, tool := range host.GetAllToolsForLLM() {
+			allowed[tool.Name] = true
+		}
+	}
+
+	for _, server := range host.getToolsInfo() {
+		if server.IsA2A() {
+			// Skills of A2A agents are not listed in the servers info
+			if a2aClient, ok := host.getA2AClient(server.Name); ok {
+				for _, skill := range a2aClient.Card.Skills {
+					server.Tools = append(server.Tools, ServerToolInfo{
+						Name:        skill.ID,
+						Description: skill.Name + "\n" + skill.Description,
+					})
+				}
+			}
+		}
+		serverReport := ServerReport{
+			Name:      server.Name,
+			Transport: server.Transport,
+			Status:    ServerStatusConnected,
+			Tools:     len(server.Tools),
+		}
+		if config, _ := host.serverConfig(server.Name); config.Disabled {
+			serverReport.Status = ServerStatusDisabled
+		} else if host.isReconnecting(server.Name) {
+			serverReport.Status = ServerStatusReconnecting
+		} else if server.Err != nil {
+			serverReport.Status = ServerStatusError
+			serverReport.Error = server.Err.Error()
+		}
+		report.Servers = append(report.Servers, serverReport)
+
+		for _, tool := range server.Tools {
+			name := server.Name + "__" + tool.Name
+			report.Tools = append(report.Tools, ToolReport{
+				Name:        name,
+				Description: tool.Description,
+				Server:      server.Name,
+				Transport:   server.Transport,
+				Allowed:     allowed[name],
+			})
+		}
+	}
+
+	sort.Slice(report.Servers, func(i, j int) bool {
+		return report.Servers[i].Name < report.Servers[j].Name
+	})
+	sort.Slice(report.Tools, func(i, j int) bool {
+		return report.Tools[i].Name < report.Tools[j].Name
+	})
+	return report
+}
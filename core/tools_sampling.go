@@ -0,0 +1,98 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gelembjuk/cleverchatty/core/history"
+	"github.com/gelembjuk/cleverchatty/core/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// samplingHandler serves sampling/createMessage requests of an MCP server
+// using the LLM provider of the assistant
+type samplingHandler struct {
+	host       *ToolsHost
+	serverName string
+}
+
+// isSamplingAllowed returns true if the server may request LLM completions from the assistant
+func (host *ToolsHost) isSamplingAllowed(serverName string) bool {
+	if !host.samplingConfig.Enabled {
+		return false
+	}
+	for _, name := range host.samplingConfig.AllowedServers {
+		if name == serverName {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *samplingHandler) CreateMessage(ctx context.Context, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+	host := h.host
+
+	host.logger.Printf("Sampling request from server %s: %d messages, max tokens %d",
+		h.serverName, len(request.Messages), request.MaxTokens)
+
+	if host.samplingProvider == nil {
+		return nil, fmt.Errorf("sampling is not available, no LLM provider configured")
+	}
+
+	if request.ModelPreferences != nil && len(request.ModelPreferences.Hints) > 0 {
+		// Only the configured model can be used. Hints are reported to make it visible
+		// when a server expects a different model
+		matched := false
+		for _, hint := range request.ModelPreferences.Hints {
+			if hint.Name != "" && strings.Contains(host.samplingModel, hint.Name) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			host.logger.Printf("Sampling request from %s prefers other models, using the configured model %s",
+				h.serverName, host.samplingModel)
+		}
+	}
+
+	messages := []history.HistoryMessage{}
+	if request.SystemPrompt != "" {
+		messages = append(messages, history.NewSystemInstructionMessage(request.SystemPrompt))
+	}
+	for _, samplingMessage := range request.Messages {
+		textContent, ok := samplingMessage.Content.(mcp.TextContent)
+		if !ok {
+			return nil, fmt.Errorf("only text content is supported in sampling requests")
+		}
+		messages = append(messages, history.HistoryMessage{
+			Role: string(samplingMessage.Role),
+			Content: []history.ContentBlock{
+				{
+					Type: "text",
+					Text: textContent.Text,
+				},
+			},
+		})
+	}
+
+	llmMessages := make([]llm.Message, len(messages))
+	for i := range messages {
+		llmMessages[i] = &messages[i]
+	}
+
+	response, err := host.samplingProvider.CreateMessage(ctx, "", llmMessages, []llm.Tool{})
+	if err != nil {
+		host.logger.Printf("Sampling request from server %s failed: %v", h.serverName, err)
+		return nil, fmt.Errorf("sampling failed: %w", err)
+	}
+
+	return &mcp.CreateMessageResult{
+		SamplingMessage: mcp.SamplingMessage{
+			Role:    mcp.RoleAssistant,
+			Content: mcp.NewTextContent(response.GetContent()),
+		},
+		Model:      host.samplingModel,
+		StopReason: "endTurn",
+	}, nil
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gelembjuk/cleverchatty/core/history"
+)
+
+const (
+	// binarySampleSize is the number of leading bytes inspected to detect binary content
+	binarySampleSize = 1024
+	// binaryThreshold is the share of non-text bytes in the sample above which content is binary
+	binaryThreshold = 0.3
+)
+
+// isBinaryText reports whether the text is clearly binary data rather than readable text.
+// A NUL byte or a high share of invalid UTF-8 and control characters indicates binary data.
+func isBinaryText(text string) bool {
+	sample := text
+	if len(sample) > binarySampleSize {
+		sample = sample[:binarySampleSize]
+	}
+	if len(sample) == 0 {
+		return false
+	}
+	nonText := 0
+	for i := 0; i < len(sample); {
+		r, size := utf8.DecodeRuneInString(sample[i:])
+		switch {
+		case r == 0:
+			return true
+		case r == utf8.RuneError && size == 1:
+			// A rune cut by the sample boundary is not a sign of binary data
+			if !utf8.FullRuneInString(sample[i:]) && len(text) > len(sample) {
+				i = len(sample)
+				continue
+			}
+			nonText++
+		case r < 0x20 && r != '\n' && r != '\r' && r != '\t' && r != 0x1b:
+			nonText++
+		}
+		i += size
+	}
+	return float64(nonText)/float64(len(sample)) > binaryThreshold
+}
+
+// ansiEscapePattern matches CSI sequences (colors, cursor moves), OSC sequences (window titles, links)
+// and the other two-character escape sequences
+var ansiEscapePattern = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+// stripControlCharacters removes ANSI escape sequences and control characters from the output
+// of terminal commands. Line endings are normalized, a carriage return inside a line (progress
+// output) keeps only the text written after it, as a terminal shows it.
+func stripControlCharacters(text string) string {
+	text = ansiEscapePattern.ReplaceAllString(text, "")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if idx := strings.LastIndex(strings.TrimRight(line, "\r"), "\r"); idx >= 0 {
+			line = line[idx+1:]
+		}
+		lines[i] = strings.Map(func(r rune) rune {
+			if r == '\t' || (r >= 0x20 && r != 0x7f) {
+				return r
+			}
+			return -1
+		}, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sanitizeToolResult makes the text content of a tool result safe to send to LLM providers.
+// Invalid UTF-8 sequences are replaced and clearly binary content is moved to the file cache.
+// Terminal escape sequences are removed when enabled with strip_tool_output_escapes.
+func (host *ToolsHost) sanitizeToolResult(result ToolCallResult) ToolCallResult {
+	for i, content := range result.Content {
+		textContent, ok := content.(history.TextContent)
+		if !ok {
+			continue
+		}
+		if host.fileCache != nil && isBinaryText(textContent.Text) {
+			host.logger.Printf("Tool returned binary content (%d bytes), storing it as a file", len(textContent.Text))
+			result.Content[i] = host.fileCache.HandleBinaryText(textContent.Text)
+			continue
+		}
+		if !utf8.ValidString(textContent.Text) {
+			textContent.Text = strings.ToValidUTF8(textContent.Text, "\uFFFD")
+			result.Content[i] = textContent
+		}
+		if host.stripEscapes {
+			textContent.Text = stripControlCharacters(textContent.Text)
+			result.Content[i] = textContent
+		}
+	}
+	return result
+}
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/gelembjuk/cleverchatty/core/history"
+)
+
+func TestSanitizeToolResultInvalidUTF8(t *testing.T) {
+	host := &ToolsHost{
+		logger:    log.New(io.Discard, "", 0),
+		fileCache: NewFileCache(t.TempDir(), log.New(io.Discard, "", 0)),
+	}
+
+	result := host.sanitizeToolResult(ToolCallResult{
+		Content: []history.Content{
+			history.TextContent{Type: "text", Text: "size: 10\xff\xfe bytes"},
+		},
+	})
+
+	text := result.getTextContent()
+	if !utf8.ValidString(text) {
+		t.Fatalf("Expected valid UTF-8, got %q", text)
+	}
+	if text != "size: 10\uFFFD bytes" {
+		t.Errorf("Unexpected sanitized text %q", text)
+	}
+	// Providers send tool results as JSON, the text must survive encoding unchanged
+	data, err := json.Marshal(text)
+	if err != nil {
+		t.Fatalf("Failed to encode sanitized text: %v", err)
+	}
+	var decoded string
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded != text {
+		t.Errorf("Sanitized text changed after JSON round trip: %q", decoded)
+	}
+}
+
+func TestSanitizeToolResultBinary(t *testing.T) {
+	host := &ToolsHost{
+		logger:    log.New(io.Discard, "", 0),
+		fileCache: NewFileCache(t.TempDir(), log.New(io.Discard, "", 0)),
+	}
+
+	result := host.sanitizeToolResult(ToolCallResult{
+		Content: []history.Content{
+			history.TextContent{Type: "text", Text: "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"},
+		},
+	})
+
+	text := result.getTextContent()
+	if !isBase64(text) || strings.Contains(text, "PNG") {
+		t.Fatalf("Expected a file reference for binary content, got %q", text)
+	}
+	if resolved, ok := host.fileCache.resolveFileRef(text); !ok || !strings.HasPrefix(resolved, "\x89PNG") {
+		t.Errorf("Expected the file reference to resolve to the original bytes")
+	}
+}
+
+func TestFormatJSONToolResult(t *testing.T) {
+	result := ToolCallResult{
+		Content: []history.Content{
+			history.TextContent{Type: "text", Text: `{"city":"Paris","temp":[21,23]}`},
+			history.TextContent{Type: "text", Text: "{not json"},
+			history.TextContent{Type: "text", Text: "plain text"},
+		},
+	}
+	result.formatJSONContent()
+
+	expected := "```json\n{\n  \"city\": \"Paris\",\n  \"temp\": [\n    21,\n    23\n  ]\n}\n```"
+	if text := result.Content[0].(history.TextContent).Text; text != expected {
+		t.Errorf("Expected pretty-printed JSON, got %q", text)
+	}
+	if text := result.Content[1].(history.TextContent).Text; text != "{not json" {
+		t.Errorf("Expected invalid JSON to be left untouched, got %q", text)
+	}
+	if text := result.Content[2].(history.TextContent).Text; text != "plain text" {
+		t.Errorf("Expected plain text to be left untouched, got %q", text)
+	}
+}
+
+func TestSanitizeToolResultStripsEscapes(t *testing.T) {
+	host := &ToolsHost{
+		logger:       log.New(io.Discard, "", 0),
+		stripEscapes: true,
+	}
+
+	// Colored "ls" output with a progress line rewritten with carriage returns
+	output := "\x1b[0m\x1b[01;34mdocs\x1b[0m\r\n\x1b[01;32mrun.sh\x1b[0m\r\nREADME.md\r\n" +
+		"\x1b]0;user@host: ~\x07downloading 10%\rdownloading 100%\r\n"
+
+	result := host.sanitizeToolResult(ToolCallResult{
+		Content: []history.Content{
+			history.TextContent{Type: "text", Text: output},
+		},
+	})
+
+	expected := "docs\nrun.sh\nREADME.md\ndownloading 100%"
+	if text := result.getTextContent(); text != expected {
+		t.Errorf("Expected escape sequences to be removed, got %q", text)
+	}
+}
+
+func TestRedactSecretsJSON(t *testing.T) {
+	redacted := RedactSecretsJSON(`{"city": "Paris", "max_tokens": 100, "tokensUsed": 20,
+		"auth": {"API_Key": "abc", "accessToken": ["x"]}, "items": [{"password": "p", "apiKey": "k"}]}`)
+	for _, secret := range []string{"abc", `"x"`, `"p"`, `"k"`} {
+		if strings.Contains(redacted, secret) {
+			t.Errorf("Expected %s to be redacted, got %s", secret, redacted)
+		}
+	}
+	if !strings.Contains(redacted, `"city":"Paris"`) || strings.Count(redacted, RedactedValue) != 4 {
+		t.Errorf("Expected only the secrets redacted, got %s", redacted)
+	}
+	if !strings.Contains(redacted, `"max_tokens":100`) || !strings.Contains(redacted, `"tokensUsed":20`) {
+		t.Errorf("Expected the token counts not to be redacted, got %s", redacted)
+	}
+	for _, text := range []string{"plain text with a token", `"a string"`, "42"} {
+		if result := RedactSecretsJSON(text); result != text {
+			t.Errorf("Expected %q unchanged, got %q", text, result)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package core
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ToolStats describes the calls of one tool
+type ToolStats struct {
+	Tool             string        `json:"tool"` // server__tool
+	Calls            int64         `json:"calls"`
+	Successes        int64         `json:"successes"`
+	Failures         int64         `json:"failures"`
+	TotalLatency     time.Duration `json:"-"`
+	AverageLatencyMs int64         `json:"average_latency_ms"`
+}
+
+// AverageLatency returns the average time of a call of the tool
+func (s ToolStats) AverageLatency() time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return s.TotalLatency / time.Duration(s.Calls)
+}
+
+// toolStatsCollector counts the calls of tools
+type toolStatsCollector struct {
+	mutex sync.Mutex
+	stats map[string]*ToolStats
+}
+
+func newToolStatsCollector() *toolStatsCollector {
+	return &toolStatsCollector{
+		stats: map[string]*ToolStats{},
+	}
+}
+
+func (c *toolStatsCollector) record(tool string, elapsed time.Duration, failed bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	stats, ok := c.stats[tool]
+	if !ok {
+		stats = &ToolStats{Tool: tool}
+		c.stats[tool] = stats
+	}
+	stats.Calls++
+	if failed {
+		stats.Failures++
+	} else {
+		stats.Successes++
+	}
+	stats.TotalLatency += elapsed
+}
+
+func (c *toolStatsCollector) snapshot() []ToolStats {
+	c.mutex.Lock()
+	list := make([]ToolStats, 0, len(c.stats))
+	for _, stats := range c.stats {
+		list = append(list, *stats)
+	}
+	c.mutex.Unlock()
+
+	return sortToolStats(list)
+}
+
+func (c *toolStatsCollector) reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stats = map[string]*ToolStats{}
+}
+
+// mergeToolStats sums the stats of the same tools, for example collected by different sessions
+func mergeToolStats(lists ...[]ToolStats) []ToolStats {
+	merged := map[string]*ToolStats{}
+	for _, list := range lists {
+		for _, stats := range list {
+			total, ok := merged[stats.Tool]
+			if !ok {
+				total = &ToolStats{Tool: stats.Tool}
+				merged[stats.Tool] = total
+			}
+			total.Calls += stats.Calls
+			total.Successes += stats.Successes
+			total.Failures += stats.Failures
+			total.TotalLatency += stats.TotalLatency
+		}
+	}
+	list := make([]ToolStats, 0, len(merged))
+	for _, stats := range merged {
+		list = append(list, *stats)
+	}
+	return sortToolStats(list)
+}
+
+// sortToolStats orders the most used tools first and fills the average latency
+func sortToolStats(list []ToolStats) []ToolStats {
+	for i := range list {
+		list[i].AverageLatencyMs = list[i].AverageLatency().Milliseconds()
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Calls != list[j].Calls {
+			return list[i].Calls > list[j].Calls
+		}
+		return list[i].Tool < list[j].Tool
+	})
+	return list
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// TLSClientConfig configures TLS of the connection to an MCP server over HTTPS,
+// for servers requiring client certificates (mutual TLS) or using a private CA
+type TLSClientConfig struct {
+	ClientCertFile     string `json:"client_cert_file,omitempty"`
+	ClientKeyFile      string `json:"client_key_file,omitempty"`
+	CAFile             string `json:"ca_file,omitempty"`              // Certificates to verify the server with, instead of the system ones
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"` // Only for testing with self-signed certificates
+}
+
+func (c TLSClientConfig) isSet() bool {
+	return c.ClientCertFile != "" || c.ClientKeyFile != "" || c.CAFile != "" || c.InsecureSkipVerify
+}
+
+// buildTLSConfig loads the certificates configured for the connection
+func (c TLSClientConfig) buildTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+
+	if c.ClientCertFile != "" || c.ClientKeyFile != "" {
+		if c.ClientCertFile == "" || c.ClientKeyFile == "" {
+			return nil, fmt.Errorf("both client_cert_file and client_key_file must be set")
+		}
+		cert, err := tls.LoadX509KeyPair(c.ClientCertFile, c.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if c.CAFile != "" {
+		data, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in the CA file %s", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// newTLSHTTPClient returns the HTTP client for the MCP server with the TLS settings,
+// or nil if the server uses the default ones
+func (host *ToolsHost) newTLSHTTPClient(serverName string, config TLSClientConfig) (*http.Client, error) {
+	if !config.isSet() {
+		return nil, nil
+	}
+	tlsConfig, err := config.buildTLSConfig()
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS config of %s: %w", serverName, err)
+	}
+	if config.InsecureSkipVerify {
+		host.logger.Printf("WARNING: TLS certificate verification is DISABLED for server %s. "+
+			"The connection is not protected against interception, do not use it in production\n", serverName)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}, nil
+}
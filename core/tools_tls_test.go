@@ -0,0 +1,58 @@
+package core
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTLSClientConfig(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, caData, 0600); err != nil {
+		t.Fatalf("Failed to write the CA file: %v", err)
+	}
+
+	var wrapper ServerConfigWrapper
+	config := `{"url": "` + server.URL + `", "transport": "sse", "ca_file": "` + caFile + `"}`
+	if err := json.Unmarshal([]byte(config), &wrapper); err != nil {
+		t.Fatalf("Failed to parse the server config: %v", err)
+	}
+	sseConfig, ok := wrapper.Config.(SSEMCPServerConfig)
+	if !ok || sseConfig.CAFile != caFile {
+		t.Fatalf("Expected the SSE config with the CA file, got %+v", wrapper.Config)
+	}
+
+	host := &ToolsHost{logger: log.New(io.Discard, "", 0)}
+	client, err := host.newTLSHTTPClient("secure", sseConfig.TLSClientConfig)
+	if err != nil || client == nil {
+		t.Fatalf("Expected the HTTP client with TLS settings, got %v", err)
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected the server to be trusted with the CA file, got %v", err)
+	}
+	resp.Body.Close()
+
+	if _, err := http.Get(server.URL); err == nil {
+		t.Errorf("Expected the self-signed certificate to be rejected by the default client")
+	}
+
+	if client, err := host.newTLSHTTPClient("plain", TLSClientConfig{}); client != nil || err != nil {
+		t.Errorf("Expected no custom client without TLS settings, got %v, %v", client, err)
+	}
+	if _, err := host.newTLSHTTPClient("broken", TLSClientConfig{ClientCertFile: "cert.pem"}); err == nil {
+		t.Errorf("Expected an error for the client certificate without the key")
+	}
+}
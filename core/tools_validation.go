@@ -0,0 +1,130 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/gelembjuk/cleverchatty/core/llm"
+)
+
+// findToolSchema returns the input schema of the tool as it was presented to the LLM
+func (host *ToolsHost) findToolSchema(serverName string, toolName string) (llm.Schema, bool) {
+	fullName := serverName + "__" + toolName
+	for _, tool := range host.GetAllToolsForLLM() {
+		if tool.Name == fullName {
+			return tool.InputSchema, true
+		}
+	}
+	return llm.Schema{}, false
+}
+
+// validateToolArgs checks the arguments against the tool schema. Only required fields
+// and the types of top level properties are checked, it is not a full JSON schema validator.
+// All problems are reported at once so the model can fix them in a single retry.
+func validateToolArgs(schema llm.Schema, toolArgs map[string]interface{}) error {
+	problems := []string{}
+
+	for _, name := range schema.Required {
+		if value, ok := toolArgs[name]; !ok || value == nil {
+			problems = append(problems, fmt.Sprintf("missing required argument %q", name))
+		}
+	}
+
+	names := make([]string, 0, len(toolArgs))
+	for name := range toolArgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		property, ok := schema.Properties[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		types := schemaTypes(property["type"])
+		if len(types) == 0 || toolArgs[name] == nil {
+			continue
+		}
+		if !valueMatchesTypes(toolArgs[name], types) {
+			problems = append(problems, fmt.Sprintf("argument %q must be of type %s", name, strings.Join(types, " or ")))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidToolArguments, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// schemaTypes returns the list of types allowed by the "type" keyword
+func schemaTypes(value interface{}) []string {
+	switch t := value.(type) {
+	case string:
+		return []string{t}
+	case []string:
+		return t
+	case []interface{}:
+		types := []string{}
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func valueMatchesTypes(value interface{}, types []string) bool {
+	for _, t := range types {
+		if valueMatchesType(value, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func valueMatchesType(value interface{}, schemaType string) bool {
+	switch schemaType {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := toFloat(value)
+		return ok
+	case "integer":
+		number, ok := toFloat(value)
+		return ok && number == math.Trunc(number)
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "null":
+		return value == nil
+	}
+	// Unknown types are not validated
+	return true
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gelembjuk/cleverchatty/core/llm"
+)
+
+func TestValidateToolArgs(t *testing.T) {
+	schema := llm.Schema{
+		Type: "object",
+		Properties: map[string]interface{}{
+			"query": map[string]interface{}{"type": "string"},
+			"limit": map[string]interface{}{"type": "integer"},
+			"tags":  map[string]interface{}{"type": "array"},
+		},
+		Required: []string{"query"},
+	}
+
+	err := validateToolArgs(schema, map[string]interface{}{
+		"query": "weather",
+		"limit": float64(5),
+		"tags":  []interface{}{"a"},
+	})
+	if err != nil {
+		t.Fatalf("Expected valid arguments, got error: %v", err)
+	}
+
+	err = validateToolArgs(schema, map[string]interface{}{
+		"limit": 2.5,
+	})
+	if err == nil {
+		t.Fatalf("Expected validation error")
+	}
+	if !strings.Contains(err.Error(), `missing required argument "query"`) {
+		t.Errorf("Expected missing argument error, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), `argument "limit" must be of type integer`) {
+		t.Errorf("Expected type error, got: %v", err)
+	}
+}